@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
 	"os/signal"
@@ -14,43 +15,150 @@ import (
 )
 
 func main() {
-	// Parse command line flags
-	port := flag.Int("port", config.DefaultPort, "Port to listen on")
-	flag.Parse()
-
 	// Initialize logger
 	logger := utils.NewLogger("server")
+
+	// Parse command line flags. Flags are bound to cfg before the file and
+	// environment are loaded into it, then parsed again afterwards so that
+	// flags take precedence over the environment, which takes precedence
+	// over the config file.
+	cfg := config.Default()
+	configPath := flag.String("config", os.Getenv("MCP_CONFIG"), "Path of a JSON configuration file")
+	flag.IntVar(&cfg.Port, "port", cfg.Port, "TCP port to listen on; superseded by -listen")
+	flag.StringVar(&cfg.Listen, "listen", cfg.Listen, "Address to listen on, tcp://host:port or unix:///path/to/socket")
+	flag.IntVar(&cfg.MaxConnections, "max-connections", cfg.MaxConnections, "Maximum simultaneous client connections; 0 is unlimited")
+	flag.StringVar(&cfg.ConnectionLimitPolicy, "connection-limit-policy", cfg.ConnectionLimitPolicy, "Handling of connections beyond the limit: reject or backlog")
+	flag.StringVar(&cfg.UnknownTypePolicy, "unknown-type-policy", cfg.UnknownTypePolicy, "Reply to messages of unknown type: ignore, error or ack")
+	flag.IntVar(&cfg.RateLimit, "rate-limit", cfg.RateLimit, "Messages per second each client may send (0 for unlimited)")
+	flag.IntVar(&cfg.RateBurst, "rate-burst", cfg.RateBurst, "Messages each client may send at once before the rate limit applies (0 for the rate)")
+	flag.IntVar(&cfg.MutationLimit, "mutation-limit", cfg.MutationLimit, "Key writes and deletes per second each client may make, however they are batched (0 for unlimited)")
+	flag.IntVar(&cfg.MutationBurst, "mutation-burst", cfg.MutationBurst, "Key writes each client may make at once before the mutation limit applies, and the most one message may make (0 for the limit)")
+	flag.BoolVar(&cfg.RequireHello, "require-hello", cfg.RequireHello, "Require clients to send HELLO before other messages")
+	flag.BoolVar(&cfg.NormalizeTypes, "normalize-types", cfg.NormalizeTypes, "Accept message types in any case")
+	flag.BoolVar(&cfg.ClearOnClose, "clear-on-close", cfg.ClearOnClose, "Remove a client's context when its connection closes, unless it sent _persist=true")
+	flag.StringVar(&cfg.ContextTemplate, "context-template", cfg.ContextTemplate, "JSON or .env-style file of default values seeded into new clients' context; reloaded on SIGHUP")
+	flag.StringVar(&cfg.AuthToken, "auth-token", cfg.AuthToken, "Token clients must present in AUTH before any other message")
+	flag.StringVar(&cfg.AuthTokenFile, "auth-token-file", cfg.AuthTokenFile, "JSON or .env-style file mapping client names to the tokens they present in AUTH; reloaded on SIGHUP")
+	flag.BoolVar(&cfg.NoDelay, "no-delay", cfg.NoDelay, "Disable Nagle's algorithm on client connections unless a client asks otherwise with low_latency in HELLO")
+	flag.DurationVar(&cfg.FlushInterval, "flush-interval", cfg.FlushInterval, "Longest time outgoing messages are buffered before being written; 0 writes each at once")
+	flag.DurationVar(&cfg.HeartbeatInterval, "heartbeat-interval", cfg.HeartbeatInterval, "Ping clients silent for this long, overriding the idle timeout; 0 uses the idle timeout")
+	flag.DurationVar(&cfg.HeartbeatTimeout, "heartbeat-timeout", cfg.HeartbeatTimeout, "Time a client has to answer each heartbeat; connections missing two in a row are closed")
+	flag.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", cfg.ShutdownTimeout, "Time in-flight messages get to finish during shutdown")
+	flag.StringVar(&cfg.DataFile, "data", cfg.DataFile, "Path of the context snapshot file; enables persistence")
+	flag.DurationVar(&cfg.AutosaveInterval, "autosave-interval", cfg.AutosaveInterval, "Interval between context snapshots")
+	flag.DurationVar(&cfg.SweepInterval, "sweep-interval", cfg.SweepInterval, "Interval between sweeps for expired context keys")
+	flag.StringVar(&cfg.TLSCert, "tls-cert", cfg.TLSCert, "PEM certificate file; serves TLS together with -tls-key")
+	flag.StringVar(&cfg.TLSKey, "tls-key", cfg.TLSKey, "PEM private key file for -tls-cert")
+	flag.StringVar(&cfg.AdminSocket, "admin-socket", cfg.AdminSocket, "Path of the unix socket for the operator console; disabled if empty")
+	flag.StringVar(&cfg.MetricsAddr, "metrics-addr", cfg.MetricsAddr, "Address serving Prometheus metrics at /metrics, such as :9100; disabled if empty")
+	flag.DurationVar(&cfg.SelfStatsInterval, "self-stats-interval", cfg.SelfStatsInterval, "Interval between publications of server statistics under the __server__ client ID; 0 disables them")
+	flag.StringVar(&cfg.LogLevel, "log-level", cfg.LogLevel, "Minimum log level: debug, info, warning or error")
+	flag.StringVar(&cfg.LogFile, "log-file", cfg.LogFile, "File to append log output to in addition to stdout")
+	flag.IntVar(&cfg.LogMaxSize, "log-max-size", cfg.LogMaxSize, "Size in bytes at which the log file is rotated; 0 never rotates")
+	flag.IntVar(&cfg.LogMaxBackups, "log-max-backups", cfg.LogMaxBackups, "Number of rotated log files to keep")
+	flag.StringVar(&cfg.LogFormat, "log-format", cfg.LogFormat, "Log output format: text or json")
+	flag.Parse()
+
+	loaded, err := config.Load(*configPath)
+	if err != nil {
+		logger.Fatal("Invalid configuration: %v", err)
+	}
+	cfg = loaded
+	flag.Parse()
+
+	if err := cfg.Validate(); err != nil {
+		logger.Fatal("Invalid configuration: %v", err)
+	}
+
+	// Both were checked by Validate
+	level, _ := utils.ParseLevel(cfg.LogLevel)
+	logger.SetLevel(level)
+	format, _ := utils.ParseFormat(cfg.LogFormat)
+	logger.SetFormat(format)
+
+	if cfg.LogFile != "" {
+		logFile, err := utils.NewRotatingFile(cfg.LogFile, int64(cfg.LogMaxSize), cfg.LogMaxBackups)
+		if err != nil {
+			logger.Fatal("Failed to open log file: %v", err)
+		}
+		defer logFile.Close()
+		defer logger.Sync()
+		logger.AddOutput(logFile)
+	}
+
 	logger.Info("Starting MCP server...")
 
 	// Create context store
-	contextStore := state.NewContextStore()
+	contextStore := state.NewContextStore(state.WithCompression(cfg.CompressThreshold))
+	contextStore.StartSweeper(cfg.SweepInterval)
 
 	// Create and start the server
-	server := handler.NewServer(*port, contextStore, logger)
-
-	// Start server in a goroutine
-	go func() {
-		if err := server.Start(); err != nil {
-			logger.Error("Failed to start server: %v", err)
-			os.Exit(1)
+	server := handler.NewServer(cfg, contextStore, logger)
+	if cfg.DataFile != "" {
+		server.SetLoading(true)
+	}
+	if cfg.ContextTemplate != "" {
+		template, err := config.LoadValues(cfg.ContextTemplate)
+		if err != nil {
+			logger.Fatal("Invalid context template: %v", err)
 		}
-	}()
+		server.SetContextTemplate(template)
+	}
+	tokens, err := cfg.AuthTokens()
+	if err != nil {
+		logger.Fatal("Invalid auth tokens: %v", err)
+	}
+	server.SetAuthTokens(tokens)
+	if len(tokens) > 0 {
+		logger.Info("Requiring authentication with %d tokens", len(tokens))
+	}
 
-	logger.Info("MCP server listening on port %d", *port)
+	// Start returns once bound, accepting connections in the background
+	if err := server.Start(); err != nil {
+		logger.Fatal("Failed to start server: %v", err)
+	}
+	addr := server.Addr()
+	logger.Info("MCP server listening on %s %s", addr.Network(), addr)
+
+	// Restore persisted context while clients connect; writes are rejected
+	// until it is done. A missing or corrupt snapshot is not fatal.
+	if cfg.DataFile != "" {
+		if err := contextStore.Load(cfg.DataFile); err != nil {
+			logger.Warning("Starting with empty context: %v", err)
+		} else {
+			logger.Info("Loaded context store from %s", cfg.DataFile)
+		}
+		server.SetLoading(false)
+	}
 
 	// Set up graceful shutdown
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// SIGHUP reloads the context template and auth token file; one that
+	// fails to load leaves the current values in place
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for range hupChan {
+			server.Reload()
+		}
+	}()
+
 	// Wait for termination signal
 	sig := <-sigChan
 	logger.Info("Received signal %v, shutting down...", sig)
 
-	// Shutdown server
-	if err := server.Shutdown(); err != nil {
-		logger.Error("Error during shutdown: %v", err)
-		os.Exit(1)
+	// Shutdown server, giving in-flight messages until the timeout
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Fatal("Error during shutdown: %v", err)
 	}
 
+	// Stop background expiry
+	contextStore.StopSweeper()
+
 	logger.Info("Server shutdown complete")
 }
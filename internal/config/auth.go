@@ -0,0 +1,32 @@
+package config
+
+import "fmt"
+
+// AuthTokens returns the tokens clients may authenticate with, mapped to
+// the client name each authenticates as: AuthToken as DefaultPrincipal, and
+// the entries of AuthTokenFile. It returns an empty map if authentication
+// is not enabled.
+func (c Config) AuthTokens() (map[string]string, error) {
+	tokens := make(map[string]string)
+	if c.AuthToken != "" {
+		tokens[c.AuthToken] = DefaultPrincipal
+	}
+	if c.AuthTokenFile == "" {
+		return tokens, nil
+	}
+
+	names, err := LoadValues(c.AuthTokenFile)
+	if err != nil {
+		return nil, err
+	}
+	for name, token := range names {
+		if token == "" {
+			return nil, fmt.Errorf("%s: empty token for %s", c.AuthTokenFile, name)
+		}
+		if other, exists := tokens[token]; exists {
+			return nil, fmt.Errorf("%s: %s and %s share a token", c.AuthTokenFile, other, name)
+		}
+		tokens[token] = name
+	}
+	return tokens, nil
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAuthTokens(t *testing.T) {
+	cfg := Default()
+	if tokens, err := cfg.AuthTokens(); err != nil || len(tokens) != 0 {
+		t.Fatalf("AuthTokens() = %v, %v with none configured", tokens, err)
+	}
+
+	cfg.AuthToken = "shared"
+	cfg.AuthTokenFile = writeConfig(t, `{"alice": "a-token", "bob": "b-token"}`)
+	tokens, err := cfg.AuthTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"shared": DefaultPrincipal, "a-token": "alice", "b-token": "bob"}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Fatalf("AuthTokens() = %v, want %v", tokens, want)
+	}
+}
+
+func TestAuthTokensRejectsBadFiles(t *testing.T) {
+	for _, contents := range []string{
+		`{"alice": ""}`,
+		`{"alice": "same", "bob": "same"}`,
+		`{"alice": "shared"}`,
+		`{`,
+	} {
+		cfg := Default()
+		cfg.AuthToken = "shared"
+		cfg.AuthTokenFile = writeConfig(t, contents)
+		if tokens, err := cfg.AuthTokens(); err == nil {
+			t.Errorf("%s: AuthTokens() = %v, want an error", contents, tokens)
+		}
+	}
+}
@@ -5,20 +5,148 @@ const (
 	// DefaultPort is the default port for the MCP server
 	DefaultPort = 8080
 
+	// SocketMode is the default permission bits of a unix socket the
+	// server listens on
+	SocketMode = 0660
+
 	// MaxMessageSize is the maximum allowed size for incoming messages in bytes
 	MaxMessageSize = 4096
 
-	// ReadTimeout is the default read timeout in seconds for client connections
+	// ReadTimeout is the default time in seconds a client has to finish
+	// sending a message once it has started
 	ReadTimeout = 60
 
 	// WriteTimeout is the default write timeout in seconds for client connections
 	WriteTimeout = 10
 
-	// IdleTimeout is the default idle timeout in seconds for client connections
+	// IdleTimeout is the default time in seconds a client may be silent
+	// before it is pinged when HeartbeatInterval is zero
 	IdleTimeout = 300
 
 	// MaxConnections is the maximum number of simultaneous connections
 	MaxConnections = 1000
+
+	// ConnectionLimitPolicy is the default handling of connections beyond
+	// MaxConnections: "reject" accepts and turns them away with an ERROR,
+	// "backlog" stops accepting and leaves them in the kernel backlog
+	ConnectionLimitPolicy = "reject"
+
+	// UnknownTypePolicy is the default handling of messages of unknown type:
+	// "ignore" logs them and sends nothing, "error" replies with an ERROR,
+	// and "ack" replies with an ACK as a permissive bridge would
+	UnknownTypePolicy = "ignore"
+
+	// RateLimit is the default number of messages per second a client may
+	// send, with bursts of up to RateBurst; zero disables rate limiting
+	RateLimit = 0
+
+	// RateBurst is the default number of messages a client may send at once
+	// before RateLimit applies; zero means RateLimit
+	RateBurst = 0
+
+	// MutationLimit is the default number of key writes and deletes per
+	// second a client may make, with bursts of up to MutationBurst; a
+	// CONTEXT counts one per key. Zero disables the limit.
+	MutationLimit = 0
+
+	// MutationBurst is the default number of key writes a client may make
+	// at once before MutationLimit applies, and so the most one message
+	// may make; zero means MutationLimit
+	MutationBurst = 0
+
+	// HandlerTimeout is the default time in seconds a message handler may run
+	// before it is flagged as slow
+	HandlerTimeout = 5
+
+	// SweepInterval is the default interval in seconds between sweeps for
+	// expired context keys
+	SweepInterval = 1
+
+	// CompressThreshold is the size in bytes above which stored context
+	// values are compressed; zero disables compression
+	CompressThreshold = 0
+
+	// HeartbeatInterval is the default time in seconds a client may be
+	// silent before it is pinged; zero leaves that to IdleTimeout
+	HeartbeatInterval = 0
+
+	// HeartbeatTimeout is the default time in seconds a pinged client has to
+	// reply; it is closed after two unanswered pings in a row
+	HeartbeatTimeout = 10
+
+	// ShutdownTimeout is the default time in seconds in-flight messages are
+	// given to finish during shutdown before connections are force-closed
+	ShutdownTimeout = 10
+
+	// AutosaveInterval is the default interval in seconds between context
+	// snapshots when persistence is enabled
+	AutosaveInterval = 60
+
+	// SelfStatsInterval is the default interval in seconds between
+	// publications of the server's statistics into the context store; zero
+	// disables them
+	SelfStatsInterval = 0
+
+	// ClearOnClose makes the server remove a client's context when its
+	// connection closes
+	ClearOnClose = false
+
+	// NoDelay disables Nagle's algorithm on client TCP connections unless
+	// the client asks otherwise in HELLO, as Go does by default
+	NoDelay = true
+
+	// FlushInterval is the default time in milliseconds outgoing messages
+	// may wait in a connection's write buffer before it is flushed; zero
+	// writes each message at once
+	FlushInterval = 0
+
+	// WriteBufferSize is the size in bytes of a connection's write buffer
+	// when FlushInterval is set; a full buffer is flushed at once
+	WriteBufferSize = 4096
+
+	// MaxQueryResults is the most client IDs returned in reply to a QUERY
+	MaxQueryResults = 100
+
+	// IdempotencyCacheSize is the number of idempotency keys remembered per
+	// client
+	IdempotencyCacheSize = 256
+
+	// IdempotencyTTL is the time in seconds an idempotency key is remembered
+	IdempotencyTTL = 300
+
+	// SequenceTTL is the time in seconds the last sequence number of a
+	// client is remembered after its last sequenced message, across
+	// reconnects
+	SequenceTTL = 3600
+
+	// ReconnectTokenTTL is the time in seconds a reconnect token issued by
+	// HELLO can be used to resume its client ID
+	ReconnectTokenTTL = 3600
+
+	// MaxClockSkew is the largest clock difference in seconds reported in
+	// reply to TIME; larger differences are capped and counted as outliers
+	MaxClockSkew = 3600
+
+	// MaxStreamedValueSize is the largest value in bytes a client may write
+	// in chunks with CONTEXT_BEGIN
+	MaxStreamedValueSize = 1 << 20
+
+	// ExpiryBacklogSize is the number of expiry notices kept for a client
+	// with no connection, delivered when it next claims its client ID; the
+	// oldest are dropped beyond it
+	ExpiryBacklogSize = 16
+
+	// SubscriptionBufferSize is the number of pending context events buffered
+	// per connection before further events are dropped
+	SubscriptionBufferSize = 64
+
+	// MaxDurableSubscriptions is the number of durable subscriptions a
+	// client ID may hold
+	MaxDurableSubscriptions = 16
+
+	// DurableSubscriptionTTL is the time in seconds durable subscriptions
+	// are kept after their client ID's last connection closes
+	DurableSubscriptionTTL = 3600
 )
 
 // Protocol configuration constants
@@ -26,6 +154,18 @@ const (
 	// ProtocolVersion is the current version of the MCP protocol
 	ProtocolVersion = "1.0"
 
+	// RequireHello makes clients negotiate a protocol version with HELLO
+	// before sending any other message
+	RequireHello = true
+
+	// MaxAuthFailures is the number of messages a connection may send
+	// without authenticating, when authentication is required, before it
+	// is closed
+	MaxAuthFailures = 3
+
+	// DefaultPrincipal is the client name AuthToken authenticates as
+	DefaultPrincipal = "default"
+
 	// MessageDelimiter is the character used to separate messages
 	MessageDelimiter = '\n'
 
@@ -39,6 +179,10 @@ const (
 	ParamKeyValueSeparator = '='
 )
 
+// SupportedProtocolVersions lists the protocol versions a client may
+// negotiate with HELLO
+var SupportedProtocolVersions = []string{ProtocolVersion}
+
 // Logging constants
 const (
 	// LogTimeFormat is the time format used in log messages
@@ -46,7 +190,16 @@ const (
 
 	// LogLevel defines the default log level
 	LogLevel = "info"
+
+	// LogFormat defines the default log output format, text or json
+	LogFormat = "text"
+
+	// LogMaxSize is the default size in bytes at which the log file is
+	// rotated; zero never rotates
+	LogMaxSize = 0
+
+	// LogMaxBackups is the default number of rotated log files kept
+	LogMaxBackups = 5
 )
 
 // TODO: Add other application-wide constants as needed
-// TODO: Consider making these configurable via environment variables or config file
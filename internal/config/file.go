@@ -0,0 +1,181 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// fileConfig is the JSON configuration file format. Fields left out of the
+// file keep their current value.
+type fileConfig struct {
+	Port                  *int          `json:"port"`
+	Listen                *string       `json:"listen"`
+	SocketMode            *fileMode     `json:"socket_mode"`
+	MaxMessageSize        *int          `json:"max_message_size"`
+	ReadTimeout           *fileDuration `json:"read_timeout"`
+	WriteTimeout          *fileDuration `json:"write_timeout"`
+	IdleTimeout           *fileDuration `json:"idle_timeout"`
+	MaxConnections        *int          `json:"max_connections"`
+	ConnectionLimitPolicy *string       `json:"connection_limit_policy"`
+	UnknownTypePolicy     *string       `json:"unknown_type_policy"`
+	RateLimit             *int          `json:"rate_limit"`
+	RateBurst             *int          `json:"rate_burst"`
+	MutationLimit         *int          `json:"mutation_limit"`
+	MutationBurst         *int          `json:"mutation_burst"`
+	HandlerTimeout        *fileDuration `json:"handler_timeout"`
+	SweepInterval         *fileDuration `json:"sweep_interval"`
+	CompressThreshold     *int          `json:"compress_threshold"`
+	HeartbeatInterval     *fileDuration `json:"heartbeat_interval"`
+	HeartbeatTimeout      *fileDuration `json:"heartbeat_timeout"`
+	ShutdownTimeout       *fileDuration `json:"shutdown_timeout"`
+	DataFile              *string       `json:"data_file"`
+	AutosaveInterval      *fileDuration `json:"autosave_interval"`
+	TLSCert               *string       `json:"tls_cert"`
+	TLSKey                *string       `json:"tls_key"`
+	AdminSocket           *string       `json:"admin_socket"`
+	MetricsAddr           *string       `json:"metrics_addr"`
+	SelfStatsInterval     *fileDuration `json:"self_stats_interval"`
+	LogLevel              *string       `json:"log_level"`
+	LogFormat             *string       `json:"log_format"`
+	LogFile               *string       `json:"log_file"`
+	LogMaxSize            *int          `json:"log_max_size"`
+	LogMaxBackups         *int          `json:"log_max_backups"`
+	NormalizeTypes        *bool         `json:"normalize_types"`
+	RequireHello          *bool         `json:"require_hello"`
+	ClearOnClose          *bool         `json:"clear_on_close"`
+	ContextTemplate       *string       `json:"context_template"`
+	AuthToken             *string       `json:"auth_token"`
+	AuthTokenFile         *string       `json:"auth_token_file"`
+	NoDelay               *bool         `json:"no_delay"`
+	FlushInterval         *fileDuration `json:"flush_interval"`
+}
+
+// fileDuration is a duration given in a configuration file either as a Go
+// duration string such as "90s" or as a number of seconds
+type fileDuration time.Duration
+
+func (d *fileDuration) UnmarshalJSON(data []byte) error {
+	if seconds, err := strconv.Atoi(string(data)); err == nil {
+		*d = fileDuration(time.Duration(seconds) * time.Second)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("must be a duration such as \"30s\" or a number of seconds")
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("must be a duration such as \"30s\" or a number of seconds")
+	}
+
+	*d = fileDuration(parsed)
+	return nil
+}
+
+// fileMode is file permission bits given in a configuration file as an
+// octal string such as "0660"
+type fileMode os.FileMode
+
+func (m *fileMode) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("must be an octal string such as \"0660\"")
+	}
+	mode, err := ParseFileMode(s)
+	if err != nil {
+		return err
+	}
+
+	*m = fileMode(mode)
+	return nil
+}
+
+// applyFile overrides cfg with the settings in a JSON configuration file.
+// Unknown fields are rejected so typos do not go unnoticed.
+func applyFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	var fc fileConfig
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&fc); err != nil {
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+
+	setInt(&cfg.Port, fc.Port)
+	setString(&cfg.Listen, fc.Listen)
+	if fc.SocketMode != nil {
+		cfg.SocketMode = os.FileMode(*fc.SocketMode)
+	}
+	setInt(&cfg.MaxMessageSize, fc.MaxMessageSize)
+	setDuration(&cfg.ReadTimeout, fc.ReadTimeout)
+	setDuration(&cfg.WriteTimeout, fc.WriteTimeout)
+	setDuration(&cfg.IdleTimeout, fc.IdleTimeout)
+	setInt(&cfg.MaxConnections, fc.MaxConnections)
+	setString(&cfg.ConnectionLimitPolicy, fc.ConnectionLimitPolicy)
+	setString(&cfg.UnknownTypePolicy, fc.UnknownTypePolicy)
+	setInt(&cfg.RateLimit, fc.RateLimit)
+	setInt(&cfg.RateBurst, fc.RateBurst)
+	setInt(&cfg.MutationLimit, fc.MutationLimit)
+	setInt(&cfg.MutationBurst, fc.MutationBurst)
+	setDuration(&cfg.HandlerTimeout, fc.HandlerTimeout)
+	setDuration(&cfg.SweepInterval, fc.SweepInterval)
+	setInt(&cfg.CompressThreshold, fc.CompressThreshold)
+	setDuration(&cfg.HeartbeatInterval, fc.HeartbeatInterval)
+	setDuration(&cfg.HeartbeatTimeout, fc.HeartbeatTimeout)
+	setDuration(&cfg.ShutdownTimeout, fc.ShutdownTimeout)
+	setString(&cfg.DataFile, fc.DataFile)
+	setDuration(&cfg.AutosaveInterval, fc.AutosaveInterval)
+	setString(&cfg.TLSCert, fc.TLSCert)
+	setString(&cfg.TLSKey, fc.TLSKey)
+	setString(&cfg.AdminSocket, fc.AdminSocket)
+	setString(&cfg.MetricsAddr, fc.MetricsAddr)
+	setDuration(&cfg.SelfStatsInterval, fc.SelfStatsInterval)
+	setString(&cfg.LogLevel, fc.LogLevel)
+	setString(&cfg.LogFormat, fc.LogFormat)
+	setString(&cfg.LogFile, fc.LogFile)
+	setInt(&cfg.LogMaxSize, fc.LogMaxSize)
+	setInt(&cfg.LogMaxBackups, fc.LogMaxBackups)
+	setBool(&cfg.NormalizeTypes, fc.NormalizeTypes)
+	setBool(&cfg.RequireHello, fc.RequireHello)
+	setBool(&cfg.ClearOnClose, fc.ClearOnClose)
+	setString(&cfg.ContextTemplate, fc.ContextTemplate)
+	setString(&cfg.AuthToken, fc.AuthToken)
+	setString(&cfg.AuthTokenFile, fc.AuthTokenFile)
+	setBool(&cfg.NoDelay, fc.NoDelay)
+	setDuration(&cfg.FlushInterval, fc.FlushInterval)
+
+	return nil
+}
+
+func setInt(dst *int, src *int) {
+	if src != nil {
+		*dst = *src
+	}
+}
+
+func setBool(dst *bool, src *bool) {
+	if src != nil {
+		*dst = *src
+	}
+}
+
+func setString(dst *string, src *string) {
+	if src != nil {
+		*dst = *src
+	}
+}
+
+func setDuration(dst *time.Duration, src *fileDuration) {
+	if src != nil {
+		*dst = time.Duration(*src)
+	}
+}
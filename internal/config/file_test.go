@@ -0,0 +1,90 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a configuration file and returns its path
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFromFile(t *testing.T) {
+	path := writeConfig(t, `{
+		"port": 9200,
+		"read_timeout": "2m",
+		"write_timeout": 20,
+		"socket_mode": "0600",
+		"log_level": "warning"
+	}`)
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 9200 || cfg.ReadTimeout != 2*time.Minute || cfg.WriteTimeout != 20*time.Second {
+		t.Errorf("Port %d, ReadTimeout %v, WriteTimeout %v", cfg.Port, cfg.ReadTimeout, cfg.WriteTimeout)
+	}
+	if cfg.SocketMode != 0600 || cfg.LogLevel != "warning" {
+		t.Errorf("SocketMode %o, LogLevel %q", cfg.SocketMode, cfg.LogLevel)
+	}
+	if cfg.MaxConnections != MaxConnections {
+		t.Errorf("MaxConnections = %d, want the default", cfg.MaxConnections)
+	}
+}
+
+func TestEnvironmentOverridesFile(t *testing.T) {
+	path := writeConfig(t, `{"port": 9200, "log_level": "warning"}`)
+	t.Setenv("MCP_PORT", "9300")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 9300 {
+		t.Errorf("Port = %d, want the environment's", cfg.Port)
+	}
+	if cfg.LogLevel != "warning" {
+		t.Errorf("LogLevel = %q, want the file's", cfg.LogLevel)
+	}
+}
+
+func TestLoadRejectsInvalidFile(t *testing.T) {
+	tests := map[string]struct {
+		contents, mention string
+	}{
+		"unknown field":    {`{"prot": 1}`, "prot"},
+		"bad duration":     {`{"read_timeout": "soon"}`, "duration"},
+		"port range":       {`{"port": -1}`, "Port"},
+		"negative timeout": {`{"write_timeout": "-1s"}`, "WriteTimeout"},
+		"zero sweep":       {`{"sweep_interval": 0}`, "SweepInterval"},
+		"half of TLS pair": {`{"tls_cert": "cert.pem"}`, "TLSCert"},
+		"not JSON":         {`port = 1`, "invalid config file"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, tt.contents))
+			if err == nil {
+				t.Fatal("invalid file accepted")
+			}
+			if !strings.Contains(err.Error(), tt.mention) {
+				t.Fatalf("error %q does not mention %s", err, tt.mention)
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Fatal("missing file accepted")
+	}
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ParseListen splits a listen address of the form tcp://host:port or
+// unix:///path/to/socket into the network and address net.Listen takes
+func ParseListen(spec string) (network, address string, err error) {
+	scheme, address, ok := strings.Cut(spec, "://")
+	if !ok {
+		return "", "", fmt.Errorf("must be tcp://host:port or unix:///path")
+	}
+
+	switch scheme {
+	case "tcp":
+		if !strings.Contains(address, ":") {
+			return "", "", fmt.Errorf("missing port in %s", address)
+		}
+	case "unix":
+		if address == "" {
+			return "", "", fmt.Errorf("missing socket path")
+		}
+	default:
+		return "", "", fmt.Errorf("unsupported scheme %s, must be tcp or unix", scheme)
+	}
+
+	return scheme, address, nil
+}
+
+// ListenAddress returns the network and address to listen on: Listen if it
+// is set, otherwise the TCP port Port
+func (c Config) ListenAddress() (network, address string, err error) {
+	if c.Listen == "" {
+		return "tcp", fmt.Sprintf(":%d", c.Port), nil
+	}
+	return ParseListen(c.Listen)
+}
+
+// ParseFileMode parses permission bits written in octal, such as 0660
+func ParseFileMode(s string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || os.FileMode(mode)&^os.ModePerm != 0 {
+		return 0, fmt.Errorf("must be octal permission bits such as 0660")
+	}
+	return os.FileMode(mode), nil
+}
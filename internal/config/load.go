@@ -0,0 +1,489 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Config holds the runtime configuration of the server
+type Config struct {
+	// Port is the TCP port to listen on when Listen is empty
+	Port int
+
+	// Listen is the address to listen on, tcp://host:port or
+	// unix:///path/to/socket, superseding Port; SocketMode is the
+	// permission bits given to a unix socket
+	Listen     string
+	SocketMode os.FileMode
+
+	// MaxMessageSize is the maximum allowed size for incoming messages in bytes
+	MaxMessageSize int
+
+	// ReadTimeout, WriteTimeout and IdleTimeout bound client connection
+	// I/O. A client silent for IdleTimeout is pinged as described under
+	// HeartbeatInterval; zero never pings it.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+
+	// MaxConnections is the maximum number of simultaneous client
+	// connections; zero means unlimited
+	MaxConnections int
+
+	// ConnectionLimitPolicy is "reject" to turn away connections beyond
+	// MaxConnections with an ERROR, or "backlog" to stop accepting until
+	// a connection closes
+	ConnectionLimitPolicy string
+
+	// UnknownTypePolicy is "ignore" to log messages of unknown type and send
+	// nothing, "error" to reply with an ERROR, or "ack" to reply with an ACK
+	UnknownTypePolicy string
+
+	// RateLimit is the number of messages per second a client may send,
+	// with bursts of up to RateBurst, which defaults to RateLimit when
+	// zero. Messages beyond the rate are rejected; zero disables limiting.
+	// Admin console connections are never limited.
+	RateLimit int
+	RateBurst int
+
+	// MutationLimit is the number of key writes and deletes per second a
+	// client may make, with bursts of up to MutationBurst, which defaults
+	// to MutationLimit when zero. Zero disables the limit.
+	MutationLimit int
+	MutationBurst int
+
+	// HandlerTimeout is how long a message handler may run before it is
+	// flagged as slow
+	HandlerTimeout time.Duration
+
+	// SweepInterval is the interval between sweeps for expired context keys
+	SweepInterval time.Duration
+
+	// CompressThreshold is the size in bytes above which stored context
+	// values are compressed; zero disables compression
+	CompressThreshold int
+
+	// HeartbeatInterval is how long a client may be silent before the
+	// server pings it, superseding IdleTimeout; zero leaves that to
+	// IdleTimeout
+	HeartbeatInterval time.Duration
+
+	// HeartbeatTimeout is how long a pinged client has to send anything
+	// before it is pinged again; the connection is closed after two pings
+	// in a row go unanswered
+	HeartbeatTimeout time.Duration
+
+	// ShutdownTimeout is how long shutdown waits for in-flight messages
+	// before force-closing connections
+	ShutdownTimeout time.Duration
+
+	// DataFile is the path of the context snapshot; empty disables
+	// persistence
+	DataFile string
+
+	// AutosaveInterval is the interval between context snapshots; zero
+	// saves only on shutdown
+	AutosaveInterval time.Duration
+
+	// TLSCert and TLSKey are the PEM certificate and key files used to
+	// serve TLS; both empty serves plaintext
+	TLSCert string
+	TLSKey  string
+
+	// AdminSocket is the path of the unix socket for the operator console;
+	// empty disables it
+	AdminSocket string
+
+	// MetricsAddr is the address of the HTTP listener serving Prometheus
+	// metrics at /metrics; empty disables it
+	MetricsAddr string
+
+	// SelfStatsInterval is the interval between publications of the
+	// server's statistics under the reserved __server__ client ID; zero
+	// disables them
+	SelfStatsInterval time.Duration
+
+	// LogLevel is the minimum level of log messages to emit
+	LogLevel string
+
+	// LogFormat is the log output format, text or json
+	LogFormat string
+
+	// LogFile is a file that log output is appended to in addition to
+	// stdout; empty logs to stdout only
+	LogFile string
+
+	// LogMaxSize is the size in bytes at which LogFile is rotated, keeping
+	// LogMaxBackups rotated files; zero never rotates
+	LogMaxSize    int
+	LogMaxBackups int
+
+	// ClearOnClose removes a client's context when its connection closes,
+	// unless the client sent _persist=true with a CONTEXT. Off by default,
+	// so context outlives connections.
+	ClearOnClose bool
+
+	// ContextTemplate is a JSON or .env-style file of default values
+	// seeded into each new client's context, for keys it has not set;
+	// empty disables seeding
+	ContextTemplate string
+
+	// AuthToken, when set, must be presented in an AUTH message before a
+	// client may send anything else; it authenticates as DefaultPrincipal
+	AuthToken string
+
+	// AuthTokenFile is a JSON or .env-style file mapping client names to
+	// the tokens they authenticate with, required like AuthToken
+	AuthTokenFile string
+
+	// NoDelay disables Nagle's algorithm on client TCP connections, sending
+	// small messages at once rather than coalescing them; clients can
+	// override it with low_latency in HELLO
+	NoDelay bool
+
+	// FlushInterval batches outgoing messages in a per-connection buffer,
+	// flushed when full or at the latest this long after a message was
+	// buffered, trading latency for fewer writes; zero writes each message
+	// at once
+	FlushInterval time.Duration
+
+	// NormalizeTypes uppercases incoming message types so legacy clients
+	// sending "ping" are treated as PING. Strict deployments leave it off.
+	NormalizeTypes bool
+
+	// RequireHello makes clients negotiate a protocol version with HELLO
+	// before sending any other message
+	RequireHello bool
+}
+
+// Default returns the configuration built from the package constants
+func Default() Config {
+	return Config{
+		Port:                  DefaultPort,
+		SocketMode:            SocketMode,
+		MaxMessageSize:        MaxMessageSize,
+		ReadTimeout:           ReadTimeout * time.Second,
+		WriteTimeout:          WriteTimeout * time.Second,
+		IdleTimeout:           IdleTimeout * time.Second,
+		MaxConnections:        MaxConnections,
+		ConnectionLimitPolicy: ConnectionLimitPolicy,
+		UnknownTypePolicy:     UnknownTypePolicy,
+		RateLimit:             RateLimit,
+		RateBurst:             RateBurst,
+		MutationLimit:         MutationLimit,
+		MutationBurst:         MutationBurst,
+		HandlerTimeout:        HandlerTimeout * time.Second,
+		SweepInterval:         SweepInterval * time.Second,
+		CompressThreshold:     CompressThreshold,
+		HeartbeatInterval:     HeartbeatInterval * time.Second,
+		HeartbeatTimeout:      HeartbeatTimeout * time.Second,
+		ShutdownTimeout:       ShutdownTimeout * time.Second,
+		AutosaveInterval:      AutosaveInterval * time.Second,
+		SelfStatsInterval:     SelfStatsInterval * time.Second,
+		LogLevel:              LogLevel,
+		LogFormat:             LogFormat,
+		LogMaxSize:            LogMaxSize,
+		LogMaxBackups:         LogMaxBackups,
+		RequireHello:          RequireHello,
+		ClearOnClose:          ClearOnClose,
+		NoDelay:               NoDelay,
+		FlushInterval:         FlushInterval * time.Millisecond,
+	}
+}
+
+// Load returns the default configuration overridden first by the JSON
+// configuration file at path, if path is not empty, and then by any MCP_*
+// environment variables that are set. Durations accept either a Go duration
+// string such as "90s" or a plain number of seconds. Malformed values are
+// reported as errors rather than silently replaced by the default, and the
+// result is checked with Validate.
+func Load(path string) (Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		if err := applyFile(path, &cfg); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if err := envInt("MCP_PORT", &cfg.Port); err != nil {
+		return Config{}, err
+	}
+	if value, exists := os.LookupEnv("MCP_LISTEN"); exists {
+		cfg.Listen = value
+	}
+	if value, exists := os.LookupEnv("MCP_SOCKET_MODE"); exists {
+		mode, err := ParseFileMode(value)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid MCP_SOCKET_MODE %q: %v", value, err)
+		}
+		cfg.SocketMode = mode
+	}
+	if err := envInt("MCP_MAX_MESSAGE_SIZE", &cfg.MaxMessageSize); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration("MCP_READ_TIMEOUT", &cfg.ReadTimeout); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration("MCP_WRITE_TIMEOUT", &cfg.WriteTimeout); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration("MCP_IDLE_TIMEOUT", &cfg.IdleTimeout); err != nil {
+		return Config{}, err
+	}
+	if err := envInt("MCP_MAX_CONNECTIONS", &cfg.MaxConnections); err != nil {
+		return Config{}, err
+	}
+	if value, exists := os.LookupEnv("MCP_CONNECTION_LIMIT_POLICY"); exists {
+		cfg.ConnectionLimitPolicy = value
+	}
+	if value, exists := os.LookupEnv("MCP_UNKNOWN_TYPE_POLICY"); exists {
+		cfg.UnknownTypePolicy = value
+	}
+	if err := envInt("MCP_RATE_LIMIT", &cfg.RateLimit); err != nil {
+		return Config{}, err
+	}
+	if err := envInt("MCP_RATE_BURST", &cfg.RateBurst); err != nil {
+		return Config{}, err
+	}
+	if err := envInt("MCP_MUTATION_LIMIT", &cfg.MutationLimit); err != nil {
+		return Config{}, err
+	}
+	if err := envInt("MCP_MUTATION_BURST", &cfg.MutationBurst); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration("MCP_HANDLER_TIMEOUT", &cfg.HandlerTimeout); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration("MCP_SWEEP_INTERVAL", &cfg.SweepInterval); err != nil {
+		return Config{}, err
+	}
+	if err := envInt("MCP_COMPRESS_THRESHOLD", &cfg.CompressThreshold); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration("MCP_HEARTBEAT_INTERVAL", &cfg.HeartbeatInterval); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration("MCP_HEARTBEAT_TIMEOUT", &cfg.HeartbeatTimeout); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration("MCP_SHUTDOWN_TIMEOUT", &cfg.ShutdownTimeout); err != nil {
+		return Config{}, err
+	}
+	if value, exists := os.LookupEnv("MCP_DATA_FILE"); exists {
+		cfg.DataFile = value
+	}
+	if err := envDuration("MCP_AUTOSAVE_INTERVAL", &cfg.AutosaveInterval); err != nil {
+		return Config{}, err
+	}
+	if value, exists := os.LookupEnv("MCP_TLS_CERT"); exists {
+		cfg.TLSCert = value
+	}
+	if value, exists := os.LookupEnv("MCP_TLS_KEY"); exists {
+		cfg.TLSKey = value
+	}
+	if value, exists := os.LookupEnv("MCP_ADMIN_SOCKET"); exists {
+		cfg.AdminSocket = value
+	}
+	if value, exists := os.LookupEnv("MCP_METRICS_ADDR"); exists {
+		cfg.MetricsAddr = value
+	}
+	if err := envDuration("MCP_SELF_STATS_INTERVAL", &cfg.SelfStatsInterval); err != nil {
+		return Config{}, err
+	}
+	if err := envBool("MCP_NORMALIZE_TYPES", &cfg.NormalizeTypes); err != nil {
+		return Config{}, err
+	}
+	if err := envBool("MCP_REQUIRE_HELLO", &cfg.RequireHello); err != nil {
+		return Config{}, err
+	}
+	if err := envBool("MCP_CLEAR_ON_CLOSE", &cfg.ClearOnClose); err != nil {
+		return Config{}, err
+	}
+	if err := envBool("MCP_NO_DELAY", &cfg.NoDelay); err != nil {
+		return Config{}, err
+	}
+	if err := envDuration("MCP_FLUSH_INTERVAL", &cfg.FlushInterval); err != nil {
+		return Config{}, err
+	}
+	if value, exists := os.LookupEnv("MCP_CONTEXT_TEMPLATE"); exists {
+		cfg.ContextTemplate = value
+	}
+	if value, exists := os.LookupEnv("MCP_AUTH_TOKEN"); exists {
+		cfg.AuthToken = value
+	}
+	if value, exists := os.LookupEnv("MCP_AUTH_TOKEN_FILE"); exists {
+		cfg.AuthTokenFile = value
+	}
+	if value, exists := os.LookupEnv("MCP_LOG_LEVEL"); exists {
+		cfg.LogLevel = value
+	}
+	if value, exists := os.LookupEnv("MCP_LOG_FORMAT"); exists {
+		cfg.LogFormat = value
+	}
+	if value, exists := os.LookupEnv("MCP_LOG_FILE"); exists {
+		cfg.LogFile = value
+	}
+	if err := envInt("MCP_LOG_MAX_SIZE", &cfg.LogMaxSize); err != nil {
+		return Config{}, err
+	}
+	if err := envInt("MCP_LOG_MAX_BACKUPS", &cfg.LogMaxBackups); err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// Validate checks that every setting is in range, naming the offending
+// field in the error
+func (c Config) Validate() error {
+	if c.Port < 0 || c.Port > 65535 {
+		return fmt.Errorf("invalid Port %d: must be between 0 and 65535", c.Port)
+	}
+	if c.Listen != "" {
+		if _, _, err := ParseListen(c.Listen); err != nil {
+			return fmt.Errorf("invalid Listen %q: %v", c.Listen, err)
+		}
+	}
+	if c.SocketMode&^os.ModePerm != 0 {
+		return fmt.Errorf("invalid SocketMode %o: must only hold permission bits", c.SocketMode)
+	}
+	if c.MaxMessageSize <= 0 {
+		return fmt.Errorf("invalid MaxMessageSize %d: must be positive", c.MaxMessageSize)
+	}
+	if c.MaxConnections < 0 {
+		return fmt.Errorf("invalid MaxConnections %d: must not be negative", c.MaxConnections)
+	}
+	switch c.ConnectionLimitPolicy {
+	case "reject", "backlog":
+	default:
+		return fmt.Errorf("invalid ConnectionLimitPolicy %q: must be reject or backlog", c.ConnectionLimitPolicy)
+	}
+	switch c.UnknownTypePolicy {
+	case "ignore", "error", "ack":
+	default:
+		return fmt.Errorf("invalid UnknownTypePolicy %q: must be ignore, error or ack", c.UnknownTypePolicy)
+	}
+	if c.RateLimit < 0 {
+		return fmt.Errorf("invalid RateLimit %d: must not be negative", c.RateLimit)
+	}
+	if c.RateBurst < 0 {
+		return fmt.Errorf("invalid RateBurst %d: must not be negative", c.RateBurst)
+	}
+	if c.MutationLimit < 0 {
+		return fmt.Errorf("invalid MutationLimit %d: must not be negative", c.MutationLimit)
+	}
+	if c.MutationBurst < 0 {
+		return fmt.Errorf("invalid MutationBurst %d: must not be negative", c.MutationBurst)
+	}
+	if c.CompressThreshold < 0 {
+		return fmt.Errorf("invalid CompressThreshold %d: must not be negative", c.CompressThreshold)
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return fmt.Errorf("invalid TLSCert/TLSKey: both or neither must be set")
+	}
+	if c.SweepInterval <= 0 {
+		return fmt.Errorf("invalid SweepInterval %v: must be positive", c.SweepInterval)
+	}
+
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"ReadTimeout", c.ReadTimeout},
+		{"WriteTimeout", c.WriteTimeout},
+		{"IdleTimeout", c.IdleTimeout},
+		{"HandlerTimeout", c.HandlerTimeout},
+		{"HeartbeatInterval", c.HeartbeatInterval},
+		{"HeartbeatTimeout", c.HeartbeatTimeout},
+		{"ShutdownTimeout", c.ShutdownTimeout},
+		{"AutosaveInterval", c.AutosaveInterval},
+		{"SelfStatsInterval", c.SelfStatsInterval},
+		{"FlushInterval", c.FlushInterval},
+	}
+	for _, d := range durations {
+		if d.value < 0 {
+			return fmt.Errorf("invalid %s %v: must not be negative", d.name, d.value)
+		}
+	}
+
+	switch c.LogLevel {
+	case "debug", "info", "warning", "error":
+	default:
+		return fmt.Errorf("invalid LogLevel %q: must be debug, info, warning or error", c.LogLevel)
+	}
+
+	switch c.LogFormat {
+	case "text", "json":
+	default:
+		return fmt.Errorf("invalid LogFormat %q: must be text or json", c.LogFormat)
+	}
+	if c.LogMaxSize < 0 {
+		return fmt.Errorf("invalid LogMaxSize %d: must not be negative", c.LogMaxSize)
+	}
+	if c.LogMaxBackups < 0 {
+		return fmt.Errorf("invalid LogMaxBackups %d: must not be negative", c.LogMaxBackups)
+	}
+
+	return nil
+}
+
+// envInt overrides dst with the integer value of an environment variable
+func envInt(name string, dst *int) error {
+	value, exists := os.LookupEnv(name)
+	if !exists {
+		return nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: must be an integer", name, value)
+	}
+
+	*dst = n
+	return nil
+}
+
+// envBool overrides dst with the boolean value of an environment variable
+func envBool(name string, dst *bool) error {
+	value, exists := os.LookupEnv(name)
+	if !exists {
+		return nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: must be true or false", name, value)
+	}
+
+	*dst = b
+	return nil
+}
+
+// envDuration overrides dst with the duration value of an environment
+// variable, given either as a Go duration or a number of seconds
+func envDuration(name string, dst *time.Duration) error {
+	value, exists := os.LookupEnv(name)
+	if !exists {
+		return nil
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		*dst = time.Duration(seconds) * time.Second
+		return nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: must be a duration such as 30s or a number of seconds", name, value)
+	}
+
+	*dst = d
+	return nil
+}
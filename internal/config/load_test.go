@@ -0,0 +1,86 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadWithoutEnvironmentIsDefault(t *testing.T) {
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg != Default() {
+		t.Fatalf("Load() = %+v, want the defaults", cfg)
+	}
+}
+
+func TestLoadFromEnvironment(t *testing.T) {
+	t.Setenv("MCP_PORT", "9100")
+	t.Setenv("MCP_READ_TIMEOUT", "90s")
+	t.Setenv("MCP_WRITE_TIMEOUT", "15")
+	t.Setenv("MCP_MAX_CONNECTIONS", "12")
+	t.Setenv("MCP_REQUIRE_HELLO", "true")
+	t.Setenv("MCP_LOG_LEVEL", "debug")
+	t.Setenv("MCP_LOG_FORMAT", "json")
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != 9100 {
+		t.Errorf("Port = %d", cfg.Port)
+	}
+	if cfg.ReadTimeout != 90*time.Second {
+		t.Errorf("ReadTimeout = %v", cfg.ReadTimeout)
+	}
+	if cfg.WriteTimeout != 15*time.Second {
+		t.Errorf("WriteTimeout = %v, want plain seconds accepted", cfg.WriteTimeout)
+	}
+	if cfg.MaxConnections != 12 {
+		t.Errorf("MaxConnections = %d", cfg.MaxConnections)
+	}
+	if !cfg.RequireHello {
+		t.Error("RequireHello not set")
+	}
+	if cfg.LogLevel != "debug" {
+		t.Errorf("LogLevel = %q", cfg.LogLevel)
+	}
+	if cfg.LogFormat != "json" {
+		t.Errorf("LogFormat = %q", cfg.LogFormat)
+	}
+	if cfg.IdleTimeout != IdleTimeout*time.Second {
+		t.Errorf("IdleTimeout = %v, want the default", cfg.IdleTimeout)
+	}
+}
+
+func TestLoadRejectsInvalidEnvironment(t *testing.T) {
+	tests := []struct {
+		name, value, mention string
+	}{
+		{"MCP_PORT", "http", "MCP_PORT"},
+		{"MCP_PORT", "70000", "Port"},
+		{"MCP_READ_TIMEOUT", "soon", "MCP_READ_TIMEOUT"},
+		{"MCP_IDLE_TIMEOUT", "-5s", "IdleTimeout"},
+		{"MCP_MAX_CONNECTIONS", "-1", "MaxConnections"},
+		{"MCP_REQUIRE_HELLO", "maybe", "MCP_REQUIRE_HELLO"},
+		{"MCP_LOG_LEVEL", "loud", "LogLevel"},
+		{"MCP_LOG_FORMAT", "xml", "LogFormat"},
+		{"MCP_UNKNOWN_TYPE_POLICY", "echo", "UnknownTypePolicy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"="+tt.value, func(t *testing.T) {
+			t.Setenv(tt.name, tt.value)
+
+			_, err := Load("")
+			if err == nil {
+				t.Fatal("invalid value accepted")
+			}
+			if !strings.Contains(err.Error(), tt.mention) {
+				t.Fatalf("error %q does not name %s", err, tt.mention)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// LoadValues reads context values from a file, either a JSON object of
+// strings or .env-style KEY=VALUE lines. In the latter, blank lines and
+// lines starting with # are skipped, an "export " prefix is ignored, and a
+// value may be wrapped in single quotes, taken literally, or double quotes,
+// which allow Go escapes. Errors name the file and, for .env files, the
+// line.
+func LoadValues(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read values file: %v", err)
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var values map[string]string
+		if err := json.Unmarshal(trimmed, &values); err != nil {
+			return nil, fmt.Errorf("invalid values file %s: %v", path, err)
+		}
+		for key := range values {
+			if key == "" {
+				return nil, fmt.Errorf("invalid values file %s: empty key", path)
+			}
+		}
+		return values, nil
+	}
+
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid values file %s: line %d: expected KEY=VALUE", path, n)
+		}
+
+		value, err := unquoteValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid values file %s: line %d: %v", path, n, err)
+		}
+		values[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read values file: %v", err)
+	}
+
+	return values, nil
+}
+
+// unquoteValue strips the quotes from a .env value
+func unquoteValue(value string) (string, error) {
+	if len(value) == 0 {
+		return value, nil
+	}
+
+	switch value[0] {
+	case '"':
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return "", fmt.Errorf("malformed double-quoted value")
+		}
+		return unquoted, nil
+	case '\'':
+		if len(value) < 2 || value[len(value)-1] != '\'' {
+			return "", fmt.Errorf("unterminated single-quoted value")
+		}
+		return value[1 : len(value)-1], nil
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadValuesEnv(t *testing.T) {
+	path := writeConfig(t, `
+# agent defaults
+REGION=eu-west
+export MODEL = small
+GREETING="hello\tworld"
+RAW='$HOME \n'
+EMPTY=
+URL=http://host/?a=b
+`)
+
+	values, err := LoadValues(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"REGION":   "eu-west",
+		"MODEL":    "small",
+		"GREETING": "hello\tworld",
+		"RAW":      `$HOME \n`,
+		"EMPTY":    "",
+		"URL":      "http://host/?a=b",
+	}
+	if !reflect.DeepEqual(values, want) {
+		t.Fatalf("LoadValues = %q, want %q", values, want)
+	}
+}
+
+func TestLoadValuesJSON(t *testing.T) {
+	values, err := LoadValues(writeConfig(t, `  {"region": "eu-west", "note": "a;b=c"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"region": "eu-west", "note": "a;b=c"}; !reflect.DeepEqual(values, want) {
+		t.Fatalf("LoadValues = %q, want %q", values, want)
+	}
+}
+
+func TestLoadValuesRejectsMalformedFiles(t *testing.T) {
+	tests := []struct {
+		name, contents, mention string
+	}{
+		{"line without =", "A=1\nNOVALUE\n", "line 2"},
+		{"empty key", "=1\n", "line 1"},
+		{"unterminated single quote", "\n\nA='open\n", "line 3"},
+		{"bad double quote", `A="\q"` + "\n", "line 1"},
+		{"JSON number", `{"a": 1}`, "config.json"},
+		{"JSON empty key", `{"": "x"}`, "empty key"},
+		{"truncated JSON", `{"a": "x"`, "config.json"},
+	}
+
+	for _, tt := range tests {
+		_, err := LoadValues(writeConfig(t, tt.contents))
+		if err == nil {
+			t.Errorf("%s: accepted", tt.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.mention) {
+			t.Errorf("%s: error %q does not mention %s", tt.name, err, tt.mention)
+		}
+	}
+
+	if _, err := LoadValues(filepath.Join(t.TempDir(), "missing.env")); err == nil {
+		t.Error("missing file accepted")
+	}
+}
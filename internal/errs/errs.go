@@ -0,0 +1,144 @@
+// Package errs defines the error taxonomy shared by the store, the dispatch
+// layer and clients. Every sentinel carries the codes it maps to on each
+// transport, so a new error cannot be introduced without a wire code.
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// Code describes how an error is reported on each transport
+type Code struct {
+	// Reason is the machine-readable reason sent in protocol ERROR messages
+	Reason string
+	// HTTPStatus is the status used by HTTP transports
+	HTTPStatus int
+	// GRPCCode is the numeric gRPC status code used by gRPC transports
+	GRPCCode int
+}
+
+// gRPC status codes, numbered as in google.golang.org/grpc/codes
+const (
+	grpcInvalidArgument    = 3
+	grpcNotFound           = 5
+	grpcAlreadyExists      = 6
+	grpcPermissionDenied   = 7
+	grpcResourceExhausted  = 8
+	grpcFailedPrecondition = 9
+	grpcAborted            = 10
+	grpcUnimplemented      = 12
+	grpcInternal           = 13
+	grpcUnavailable        = 14
+	grpcUnauthenticated    = 16
+)
+
+// sentinel is an error kind with its transport codes
+type sentinel struct {
+	msg  string
+	code Code
+}
+
+func (s *sentinel) Error() string {
+	return s.msg
+}
+
+// define creates a sentinel error with its transport codes
+func define(msg, reason string, httpStatus, grpcCode int) error {
+	return &sentinel{
+		msg:  msg,
+		code: Code{Reason: reason, HTTPStatus: httpStatus, GRPCCode: grpcCode},
+	}
+}
+
+// Sentinel errors and their mapping to protocol, HTTP and gRPC codes
+var (
+	ErrNotFound           = define("not found", protocol.ReasonNotFound, http.StatusNotFound, grpcNotFound)
+	ErrQuotaExceeded      = define("quota exceeded", protocol.ReasonQuotaExceeded, http.StatusInsufficientStorage, grpcResourceExhausted)
+	ErrVersionMismatch    = define("unsupported protocol version", protocol.ReasonUnsupportedVersion, http.StatusBadRequest, grpcFailedPrecondition)
+	ErrReadOnly           = define("read only", protocol.ReasonReadOnly, http.StatusForbidden, grpcPermissionDenied)
+	ErrRateLimited        = define("rate limited", protocol.ReasonRateLimited, http.StatusTooManyRequests, grpcResourceExhausted)
+	ErrMutationLimited    = define("mutation rate limited", protocol.ReasonMutationLimited, http.StatusTooManyRequests, grpcResourceExhausted)
+	ErrUnauthorized       = define("unauthorized", protocol.ReasonUnauthorized, http.StatusUnauthorized, grpcUnauthenticated)
+	ErrUnauthenticated    = define("unauthenticated", protocol.ReasonUnauthenticated, http.StatusUnauthorized, grpcUnauthenticated)
+	ErrShuttingDown       = define("shutting down", protocol.ReasonShuttingDown, http.StatusServiceUnavailable, grpcUnavailable)
+	ErrInvalidParams      = define("invalid parameters", protocol.ReasonInvalidParams, http.StatusBadRequest, grpcInvalidArgument)
+	ErrInvalidValue       = define("invalid value", protocol.ReasonInvalidValue, http.StatusUnprocessableEntity, grpcInvalidArgument)
+	ErrUnregisteredKey    = define("unregistered key", protocol.ReasonUnregisteredKey, http.StatusUnprocessableEntity, grpcInvalidArgument)
+	ErrMessageTooLarge    = define("message too large", protocol.ReasonMessageTooLarge, http.StatusRequestEntityTooLarge, grpcResourceExhausted)
+	ErrHandshakeRequired  = define("handshake required", protocol.ReasonHandshakeRequired, http.StatusPreconditionRequired, grpcFailedPrecondition)
+	ErrAlreadyNegotiated  = define("already negotiated", protocol.ReasonAlreadyNegotiated, http.StatusConflict, grpcAlreadyExists)
+	ErrClientIDInUse      = define("client ID in use", protocol.ReasonClientIDInUse, http.StatusConflict, grpcAlreadyExists)
+	ErrStaleSequence      = define("stale sequence number", protocol.ReasonStaleSequence, http.StatusConflict, grpcAborted)
+	ErrDraining           = define("connection draining", protocol.ReasonDraining, http.StatusServiceUnavailable, grpcUnavailable)
+	ErrUnknownType        = define("unknown message type", protocol.ReasonUnknownType, http.StatusNotImplemented, grpcUnimplemented)
+	ErrTooManyConnections = define("too many connections", protocol.ReasonTooManyConnections, http.StatusServiceUnavailable, grpcResourceExhausted)
+	ErrLoading            = define("server loading", protocol.ReasonServerLoading, http.StatusServiceUnavailable, grpcUnavailable)
+	ErrServerFull         = define("server full", protocol.ReasonServerFull, http.StatusServiceUnavailable, grpcResourceExhausted)
+	ErrParseFailed        = define("malformed message", protocol.ReasonParseFailed, http.StatusBadRequest, grpcInvalidArgument)
+)
+
+// internalCode is reported for errors outside the taxonomy
+var internalCode = Code{Reason: protocol.ReasonInternal, HTTPStatus: http.StatusInternalServerError, GRPCCode: grpcInternal}
+
+// Error wraps a sentinel with request-specific detail. errors.Is matches it
+// against its sentinel.
+type Error struct {
+	Kind   error
+	Detail string
+	// RetryAfter is how long the client should wait before retrying, or
+	// zero if there is no telling
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return e.Kind.Error() + ": " + e.Detail
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// New wraps a sentinel with a formatted detail message
+func New(kind error, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Detail: fmt.Sprintf(format, args...)}
+}
+
+// NewRetry is New with a hint of how long to wait before retrying
+func NewRetry(kind error, retryAfter time.Duration, format string, args ...interface{}) error {
+	return &Error{Kind: kind, Detail: fmt.Sprintf(format, args...), RetryAfter: retryAfter}
+}
+
+// RetryAfter returns how long to wait before retrying after err, or zero
+// if err gives no hint
+func RetryAfter(err error) time.Duration {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.RetryAfter
+	}
+	return 0
+}
+
+// CodeOf returns the transport codes for err, falling back to an internal
+// error code if err does not wrap a sentinel
+func CodeOf(err error) Code {
+	var s *sentinel
+	if errors.As(err, &s) {
+		return s.code
+	}
+	return internalCode
+}
+
+// Detail returns the human-readable detail of err: the detail given to New,
+// or the error text otherwise
+func Detail(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Detail
+	}
+	return err.Error()
+}
@@ -0,0 +1,165 @@
+package errs
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sentinels lists every sentinel by name, so the tests below cover them all
+var sentinels = map[string]error{
+	"ErrNotFound":           ErrNotFound,
+	"ErrQuotaExceeded":      ErrQuotaExceeded,
+	"ErrVersionMismatch":    ErrVersionMismatch,
+	"ErrReadOnly":           ErrReadOnly,
+	"ErrRateLimited":        ErrRateLimited,
+	"ErrMutationLimited":    ErrMutationLimited,
+	"ErrUnauthorized":       ErrUnauthorized,
+	"ErrUnauthenticated":    ErrUnauthenticated,
+	"ErrShuttingDown":       ErrShuttingDown,
+	"ErrInvalidParams":      ErrInvalidParams,
+	"ErrInvalidValue":       ErrInvalidValue,
+	"ErrUnregisteredKey":    ErrUnregisteredKey,
+	"ErrMessageTooLarge":    ErrMessageTooLarge,
+	"ErrHandshakeRequired":  ErrHandshakeRequired,
+	"ErrAlreadyNegotiated":  ErrAlreadyNegotiated,
+	"ErrClientIDInUse":      ErrClientIDInUse,
+	"ErrStaleSequence":      ErrStaleSequence,
+	"ErrDraining":           ErrDraining,
+	"ErrUnknownType":        ErrUnknownType,
+	"ErrTooManyConnections": ErrTooManyConnections,
+	"ErrLoading":            ErrLoading,
+	"ErrServerFull":         ErrServerFull,
+	"ErrParseFailed":        ErrParseFailed,
+}
+
+// declaredErrors returns the names of the Err* variables declared in the
+// package's non-test sources
+func declaredErrors(t *testing.T) []string {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, ".", func(info fs.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.VAR {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					for _, name := range spec.(*ast.ValueSpec).Names {
+						if strings.HasPrefix(name.Name, "Err") {
+							names = append(names, name.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+func TestEverySentinelHasCodes(t *testing.T) {
+	declared := declaredErrors(t)
+	if len(declared) != len(sentinels) {
+		t.Errorf("%d sentinels declared, %d listed in the test", len(declared), len(sentinels))
+	}
+
+	reasons := make(map[string]string)
+	for _, name := range declared {
+		err, listed := sentinels[name]
+		if !listed {
+			t.Errorf("%s is not listed in the test", name)
+			continue
+		}
+
+		code := CodeOf(err)
+		if code == internalCode || code.Reason == "" {
+			t.Errorf("%s has no wire reason", name)
+		}
+		if http.StatusText(code.HTTPStatus) == "" {
+			t.Errorf("%s maps to unknown HTTP status %d", name, code.HTTPStatus)
+		}
+		if code.GRPCCode <= 0 || code.GRPCCode > 16 {
+			t.Errorf("%s maps to unknown gRPC code %d", name, code.GRPCCode)
+		}
+		if other, taken := reasons[code.Reason]; taken {
+			t.Errorf("%s and %s share reason %s", name, other, code.Reason)
+		}
+		reasons[code.Reason] = name
+	}
+}
+
+func TestErrorsIsAndAs(t *testing.T) {
+	for name, sentinel := range sentinels {
+		t.Run(name, func(t *testing.T) {
+			err := New(sentinel, "key %s", "k")
+			wrapped := fmt.Errorf("handling GET: %w", err)
+
+			for _, e := range []error{err, wrapped} {
+				if !errors.Is(e, sentinel) {
+					t.Fatalf("errors.Is(%v, %s) = false", e, name)
+				}
+				var detailed *Error
+				if !errors.As(e, &detailed) || detailed.Detail != "key k" {
+					t.Fatalf("errors.As(%v) did not find the detail", e)
+				}
+				if CodeOf(e) != CodeOf(sentinel) {
+					t.Fatalf("CodeOf(%v) differs from its sentinel", e)
+				}
+			}
+
+			for other, otherSentinel := range sentinels {
+				if other != name && errors.Is(err, otherSentinel) {
+					t.Fatalf("%s also matches %s", name, other)
+				}
+			}
+		})
+	}
+}
+
+func TestUnknownErrorsAreInternal(t *testing.T) {
+	err := errors.New("disk on fire")
+
+	if code := CodeOf(err); code != internalCode {
+		t.Fatalf("CodeOf = %+v, want the internal code", code)
+	}
+	if code := CodeOf(err); code.HTTPStatus != http.StatusInternalServerError {
+		t.Fatalf("HTTP status %d, want 500", code.HTTPStatus)
+	}
+	if detail := Detail(err); detail != "disk on fire" {
+		t.Fatalf("Detail = %q", detail)
+	}
+	if retry := RetryAfter(err); retry != 0 {
+		t.Fatalf("RetryAfter = %v", retry)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", NewRetry(ErrRateLimited, 250*time.Millisecond, "slow down"))
+
+	if retry := RetryAfter(err); retry != 250*time.Millisecond {
+		t.Fatalf("RetryAfter = %v", retry)
+	}
+	if !errors.Is(err, ErrRateLimited) || Detail(err) != "slow down" {
+		t.Fatalf("lost the kind or detail of %v", err)
+	}
+	if retry := RetryAfter(New(ErrRateLimited, "no hint")); retry != 0 {
+		t.Fatalf("RetryAfter without a hint = %v", retry)
+	}
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+	"github.com/Artimus100/mcp-server-go/internal/utils"
+)
+
+// failingListener fails every Accept until it is closed
+type failingListener struct {
+	accepts atomic.Int64
+	closed  chan struct{}
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	l.accepts.Add(1)
+	select {
+	case <-l.closed:
+		return nil, net.ErrClosed
+	default:
+		return nil, errors.New("accept: transient failure")
+	}
+}
+
+func (l *failingListener) Close() error {
+	close(l.closed)
+	return nil
+}
+
+func (l *failingListener) Addr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+}
+
+func TestAcceptLoopBacksOffOnFailingListener(t *testing.T) {
+	log := &syncBuffer{}
+	cfg := config.Default()
+	srv := NewServer(cfg, state.NewContextStore(), utils.NewLoggerTo(log, "test"))
+	listener := &failingListener{closed: make(chan struct{})}
+
+	done := make(chan struct{})
+	go func() {
+		srv.acceptConnections(listener, false)
+		close(done)
+	}()
+
+	// Backing off from 5ms and doubling, 300ms allows about six attempts;
+	// a hot loop would make hundreds of thousands
+	time.Sleep(300 * time.Millisecond)
+	if n := listener.accepts.Load(); n > 10 {
+		t.Errorf("%d accept attempts in 300ms, want the failures throttled", n)
+	}
+	for _, want := range []string{"retrying in 5ms", "retrying in 10ms", "retrying in 20ms"} {
+		if !strings.Contains(log.String(), want) {
+			t.Errorf("log missing %q:\n%s", want, log)
+		}
+	}
+
+	listener.Close()
+	select {
+	case <-done:
+	case <-time.After(testTimeout):
+		t.Fatal("accept loop still running after its listener closed")
+	}
+}
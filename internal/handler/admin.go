@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/errs"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+	"github.com/Artimus100/mcp-server-go/internal/utils"
+)
+
+// adminDrainGrace is the grace period of a DRAIN without a grace parameter
+const adminDrainGrace = 30 * time.Second
+
+// listenAdmin listens on the unix socket at path, replacing a stale socket
+// left by a previous run, and restricts it to the server's own user
+func listenAdmin(path string) (net.Listener, error) {
+	listener, err := listenUnix(path, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("admin socket: %v", err)
+	}
+	return listener, nil
+}
+
+// DisconnectClient closes the connection with the given ID, returning
+// errs.ErrNotFound if there is none
+func (s *Server) DisconnectClient(id string) error {
+	s.mu.RLock()
+	conn, exists := s.connections[id]
+	s.mu.RUnlock()
+
+	if !exists {
+		return errs.New(errs.ErrNotFound, "no connection %s", id)
+	}
+
+	conn.Close()
+	return nil
+}
+
+// ActiveConnections returns a snapshot of the open connections, oldest
+// first. The result is a copy taken under the read lock, unaffected by later
+// connects and disconnects.
+func (s *Server) ActiveConnections() []ConnectionInfo {
+	s.mu.RLock()
+	infos := make([]ConnectionInfo, 0, len(s.connections))
+	for _, conn := range s.connections {
+		infos = append(infos, conn.Info())
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ConnectedAt.Before(infos[j].ConnectedAt)
+	})
+	return infos
+}
+
+// requireAdmin rejects admin-only messages outside the admin socket
+func (c *Connection) requireAdmin(msg protocol.Message) error {
+	if !c.admin {
+		return errs.New(errs.ErrUnauthorized, "%s is only available on the admin socket", msg.Type)
+	}
+	return nil
+}
+
+// handleKick closes the connection named by the conn parameter
+func (c *Connection) handleKick(msg protocol.Message) (protocol.Message, error) {
+	if err := c.requireAdmin(msg); err != nil {
+		return protocol.Message{}, err
+	}
+
+	id := msg.Params["conn"]
+	if id == "" {
+		return protocol.Message{}, errs.New(errs.ErrInvalidParams, "missing conn parameter")
+	}
+
+	if err := c.server.DisconnectClient(id); err != nil {
+		return protocol.Message{}, err
+	}
+
+	return ackMessage(), nil
+}
+
+// handleDrain drains client connections, optionally only those from the
+// network in the cidr parameter, over the grace parameter (default 30s).
+// Admin connections are never drained.
+func (c *Connection) handleDrain(msg protocol.Message) (protocol.Message, error) {
+	if err := c.requireAdmin(msg); err != nil {
+		return protocol.Message{}, err
+	}
+
+	grace := adminDrainGrace
+	if raw, exists := msg.Params["grace"]; exists {
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return protocol.Message{}, errs.New(errs.ErrInvalidParams, "invalid grace %q", raw)
+		}
+		grace = d
+	}
+
+	selector := SelectAll()
+	if cidr, exists := msg.Params["cidr"]; exists {
+		var err error
+		if selector, err = SelectCIDR(cidr); err != nil {
+			return protocol.Message{}, errs.New(errs.ErrInvalidParams, "%v", err)
+		}
+	}
+
+	matched := c.server.Drain(func(info ConnectionInfo) bool {
+		return !info.Admin && selector(info)
+	}, grace)
+
+	ack := ackMessage()
+	ack.Params["matched"] = strconv.Itoa(matched)
+	return ack, nil
+}
+
+// handleExport writes a snapshot of the context store to the path parameter
+func (c *Connection) handleExport(msg protocol.Message) (protocol.Message, error) {
+	if err := c.requireAdmin(msg); err != nil {
+		return protocol.Message{}, err
+	}
+
+	path := msg.Params["path"]
+	if path == "" {
+		return protocol.Message{}, errs.New(errs.ErrInvalidParams, "missing path parameter")
+	}
+
+	if err := c.store.Save(path); err != nil {
+		return protocol.Message{}, err
+	}
+
+	return ackMessage(), nil
+}
+
+// handleLogLevel changes the server's log level to the level parameter.
+// Connection loggers share the server logger's level, so existing
+// connections follow it; the audit log keeps its own.
+func (c *Connection) handleLogLevel(msg protocol.Message) (protocol.Message, error) {
+	if err := c.requireAdmin(msg); err != nil {
+		return protocol.Message{}, err
+	}
+
+	level, err := utils.ParseLevel(msg.Params["level"])
+	if err != nil {
+		return protocol.Message{}, errs.New(errs.ErrInvalidParams, "%v", err)
+	}
+
+	c.server.logger.SetLevel(level)
+	c.server.logger.Info("Log level set to %s", level)
+	return ackMessage(), nil
+}
+
+// handleClearAll removes the context of every client. The server's
+// published statistics are kept.
+func (c *Connection) handleClearAll(msg protocol.Message) (protocol.Message, error) {
+	if err := c.requireAdmin(msg); err != nil {
+		return protocol.Message{}, err
+	}
+
+	cleared := 0
+	for _, clientID := range c.store.ListClients() {
+		if clientID == state.ServerClientID {
+			continue
+		}
+		c.store.Clear(clientID)
+		cleared++
+	}
+
+	ack := ackMessage()
+	ack.Params["cleared"] = strconv.Itoa(cleared)
+	return ack, nil
+}
+
+// handleReload rereads the auth token file and context template, as SIGHUP
+// does. A file that fails to load is reported and keeps its current values.
+func (c *Connection) handleReload(msg protocol.Message) (protocol.Message, error) {
+	if err := c.requireAdmin(msg); err != nil {
+		return protocol.Message{}, err
+	}
+
+	if err := c.server.Reload(); err != nil {
+		return protocol.Message{}, errs.New(errs.ErrInvalidValue, "reload failed: %v", err)
+	}
+	return ackMessage(), nil
+}
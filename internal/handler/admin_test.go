@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/idgen"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+	"github.com/Artimus100/mcp-server-go/internal/utils"
+)
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of loggers
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// newAdminServer starts a server with an admin socket, logging to log
+func newAdminServer(t *testing.T, log *syncBuffer) *Server {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.Port = 0
+	cfg.AdminSocket = filepath.Join(t.TempDir(), "admin.sock")
+	return startTestServer(t, NewServer(cfg, state.NewContextStore(), utils.NewLoggerTo(log, "test")))
+}
+
+// dialAdmin connects to srv's admin socket and completes a HELLO
+func dialAdmin(t *testing.T, srv *Server) *testConn {
+	t.Helper()
+
+	conn, err := net.DialTimeout("unix", srv.cfg.AdminSocket, testTimeout)
+	if err != nil {
+		t.Fatalf("dial admin socket: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	c := &testConn{t: t, conn: conn, reader: bufio.NewReader(conn)}
+	c.expect("HELLO:version="+config.ProtocolVersion, protocol.TypeHello)
+	return c
+}
+
+func TestLogLevelReachesExistingConnections(t *testing.T) {
+	var log syncBuffer
+	srv := newAdminServer(t, &log)
+
+	client := dialHello(t, srv, "")
+	admin := dialAdmin(t, srv)
+
+	admin.expect("LOGLEVEL:level=error", protocol.TypeAck)
+	client.expect("GET:key=before-debug", protocol.TypeResult)
+	if strings.Contains(log.String(), "before-debug") {
+		t.Fatal("connection opened earlier still logs at INFO after LOGLEVEL error")
+	}
+
+	admin.expect("LOGLEVEL:level=debug", protocol.TypeAck)
+	client.expect("GET:key=after-debug", protocol.TypeResult)
+	if !strings.Contains(log.String(), "after-debug") {
+		t.Fatal("connection opened earlier does not log after LOGLEVEL debug")
+	}
+}
+
+func TestAuditIgnoresLogLevel(t *testing.T) {
+	var log syncBuffer
+	srv := newAdminServer(t, &log)
+	admin := dialAdmin(t, srv)
+
+	admin.expect("LOGLEVEL:level=error", protocol.TypeAck)
+	admin.request("KICK:conn=nobody")
+	if !strings.Contains(log.String(), "map[conn:nobody]") {
+		t.Fatalf("admin command not audited at ERROR level:\n%s", log.String())
+	}
+}
+
+func TestLogLevelRequiresAdminSocket(t *testing.T) {
+	var log syncBuffer
+	srv := newAdminServer(t, &log)
+
+	dialHello(t, srv, "").expectError("LOGLEVEL:level=debug", "unauthorized")
+}
+
+// connectionIDs returns the IDs in a connection snapshot, in order
+func connectionIDs(infos []ConnectionInfo) []string {
+	ids := make([]string, len(infos))
+	for i, info := range infos {
+		ids[i] = info.ID
+	}
+	return ids
+}
+
+func TestActiveConnectionsIsSnapshot(t *testing.T) {
+	clock := newTestClock()
+	srv := newUnstartedServer(t, nil)
+	srv.SetClock(clock.Now)
+	srv.SetIDGenerator(&idgen.Sequential{})
+	startTestServer(t, srv)
+
+	var clients []*testConn
+	for i := 0; i < 3; i++ {
+		clients = append(clients, dialHello(t, srv, ""))
+		clock.Advance(time.Second)
+	}
+
+	snapshot := srv.ActiveConnections()
+	if got, want := connectionIDs(snapshot), []string{"conn-1", "conn-2", "conn-3"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("IDs = %q, want %q oldest first", got, want)
+	}
+	for i, info := range snapshot {
+		if info.RemoteAddr != clients[i].conn.LocalAddr().String() {
+			t.Errorf("%s: RemoteAddr %s, want %s", info.ID, info.RemoteAddr, clients[i].conn.LocalAddr())
+		}
+		if info.Admin {
+			t.Errorf("%s reported as admin", info.ID)
+		}
+	}
+
+	clients[1].conn.Close()
+	waitFor(t, func() bool { return srv.ConnectionCount() == 2 })
+	dialHello(t, srv, "")
+	snapshot[0].ID = "changed"
+
+	if got := connectionIDs(snapshot); !reflect.DeepEqual(got, []string{"changed", "conn-2", "conn-3"}) {
+		t.Fatalf("snapshot changed to %q", got)
+	}
+	if got, want := connectionIDs(srv.ActiveConnections()), []string{"conn-1", "conn-3", "conn-4"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("IDs = %q, want %q", got, want)
+	}
+}
+
+func TestReloadRereadsConfiguredFiles(t *testing.T) {
+	dir := t.TempDir()
+	tokens := filepath.Join(dir, "tokens.json")
+	template := filepath.Join(dir, "template.json")
+	write := func(path, contents string) {
+		if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(tokens, `{"alice": "first"}`)
+	write(template, `{"region": "eu"}`)
+
+	cfg := config.Default()
+	cfg.Port = 0
+	cfg.AdminSocket = filepath.Join(dir, "admin.sock")
+	cfg.AuthTokenFile = tokens
+	cfg.ContextTemplate = template
+	srv := startTestServer(t, NewServer(cfg, state.NewContextStore(), utils.NewLoggerTo(io.Discard, "test")))
+	admin := dialAdmin(t, srv)
+
+	// region reports the template value seen by a new client
+	// authenticating with token
+	clients := 0
+	region := func(token string) string {
+		clients++
+		c := dial(t, srv)
+		c.expect("AUTH:token="+token, protocol.TypeAck)
+		c.expect("HELLO:version="+config.ProtocolVersion+";client_id=c"+strconv.Itoa(clients), protocol.TypeHello)
+		return c.expect("GET:key=region", protocol.TypeResult).Params["region"]
+	}
+
+	admin.expect("RELOAD:", protocol.TypeAck)
+	if got := region("first"); got != "eu" {
+		t.Fatalf("region = %q after RELOAD, want eu", got)
+	}
+
+	write(tokens, `{"alice": "second"}`)
+	write(template, `{"region": "us"}`)
+	admin.expect("RELOAD:", protocol.TypeAck)
+	dial(t, srv).expectError("AUTH:token=first", protocol.ReasonUnauthenticated)
+	if got := region("second"); got != "us" {
+		t.Fatalf("region = %q after the second RELOAD, want us", got)
+	}
+
+	// A file that fails to load is reported and keeps its current values
+	write(template, `{`)
+	admin.expectError("RELOAD:", protocol.ReasonInvalidValue)
+	if got := region("second"); got != "us" {
+		t.Fatalf("region = %q after a failed RELOAD, want us kept", got)
+	}
+
+	c := dial(t, srv)
+	c.expect("AUTH:token=second", protocol.TypeAck)
+	c.expect("HELLO:version="+config.ProtocolVersion, protocol.TypeHello)
+	c.expectError("RELOAD:", protocol.ReasonUnauthorized)
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"crypto/subtle"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/errs"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// SetAuthTokens sets the tokens clients authenticate with in AUTH, mapped
+// to the client name each authenticates as. While any are set, AUTH must be
+// the first message on every client connection. It may be called while the
+// server is running, as on a reload; connections already authenticated keep
+// their client name. A nil or empty map disables authentication.
+func (s *Server) SetAuthTokens(tokens map[string]string) {
+	copied := make(map[string]string, len(tokens))
+	for token, principal := range tokens {
+		copied[token] = principal
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authTokens = copied
+}
+
+// AuthFunc decides whether a client may complete the HELLO handshake from
+// its HELLO parameters, such as a token. It returns the client ID to store
+// the client's context under, in place of any client_id the client asked
+// for, or "" to leave the choice to the client. An error refuses the
+// client, which is sent ERROR reason=unauthorized and disconnected.
+type AuthFunc func(params map[string]string) (clientID string, err error)
+
+// SetAuthFunc installs a hook consulted in every HELLO from a client
+// connection. Passing nil removes the hook.
+func (s *Server) SetAuthFunc(fn AuthFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.authFn = fn
+}
+
+// authFunc returns the installed HELLO authentication hook
+func (s *Server) authFunc() AuthFunc {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.authFn
+}
+
+// authorizeHello runs the AuthFunc, if any, on the HELLO params. It returns
+// the client ID the hook binds, or "" if it binds none. A refusal uses up
+// the connection's authentication attempts, so it is closed once told why.
+func (c *Connection) authorizeHello(params map[string]string) (string, error) {
+	fn := c.server.authFunc()
+	if fn == nil || c.admin {
+		return "", nil
+	}
+
+	copied := make(map[string]string, len(params))
+	for key, value := range params {
+		copied[key] = value
+	}
+	clientID, err := fn(copied)
+	if err != nil {
+		c.authFailures = config.MaxAuthFailures
+		return "", errs.New(errs.ErrUnauthorized, "%v", err)
+	}
+	return clientID, nil
+}
+
+// authRequired reports whether clients must authenticate
+func (s *Server) authRequired() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.authTokens) > 0
+}
+
+// principalFor returns the client name token authenticates as. Every
+// configured token is compared in constant time, so the time taken does
+// not reveal how much of a token was right.
+func (s *Server) principalFor(token string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var principal string
+	found := false
+	for candidate, name := range s.authTokens {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(token)) == 1 {
+			principal, found = name, true
+		}
+	}
+	return principal, found
+}
+
+// unauthenticated reports whether the connection must authenticate before
+// anything else is handled. Admin connections are trusted by the socket's
+// permissions instead.
+func (c *Connection) unauthenticated() bool {
+	return c.principal == "" && !c.admin && c.server.authRequired()
+}
+
+// handleAuth authenticates the connection with the token parameter,
+// replying with the client name it authenticates as. Every rejected
+// attempt counts towards MaxAuthFailures. Without configured tokens AUTH is
+// accepted and ignored, so clients may send it regardless.
+func (c *Connection) handleAuth(msg protocol.Message) (protocol.Message, error) {
+	if c.principal != "" {
+		return protocol.Message{}, errs.New(errs.ErrAlreadyNegotiated, "already authenticated as %s", c.principal)
+	}
+	if !c.unauthenticated() {
+		return ackMessage(), nil
+	}
+
+	principal, ok := c.server.principalFor(msg.Params["token"])
+	if !ok {
+		c.authFailures++
+		return protocol.Message{}, errs.New(errs.ErrUnauthenticated, "invalid token")
+	}
+	c.principal = principal
+	c.logger.Info("Authenticated as %s", principal)
+
+	response := ackMessage()
+	response.Params["principal"] = principal
+	return response, nil
+}
+
+// rejectUnauthenticated refuses a message sent before AUTH, counting it
+// towards MaxAuthFailures
+func (c *Connection) rejectUnauthenticated(msg protocol.Message) {
+	c.logger.Warning("Rejecting %s sent before AUTH", msg.Type)
+	c.authFailures++
+	c.sendError(errs.New(errs.ErrUnauthenticated, "send AUTH with a valid token before any other message"))
+}
+
+// closeIfAuthFailed closes a connection that has failed to authenticate
+// MaxAuthFailures times, once it has been told why
+func (c *Connection) closeIfAuthFailed() {
+	if c.authFailures >= config.MaxAuthFailures {
+		c.logger.Warning("Closing connection after %d failed authentication attempts", c.authFailures)
+		c.Close()
+	}
+}
+
+// redactToken returns the log form of msg, with the token of an AUTH or
+// HELLO message, and the reconnect token of a HELLO, masked
+func redactToken(msg protocol.Message) string {
+	if msg.Type != protocol.TypeAuth && msg.Type != protocol.TypeHello {
+		return msg.String()
+	}
+	masked := protocol.NewMessage(msg.Type, nil)
+	for key, value := range msg.Params {
+		masked.Params[key] = value
+	}
+	for _, param := range []string{"token", "reconnect_token"} {
+		if _, exists := masked.Params[param]; exists {
+			masked.Params[param] = "<redacted>"
+		}
+	}
+	return masked.String()
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+	"github.com/Artimus100/mcp-server-go/internal/utils"
+)
+
+// newAuthServer starts a server that accepts the token s3cret for alice,
+// logging to log
+func newAuthServer(t *testing.T, log *syncBuffer) *Server {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.Port = 0
+	srv := NewServer(cfg, state.NewContextStore(), utils.NewLoggerTo(log, "test"))
+	srv.SetAuthTokens(map[string]string{"s3cret": "alice"})
+	return startTestServer(t, srv)
+}
+
+func TestAuthBeforeHello(t *testing.T) {
+	log := &syncBuffer{}
+	srv := newAuthServer(t, log)
+	c := dial(t, srv)
+
+	if ack := c.expect("AUTH:token=s3cret", protocol.TypeAck); ack.Params["principal"] != "alice" {
+		t.Fatalf("AUTH = %s, want principal=alice", ack)
+	}
+	c.expect("HELLO:version="+config.ProtocolVersion+";client_id=a", protocol.TypeHello)
+	c.expect("CONTEXT:k=v", protocol.TypeAck)
+	c.expectError("AUTH:token=s3cret", protocol.ReasonAlreadyNegotiated)
+
+	if !strings.Contains(log.String(), "Received message from alice: Message{Type: CONTEXT") {
+		t.Errorf("messages not logged with the principal:\n%s", log)
+	}
+	if strings.Contains(log.String(), "s3cret") {
+		t.Errorf("token written to the log:\n%s", log)
+	}
+}
+
+func TestAuthWrongTokenClosesAfterThreeFailures(t *testing.T) {
+	srv := newAuthServer(t, &syncBuffer{})
+	c := dial(t, srv)
+
+	for i := 1; i < config.MaxAuthFailures; i++ {
+		c.expectError("AUTH:token=guess", protocol.ReasonUnauthenticated)
+	}
+	c.send("AUTH:token=s3cre")
+	c.expectClosed()
+}
+
+func TestContextBeforeAuthRejected(t *testing.T) {
+	srv := newAuthServer(t, &syncBuffer{})
+	c := dial(t, srv)
+
+	c.expectError("CONTEXT:k=v", protocol.ReasonUnauthenticated)
+	c.expectError("HELLO:version="+config.ProtocolVersion+";client_id=a", protocol.ReasonUnauthenticated)
+	if clients := srv.store.ListClients(); len(clients) != 0 {
+		t.Fatalf("context stored before AUTH for %v", clients)
+	}
+
+	// Two failures leave one attempt
+	c.expect("AUTH:token=s3cret", protocol.TypeAck)
+	c.expect("HELLO:version="+config.ProtocolVersion+";client_id=a", protocol.TypeHello)
+}
+
+func TestAuthNotRequiredOnAdminSocket(t *testing.T) {
+	srv := newAdminServer(t, &syncBuffer{})
+	srv.SetAuthTokens(map[string]string{"s3cret": "alice"})
+
+	dial(t, srv).expectError("PING:", protocol.ReasonUnauthenticated)
+	dialAdmin(t, srv).expect("PING:", protocol.TypePong)
+}
+
+// tokenAuth admits HELLOs carrying a known token as the client it names,
+// and anonymous HELLOs with no token at all
+func tokenAuth(params map[string]string) (string, error) {
+	switch params["token"] {
+	case "":
+		return "", nil
+	case "t-bob":
+		return "bob", nil
+	default:
+		return "", errors.New("unknown token")
+	}
+}
+
+func TestAuthFuncBindsClientID(t *testing.T) {
+	srv := newUnstartedServer(t, nil)
+	srv.SetAuthFunc(tokenAuth)
+	startTestServer(t, srv)
+
+	// The hook's client ID wins over the one asked for
+	c := dialHello(t, srv, "token=t-bob;client_id=mallory")
+	c.expect("CONTEXT:k=v", protocol.TypeAck)
+	if value, _ := srv.store.Get("bob", "k"); value != "v" {
+		t.Fatal("context not stored under the client ID the hook bound")
+	}
+	if _, ok := srv.store.Get("mallory", "k"); ok {
+		t.Fatal("context stored under the client ID the client asked for")
+	}
+
+	// An empty ID leaves the choice to the client
+	dialHello(t, srv, "client_id=carol").expect("CONTEXT:k=v", protocol.TypeAck)
+	if _, ok := srv.store.Get("carol", "k"); !ok {
+		t.Fatal("context not stored under the client's own ID")
+	}
+}
+
+func TestAuthFuncRefusalCloses(t *testing.T) {
+	srv := newUnstartedServer(t, nil)
+	srv.SetAuthFunc(tokenAuth)
+	startTestServer(t, srv)
+
+	c := dial(t, srv)
+	reply := c.expectError("HELLO:version="+config.ProtocolVersion+";token=forged;client_id=bob", protocol.ReasonUnauthorized)
+	if !strings.Contains(reply.Params["detail"], "unknown token") {
+		t.Errorf("detail %q does not carry the hook's error", reply.Params["detail"])
+	}
+	c.expectClosed()
+	if clients := srv.store.ListClients(); len(clients) != 0 {
+		t.Fatalf("refused HELLO bound %v", clients)
+	}
+}
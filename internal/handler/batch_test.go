@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+func TestContextAppliedWhole(t *testing.T) {
+	srv := newTestServer(t, nil)
+	c := dialHello(t, srv, "client_id=batch")
+
+	params := make([]string, 10)
+	for i := range params {
+		params[i] = "k" + strconv.Itoa(i) + "=" + strconv.Itoa(i)
+	}
+	c.expect("CONTEXT:"+strings.Join(params, ";"), protocol.TypeAck)
+
+	// Everything is stored by the time the ACK is sent
+	all, _ := srv.store.GetAll("batch")
+	if len(all) != 10 {
+		t.Fatalf("%d keys stored at ACK, want 10", len(all))
+	}
+	for i := range params {
+		if key := "k" + strconv.Itoa(i); all[key] != strconv.Itoa(i) {
+			t.Errorf("%s = %q, want %d", key, all[key], i)
+		}
+	}
+}
+
+func TestContextRejectedWhole(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"misspelt parameter", "CONTEXT:a=2;b=2;_tll=5s"},
+		{"bad ttl", "CONTEXT:a=2;b=2;_ttl=soon"},
+		{"ttl with schedule", "CONTEXT:a=2;b=2;_ttl=5s;_in=5s"},
+		{"bad persist", "CONTEXT:a=2;b=2;_persist=maybe"},
+	}
+
+	srv := newTestServer(t, nil)
+	c := dialHello(t, srv, "client_id=batch")
+	c.expect("CONTEXT:a=1;b=1", protocol.TypeAck)
+	for _, tt := range tests {
+		c.expectError(tt.line, protocol.ReasonInvalidParams)
+		if all, _ := srv.store.GetAll("batch"); len(all) != 2 || all["a"] != "1" || all["b"] != "1" {
+			t.Errorf("%s: context = %v after the rejection, want it unchanged", tt.name, all)
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"sort"
+	"strconv"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/errs"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// Values too large for a single message travel in chunks. A transfer is
+// announced with a BEGIN message giving the key, the value size in bytes
+// and the number of chunks. CHUNK messages follow, each with an index
+// counting from 0 and its share of the value's bytes base64-encoded
+// (unpadded) in a data parameter. An END message carrying the hex SHA-256
+// of the whole value closes the transfer.
+//
+// Reads: when a GET reply would exceed the message size limit, the largest
+// values are sent as VALUE_BEGIN/VALUE_CHUNK/VALUE_END transfers before the
+// RESULT, which lists their keys in a _streamed parameter.
+//
+// Writes: a client sends CONTEXT_BEGIN, which is acknowledged, then its
+// CONTEXT_CHUNKs, which are not, then CONTEXT_END. The value is stored only
+// once END has verified the size, chunk count and hash; any error, or the
+// connection closing, abandons the transfer without storing anything.
+
+// chunkOverhead is the room left in each chunk message for its type, index
+// and parameter separators
+const chunkOverhead = 64
+
+// upload is a value being received in chunks
+type upload struct {
+	key    string
+	size   int
+	chunks int
+	next   int // index of the next expected chunk
+	data   []byte
+}
+
+// chunkSize returns how many value bytes fit in each chunk message for key
+// under limit, or zero if even the key does not leave room
+func chunkSize(key string, limit int) int {
+	room := limit - chunkOverhead - len(protocol.EscapeValue(key))
+	// Four base64 characters carry three bytes
+	return room / 4 * 3
+}
+
+// checksum returns the hex SHA-256 of a value, as carried by END messages
+func checksum(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// streamLargeValues moves values out of a GET reply, largest first, until
+// the reply fits the connection's message size limit, sending each as a
+// chunked transfer. The keys sent are listed in a _streamed parameter.
+func (c *Connection) streamLargeValues(values map[string]string) error {
+	limit := c.messageSizeLimit()
+	if len(protocol.NewMessage(protocol.TypeResult, values).Format()) <= limit {
+		return nil
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return len(values[keys[i]]) > len(values[keys[j]])
+	})
+
+	var streamed []string
+	for _, key := range keys {
+		if err := c.streamValue(key, values[key], limit); err != nil {
+			return err
+		}
+		delete(values, key)
+		streamed = append(streamed, key)
+
+		values["_streamed"] = protocol.JoinList(streamed)
+		if len(protocol.NewMessage(protocol.TypeResult, values).Format()) <= limit {
+			return nil
+		}
+	}
+	return nil
+}
+
+// streamValue sends one value as a VALUE_BEGIN/VALUE_CHUNK/VALUE_END
+// transfer
+func (c *Connection) streamValue(key, value string, limit int) error {
+	size := chunkSize(key, limit)
+	if size <= 0 {
+		return errs.New(errs.ErrMessageTooLarge, "key %s is too long to stream within %d bytes", key, limit)
+	}
+
+	chunks := (len(value) + size - 1) / size
+	err := c.Send(protocol.NewMessage(protocol.TypeValueBegin, map[string]string{
+		"key":    key,
+		"size":   strconv.Itoa(len(value)),
+		"chunks": strconv.Itoa(chunks),
+	}))
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < chunks; i++ {
+		end := (i + 1) * size
+		if end > len(value) {
+			end = len(value)
+		}
+		err := c.Send(protocol.NewMessage(protocol.TypeValueChunk, map[string]string{
+			"key":   key,
+			"index": strconv.Itoa(i),
+			"data":  base64.RawStdEncoding.EncodeToString([]byte(value[i*size : end])),
+		}))
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.Send(protocol.NewMessage(protocol.TypeValueEnd, map[string]string{
+		"key":    key,
+		"sha256": checksum(value),
+	}))
+}
+
+// handleContextBegin starts receiving a value in chunks. Only one transfer
+// may be in progress per connection.
+func (c *Connection) handleContextBegin(msg protocol.Message) (protocol.Message, error) {
+	if c.upload != nil {
+		return protocol.Message{}, errs.New(errs.ErrInvalidParams, "transfer of key %s already in progress", c.upload.key)
+	}
+
+	key := msg.Params["key"]
+	if key == "" {
+		return protocol.Message{}, errs.New(errs.ErrInvalidParams, "CONTEXT_BEGIN needs a key parameter")
+	}
+	size, err := strconv.Atoi(msg.Params["size"])
+	if err != nil || size < 0 {
+		return protocol.Message{}, errs.New(errs.ErrInvalidParams, "size must be a non-negative integer, got %q", msg.Params["size"])
+	}
+	if size > config.MaxStreamedValueSize {
+		return protocol.Message{}, errs.New(errs.ErrMessageTooLarge, "value of %d bytes exceeds the limit of %d", size, config.MaxStreamedValueSize)
+	}
+	chunks, err := strconv.Atoi(msg.Params["chunks"])
+	if err != nil || chunks < 0 || chunks > size {
+		return protocol.Message{}, errs.New(errs.ErrInvalidParams, "chunks must be between 0 and size, got %q", msg.Params["chunks"])
+	}
+
+	c.upload = &upload{
+		key:    key,
+		size:   size,
+		chunks: chunks,
+		data:   make([]byte, 0, size),
+	}
+	return ackMessage(), nil
+}
+
+// handleContextChunk appends a chunk to the transfer in progress. Accepted
+// chunks get no reply; a bad chunk abandons the transfer.
+func (c *Connection) handleContextChunk(msg protocol.Message) (protocol.Message, error) {
+	up := c.upload
+	if up == nil {
+		return protocol.Message{}, errs.New(errs.ErrInvalidParams, "no transfer in progress")
+	}
+
+	if key := msg.Params["key"]; key != up.key {
+		c.upload = nil
+		return protocol.Message{}, errs.New(errs.ErrInvalidParams, "chunk for key %s during transfer of %s, transfer abandoned", key, up.key)
+	}
+	if index := msg.Params["index"]; index != strconv.Itoa(up.next) {
+		c.upload = nil
+		return protocol.Message{}, errs.New(errs.ErrInvalidParams, "expected chunk %d of %s, got %q, transfer abandoned", up.next, up.key, index)
+	}
+	data, err := base64.RawStdEncoding.DecodeString(msg.Params["data"])
+	if err != nil {
+		c.upload = nil
+		return protocol.Message{}, errs.New(errs.ErrInvalidParams, "chunk %d of %s is not valid base64, transfer abandoned", up.next, up.key)
+	}
+	if up.next >= up.chunks || len(up.data)+len(data) > up.size {
+		c.upload = nil
+		return protocol.Message{}, errs.New(errs.ErrInvalidParams, "chunk %d of %s exceeds the announced size, transfer abandoned", up.next, up.key)
+	}
+
+	up.data = append(up.data, data...)
+	up.next++
+	return protocol.Message{}, nil
+}
+
+// handleContextEnd verifies the transfer in progress and stores the value
+func (c *Connection) handleContextEnd(msg protocol.Message) (protocol.Message, error) {
+	up := c.upload
+	if up == nil {
+		return protocol.Message{}, errs.New(errs.ErrInvalidParams, "no transfer in progress")
+	}
+	// Whatever the outcome, the transfer is over
+	c.upload = nil
+
+	if key := msg.Params["key"]; key != up.key {
+		return protocol.Message{}, errs.New(errs.ErrInvalidParams, "end of key %s during transfer of %s, transfer abandoned", key, up.key)
+	}
+	if up.next != up.chunks || len(up.data) != up.size {
+		return protocol.Message{}, errs.New(errs.ErrInvalidValue, "received %d of %d chunks and %d of %d bytes for %s, transfer abandoned",
+			up.next, up.chunks, len(up.data), up.size, up.key)
+	}
+
+	value := string(up.data)
+	if msg.Params["sha256"] != checksum(value) {
+		return protocol.Message{}, errs.New(errs.ErrInvalidValue, "checksum mismatch for %s, transfer abandoned", up.key)
+	}
+
+	values := map[string]string{up.key: value}
+	if err := c.server.keySpecs.validate(values); err != nil {
+		return protocol.Message{}, err
+	}
+
+	if err := c.takeMutations(1); err != nil {
+		return protocol.Message{}, err
+	}
+
+	c.store.Set(c.clientID, up.key, value)
+	return ackMessage(), nil
+}
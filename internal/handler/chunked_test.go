@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// uploadLines returns the CONTEXT_BEGIN, CONTEXT_CHUNK and CONTEXT_END lines
+// that write value to key in chunks of n bytes
+func uploadLines(key, value string, n int) []string {
+	chunks := (len(value) + n - 1) / n
+	lines := []string{protocol.NewMessage(protocol.TypeContextBegin, map[string]string{
+		"key":    key,
+		"size":   strconv.Itoa(len(value)),
+		"chunks": strconv.Itoa(chunks),
+	}).Format()}
+	for i := 0; i < chunks; i++ {
+		end := min((i+1)*n, len(value))
+		lines = append(lines, protocol.NewMessage(protocol.TypeContextChunk, map[string]string{
+			"key":   key,
+			"index": strconv.Itoa(i),
+			"data":  base64.RawStdEncoding.EncodeToString([]byte(value[i*n : end])),
+		}).Format())
+	}
+	return append(lines, protocol.NewMessage(protocol.TypeContextEnd, map[string]string{
+		"key":    key,
+		"sha256": checksum(value),
+	}).Format())
+}
+
+// sendUpload sends lines followed by a PING and returns every reply before
+// the PONG
+func sendUpload(c *testConn, lines []string) []protocol.Message {
+	c.t.Helper()
+
+	for _, line := range lines {
+		c.send(line)
+	}
+	c.send("PING:")
+	var replies []protocol.Message
+	for {
+		reply := c.recv()
+		if reply.Type == protocol.TypePong {
+			return replies
+		}
+		replies = append(replies, reply)
+	}
+}
+
+// largeValue is bigger than the default message size limit
+var largeValue = strings.Repeat("0123456789abcdef", 625)
+
+func TestGetStreamsLargeValues(t *testing.T) {
+	srv := newTestServer(t, nil)
+	c := dialHello(t, srv, "client_id=dl")
+	srv.store.SetMultiple("dl", map[string]string{"big": largeValue, "small": "x"})
+
+	c.send("GET:")
+	var data []byte
+	begin := c.recv()
+	if begin.Type != protocol.TypeValueBegin || begin.Params["key"] != "big" || begin.Params["size"] != strconv.Itoa(len(largeValue)) {
+		t.Fatalf("got %s, want VALUE_BEGIN for big", begin)
+	}
+	chunks, _ := strconv.Atoi(begin.Params["chunks"])
+	for i := 0; i < chunks; i++ {
+		chunk := c.recv()
+		if chunk.Type != protocol.TypeValueChunk || chunk.Params["index"] != strconv.Itoa(i) {
+			t.Fatalf("got %s, want VALUE_CHUNK %d", chunk, i)
+		}
+		if size := len(chunk.Format()); size > config.Default().MaxMessageSize {
+			t.Fatalf("chunk %d is %d bytes, over the message size limit", i, size)
+		}
+		decoded, err := base64.RawStdEncoding.DecodeString(chunk.Params["data"])
+		if err != nil {
+			t.Fatalf("chunk %d: %v", i, err)
+		}
+		data = append(data, decoded...)
+	}
+	end := c.recv()
+	if end.Type != protocol.TypeValueEnd || end.Params["sha256"] != checksum(string(data)) || string(data) != largeValue {
+		t.Fatalf("got %s after %d bytes, want a VALUE_END matching the value", end, len(data))
+	}
+
+	// Values that fit stay in the reply
+	reply := c.recv()
+	if reply.Type != protocol.TypeResult || reply.Params["_streamed"] != "big" || reply.Params["small"] != "x" {
+		t.Fatalf("got %s, want a RESULT listing big as streamed and small inline", reply)
+	}
+	if _, inline := reply.Params["big"]; inline {
+		t.Fatalf("RESULT carries the streamed value inline too")
+	}
+}
+
+func TestChunkedUploadStoresVerifiedValue(t *testing.T) {
+	srv := newTestServer(t, nil)
+	c := dialHello(t, srv, "client_id=up")
+
+	replies := sendUpload(c, uploadLines("big", largeValue, 3000))
+	if len(replies) != 2 || replies[0].Type != protocol.TypeAck || replies[1].Type != protocol.TypeAck {
+		t.Fatalf("replies = %v, want ACKs for BEGIN and END only", replies)
+	}
+	if got, _ := srv.store.Get("up", "big"); got != largeValue {
+		t.Fatalf("stored %d bytes, want %d", len(got), len(largeValue))
+	}
+}
+
+func TestChunkedUploadFailuresStoreNothing(t *testing.T) {
+	other := checksum("something else")
+
+	tests := []struct {
+		name   string
+		change func(lines []string) []string
+		reason string
+	}{
+		{"hash mismatch", func(lines []string) []string {
+			lines[len(lines)-1] = "CONTEXT_END:key=big;sha256=" + other
+			return lines
+		}, protocol.ReasonInvalidValue},
+		{"missing chunk", func(lines []string) []string {
+			return append(lines[:2:2], lines[len(lines)-1])
+		}, protocol.ReasonInvalidValue},
+		{"chunk out of order", func(lines []string) []string {
+			lines[1], lines[2] = lines[2], lines[1]
+			return lines
+		}, protocol.ReasonInvalidParams},
+		{"chunk for another key", func(lines []string) []string {
+			lines[1] = strings.Replace(lines[1], "key=big", "key=other", 1)
+			return lines
+		}, protocol.ReasonInvalidParams},
+		{"bad base64", func(lines []string) []string {
+			lines[2] = "CONTEXT_CHUNK:data=@@@@;index=1;key=big"
+			return lines
+		}, protocol.ReasonInvalidParams},
+		{"more data than announced", func(lines []string) []string {
+			lines[0] = strings.Replace(lines[0], "size="+strconv.Itoa(len(largeValue)), "size=4000", 1)
+			return lines
+		}, protocol.ReasonInvalidParams},
+		{"size over the quota", func(lines []string) []string {
+			lines[0] = strings.Replace(lines[0], "size="+strconv.Itoa(len(largeValue)), "size="+strconv.Itoa(config.MaxStreamedValueSize+1), 1)
+			return lines
+		}, protocol.ReasonMessageTooLarge},
+	}
+
+	srv := newTestServer(t, nil)
+	for _, tt := range tests {
+		c := dialHello(t, srv, "client_id=up")
+		replies := sendUpload(c, tt.change(uploadLines("big", largeValue, 3000)))
+
+		var reason string
+		for _, reply := range replies {
+			if reply.Type == protocol.TypeError {
+				reason = reply.Params["reason"]
+				break
+			}
+		}
+		if reason != tt.reason {
+			t.Errorf("%s: replies = %v, want a first error of %s", tt.name, replies, tt.reason)
+		}
+		if value, stored := srv.store.Get("up", "big"); stored {
+			t.Errorf("%s: %d bytes stored after a failed transfer", tt.name, len(value))
+		}
+		c.conn.Close()
+		waitFor(t, func() bool { return srv.ConnectionCount() == 0 })
+	}
+}
+
+func TestChunkedUploadAbandonedOnDisconnect(t *testing.T) {
+	srv := newTestServer(t, nil)
+	c := dialHello(t, srv, "client_id=up")
+
+	lines := uploadLines("big", largeValue, 3000)
+	c.expect(lines[0], protocol.TypeAck)
+	for _, line := range lines[1 : len(lines)-1] {
+		c.send(line)
+	}
+	c.conn.Close()
+	waitFor(t, func() bool { return srv.ConnectionCount() == 0 })
+
+	if _, stored := srv.store.Get("up", "big"); stored {
+		t.Fatal("value stored from a transfer cut off before CONTEXT_END")
+	}
+
+	// The next connection starts with no transfer in progress
+	c = dialHello(t, srv, "client_id=up")
+	c.expectError(lines[len(lines)-1], protocol.ReasonInvalidParams)
+	if _, stored := srv.store.Get("up", "big"); stored {
+		t.Fatal("value stored by an END on a new connection")
+	}
+}
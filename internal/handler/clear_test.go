@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// clearOnClose enables clearing a client's context when it disconnects
+func clearOnClose(cfg *config.Config) {
+	cfg.ClearOnClose = true
+}
+
+func TestContextClearedOnClose(t *testing.T) {
+	srv := newTestServer(t, clearOnClose)
+	c := dialHello(t, srv, "client_id=short-lived")
+	c.expect("CONTEXT:k=v", protocol.TypeAck)
+	if !slices.Contains(srv.store.ListClients(), "short-lived") {
+		t.Fatal("client not listed while connected")
+	}
+
+	c.conn.Close()
+	waitFor(t, func() bool { return !slices.Contains(srv.store.ListClients(), "short-lived") })
+}
+
+func TestPersistKeepsContextOnClose(t *testing.T) {
+	srv := newTestServer(t, clearOnClose)
+	c := dialHello(t, srv, "client_id=sticky")
+	c.expect("CONTEXT:k=v;_persist=true", protocol.TypeAck)
+	c.expectError("CONTEXT:k=v;_persist=sometimes", protocol.ReasonInvalidParams)
+	c.conn.Close()
+	waitFor(t, func() bool { return srv.ConnectionCount() == 0 })
+
+	c = dialHello(t, srv, "client_id=sticky")
+	if reply := c.expect("GET:key=k", protocol.TypeResult); reply.Params["k"] != "v" {
+		t.Fatalf("GET after reconnecting = %s, want the persisted value", reply)
+	}
+
+	// Persistence can be withdrawn again
+	c.expect("CONTEXT:k=w;_persist=false", protocol.TypeAck)
+	c.conn.Close()
+	waitFor(t, func() bool { return !slices.Contains(srv.store.ListClients(), "sticky") })
+}
+
+func TestContextKeptOnCloseByDefault(t *testing.T) {
+	srv := newTestServer(t, nil)
+	c := dialHello(t, srv, "client_id=kept")
+	c.expect("CONTEXT:k=v", protocol.TypeAck)
+	c.conn.Close()
+	waitFor(t, func() bool { return srv.ConnectionCount() == 0 })
+
+	if reply := dialHello(t, srv, "client_id=kept").expect("GET:key=k", protocol.TypeResult); reply.Params["k"] != "v" {
+		t.Fatalf("GET after reconnecting = %s, want the value kept", reply)
+	}
+}
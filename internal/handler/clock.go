@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/errs"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// SetClock replaces the clock used for reported times, connection ages,
+// liveness checks, schedule times and the expiry of idempotency keys and
+// sequence numbers, so tests can control time instead of sleeping. Uptime
+// is measured from the call. Read and write deadlines are enforced by the
+// operating system and keep using the system clock. It must be called
+// before Start.
+func (s *Server) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+	s.startedAt = now()
+	s.idempotency.now = now
+	s.sequences.now = now
+}
+
+// timeParams returns the server's wall clock time and monotonic uptime in
+// the form reported by PONG, HELLO and TIME
+func (s *Server) timeParams(now time.Time) map[string]string {
+	return map[string]string{
+		"time":        strconv.FormatInt(now.Unix(), 10),
+		"server_time": now.UTC().Format(time.RFC3339Nano),
+		"uptime_ms":   strconv.FormatInt(now.Sub(s.startedAt).Milliseconds(), 10),
+	}
+}
+
+// ClockOutliers returns how many TIME requests carried a client timestamp
+// further from the server clock than config.MaxClockSkew
+func (s *Server) ClockOutliers() uint64 {
+	return s.clockOutliers.Load()
+}
+
+// handleTime reports the server clock. If the client includes its own send
+// time as an RFC 3339 sent parameter, the reply also carries delay_ms, the
+// apparent one-way delay: the true network delay plus the clock skew between
+// client and server. Delays beyond config.MaxClockSkew in either direction
+// are capped, flagged with skew_capped and counted as outliers, so a client
+// with a wildly wrong clock cannot report arbitrary values.
+func (c *Connection) handleTime(msg protocol.Message) (protocol.Message, error) {
+	now := c.server.now()
+	params := c.server.timeParams(now)
+
+	if raw, exists := msg.Params["sent"]; exists {
+		sent, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			return protocol.Message{}, errs.New(errs.ErrInvalidParams, "sent is not an RFC 3339 time: %s", raw)
+		}
+
+		delay := now.Sub(sent)
+		limit := config.MaxClockSkew * time.Second
+		if delay > limit || delay < -limit {
+			c.server.clockOutliers.Add(1)
+			c.logger.Warning("Client clock is %v away from server clock", delay)
+			if delay > limit {
+				delay = limit
+			} else {
+				delay = -limit
+			}
+			params["skew_capped"] = "true"
+		}
+		params["delay_ms"] = strconv.FormatInt(delay.Milliseconds(), 10)
+	}
+
+	return protocol.NewMessage(protocol.TypeTime, params), nil
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+func TestServerTimeReported(t *testing.T) {
+	clock := newTestClock()
+	srv := newUnstartedServer(t, nil)
+	srv.SetClock(clock.Now)
+	startTestServer(t, srv)
+
+	clock.Advance(1500 * time.Millisecond)
+	hello := protocol.NewMessage(protocol.TypeHello, map[string]string{"version": config.ProtocolVersion})
+	c := dial(t, srv)
+	for _, reply := range []protocol.Message{
+		c.expect(hello.Format(), protocol.TypeHello),
+		c.expect("PING:", protocol.TypePong),
+		c.expect("PING:id=1", protocol.TypePong),
+		c.expect("TIME:", protocol.TypeTime),
+	} {
+		if reply.Params["server_time"] != "2024-01-01T00:00:01.5Z" || reply.Params["uptime_ms"] != "1500" {
+			t.Errorf("%s: want server_time 2024-01-01T00:00:01.5Z and uptime_ms 1500", reply)
+		}
+	}
+}
+
+func TestTimeReportsDelayAgainstClientClock(t *testing.T) {
+	limit := config.MaxClockSkew * time.Second
+	tests := []struct {
+		name   string
+		offset time.Duration // client clock minus server clock
+		delay  time.Duration // network delay
+		want   string
+		capped bool
+	}{
+		{"in sync", 0, 40 * time.Millisecond, "40", false},
+		{"client behind", -2 * time.Second, 40 * time.Millisecond, "2040", false},
+		{"client ahead", 5 * time.Second, 40 * time.Millisecond, "-4960", false},
+		{"at the cap", -limit, 0, "3600000", false},
+		{"far behind", -48 * time.Hour, 0, "3600000", true},
+		{"far ahead", 365 * 24 * time.Hour, 0, "-3600000", true},
+	}
+
+	serverClock := newTestClock()
+	srv := newUnstartedServer(t, nil)
+	srv.SetClock(serverClock.Now)
+	startTestServer(t, srv)
+	c := dialHello(t, srv, "")
+
+	outliers := 0
+	for _, tt := range tests {
+		sent := serverClock.Now().Add(tt.offset).Format(time.RFC3339Nano)
+		serverClock.Advance(tt.delay)
+
+		reply := c.expect("TIME:sent="+sent, protocol.TypeTime)
+		if reply.Params["delay_ms"] != tt.want {
+			t.Errorf("%s: delay_ms = %s, want %s", tt.name, reply.Params["delay_ms"], tt.want)
+		}
+		if capped := reply.Params["skew_capped"] == "true"; capped != tt.capped {
+			t.Errorf("%s: skew_capped = %v, want %v", tt.name, capped, tt.capped)
+		}
+		if tt.capped {
+			outliers++
+		}
+	}
+
+	stats := c.expect("STATS:", protocol.TypeResult)
+	if srv.ClockOutliers() != uint64(outliers) || stats.Params["clock_outliers"] != strconv.Itoa(outliers) {
+		t.Fatalf("ClockOutliers() = %d, STATS %s; want %d", srv.ClockOutliers(), stats, outliers)
+	}
+
+	c.expectError("TIME:sent=yesterday", protocol.ReasonInvalidParams)
+}
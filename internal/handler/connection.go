@@ -2,11 +2,21 @@ package handler
 
 import (
 	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
+	"net/http"
+	"os"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/errs"
+	"github.com/Artimus100/mcp-server-go/internal/idgen"
 	"github.com/Artimus100/mcp-server-go/internal/protocol"
 	"github.com/Artimus100/mcp-server-go/internal/state"
 	"github.com/Artimus100/mcp-server-go/internal/utils"
@@ -14,130 +24,721 @@ import (
 
 // Connection represents a client connection to the MCP server
 type Connection struct {
-	id         string
-	conn       net.Conn
-	store      *state.ContextStore
-	logger     *utils.Logger
-	closeChan  chan struct{}
-	closedOnce sync.Once
+	id            string
+	clientID      string // ID the context is stored under; set under server.mu
+	conn          net.Conn
+	server        *Server
+	store         *state.ContextStore
+	logger        *utils.Logger
+	connectedAt   time.Time
+	events        chan state.ContextEvent
+	drain         atomic.Pointer[drainOp]
+	version       string      // protocol version negotiated with HELLO
+	stopping      atomic.Bool // set by shutdown; no further messages are read
+	closeChan     chan struct{}
+	closedOnce    sync.Once
+	writeMu       sync.Mutex
+	pong          []byte             // reused PONG buffer for fastPing, guarded by writeMu
+	out           *bufio.Writer      // write buffer if FlushInterval is set, guarded by writeMu
+	flushPending  bool               // a timed flush of out is scheduled, guarded by writeMu
+	lineBuf       []byte             // reused buffer for lines spanning reads, owned by Handle
+	maxMessage    int                // message size limit negotiated in HELLO, zero if none
+	upload        *upload            // chunked value being received, owned by Handle
+	queued        []protocol.Message // pushes to send after the current reply, owned by Handle
+	seeded        bool               // context template applied, owned by Handle
+	principal     string             // client name authenticated with AUTH, owned by Handle
+	authFailures  int                // messages rejected for want of authentication, owned by Handle
+	watching      bool               // registered with WATCH_ALL, owned by Handle
+	resumable     int                // durable subscriptions awaiting resumption when HELLO claimed the client ID
+	warned        map[string]bool    // deprecation tags already warned about, owned by Handle
+	request       protocol.Message   // type and correlation id of the message being handled, owned by Handle
+	handling      protocol.Message   // message being handled as dispatched, for panic reports; owned by Handle
+	limiter       *tokenBucket       // message rate limit, nil if unlimited; owned by Handle
+	mutations     *tokenBucket       // key write rate limit, nil if unlimited; owned by Handle
+	mutationLimit mutationLimit      // limit mutations was built for, owned by Handle
+	lastSeen      atomic.Int64       // UnixNano of the last message received
+	noDelay       atomic.Bool        // Nagle's algorithm disabled on the TCP connection
+	delivered     atomic.Uint64      // version of the last context event pushed
+	persist       atomic.Bool        // keep the context on close despite ClearOnClose
+	superseded    atomic.Bool        // client ID taken over by a resuming connection
+	migrated      int                // subscriptions taken over from the previous connection in HELLO
+	admin         bool               // accepted on the admin socket
+	peer          string             // peer credentials of admin connections
 }
 
 // Server handles incoming TCP connections
 type Server struct {
-	port        int
+	cfg         config.Config
 	listener    net.Listener
 	store       *state.ContextStore
 	logger      *utils.Logger
 	connections map[string]*Connection
-	mu          sync.RWMutex
-	closeChan   chan struct{}
+	claims      map[string]string // client_id claimed in HELLO -> connection ID
+
+	// expiryBacklog holds EXPIRED notices for clients with no connection
+	expiryBacklog map[string][]protocol.Message
+
+	// lastUpdate is the most recent change's UPDATE, encoded once for all
+	// of its subscribers
+	lastUpdate atomic.Pointer[encodedUpdate]
+
+	// durable holds durable subscriptions by claimed client ID
+	durable   map[string]*durableSubscriptions
+	mu        sync.RWMutex
+	closeChan chan struct{}
+
+	// clientConns counts connections other than the admin console, guarded
+	// by mu; slotFreed wakes an accept loop paused at the limit
+	clientConns int
+	slotFreed   chan struct{}
+
+	// lifecycle is the server's lifecycle state, guarded by mu
+	lifecycle ServerState
+
+	// loading is set while the store is being restored; denyLoadingReads
+	// also rejects reads during that time
+	loading          atomic.Bool
+	denyLoadingReads atomic.Bool
+
+	// reserveFD is a spare descriptor released to turn clients away when
+	// the process runs out, guarded by reserveMu since both accept loops
+	// and Shutdown use it; fdPressure is set while that is happening
+	reserveMu  sync.Mutex
+	reserveFD  *os.File
+	fdPressure atomic.Bool
+
+	// tlsConfig, if set, makes Start serve TLS
+	tlsConfig *tls.Config
+
+	// adminListener accepts operator connections on a unix socket
+	adminListener net.Listener
+
+	// now is the clock behind reported times, connection ages, liveness
+	// checks, schedules and idempotency expiry; I/O deadlines always use
+	// the system clock. ids generates connection IDs.
+	now func() time.Time
+	ids idgen.Generator
+
+	// startedAt anchors uptime reporting; clockOutliers counts clients
+	// whose clocks are beyond config.MaxClockSkew
+	startedAt     time.Time
+	clockOutliers atomic.Uint64
+
+	// audit records every command issued over the admin socket, at INFO
+	// whatever the server's log level
+	audit *utils.Logger
+
+	// Handler timeouts, keyed by message type, with a fallback default
+	handlerTimeouts       map[string]time.Duration
+	defaultHandlerTimeout time.Duration
+
+	// maxMessageSize is the largest message in bytes, excluding the
+	// delimiter, accepted from a client
+	maxMessageSize int
+
+	// sequences tracks the last inbound sequence number per session
+	sequences *sequenceTracker
+
+	// idempotency remembers responses to messages carrying an idem key
+	idempotency *idempotencyCache
+
+	// sessions holds the reconnect tokens HELLO hands out for claimed
+	// client IDs
+	sessions *state.SessionStore
+
+	// keySpecs constrains the values clients may write
+	keySpecs *keySpecRegistry
+
+	// handlers maps message types to the functions that handle them
+	handlers *handlerRegistry
+
+	// emptyValues controls how empty CONTEXT values are handled
+	emptyValues EmptyValuePolicy
+
+	// template holds default values seeded into new clients' context
+	template map[string]string
+
+	// authTokens maps the tokens clients authenticate with to their names
+	authTokens map[string]string
+
+	// authFn vets HELLO handshakes and may bind the client ID
+	authFn AuthFunc
+
+	// mutationLimits overrides MutationLimit for some client IDs
+	mutationLimits map[string]mutationLimit
+
+	// deprecations counts uses of deprecated constructs by tag
+	deprecations   map[string]uint64
+	deprecationsMu sync.Mutex
+
+	// counters accumulate the totals reported by GetStats; metrics
+	// receives finer-grained instrumentation
+	counters serverCounters
+	metrics  utils.Metrics
+
+	// metricsServer serves metrics over HTTP if MetricsAddr is set
+	metricsServer   *http.Server
+	metricsListener net.Listener
+
+	// lastDrain tracks the progress of the most recent Drain call
+	lastDrain atomic.Pointer[drainOp]
+
+	// Message transform hooks applied on dispatch and send
+	inbound  TransformFunc
+	outbound TransformFunc
 }
 
 // NewServer creates a new MCP server
-func NewServer(port int, store *state.ContextStore, logger *utils.Logger) *Server {
-	return &Server{
-		port:        port,
-		store:       store,
-		logger:      logger,
-		connections: make(map[string]*Connection),
-		closeChan:   make(chan struct{}),
+func NewServer(cfg config.Config, store *state.ContextStore, logger *utils.Logger) *Server {
+	s := &Server{
+		cfg:                   cfg,
+		store:                 store,
+		logger:                logger,
+		audit:                 logger.WithPrefix("audit").WithFixedLevel(utils.INFO),
+		now:                   time.Now,
+		ids:                   idgen.Random{},
+		metrics:               utils.NopMetrics,
+		startedAt:             time.Now(),
+		connections:           make(map[string]*Connection),
+		claims:                make(map[string]string),
+		expiryBacklog:         make(map[string][]protocol.Message),
+		durable:               make(map[string]*durableSubscriptions),
+		mutationLimits:        make(map[string]mutationLimit),
+		closeChan:             make(chan struct{}),
+		slotFreed:             make(chan struct{}, 1),
+		handlerTimeouts:       make(map[string]time.Duration),
+		defaultHandlerTimeout: cfg.HandlerTimeout,
+		maxMessageSize:        cfg.MaxMessageSize,
+		sequences:             newSequenceTracker(config.SequenceTTL * time.Second),
+		idempotency:           newIdempotencyCache(config.IdempotencyCacheSize, config.IdempotencyTTL*time.Second),
+		sessions:              state.NewSessionStore(),
+		keySpecs:              newKeySpecRegistry(),
+		handlers:              newHandlerRegistry(),
+	}
+	store.OnExpiry(s.notifyExpiry)
+	store.OnEventDropped(func(string) { s.recordDrop(DropQueueFull) })
+	return s
+}
+
+// SetTLSConfig makes Start serve TLS with tlsConfig, taking precedence over
+// the certificate and key files in the server configuration. It must be
+// called before Start.
+func (s *Server) SetTLSConfig(tlsConfig *tls.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tlsConfig = tlsConfig
+}
+
+// SetIDGenerator replaces the generator of connection IDs, which are random
+// by default. It must be called before Start.
+func (s *Server) SetIDGenerator(gen idgen.Generator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids = gen
+}
+
+// SetMaxMessageSize sets the largest message in bytes accepted from a client
+func (s *Server) SetMaxMessageSize(size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxMessageSize = size
+}
+
+// SetEmptyValuePolicy controls how CONTEXT parameters with empty values are
+// handled
+func (s *Server) SetEmptyValuePolicy(policy EmptyValuePolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.emptyValues = policy
+}
+
+// emptyValuePolicy returns the configured empty value policy
+func (s *Server) emptyValuePolicy() EmptyValuePolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.emptyValues
+}
+
+// messageSizeLimit returns the largest message accepted from a client
+func (s *Server) messageSizeLimit() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.maxMessageSize
+}
+
+// messageSizeLimit returns the largest message accepted on this connection:
+// the limit negotiated in HELLO, or the server's limit before then
+func (c *Connection) messageSizeLimit() int {
+	if c.maxMessage > 0 {
+		return c.maxMessage
 	}
+	return c.server.messageSizeLimit()
+}
+
+// SetHandlerTimeout overrides the handler timeout for a single message type
+func (s *Server) SetHandlerTimeout(msgType string, timeout time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlerTimeouts[msgType] = timeout
 }
 
-// Start begins listening for connections
+// SetDefaultHandlerTimeout sets the timeout used for message types without
+// an explicit override
+func (s *Server) SetDefaultHandlerTimeout(timeout time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.defaultHandlerTimeout = timeout
+}
+
+// handlerTimeout returns the timeout that applies to a message type
+func (s *Server) handlerTimeout(msgType string) time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if timeout, exists := s.handlerTimeouts[msgType]; exists {
+		return timeout
+	}
+	return s.defaultHandlerTimeout
+}
+
+// Start begins listening for connections on the configured TCP port or
+// listen address, over TLS if a TLS config was set or a certificate and key
+// are configured. A unix socket is removed again by Shutdown. Start
+// returns once the listener is bound, with connections accepted in the
+// background, so Addr reports the bound address, including the port chosen
+// for port 0. A server can only be started once; starting it again returns
+// an error.
 func (s *Server) Start() error {
-	addr := fmt.Sprintf(":%d", s.port)
-	listener, err := net.Listen("tcp", addr)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lifecycle != StateCreated {
+		return fmt.Errorf("cannot start server in state %s", s.lifecycle)
+	}
+
+	tlsConfig := s.tlsConfig
+	if tlsConfig == nil && s.cfg.TLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(s.cfg.TLSCert, s.cfg.TLSKey)
+		if err != nil {
+			return fmt.Errorf("failed to load TLS certificate: %v", err)
+		}
+		tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+
+	network, addr, err := s.cfg.ListenAddress()
+	if err != nil {
+		return err
+	}
+	listener, err := listen(network, addr, s.cfg.SocketMode)
 	if err != nil {
-		return fmt.Errorf("failed to listen on %s: %v", addr, err)
+		return err
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+		s.logger.Info("Serving TLS on %s", addr)
 	}
+
+	if s.cfg.MetricsAddr != "" {
+		if err := s.startMetrics(); err != nil {
+			listener.Close()
+			return err
+		}
+	}
+
+	if s.cfg.AdminSocket != "" {
+		adminListener, err := listenAdmin(s.cfg.AdminSocket)
+		if err != nil {
+			listener.Close()
+			if s.metricsServer != nil {
+				s.metricsServer.Close()
+			}
+			return err
+		}
+		s.adminListener = adminListener
+		s.logger.Info("Admin console listening on %s", s.cfg.AdminSocket)
+		go s.acceptConnections(adminListener, true)
+	}
+
 	s.listener = listener
+	s.lifecycle = StateStarted
+	s.openReserveFD()
 
-	go s.acceptConnections()
+	go s.acceptConnections(listener, false)
+	s.sessions.StartSweeper(s.cfg.SweepInterval)
+	if s.cfg.DataFile != "" && s.cfg.AutosaveInterval > 0 {
+		go s.autosave()
+	}
+	if s.cfg.SelfStatsInterval > 0 {
+		go s.publishSelfStats()
+	}
 	return nil
 }
 
-// Shutdown gracefully stops the server
-func (s *Server) Shutdown() error {
+// Addr returns the address the server listens on for clients, or nil if it
+// has not been started
+func (s *Server) Addr() net.Addr {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// autosave periodically snapshots the context store until shutdown
+func (s *Server) autosave() {
+	ticker := time.NewTicker(s.cfg.AutosaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.closeChan:
+			return
+		case <-ticker.C:
+			if s.Loading() {
+				continue
+			}
+			if err := s.store.Save(s.cfg.DataFile); err != nil {
+				s.logger.Error("Autosave failed: %v", err)
+			}
+		}
+	}
+}
+
+// Shutdown gracefully stops the server. It stops accepting connections,
+// sends each client a GOODBYE and stops reading from it, and gives messages
+// already being handled until ctx is done to finish and send their
+// responses. Connections still open after that are force-closed and an error
+// is returned. Shutting down a server that was never started stops it and
+// returns an error, as does shutting it down twice. The metrics endpoint,
+// if served, is stopped last.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	switch s.lifecycle {
+	case StateCreated:
+		s.lifecycle = StateStopped
+		close(s.closeChan)
+		s.mu.Unlock()
+		return fmt.Errorf("server was never started")
+	case StateShuttingDown, StateStopped:
+		current := s.lifecycle
+		s.mu.Unlock()
+		return fmt.Errorf("cannot shut down server in state %s", current)
+	}
+	s.lifecycle = StateShuttingDown
+	listener := s.listener
+	adminListener := s.adminListener
+	s.mu.Unlock()
+
 	close(s.closeChan)
+	s.sessions.StopSweeper()
+	defer func() {
+		s.mu.Lock()
+		s.lifecycle = StateStopped
+		s.mu.Unlock()
+	}()
+	// Metrics stay up while connections drain, so the drain can be watched
+	defer s.stopMetrics(ctx)
 
-	// Close listener
-	if s.listener != nil {
-		err := s.listener.Close()
-		if err != nil {
-			return err
+	// Close listeners. A failure is reported, but the connections are
+	// still drained and the store saved.
+	var failures []error
+	if listener != nil {
+		if err := listener.Close(); err != nil {
+			s.logger.Error("Failed to close listener: %v", err)
+			failures = append(failures, fmt.Errorf("failed to close listener: %v", err))
 		}
 	}
+	if adminListener != nil {
+		adminListener.Close()
+	}
+	s.closeReserveFD()
 
-	// Clean up connections
+	// Collect connections first rather than closing under the lock, as
+	// closing a connection removes it from the map
+	s.mu.RLock()
+	conns := make([]*Connection, 0, len(s.connections))
+	for _, conn := range s.connections {
+		conns = append(conns, conn)
+	}
+	s.mu.RUnlock()
+
+	goodbye := protocol.NewMessage(protocol.TypeGoodbye, map[string]string{
+		"reason": "shutdown",
+	})
+	for _, conn := range conns {
+		if err := conn.Send(goodbye); err != nil {
+			conn.logger.Warning("Failed to send GOODBYE: %v", err)
+		}
+		conn.stopReading()
+	}
+
+	if killed := s.awaitConnections(ctx, conns); killed > 0 {
+		failures = append(failures, fmt.Errorf("shutdown interrupted (%v), force-closed %d connection(s)", ctx.Err(), killed))
+	}
+
+	// Final snapshot once no connection can write any more
+	if s.cfg.DataFile != "" && !s.Loading() {
+		if err := s.store.Save(s.cfg.DataFile); err != nil {
+			failures = append(failures, fmt.Errorf("failed to save context store: %v", err))
+		} else {
+			s.logger.Info("Saved context store to %s", s.cfg.DataFile)
+		}
+	}
+
+	return errors.Join(failures...)
+}
+
+// awaitConnections gives in-flight messages on conns until ctx is done to
+// finish, then force-closes whatever is left, returning how many were.
+// A connection that has already closed is never counted, even once ctx is
+// done.
+func (s *Server) awaitConnections(ctx context.Context, conns []*Connection) int {
+	killed := 0
+	for _, conn := range conns {
+		select {
+		case <-conn.closeChan:
+			continue
+		default:
+		}
+
+		select {
+		case <-conn.closeChan:
+		case <-ctx.Done():
+			s.logger.Warning("Force-closing connection %s: %v", conn.id, ctx.Err())
+			conn.Close()
+			killed++
+		}
+	}
+	return killed
+}
+
+// removeConnection forgets a closed connection
+func (s *Server) removeConnection(id string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	for id, conn := range s.connections {
-		s.logger.Info("Closing connection %s", id)
-		conn.Close()
-		delete(s.connections, id)
+	conn, exists := s.connections[id]
+	if !exists {
+		return
+	}
+	delete(s.connections, id)
+	if s.claims[conn.clientID] == id {
+		delete(s.claims, conn.clientID)
+		s.detachDurable(conn)
 	}
 
-	return nil
+	if !conn.admin {
+		s.clientConns--
+		select {
+		case s.slotFreed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// claimClientID makes c store its context under clientID, failing if
+// another live connection already uses that ID unless resume is set. A
+// resuming connection takes the ID over along with the holder's
+// subscriptions, and the holder is returned for the caller to retire.
+func (s *Server) claimClientID(c *Connection, clientID string, resume bool) (*Connection, error) {
+	if clientID == state.ServerClientID {
+		return nil, errs.New(errs.ErrReadOnly, "client ID %s is reserved", clientID)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var old *Connection
+	if holder, claimed := s.claims[clientID]; claimed {
+		if !resume {
+			return nil, errs.New(errs.ErrClientIDInUse, "client ID %s is in use by another connection", clientID)
+		}
+		old = s.connections[holder]
+	}
+	if _, live := s.connections[clientID]; live {
+		return nil, errs.New(errs.ErrClientIDInUse, "client ID %s is in use by another connection", clientID)
+	}
+
+	s.claims[clientID] = c.id
+	// Written under s.mu, where removeConnection reads it
+	c.clientID = clientID
+
+	// Expiries the client missed while away follow the HELLO reply
+	c.queued = append(c.queued, s.expiryBacklog[clientID]...)
+	delete(s.expiryBacklog, clientID)
+	c.resumable = s.attachDurable(c)
+	if old != nil {
+		c.migrated = s.takeOver(c, old)
+	}
+	return old, nil
 }
 
-// acceptConnections handles incoming connections
-func (s *Server) acceptConnections() {
+// minAcceptDelay and maxAcceptDelay bound the backoff between attempts to
+// accept after an error other than running out of file descriptors
+const (
+	minAcceptDelay = 5 * time.Millisecond
+	maxAcceptDelay = time.Second
+)
+
+// acceptConnections accepts connections on listener until it is closed or
+// the server shuts down
+func (s *Server) acceptConnections(listener net.Listener, admin bool) {
+	if listener == nil {
+		s.logger.Error("Accept loop started without a listener")
+		return
+	}
+
+	var delay time.Duration // backoff after a failed accept
 	for {
-		select {
-		case <-s.closeChan:
+		if !admin && !s.waitForCapacity() {
 			return
-		default:
-			conn, err := s.listener.Accept()
-			if err != nil {
-				select {
-				case <-s.closeChan:
+		}
+
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-s.closeChan:
+				return
+			default:
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+
+			if isFDExhausted(err) {
+				if !s.relieveFDPressure(listener) {
 					return
-				default:
-					s.logger.Error("Error accepting connection: %v", err)
-					continue
 				}
+				continue
+			}
+
+			// Back off as net/http does, so a persistent failure is
+			// retried and logged a few times a second rather than in a
+			// hot loop
+			if delay == 0 {
+				delay = minAcceptDelay
+			} else if delay *= 2; delay > maxAcceptDelay {
+				delay = maxAcceptDelay
+			}
+			s.logger.Error("Error accepting connection, retrying in %v: %v", delay, err)
+			select {
+			case <-s.closeChan:
+				return
+			case <-time.After(delay):
 			}
+			continue
+		}
+		delay = 0
+		s.clearFDPressure()
+		if !admin {
+			s.counters.accepted.Add(1)
+			s.metrics.Inc("mcp_connections_accepted_total")
+		}
 
-			// Create new connection
-			connID := fmt.Sprintf("%s-%d", conn.RemoteAddr().String(), time.Now().UnixNano())
-			c := &Connection{
-				id:        connID,
-				conn:      conn,
-				store:     s.store,
-				logger:    s.logger.WithPrefix(fmt.Sprintf("conn[%s]", connID)),
-				closeChan: make(chan struct{}),
+		// Create new connection
+		connID := s.ids.ConnectionID(conn.RemoteAddr().String())
+		if admin {
+			connID = s.ids.ConnectionID("admin")
+		}
+		if connID == state.ServerClientID {
+			// A custom generator must not let a client write the
+			// server's statistics
+			s.logger.Error("Connection ID generator returned the reserved ID %s", connID)
+			rejectWith(conn, errs.New(errs.ErrReadOnly, "client ID %s is reserved", connID))
+			continue
+		}
+		c := &Connection{
+			id:          connID,
+			clientID:    connID,
+			conn:        conn,
+			server:      s,
+			store:       s.store,
+			logger:      s.logger.WithPrefix(fmt.Sprintf("conn[%s]", connID)),
+			connectedAt: s.now(),
+			events:      make(chan state.ContextEvent, config.SubscriptionBufferSize),
+			closeChan:   make(chan struct{}),
+			admin:       admin,
+		}
+		if s.cfg.FlushInterval > 0 {
+			c.out = bufio.NewWriterSize(conn, config.WriteBufferSize)
+		}
+		if admin {
+			c.peer = peerCredentials(conn)
+		} else {
+			c.limiter = newTokenBucket(s.cfg.RateLimit, s.cfg.RateBurst, c.connectedAt)
+			if tcp, err := setNoDelay(conn, s.cfg.NoDelay); err != nil {
+				c.logger.Warning("Failed to set TCP no-delay: %v", err)
+			} else if tcp {
+				c.noDelay.Store(s.cfg.NoDelay)
 			}
+		}
 
-			// Add to connections map
-			s.mu.Lock()
-			s.connections[connID] = c
+		// Add to connections map, unless shutdown began while this
+		// connection was being accepted
+		s.mu.Lock()
+		if s.lifecycle != StateStarted {
 			s.mu.Unlock()
-
-			// Handle connection in goroutine
-			go c.Handle()
+			conn.Close()
+			return
+		}
+		if !admin && s.atCapacity() {
+			s.mu.Unlock()
+			s.logger.Warning("Rejecting connection from %s: limit of %d reached", conn.RemoteAddr(), s.cfg.MaxConnections)
+			go s.rejectConnection(conn)
+			continue
 		}
+		s.connections[connID] = c
+		if !admin {
+			s.clientConns++
+		}
+		s.mu.Unlock()
+
+		// Handle connection in goroutine
+		go c.Handle()
 	}
 }
 
-// BroadcastMessage sends a message to all connected clients
-// TODO: Implement message broadcasting logic
+// BroadcastMessage sends a message to every connected client, other than
+// admin console connections. The message is encoded once for all of them
+// unless an outbound transform is installed. Connections are written to in
+// turn, and one the message cannot be written to is closed.
 func (s *Server) BroadcastMessage(msg protocol.Message) {
 	s.mu.RLock()
-	defer s.mu.RUnlock()
+	conns := make([]*Connection, 0, len(s.connections))
+	for _, conn := range s.connections {
+		if !conn.admin {
+			conns = append(conns, conn)
+		}
+	}
+	s.mu.RUnlock()
 
-	for range s.connections {
-		// TODO: Format message according to protocol and send
+	frame := msg.Encode()
+	for _, conn := range conns {
+		if err := conn.sendShared(msg, frame); err != nil {
+			conn.logger.Error("Failed to broadcast %s: %v", msg.Type, err)
+			conn.Close()
+		}
 	}
 }
 
 // Handle processes incoming messages from a client
 func (c *Connection) Handle() {
 	defer c.Close()
+	defer c.recoverPanic()
 
 	c.logger.Info("New connection established")
 
+	go c.forwardEvents()
+
+	c.touch()
+	if interval := c.server.heartbeatInterval(); interval > 0 {
+		go c.heartbeat(interval, c.server.cfg.HeartbeatTimeout)
+	}
+
 	reader := bufio.NewReader(c.conn)
 
 	for {
@@ -145,99 +746,477 @@ func (c *Connection) Handle() {
 		case <-c.closeChan:
 			return
 		default:
-			// Set read deadline
-			err := c.conn.SetReadDeadline(time.Now().Add(time.Minute))
-			if err != nil {
-				c.logger.Error("Failed to set read deadline: %v", err)
+			// Wait as long as it takes for the next message; heartbeat
+			// closes the connection if the client goes silent
+			if !c.setReadDeadline(0) {
+				return
+			}
+			if _, err := reader.Peek(1); err != nil {
+				if c.stopping.Load() || c.closed() {
+					return
+				}
+				c.logger.Error("Error reading from connection: %v", err)
+				return
+			}
+
+			// A message has started arriving; bound how long the rest of
+			// it may take
+			if !c.setReadDeadline(c.server.cfg.ReadTimeout) {
 				return
 			}
 
 			// Read line from connection
-			line, err := reader.ReadString('\n')
+			limit := c.messageSizeLimit()
+			line, err := c.readLine(reader, limit)
+			if errors.Is(err, errs.ErrMessageTooLarge) {
+				c.logger.Warning("Message exceeds size limit of %d bytes, closing connection", limit)
+				c.sendError(errs.New(errs.ErrMessageTooLarge, "message exceeds size limit of %d bytes", limit))
+				return
+			}
 			if err != nil {
+				if c.stopping.Load() || c.closed() {
+					return
+				}
 				c.logger.Error("Error reading from connection: %v", err)
 				return
 			}
 
+			c.touch()
+			c.server.counters.messages.Add(1)
+
+			// Answer heartbeats without building a Message
+			if c.fastPing(line) {
+				c.server.metrics.Inc("mcp_messages_received_total", pingLabels...)
+				continue
+			}
+
 			// Parse message
-			msg, err := protocol.Parse(line)
+			// The line is only valid until the next read, so Parse gets
+			// its own copy for the message to reference
+			msg, err := protocol.Parse(string(line))
 			if err != nil {
 				c.logger.Error("Failed to parse message: %v", err)
+				c.server.counters.parseErrors.Add(1)
+				c.server.metrics.Inc("mcp_parse_errors_total")
+				c.request = protocol.NewMessage("", nil)
+				if id, found := protocol.RecoverID(string(line)); found {
+					c.request.Params["id"] = id
+				}
+				c.sendError(errs.New(errs.ErrParseFailed, "%v", err))
+				c.request = protocol.Message{}
 				continue
 			}
+			if c.server.cfg.NormalizeTypes {
+				if normalized := protocol.NormalizeType(msg.Type); normalized != msg.Type {
+					c.deprecated(DeprecatedLowercaseType, "message types are uppercase; send %s rather than %s", normalized, msg.Type)
+					msg.Type = normalized
+				}
+			}
+			c.server.metrics.Inc("mcp_messages_received_total", "type", c.server.metricType(msg.Type))
 
 			// Process message
+			c.handling = msg
 			c.handleMessage(msg)
+			c.handling = protocol.Message{}
 		}
 	}
 }
 
-// handleMessage processes a parsed message
+// setReadDeadline sets the read deadline timeout from now. It returns false
+// if the connection should stop reading, either because the deadline could
+// not be set or because stopReading has been called; checking afterwards
+// ensures the deadline set by stopReading is never overwritten.
+func (c *Connection) setReadDeadline(timeout time.Duration) bool {
+	if err := c.conn.SetReadDeadline(deadline(timeout)); err != nil {
+		c.logger.Error("Failed to set read deadline: %v", err)
+		return false
+	}
+	return !c.stopping.Load()
+}
+
+// handleMessage processes a parsed message, flagging handlers that run
+// longer than the timeout configured for the message type
 func (c *Connection) handleMessage(msg protocol.Message) {
-	c.logger.Info("Received message: %s", msg.String())
+	if c.principal != "" {
+		c.logger.Info("Received message from %s: %s", c.principal, redactToken(msg))
+	} else {
+		c.logger.Info("Received message: %s", redactToken(msg))
+	}
 
-	switch msg.Type {
-	case protocol.TypePing:
-		// Handle ping message
-		c.handlePing(msg)
+	// Runs last, so the client is told why before it is disconnected
+	defer c.closeIfAuthFailed()
 
-	case protocol.TypeContext:
-		// Handle context update
-		c.handleContextUpdate(msg)
+	// Pushes queued while handling the message, such as deprecation
+	// warnings, follow whatever reply it draws
+	defer c.flushQueued()
 
-	default:
-		c.logger.Warning("Unknown message type: %s", msg.Type)
+	// PONGs answer server heartbeats, which may precede the handshake
+	if msg.Type == protocol.TypePong {
+		return
+	}
+
+	// Every reply to the message, errors included, echoes its correlation
+	// id. Handlers never see it.
+	c.request = protocol.NewMessage(msg.Type, nil)
+	defer func() { c.request = protocol.Message{} }()
+	if id, exists := msg.ID(); exists {
+		c.request.Params["id"] = id
+		delete(msg.Params, "id")
+	}
+
+	if !c.limiter.allow(c.server.now()) {
+		c.logger.Warning("Rejecting %s: rate limit exceeded", msg.Type)
+		c.sendError(errs.New(errs.ErrRateLimited, "more than %d messages per second", c.server.cfg.RateLimit))
+		return
 	}
+
+	if msg.Type != protocol.TypeAuth && c.unauthenticated() {
+		c.rejectUnauthenticated(msg)
+		return
+	}
+
+	if msg.Type != protocol.TypeHello && msg.Type != protocol.TypeAuth && c.version == "" {
+		if c.server.cfg.RequireHello {
+			c.logger.Warning("Rejecting %s sent before HELLO", msg.Type)
+			c.sendError(errs.New(errs.ErrHandshakeRequired, "send HELLO before any other message"))
+			return
+		}
+		c.deprecated(DeprecatedNoHello, "send HELLO before any other message; it will become required")
+	}
+
+	if msg.Type != protocol.TypeHello && msg.Type != protocol.TypeAuth {
+		c.seedTemplate()
+	}
+
+	if c.admin {
+		c.server.audit.Info("admin command from %s (%s): %s", c.id, c.peer, msg.String())
+	}
+
+	// A retried message is answered with the outcome of the original
+	idem, hasIdem := msg.Params["idem"]
+	delete(msg.Params, "idem")
+	if hasIdem {
+		if idem == "" {
+			c.sendError(errs.New(errs.ErrInvalidParams, "empty idempotency key"))
+			return
+		}
+		if response, err, found := c.server.idempotency.lookup(c.clientID, idem); found {
+			c.logger.Info("Replaying outcome of %s with idempotency key %s", msg.Type, idem)
+			c.reply(msg, response, err)
+			return
+		}
+	}
+
+	if err := c.server.checkLoading(msg.Type); err != nil {
+		c.sendError(err)
+		return
+	}
+
+	if err := c.checkSequence(msg); err != nil {
+		c.logger.Warning("Rejecting %s message: %v", msg.Type, err)
+		c.sendError(err)
+		return
+	}
+
+	if inbound, _ := c.server.transforms(); inbound != nil {
+		msg = inbound(c, msg)
+	}
+
+	c.handling = msg
+	start := time.Now()
+	response, err := c.dispatch(msg)
+
+	elapsed := time.Since(start)
+	c.server.metrics.Observe("mcp_handler_duration_seconds", elapsed.Seconds(), "type", c.server.metricType(msg.Type))
+	if timeout := c.server.handlerTimeout(msg.Type); elapsed > timeout {
+		c.logger.Warning("Handler for %s took %v, exceeding its %v timeout", msg.Type, elapsed, timeout)
+	}
+
+	if hasIdem {
+		c.server.idempotency.store(c.clientID, idem, response, err)
+	}
+
+	c.reply(msg, response, err)
 }
 
-// handlePing responds to ping messages
-func (c *Connection) handlePing(msg protocol.Message) {
-	// TODO: Implement proper ping response
-	// For now, just log it
-	c.logger.Info("Ping received with params: %v", msg.Params)
+// flushQueued sends the pushes queued while handling a message
+func (c *Connection) flushQueued() {
+	for _, pushed := range c.queued {
+		if err := c.Send(pushed); err != nil {
+			c.logger.Warning("Failed to send queued %s: %v", pushed.Type, err)
+		}
+	}
+	c.queued = nil
+}
 
-	// Prepare response
-	response := protocol.NewMessage(protocol.TypePong, map[string]string{
-		"time": fmt.Sprintf("%d", time.Now().Unix()),
-	})
+// reply sends the outcome of handling msg: an ERROR if err is set, otherwise
+// the response, if any
+func (c *Connection) reply(msg protocol.Message, response protocol.Message, err error) {
+	if err != nil {
+		c.logger.Warning("Failed to handle %s message: %v", msg.Type, err)
+		c.sendError(err)
+		return
+	}
 
-	// Send response
-	c.Send(response)
+	// Some messages, such as unknown types, get no reply
+	if response.Type == "" {
+		return
+	}
+
+	if err := c.Send(protocol.NewResponse(c.request, response.Type, response.Params)); err != nil {
+		c.logger.Error("Failed to send %s: %v", response.Type, err)
+		c.Close()
+	}
 }
 
-// handleContextUpdate processes context updates
-func (c *Connection) handleContextUpdate(msg protocol.Message) {
-	// Log context update
-	c.logger.Info("Context update received with params: %v", msg.Params)
+// checkSequence validates the optional seq parameter and strips it from the
+// message so handlers never see it. Messages whose sequence number is not
+// higher than the last one processed for the client ID are rejected, which
+// keeps stale writes from a dead connection from overwriting newer state.
+// The count carries over to later connections claiming the same client ID,
+// so a resuming client must continue its numbering rather than restart it.
+func (c *Connection) checkSequence(msg protocol.Message) error {
+	raw, exists := msg.Params["seq"]
+	if !exists {
+		return nil
+	}
+	delete(msg.Params, "seq")
+
+	seq, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return errs.New(errs.ErrInvalidParams, "invalid sequence number %q", raw)
+	}
 
-	// Update context in the store
-	// TODO: Add proper context update logic
-	for key, value := range msg.Params {
-		c.store.Set(c.id, key, value)
+	if !c.server.sequences.Accept(c.clientID, seq) {
+		return errs.New(errs.ErrStaleSequence, "sequence %d already processed", seq)
 	}
 
-	// Respond with acknowledgment
-	response := protocol.NewMessage(protocol.TypeAck, map[string]string{
-		"status": "ok",
+	return nil
+}
+
+// forwardEvents pushes subscribed context changes to the client as UPDATE
+// messages until the connection is closed
+func (c *Connection) forwardEvents() {
+	for {
+		select {
+		case <-c.closeChan:
+			return
+		case event := <-c.events:
+			if err := c.sendUpdate(event); err != nil {
+				c.logger.Error("Failed to push context event: %v", err)
+				c.Close()
+				return
+			}
+			c.delivered.Store(event.Version)
+		}
+	}
+}
+
+// updateMessage builds the UPDATE pushed for a context event
+func updateMessage(event state.ContextEvent) protocol.Message {
+	push := protocol.NewMessage(protocol.TypeUpdate, map[string]string{
+		"client":  event.ClientID,
+		"key":     event.Key,
+		"old":     event.OldValue,
+		"new":     event.Value,
+		"version": strconv.FormatUint(event.Version, 10),
 	})
+	if event.Deleted {
+		push.Params["deleted"] = "true"
+	}
+	if event.Scheduled {
+		push.Params["scheduled"] = "true"
+	}
+	if event.Overdue {
+		push.Params["overdue"] = "true"
+	}
+	if event.Replayed {
+		push.Params["replayed"] = "true"
+	}
+	return push
+}
 
-	c.Send(response)
+// sendError reports a failure to the client as an ERROR message with the
+// numeric code and machine-readable reason mapped from the error and a
+// human-readable detail. Rejections such as rate limiting are counted as
+// dropped messages.
+func (c *Connection) sendError(err error) {
+	if reason := dropReason(err); reason != "" {
+		c.server.recordDrop(reason)
+	}
+	msg := errorMessage(err)
+	if err := c.Send(protocol.NewResponse(c.request, msg.Type, msg.Params)); err != nil {
+		c.logger.Error("Failed to send error: %v", err)
+		c.Close()
+	}
 }
 
-// Send transmits a message to the client
-func (c *Connection) Send(msg protocol.Message) {
-	formatted := msg.Format()
-	_, err := c.conn.Write([]byte(formatted + "\n"))
-	if err != nil {
-		c.logger.Error("Failed to send message: %v", err)
+// errorMessage builds the ERROR message reporting err
+func errorMessage(err error) protocol.Message {
+	code := errs.CodeOf(err)
+	msg := protocol.NewError(code.HTTPStatus, code.Reason)
+	msg.Params["detail"] = errs.Detail(err)
+	if retry := errs.RetryAfter(err); retry > 0 {
+		msg.Params["retry_after_ms"] = strconv.FormatInt(retry.Milliseconds(), 10)
+	}
+	return msg
+}
+
+// Send transmits a message to the client. It is safe for concurrent use:
+// each message and its delimiter are written under the connection's write
+// mutex so frames from different goroutines never interleave.
+func (c *Connection) Send(msg protocol.Message) error {
+	if _, outbound := c.server.transforms(); outbound != nil {
+		msg = outbound(c, msg)
+	}
+
+	return c.sendFrame(msg.Type, msg.Encode())
+}
+
+// sendFrame sends a message of type msgType already encoded as frame,
+// bypassing the outbound transform. frame is not modified or retained.
+func (c *Connection) sendFrame(msgType string, frame []byte) error {
+	c.server.metrics.Inc("mcp_messages_sent_total", "type", msgType)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	return c.write(frame)
+}
+
+// write sends a formatted frame. With a FlushInterval the frame is buffered
+// instead, and written out once the buffer fills or the interval passes.
+// Callers must hold c.writeMu.
+func (c *Connection) write(frame []byte) error {
+	if err := c.conn.SetWriteDeadline(deadline(c.server.cfg.WriteTimeout)); err != nil {
+		return fmt.Errorf("failed to set write deadline: %v", err)
+	}
+
+	if c.out != nil {
+		if _, err := c.out.Write(frame); err != nil {
+			return fmt.Errorf("failed to send message: %v", err)
+		}
+		if c.out.Buffered() > 0 && !c.flushPending {
+			c.flushPending = true
+			time.AfterFunc(c.server.cfg.FlushInterval, c.flushBuffered)
+		}
+		return nil
+	}
+
+	if _, err := c.conn.Write(frame); err != nil {
+		return fmt.Errorf("failed to send message: %v", err)
+	}
+
+	return nil
+}
+
+// deadline returns the I/O deadline for a timeout, or the zero time (no
+// deadline) if the timeout is zero
+func deadline(timeout time.Duration) time.Time {
+	if timeout == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
+
+// readLine reads a single delimited message, returning it without the
+// delimiter. Messages longer than limit bytes fail with ErrMessageTooLarge as
+// soon as the limit is crossed, without buffering the rest of the line.
+//
+// To avoid an allocation per message, the returned slice aliases the
+// reader's buffer, or lineBuf for lines longer than the reader's buffer, and
+// is only valid until the next call.
+func (c *Connection) readLine(reader *bufio.Reader, limit int) ([]byte, error) {
+	chunk, err := reader.ReadSlice(config.MessageDelimiter)
+	if err == nil {
+		// The whole line was buffered; hand it out without copying
+		line := chunk[:len(chunk)-1]
+		if len(line) > limit {
+			return nil, errs.ErrMessageTooLarge
+		}
+		return line, nil
+	}
+
+	line := c.lineBuf[:0]
+	defer func() {
+		c.lineBuf = line[:0]
+	}()
+
+	for {
+		line = append(line, chunk...)
+
+		switch {
+		case err == nil:
+			line = line[:len(line)-1]
+			if len(line) > limit {
+				return nil, errs.ErrMessageTooLarge
+			}
+			return line, nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			if len(line) > limit {
+				return nil, errs.ErrMessageTooLarge
+			}
+		default:
+			return nil, err
+		}
+
+		chunk, err = reader.ReadSlice(config.MessageDelimiter)
+	}
+}
+
+// stopReading makes the read loop exit once the message being handled, if
+// any, has been answered. Pending reads are interrupted by moving the read
+// deadline into the past.
+func (c *Connection) stopReading() {
+	c.stopping.Store(true)
+	c.conn.SetReadDeadline(time.Now())
+}
+
+// clearContext removes the client's context when the server is configured
+// to clear it on close, unless the client asked for it to persist or a
+// resuming connection has taken it over
+func (c *Connection) clearContext() {
+	if !c.server.cfg.ClearOnClose || c.admin || c.persist.Load() || c.superseded.Load() {
+		return
+	}
+
+	c.server.mu.RLock()
+	clientID := c.clientID
+	c.server.mu.RUnlock()
+
+	c.store.Clear(clientID)
+	c.logger.Debug("Cleared context of %s", clientID)
+}
+
+// closed reports whether Close has been called
+func (c *Connection) closed() bool {
+	select {
+	case <-c.closeChan:
+		return true
+	default:
+		return false
 	}
 }
 
 // Close terminates the connection
 func (c *Connection) Close() {
 	c.closedOnce.Do(func() {
+		// Dropping the registrations before closeChan stops forwardEvents
+		// ensures no event is sent to a channel nobody reads
+		c.store.UnsubscribeAll(c.id)
 		close(c.closeChan)
+		// Buffered messages such as a GOODBYE still go out
+		c.writeMu.Lock()
+		if err := c.flushLocked(); err != nil {
+			c.logger.Debug("Failed to flush buffered messages on close: %v", err)
+		}
+		c.writeMu.Unlock()
 		c.conn.Close()
+		c.server.removeConnection(c.id)
+		c.clearContext()
+		if op := c.drain.Load(); op != nil {
+			op.closed.Add(1)
+		}
 		c.logger.Info("Connection closed")
 	})
 }
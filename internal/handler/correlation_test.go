@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+func TestPipelinedRepliesEchoIDs(t *testing.T) {
+	c := dialHello(t, newTestServer(t, nil), "")
+
+	// Written at once, so both are read before either is answered
+	c.conn.Write([]byte("PING:id=a\nPING:id=b\nCONTEXT:k=v;id=c\nCONTEXT:_ttl=never;k=v;id=d\nPING:\n"))
+	for _, want := range []struct{ msgType, id string }{
+		{protocol.TypePong, "a"},
+		{protocol.TypePong, "b"},
+		{protocol.TypeAck, "c"},
+		{protocol.TypeError, "d"},
+	} {
+		reply := c.recv()
+		if id, _ := reply.ID(); reply.Type != want.msgType || id != want.id {
+			t.Fatalf("got %s, want %s with id %s", reply, want.msgType, want.id)
+		}
+	}
+
+	// A request without an id gets a reply without one
+	if reply := c.recv(); reply.Type != protocol.TypePong {
+		t.Fatalf("got %s, want PONG", reply)
+	} else if _, exists := reply.ID(); exists {
+		t.Fatalf("got %s, want no id", reply)
+	}
+}
+
+func TestConcurrentRequestsMatchedByID(t *testing.T) {
+	c := dialHello(t, newTestServer(t, nil), "")
+
+	// Each writer sends its own requests over the shared connection; the
+	// replies, however they interleave, must each name their request
+	kinds := []struct{ line, reply string }{
+		{"PING:", protocol.TypePong},
+		{"CONTEXT:k=v;", protocol.TypeAck},
+		{"GET:key=k;", protocol.TypeResult},
+	}
+	const writers, perWriter = 4, 30
+	want := make(map[string]string)
+	for w := 0; w < writers; w++ {
+		for i := 0; i < perWriter; i++ {
+			want[fmt.Sprintf("w%d-%d", w, i)] = kinds[i%len(kinds)].reply
+		}
+	}
+
+	var writeMu sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				line := kinds[i%len(kinds)].line + fmt.Sprintf("id=w%d-%d\n", w, i)
+				writeMu.Lock()
+				c.conn.Write([]byte(line))
+				writeMu.Unlock()
+			}
+		}(w)
+	}
+
+	for n := writers * perWriter; n > 0; n-- {
+		reply := c.recv()
+		id, _ := reply.ID()
+		msgType, pending := want[id]
+		if !pending || reply.Type != msgType {
+			t.Fatalf("got %s, want a reply to an outstanding request", reply)
+		}
+		delete(want, id)
+	}
+	wg.Wait()
+}
+
+func TestTargetsNamedApartFromID(t *testing.T) {
+	srv := newAdminServer(t, &syncBuffer{})
+	c := dialHello(t, srv, "client_id=sched")
+
+	ack := c.expect("CONTEXT:k=v;_in=1h", protocol.TypeAck)
+	schedule := ack.Params["schedule.k"]
+	c.expectError("CANCEL:id="+schedule, protocol.ReasonInvalidParams)
+	if reply := c.request("CANCEL:schedule=" + schedule + ";id=c1"); reply.Type != protocol.TypeAck {
+		t.Fatalf("CANCEL = %s, want ACK", reply)
+	} else if id, _ := reply.ID(); id != "c1" {
+		t.Fatalf("CANCEL reply id = %q, want c1", id)
+	}
+	if scheds := srv.store.Schedules("sched"); len(scheds) != 0 {
+		t.Fatalf("schedules after CANCEL = %v", scheds)
+	}
+
+	target := dial(t, srv)
+	hello := target.expect("HELLO:version="+config.ProtocolVersion, protocol.TypeHello)
+	admin := dialAdmin(t, srv)
+	if reply := admin.request("KICK:conn=" + hello.Params["session"] + ";id=k1"); reply.Type != protocol.TypeAck {
+		t.Fatalf("KICK = %s, want ACK", reply)
+	} else if id, _ := reply.ID(); id != "k1" {
+		t.Fatalf("KICK reply id = %q, want k1", id)
+	}
+	target.expectClosed()
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// Tags of deprecated constructs the server still accepts, as reported in
+// WARNING messages, ServerStats.Deprecations and the tag label of
+// mcp_deprecated_total
+const (
+	// DeprecatedLowercaseType is a message type not in uppercase, accepted
+	// when NormalizeTypes is on
+	DeprecatedLowercaseType = "lowercase_type"
+	// DeprecatedNoHello is a message sent before HELLO, accepted when
+	// RequireHello is off
+	DeprecatedNoHello = "no_hello"
+)
+
+// deprecated records a client's use of a deprecated construct. Every use is
+// counted, so operators can tell when the legacy behaviour can be turned
+// off, but each connection is warned about a construct only once: a
+// WARNING naming the tag follows the reply to the message. It must be
+// called from the connection's Handle goroutine.
+func (c *Connection) deprecated(tag, format string, args ...interface{}) {
+	c.server.recordDeprecation(tag)
+
+	if c.admin || c.warned[tag] {
+		return
+	}
+	if c.warned == nil {
+		c.warned = make(map[string]bool)
+	}
+	c.warned[tag] = true
+
+	c.queued = append(c.queued, protocol.NewMessage(protocol.TypeWarning, map[string]string{
+		"tag":    tag,
+		"detail": fmt.Sprintf(format, args...),
+	}))
+}
+
+// recordDeprecation counts a use of the deprecated construct tag
+func (s *Server) recordDeprecation(tag string) {
+	s.deprecationsMu.Lock()
+	if s.deprecations == nil {
+		s.deprecations = make(map[string]uint64)
+	}
+	s.deprecations[tag]++
+	s.deprecationsMu.Unlock()
+
+	s.metrics.Inc("mcp_deprecated_total", "tag", tag)
+}
+
+// deprecationCounts returns a copy of the uses counted per deprecation tag
+func (s *Server) deprecationCounts() map[string]uint64 {
+	s.deprecationsMu.Lock()
+	defer s.deprecationsMu.Unlock()
+
+	counts := make(map[string]uint64, len(s.deprecations))
+	for tag, count := range s.deprecations {
+		counts[tag] = count
+	}
+	return counts
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+func TestDeprecationWarnedOncePerConnection(t *testing.T) {
+	tests := []struct {
+		tag       string
+		configure func(*config.Config)
+		connect   func(*Server) *testConn
+		line      string // uses the deprecated construct
+		reply     string
+		next      string // does not, so draws no WARNING
+		nextReply string
+	}{
+		{
+			tag:       DeprecatedLowercaseType,
+			configure: typeConfig(true),
+			connect:   func(srv *Server) *testConn { return dialHello(t, srv, "") },
+			line:      "context:k=v",
+			reply:     protocol.TypeAck,
+			next:      "PING:",
+			nextReply: protocol.TypePong,
+		},
+		{
+			tag:       DeprecatedNoHello,
+			configure: func(cfg *config.Config) { cfg.RequireHello = false },
+			connect:   func(srv *Server) *testConn { return dial(t, srv) },
+			line:      "CONTEXT:k=v",
+			reply:     protocol.TypeAck,
+			next:      "HELLO:version=" + config.ProtocolVersion,
+			nextReply: protocol.TypeHello,
+		},
+	}
+
+	for _, tt := range tests {
+		srv := newTestServer(t, tt.configure)
+
+		for conn := 0; conn < 2; conn++ {
+			c := tt.connect(srv)
+			c.expect(tt.line, tt.reply)
+			if warning := c.recv(); warning.Type != protocol.TypeWarning || warning.Params["tag"] != tt.tag || warning.Params["detail"] == "" {
+				t.Fatalf("%s, connection %d: got %s, want one WARNING", tt.tag, conn, warning)
+			}
+			// Later uses are answered with no further WARNING
+			c.expect(tt.line, tt.reply)
+			c.expect(tt.line, tt.reply)
+			c.expect(tt.next, tt.nextReply)
+		}
+
+		// Every use is counted, warned or not
+		if got := srv.GetStats().Deprecations[tt.tag]; got != 6 {
+			t.Errorf("Deprecations[%s] = %d, want 6", tt.tag, got)
+		}
+		stats := dialHello(t, srv, "").expect("STATS:", protocol.TypeResult)
+		if got := stats.Params["deprecated."+tt.tag]; got != strconv.Itoa(6) {
+			t.Errorf("STATS deprecated.%s = %q, want 6", tt.tag, got)
+		}
+	}
+}
+
+func TestStrictModeRejectsDeprecatedConstructs(t *testing.T) {
+	srv := newTestServer(t, func(cfg *config.Config) {
+		cfg.NormalizeTypes = false
+		cfg.RequireHello = true
+		cfg.UnknownTypePolicy = "error"
+	})
+
+	dial(t, srv).expectError("CONTEXT:k=v", protocol.ReasonHandshakeRequired)
+	dialHello(t, srv, "").expectError("context:k=v", protocol.ReasonUnknownType)
+	if counts := srv.GetStats().Deprecations; len(counts) != 0 {
+		t.Fatalf("Deprecations = %v with the legacy behaviour off", counts)
+	}
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// ConnectionInfo describes a live client connection
+type ConnectionInfo struct {
+	ID          string
+	RemoteAddr  string
+	ConnectedAt time.Time
+	LastActive  time.Time // when a message was last received
+	Admin       bool      // accepted on the admin socket
+	NoDelay     bool      // Nagle's algorithm disabled; false on non-TCP connections
+
+	takenAt time.Time // when the info was taken, by the server clock
+}
+
+// IdleFor returns how long the connection had been silent when the info was
+// taken
+func (info ConnectionInfo) IdleFor() time.Duration {
+	return info.takenAt.Sub(info.LastActive)
+}
+
+// ConnectionSelector reports whether a connection should be acted on
+type ConnectionSelector func(info ConnectionInfo) bool
+
+// SelectCIDR matches connections whose remote IP lies within cidr
+func SelectCIDR(cidr string) (ConnectionSelector, error) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CIDR %q: %v", cidr, err)
+	}
+
+	return func(info ConnectionInfo) bool {
+		host, _, err := net.SplitHostPort(info.RemoteAddr)
+		if err != nil {
+			host = info.RemoteAddr
+		}
+		ip := net.ParseIP(host)
+		return ip != nil && network.Contains(ip)
+	}, nil
+}
+
+// SelectConnectedBefore matches connections established before t
+func SelectConnectedBefore(t time.Time) ConnectionSelector {
+	return func(info ConnectionInfo) bool {
+		return info.ConnectedAt.Before(t)
+	}
+}
+
+// SelectAll matches connections that satisfy every given selector
+func SelectAll(selectors ...ConnectionSelector) ConnectionSelector {
+	return func(info ConnectionInfo) bool {
+		for _, selector := range selectors {
+			if !selector(info) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// DrainProgress reports how far a drain has progressed
+type DrainProgress struct {
+	Matched   int
+	Closed    int
+	Remaining int
+}
+
+// drainOp tracks the connections matched by a single Drain call
+type drainOp struct {
+	matched int
+	closed  atomic.Int64
+}
+
+// Drain asks every connection matched by selector to go away: each receives
+// a GOAWAY with a reconnect hint, can no longer subscribe, and is force-closed
+// once grace has elapsed. Connections not matched are untouched, as are
+// connections established after Drain returns, so a drained client that
+// reconnects is served normally. It returns the number of matched
+// connections.
+func (s *Server) Drain(selector ConnectionSelector, grace time.Duration) int {
+	s.mu.RLock()
+	var matched []*Connection
+	for _, conn := range s.connections {
+		if conn.drain.Load() == nil && selector(conn.Info()) {
+			matched = append(matched, conn)
+		}
+	}
+	s.mu.RUnlock()
+
+	op := &drainOp{matched: len(matched)}
+	s.lastDrain.Store(op)
+
+	goaway := protocol.NewMessage(protocol.TypeGoAway, map[string]string{
+		"reason":          "maintenance",
+		"reconnect":       "true",
+		"reconnect_after": strconv.FormatInt(grace.Milliseconds(), 10),
+	})
+
+	for _, conn := range matched {
+		conn.drain.Store(op)
+		conn.logger.Info("Draining connection, closing in %v", grace)
+		if err := conn.Send(goaway); err != nil {
+			conn.logger.Error("Failed to send goaway: %v", err)
+			conn.Close()
+			continue
+		}
+
+		time.AfterFunc(grace, conn.Close)
+	}
+
+	s.logger.Info("Draining %d connections with %v grace period", len(matched), grace)
+	return len(matched)
+}
+
+// DrainProgress reports the progress of the most recent Drain call
+func (s *Server) DrainProgress() DrainProgress {
+	op := s.lastDrain.Load()
+	if op == nil {
+		return DrainProgress{}
+	}
+
+	closed := int(op.closed.Load())
+	return DrainProgress{
+		Matched:   op.matched,
+		Closed:    closed,
+		Remaining: op.matched - closed,
+	}
+}
+
+// Info returns a description of the connection
+func (c *Connection) Info() ConnectionInfo {
+	return ConnectionInfo{
+		ID:          c.id,
+		RemoteAddr:  c.conn.RemoteAddr().String(),
+		ConnectedAt: c.connectedAt,
+		LastActive:  c.lastReceived(),
+		Admin:       c.admin,
+		NoDelay:     c.noDelay.Load(),
+		takenAt:     c.server.now(),
+	}
+}
+
+// draining reports whether the connection has been selected by a Drain
+func (c *Connection) draining() bool {
+	return c.drain.Load() != nil
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+func TestDrainedClientReconnectsNormally(t *testing.T) {
+	const grace = 50 * time.Millisecond
+
+	clock := newTestClock()
+	srv := newUnstartedServer(t, nil)
+	srv.SetClock(clock.Now)
+	startTestServer(t, srv)
+
+	old := dialHello(t, srv, "client_id=old")
+	clock.Advance(time.Minute)
+	cutoff := clock.Now()
+	bystander := dialHello(t, srv, "client_id=bystander")
+
+	if matched := srv.Drain(SelectConnectedBefore(cutoff), grace); matched != 1 {
+		t.Fatalf("Drain matched %d connections, want 1", matched)
+	}
+
+	goaway := old.recv()
+	if goaway.Type != protocol.TypeGoAway || goaway.Params["reconnect"] != "true" || goaway.Params["reconnect_after"] != "50" {
+		t.Fatalf("got %s, want GOAWAY with a reconnect hint", goaway)
+	}
+	old.expectError("SUBSCRIBE:key=k", protocol.ReasonDraining)
+	old.expectClosed()
+
+	// Reconnecting under the same client ID lands on a fresh connection
+	// the finished drain knows nothing of
+	resumed := dialHello(t, srv, "client_id=old")
+	resumed.expect("SUBSCRIBE:key=k", protocol.TypeAck)
+	time.Sleep(2 * grace)
+	resumed.expect("PING:", protocol.TypePong)
+	bystander.expect("PING:", protocol.TypePong)
+
+	if progress := srv.DrainProgress(); progress != (DrainProgress{Matched: 1, Closed: 1}) {
+		t.Fatalf("DrainProgress = %+v", progress)
+	}
+
+	// A second drain with the same cutoff leaves the reconnected client be
+	if matched := srv.Drain(SelectConnectedBefore(cutoff), grace); matched != 0 {
+		t.Fatalf("second Drain matched %d connections", matched)
+	}
+}
+
+func TestSelectCIDR(t *testing.T) {
+	selector, err := SelectCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for addr, want := range map[string]bool{
+		"10.1.2.3:4000":    true,
+		"192.168.0.1:4000": false,
+		"[::1]:4000":       false,
+		"not an address":   false,
+	} {
+		if got := selector(ConnectionInfo{RemoteAddr: addr}); got != want {
+			t.Errorf("%s: matched %v, want %v", addr, got, want)
+		}
+	}
+
+	if _, err := SelectCIDR("10.0.0.0"); err == nil {
+		t.Fatal("CIDR without a prefix length accepted")
+	}
+}
+
+// expectDrainStats checks the drain progress reported in STATS
+func expectDrainStats(t *testing.T, c *testConn, matched, closed, remaining int) {
+	t.Helper()
+
+	stats := c.expect("STATS:", protocol.TypeResult)
+	for param, want := range map[string]int{"drain.matched": matched, "drain.closed": closed, "drain.remaining": remaining} {
+		if stats.Params[param] != strconv.Itoa(want) {
+			t.Fatalf("STATS %s = %q, want %d", param, stats.Params[param], want)
+		}
+	}
+}
+
+func TestStatsReportDrainProgress(t *testing.T) {
+	srv := newTestServer(t, nil)
+	first := dialHello(t, srv, "client_id=first")
+	second := dialHello(t, srv, "client_id=second")
+	watcher := dialHello(t, srv, "client_id=watcher")
+	expectDrainStats(t, watcher, 0, 0, 0)
+
+	watcherAddr := watcher.conn.LocalAddr().String()
+	srv.Drain(func(info ConnectionInfo) bool { return info.RemoteAddr != watcherAddr }, time.Hour)
+	expectDrainStats(t, watcher, 2, 0, 2)
+
+	// A drained client leaving on its own counts as closed
+	if msg := first.recv(); msg.Type != protocol.TypeGoAway {
+		t.Fatalf("got %s, want GOAWAY", msg)
+	}
+	first.conn.Close()
+	waitFor(t, func() bool { return srv.DrainProgress().Closed == 1 })
+	expectDrainStats(t, watcher, 2, 1, 1)
+
+	second.conn.Close()
+	waitFor(t, func() bool { return srv.DrainProgress().Remaining == 0 })
+	expectDrainStats(t, watcher, 2, 2, 0)
+}
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/errs"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+)
+
+// durableSubscription is a subscription kept for a client ID across its
+// connections. A client reconnecting with the same client_id resumes it by
+// subscribing again with durable=true, and is first replayed the matching
+// changes made since it was last delivered one.
+type durableSubscription struct {
+	sub      state.Subscription
+	version  uint64 // store revision up to which changes were delivered
+	attached bool   // registered on the client ID's current connection
+}
+
+// durableSubscriptions are the durable subscriptions of one client ID. They
+// are dropped DurableSubscriptionTTL seconds after its last connection
+// closes unless a new one claims the client ID.
+type durableSubscriptions struct {
+	subs       []*durableSubscription
+	detachedAt time.Time // zero while a connection holds the client ID
+}
+
+// find returns the durable subscription equal to sub, or nil
+func (d *durableSubscriptions) find(sub state.Subscription) *durableSubscription {
+	for _, ds := range d.subs {
+		if ds.sub == sub {
+			return ds
+		}
+	}
+	return nil
+}
+
+// subscribeDurable registers sub on c and keeps it for c's client ID, which
+// must have been claimed in HELLO. If the client ID already holds sub from
+// an earlier connection, the changes it missed since then are queued ahead
+// of live ones; the returned parameters report how many and whether some
+// could not be replayed.
+func (s *Server) subscribeDurable(c *Connection, sub state.Subscription) (map[string]string, error) {
+	if c.clientID == c.id {
+		return nil, errs.New(errs.ErrInvalidParams, "durable subscriptions need a client_id claimed in HELLO")
+	}
+
+	// s.mu is held across the store calls so the subscription cannot be
+	// detached in between; the store never takes s.mu under its own lock
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.durable[c.clientID]
+	if d == nil {
+		d = &durableSubscriptions{}
+		s.durable[c.clientID] = d
+	}
+
+	if ds := d.find(sub); ds != nil {
+		if ds.attached {
+			c.store.Subscribe(c.id, sub, c.events)
+			return nil, nil
+		}
+		replayed, gap := c.store.ResumeSubscriptions(c.id, []state.Subscription{sub}, c.events, ds.version)
+		ds.attached = true
+		c.logger.Info("Resumed durable subscription %+v, replaying %d changes since version %d", sub, replayed, ds.version)
+		return map[string]string{
+			"replayed": strconv.Itoa(replayed),
+			"gap":      strconv.FormatBool(gap),
+		}, nil
+	}
+
+	if len(d.subs) >= config.MaxDurableSubscriptions {
+		return nil, errs.New(errs.ErrQuotaExceeded, "client ID %s already holds %d durable subscriptions", c.clientID, config.MaxDurableSubscriptions)
+	}
+
+	// Subscribing since the last possible revision registers without
+	// backfill and reports the revision the subscription starts from
+	_, version, _ := c.store.SubscribeSince(c.id, sub, c.events, ^uint64(0))
+	d.subs = append(d.subs, &durableSubscription{sub: sub, version: version, attached: true})
+	c.logger.Info("Subscribed durably to %+v at version %d", sub, version)
+	return nil, nil
+}
+
+// unsubscribeDurable forgets a durable subscription of a client ID,
+// reporting whether it held one
+func (s *Server) unsubscribeDurable(clientID string, sub state.Subscription) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d := s.durable[clientID]
+	if d == nil {
+		return false
+	}
+	for i, ds := range d.subs {
+		if ds.sub == sub {
+			d.subs = append(d.subs[:i], d.subs[i+1:]...)
+			if len(d.subs) == 0 {
+				delete(s.durable, clientID)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// attachDurable notes that c has claimed its client ID, stopping the expiry
+// of the client ID's durable subscriptions, and returns how many are waiting
+// to be resumed rather than still registered on a connection c took over.
+// Callers must hold s.mu.
+func (s *Server) attachDurable(c *Connection) int {
+	d := s.durable[c.clientID]
+	if d == nil {
+		return 0
+	}
+	if !d.detachedAt.IsZero() && s.now().Sub(d.detachedAt) > config.DurableSubscriptionTTL*time.Second {
+		delete(s.durable, c.clientID)
+		return 0
+	}
+	d.detachedAt = time.Time{}
+
+	waiting := 0
+	for _, ds := range d.subs {
+		if !ds.attached {
+			waiting++
+		}
+	}
+	return waiting
+}
+
+// detachDurable notes that c, holding its client ID, has closed, recording
+// how far its durable subscriptions were delivered, and drops those of
+// client IDs left unclaimed past DurableSubscriptionTTL. Callers must hold
+// s.mu.
+func (s *Server) detachDurable(c *Connection) {
+	now := s.now()
+	if d := s.durable[c.clientID]; d != nil {
+		delivered := c.delivered.Load()
+		for _, ds := range d.subs {
+			if ds.attached && delivered > ds.version {
+				ds.version = delivered
+			}
+			ds.attached = false
+		}
+		d.detachedAt = now
+	}
+
+	for clientID, d := range s.durable {
+		if !d.detachedAt.IsZero() && now.Sub(d.detachedAt) > config.DurableSubscriptionTTL*time.Second {
+			delete(s.durable, clientID)
+		}
+	}
+}
+
+// handleSubscriptions lists this client's durable subscriptions as
+// <n>.key, <n>.prefix, <n>.client and <n>.active parameters, numbered from
+// zero, with count giving how many there are. active is false for those
+// not yet resumed on this connection.
+func (c *Connection) handleSubscriptions(msg protocol.Message) (protocol.Message, error) {
+	c.server.mu.RLock()
+	var subs []durableSubscription
+	if d := c.server.durable[c.clientID]; d != nil {
+		for _, ds := range d.subs {
+			subs = append(subs, *ds)
+		}
+	}
+	c.server.mu.RUnlock()
+
+	params := map[string]string{
+		"count": strconv.Itoa(len(subs)),
+	}
+	for i, ds := range subs {
+		n := strconv.Itoa(i)
+		params[n+".key"] = ds.sub.Key
+		params[n+".prefix"] = strconv.FormatBool(ds.sub.Prefix)
+		params[n+".active"] = strconv.FormatBool(ds.attached)
+		if ds.sub.ClientID != "" {
+			params[n+".client"] = ds.sub.ClientID
+		}
+	}
+
+	return protocol.NewMessage(protocol.TypeResult, params), nil
+}
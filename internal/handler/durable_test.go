@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+	"github.com/Artimus100/mcp-server-go/internal/utils"
+)
+
+// newDurableServer starts a server keeping journal changes for resuming
+// subscriptions, on a test clock
+func newDurableServer(t *testing.T, journal int) (*Server, *testClock) {
+	t.Helper()
+
+	clock := newTestClock()
+	cfg := config.Default()
+	cfg.Port = 0
+	srv := NewServer(cfg, state.NewContextStore(state.WithJournal(journal)), utils.NewLoggerTo(io.Discard, "test"))
+	srv.SetClock(clock.Now)
+	return startTestServer(t, srv), clock
+}
+
+// resubscribe sends line and returns its ACK and the n UPDATEs replayed
+// with it, in the order they arrived
+func resubscribe(c *testConn, line string, n int) (protocol.Message, []protocol.Message) {
+	c.t.Helper()
+
+	c.send(line)
+	var ack protocol.Message
+	var updates []protocol.Message
+	for ack.Type == "" || len(updates) < n {
+		msg := c.recv()
+		switch msg.Type {
+		case protocol.TypeAck:
+			ack = msg
+		case protocol.TypeUpdate:
+			updates = append(updates, msg)
+		default:
+			c.t.Fatalf("%q: got %s, want ACK and UPDATEs", line, msg)
+		}
+	}
+	return ack, updates
+}
+
+func TestDurableSubscriptionReplaysMissedChanges(t *testing.T) {
+	srv, _ := newDurableServer(t, state.DefaultJournalSize)
+	writer := dialHello(t, srv, "client_id=w")
+	mon := dialHello(t, srv, "client_id=mon")
+
+	const subscribe = "SUBSCRIBE:prefix=job.;durable=true"
+	mon.expect(subscribe, protocol.TypeAck)
+	writer.expect("CONTEXT:job.a=1", protocol.TypeAck)
+	if live := mon.recv(); live.Params["key"] != "job.a" || live.Params["new"] != "1" {
+		t.Fatalf("got %s, want the live UPDATE for job.a", live)
+	}
+	mon.conn.Close()
+	waitFor(t, func() bool { return srv.ConnectionCount() == 1 })
+
+	// Missed while away, in this order
+	writer.expect("CONTEXT:job.a=2", protocol.TypeAck)
+	writer.expect("CONTEXT:other=x", protocol.TypeAck)
+	writer.expect("CONTEXT:job.b=3", protocol.TypeAck)
+	writer.expect("REMOVE:key=job.a", protocol.TypeAck)
+
+	mon = dial(t, srv)
+	if hello := mon.expect("HELLO:version="+config.ProtocolVersion+";client_id=mon", protocol.TypeHello); hello.Params["durable"] != "1" {
+		t.Fatalf("HELLO = %s, want one durable subscription waiting", hello)
+	}
+	ack, updates := resubscribe(mon, subscribe, 3)
+	if ack.Params["replayed"] != "3" || ack.Params["gap"] != "false" {
+		t.Fatalf("ACK = %s, want 3 replayed and no gap", ack)
+	}
+	for i, want := range []struct{ key, value, deleted string }{
+		{"job.a", "2", ""},
+		{"job.b", "3", ""},
+		{"job.a", "", "true"},
+	} {
+		u := updates[i]
+		if u.Params["key"] != want.key || u.Params["new"] != want.value || u.Params["deleted"] != want.deleted || u.Params["replayed"] != "true" {
+			t.Fatalf("replayed UPDATE %d = %s, want %s=%q deleted=%q", i, u, want.key, want.value, want.deleted)
+		}
+	}
+
+	// Then live delivery resumes
+	writer.expect("CONTEXT:job.c=4", protocol.TypeAck)
+	if live := mon.recv(); live.Params["key"] != "job.c" || live.Params["replayed"] != "" {
+		t.Fatalf("got %s, want the live UPDATE for job.c", live)
+	}
+}
+
+func TestDurableSubscriptionReportsGap(t *testing.T) {
+	srv, _ := newDurableServer(t, 2)
+	writer := dialHello(t, srv, "client_id=w")
+	mon := dialHello(t, srv, "client_id=mon")
+
+	const subscribe = "SUBSCRIBE:key=k;durable=true"
+	mon.expect(subscribe, protocol.TypeAck)
+	mon.conn.Close()
+	waitFor(t, func() bool { return srv.ConnectionCount() == 1 })
+
+	// More changes than the journal holds
+	for i := 1; i <= 5; i++ {
+		writer.expect("CONTEXT:k="+strconv.Itoa(i), protocol.TypeAck)
+	}
+
+	mon = dialHello(t, srv, "client_id=mon")
+	ack, updates := resubscribe(mon, subscribe, 2)
+	if ack.Params["gap"] != "true" || ack.Params["replayed"] != "2" {
+		t.Fatalf("ACK = %s, want a gap and the 2 changes still journaled", ack)
+	}
+	if updates[0].Params["new"] != "4" || updates[1].Params["new"] != "5" {
+		t.Fatalf("replayed %s then %s, want k=4 then k=5", updates[0], updates[1])
+	}
+}
+
+func TestDurableSubscriptionLifecycle(t *testing.T) {
+	srv, clock := newDurableServer(t, state.DefaultJournalSize)
+	dialHello(t, srv, "").expectError("SUBSCRIBE:key=k;durable=true", protocol.ReasonInvalidParams)
+
+	mon := dialHello(t, srv, "client_id=mon")
+	mon.expect("SUBSCRIBE:key=a;durable=true", protocol.TypeAck)
+	mon.expect("SUBSCRIBE:prefix=b;durable=true", protocol.TypeAck)
+	list := mon.expect("SUBSCRIPTIONS:", protocol.TypeResult)
+	if list.Params["count"] != "2" || list.Params["0.key"] != "a" || list.Params["1.prefix"] != "true" || list.Params["0.active"] != "true" {
+		t.Fatalf("SUBSCRIPTIONS = %s", list)
+	}
+
+	// Deletable
+	mon.expect("UNSUBSCRIBE:key=a", protocol.TypeAck)
+	if list := mon.expect("SUBSCRIPTIONS:", protocol.TypeResult); list.Params["count"] != "1" {
+		t.Fatalf("SUBSCRIPTIONS after UNSUBSCRIBE = %s", list)
+	}
+
+	// Bounded per client ID
+	for i := 1; i < config.MaxDurableSubscriptions; i++ {
+		mon.expect("SUBSCRIBE:key=q"+strconv.Itoa(i)+";durable=true", protocol.TypeAck)
+	}
+	mon.expectError("SUBSCRIBE:key=over;durable=true", protocol.ReasonQuotaExceeded)
+
+	// Expire once the client ID is left unclaimed too long
+	mon.conn.Close()
+	waitFor(t, func() bool { return srv.ConnectionCount() == 1 })
+	clock.Advance((config.DurableSubscriptionTTL + 1) * time.Second)
+	mon = dial(t, srv)
+	if hello := mon.expect("HELLO:version="+config.ProtocolVersion+";client_id=mon", protocol.TypeHello); hello.Params["durable"] != "" {
+		t.Fatalf("HELLO = %s, want no durable subscriptions left", hello)
+	}
+	if list := mon.expect("SUBSCRIPTIONS:", protocol.TypeResult); list.Params["count"] != "0" {
+		t.Fatalf("SUBSCRIPTIONS after expiry = %s", list)
+	}
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+func TestMalformedLineGetsError(t *testing.T) {
+	srv := newTestServer(t, nil)
+	c := dialHello(t, srv, "")
+
+	for _, line := range []string{
+		"no separator",
+		":k=v",
+		"CONTEXT:k",
+		`CONTEXT:k=a\q`,
+	} {
+		// Read the reply as a raw line, so a reply that would not parse
+		// fails here rather than in the helper
+		c.send(line)
+		reply, err := c.read(testTimeout)
+		if err != nil {
+			t.Fatalf("%q: no well-formed reply: %v", line, err)
+		}
+		if reply.Type != protocol.TypeError ||
+			reply.Params["code"] != strconv.Itoa(http.StatusBadRequest) ||
+			reply.Params["reason"] != protocol.ReasonParseFailed ||
+			reply.Params["detail"] == "" {
+			t.Errorf("%q: got %s, want ERROR code=400 reason=parse_failed with a detail", line, reply)
+		}
+	}
+
+	// The connection survives bad lines
+	c.expect("PING:", protocol.TypePong)
+	if got := srv.GetStats().ParseErrors; got != 4 {
+		t.Fatalf("ParseErrors = %d, want 4", got)
+	}
+}
+
+func TestErrorsCarryRecoveredID(t *testing.T) {
+	c := dialHello(t, newTestServer(t, func(cfg *config.Config) {
+		cfg.UnknownTypePolicy = "error"
+	}), "")
+
+	tests := []struct {
+		line, reason, id string
+	}{
+		{`CONTEXT:k;id=m\;1`, protocol.ReasonParseFailed, "m;1"},
+		{`CONTEXT:a=\q;id=m2`, protocol.ReasonParseFailed, "m2"},
+		{"CONTEXT:k", protocol.ReasonParseFailed, ""},
+		{"FROB:x=1;id=u1", protocol.ReasonUnknownType, "u1"},
+		{"CONTEXT:_ttl=soon;k=v;id=p1", protocol.ReasonInvalidParams, "p1"},
+	}
+	for _, tt := range tests {
+		reply := c.expectError(tt.line, tt.reason)
+		id, found := reply.ID()
+		if id != tt.id || found != (tt.id != "") {
+			t.Errorf("%q: got %s, want id %q", tt.line, reply, tt.id)
+		}
+		if reply.Params["code"] == "" || reply.Params["detail"] == "" {
+			t.Errorf("%q: got %s, want a code and a detail", tt.line, reply)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+)
+
+// expiredMessage builds the EXPIRED notice for a value the sweeper removed
+func expiredMessage(exp state.Expiry) protocol.Message {
+	return protocol.NewMessage(protocol.TypeExpired, map[string]string{
+		"key":        exp.Key,
+		"value":      exp.Value,
+		"set_at":     exp.SetAt.UTC().Format(time.RFC3339Nano),
+		"expired_at": exp.ExpiredAt.UTC().Format(time.RFC3339Nano),
+	})
+}
+
+// notifyExpiry sends an EXPIRED notice to the connections bound to the
+// client that owned the value. A client with no connection gets the notice
+// when it next claims its client ID in HELLO; only the newest
+// ExpiryBacklogSize notices are kept for it.
+func (s *Server) notifyExpiry(exp state.Expiry) {
+	msg := expiredMessage(exp)
+
+	s.mu.Lock()
+	var targets []*Connection
+	for _, conn := range s.connections {
+		if conn.clientID == exp.ClientID {
+			targets = append(targets, conn)
+		}
+	}
+	if len(targets) == 0 {
+		backlog := append(s.expiryBacklog[exp.ClientID], msg)
+		if len(backlog) > config.ExpiryBacklogSize {
+			backlog = backlog[len(backlog)-config.ExpiryBacklogSize:]
+		}
+		s.expiryBacklog[exp.ClientID] = backlog
+	}
+	s.mu.Unlock()
+
+	for _, conn := range targets {
+		if err := conn.Send(msg); err != nil {
+			conn.logger.Warning("Failed to send expiry of %s: %v", exp.Key, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+	"github.com/Artimus100/mcp-server-go/internal/utils"
+)
+
+// newExpiryServer starts a server whose store runs on a test clock, so
+// tests expire keys by advancing it and calling Sweep
+func newExpiryServer(t *testing.T) (*Server, *testClock) {
+	t.Helper()
+
+	clock := newTestClock()
+	cfg := config.Default()
+	cfg.Port = 0
+	store := state.NewContextStore(state.WithClock(clock.Now))
+	return startTestServer(t, NewServer(cfg, store, utils.NewLoggerTo(io.Discard, "test"))), clock
+}
+
+// expectExpired fails the test unless msg is the EXPIRED notice for key
+// holding value
+func expectExpired(t *testing.T, msg protocol.Message, key, value string) {
+	t.Helper()
+
+	if msg.Type != protocol.TypeExpired || msg.Params["key"] != key || msg.Params["value"] != value {
+		t.Fatalf("got %s, want EXPIRED for %s=%s", msg, key, value)
+	}
+}
+
+func TestExpiryNotifiesConnectedClient(t *testing.T) {
+	srv, clock := newExpiryServer(t)
+	c := dialHello(t, srv, "client_id=agent")
+	setAt := clock.Now()
+	c.expect("CONTEXT:job=run;_ttl=5s;_notify_expiry=true", protocol.TypeAck)
+	c.expect("CONTEXT:quiet=q;_ttl=5s", protocol.TypeAck)
+
+	clock.Advance(6 * time.Second)
+	srv.store.Sweep()
+
+	msg := c.recv()
+	expectExpired(t, msg, "job", "run")
+	if msg.Params["set_at"] != setAt.UTC().Format(time.RFC3339Nano) {
+		t.Fatalf("set_at = %s, want %s", msg.Params["set_at"], setAt.UTC().Format(time.RFC3339Nano))
+	}
+	// Keys set without _notify_expiry vanish silently
+	if reply := c.request("PING:"); reply.Type != protocol.TypePong {
+		t.Fatalf("got %s, want only one EXPIRED", reply)
+	}
+}
+
+func TestExpiryHeldUntilReconnect(t *testing.T) {
+	srv, clock := newExpiryServer(t)
+	c := dialHello(t, srv, "client_id=agent")
+	// Staggered so they expire, and are backlogged, in order
+	total := config.ExpiryBacklogSize + 2
+	for i := 0; i < total; i++ {
+		c.expect("CONTEXT:k"+strconv.Itoa(i)+"=v;_ttl="+strconv.Itoa(i+1)+"s;_notify_expiry=true", protocol.TypeAck)
+	}
+	c.conn.Close()
+	waitFor(t, func() bool { return srv.ConnectionCount() == 0 })
+
+	clock.Advance(time.Duration(total+1) * time.Second)
+	srv.store.Sweep()
+
+	// Only the newest notices are kept, delivered right after HELLO
+	c = dialHello(t, srv, "client_id=agent")
+	for i := total - config.ExpiryBacklogSize; i < total; i++ {
+		expectExpired(t, c.recv(), "k"+strconv.Itoa(i), "v")
+	}
+	if reply := c.request("PING:"); reply.Type != protocol.TypePong {
+		t.Fatalf("got %s, want the backlog exhausted", reply)
+	}
+
+	// Delivered notices are not sent again
+	c.conn.Close()
+	waitFor(t, func() bool { return srv.ConnectionCount() == 0 })
+	if reply := dialHello(t, srv, "client_id=agent").request("PING:"); reply.Type != protocol.TypePong {
+		t.Fatalf("got %s on the next connection, want no notices", reply)
+	}
+}
+
+func TestExpiryNotifiesEveryBoundConnection(t *testing.T) {
+	srv, clock := newExpiryServer(t)
+	first := dial(t, srv)
+	first.expect("HELLO:version="+config.ProtocolVersion+";client_id=agent", protocol.TypeHello)
+	second := dial(t, srv)
+	session := second.expect("HELLO:version="+config.ProtocolVersion, protocol.TypeHello).Params["session"]
+	bystander := dialHello(t, srv, "")
+
+	// HELLO lets one live connection hold an ID, so bind the second
+	// directly
+	srv.mu.Lock()
+	srv.connections[session].clientID = "agent"
+	srv.mu.Unlock()
+
+	first.expect("CONTEXT:job=run;_ttl=1s;_notify_expiry=true", protocol.TypeAck)
+	clock.Advance(2 * time.Second)
+	srv.store.Sweep()
+
+	expectExpired(t, first.recv(), "job", "run")
+	expectExpired(t, second.recv(), "job", "run")
+	if reply := bystander.request("PING:"); reply.Type != protocol.TypePong {
+		t.Fatalf("got %s on another client's connection", reply)
+	}
+}
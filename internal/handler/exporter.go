@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/Artimus100/mcp-server-go/internal/utils"
+)
+
+// startMetrics serves Prometheus metrics at /metrics on MetricsAddr:
+// readiness, connections, store size, file descriptor usage where it can be
+// read and everything the server records, such as messages by type and
+// parse errors. Readiness is also served at /ready for load balancer health
+// checks. Metrics are recorded in a new registry unless SetMetrics gave
+// one. Callers must hold s.mu, before any connection is accepted.
+func (s *Server) startMetrics() error {
+	registry, ok := s.metrics.(*utils.MetricsRegistry)
+	if !ok {
+		if s.metrics != utils.NopMetrics {
+			return fmt.Errorf("serving metrics on %s needs them recorded in a *utils.MetricsRegistry", s.cfg.MetricsAddr)
+		}
+		registry = utils.NewMetricsRegistry()
+		s.metrics = registry
+	}
+	registry.GaugeFunc("mcp_ready", func() float64 {
+		if s.Ready() {
+			return 1
+		}
+		return 0
+	})
+	registry.GaugeFunc("mcp_connections", func() float64 {
+		return float64(s.ConnectionCount())
+	})
+	registry.GaugeFunc("mcp_store_clients", func() float64 {
+		return float64(s.store.Stats().Clients)
+	})
+	registry.GaugeFunc("mcp_store_keys", func() float64 {
+		return float64(s.store.Stats().Keys)
+	})
+	if _, _, err := FDUsage(); err == nil {
+		registry.GaugeFunc("mcp_open_fds", func() float64 {
+			open, _, _ := FDUsage()
+			return float64(open)
+		})
+		registry.GaugeFunc("mcp_fd_limit", func() float64 {
+			_, limit, _ := FDUsage()
+			return float64(limit)
+		})
+	}
+
+	listener, err := net.Listen("tcp", s.cfg.MetricsAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for metrics: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry)
+	mux.HandleFunc("/ready", s.serveReady)
+	s.metricsServer = &http.Server{Handler: mux}
+	s.metricsListener = listener
+
+	go func() {
+		if err := s.metricsServer.Serve(listener); err != nil && err != http.ErrServerClosed {
+			s.logger.Error("Failed to serve metrics: %v", err)
+		}
+	}()
+	s.logger.Info("Serving metrics on %s", listener.Addr())
+	return nil
+}
+
+// serveReady answers 200 while the server is Ready and 503 otherwise, such
+// as while it is loading or short of file descriptors
+func (s *Server) serveReady(w http.ResponseWriter, r *http.Request) {
+	if !s.Ready() {
+		http.Error(w, "not ready", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ready")
+}
+
+// stopMetrics stops serving metrics, giving scrapes in progress until ctx
+// is done
+func (s *Server) stopMetrics(ctx context.Context) {
+	s.mu.RLock()
+	metricsServer := s.metricsServer
+	s.mu.RUnlock()
+	if metricsServer == nil {
+		return
+	}
+	if err := metricsServer.Shutdown(ctx); err != nil {
+		metricsServer.Close()
+	}
+}
+
+// MetricsAddr returns the address metrics are served on, or nil if they
+// are not served or the server has not been started
+func (s *Server) MetricsAddr() net.Addr {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.metricsListener == nil {
+		return nil
+	}
+	return s.metricsListener.Addr()
+}
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// scrape fetches /metrics from srv and returns each sample by its name and
+// labels as written, such as mcp_messages_received_total{type="PING"}
+func scrape(t *testing.T, srv *Server) map[string]float64 {
+	t.Helper()
+
+	resp, err := http.Get("http://" + srv.MetricsAddr().String() + "/metrics")
+	if err != nil {
+		t.Fatalf("scraping metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("scraping metrics: %s", resp.Status)
+	}
+
+	samples := make(map[string]float64)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("bad sample %q: %v", line, err)
+		}
+		samples[line[:i]] = value
+	}
+	return samples
+}
+
+// serveMetrics has the server serve metrics on an ephemeral local port
+func serveMetrics(cfg *config.Config) {
+	cfg.MetricsAddr = "127.0.0.1:0"
+}
+
+func TestMetricsCountPing(t *testing.T) {
+	srv := newTestServer(t, serveMetrics)
+	c := dialHello(t, srv, "")
+	c.expect("PING:", protocol.TypePong)
+
+	// PING is answered before it is counted
+	const received = `mcp_messages_received_total{type="PING"}`
+	waitFor(t, func() bool { return scrape(t, srv)[received] >= 1 })
+
+	samples := scrape(t, srv)
+	if samples[`mcp_messages_sent_total{type="PONG"}`] < 1 {
+		t.Errorf("no PONG counted as sent")
+	}
+	if samples[`mcp_handler_duration_seconds_count{type="HELLO"}`] != 1 {
+		t.Errorf("HELLO latency observed %v times, want 1", samples[`mcp_handler_duration_seconds_count{type="HELLO"}`])
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	srv := newTestServer(t, serveMetrics)
+	writer := dialHello(t, srv, "client_id=w")
+	dialHello(t, srv, "client_id=r").expect("PING:", protocol.TypePong)
+
+	writer.expect("CONTEXT:a=1;b=2", protocol.TypeAck)
+	writer.expectError("no delimiter", protocol.ReasonParseFailed)
+
+	samples := scrape(t, srv)
+	for name, want := range map[string]float64{
+		"mcp_connections":                             2,
+		"mcp_store_clients":                           1,
+		"mcp_store_keys":                              2,
+		"mcp_parse_errors_total":                      1,
+		`mcp_messages_received_total{type="HELLO"}`:   2,
+		`mcp_messages_received_total{type="CONTEXT"}`: 1,
+	} {
+		if got, ok := samples[name]; !ok || got != want {
+			t.Errorf("%s = %v (present %v), want %v", name, got, ok, want)
+		}
+	}
+
+	addr := srv.MetricsAddr().String()
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := http.Get("http://" + addr + "/metrics"); err == nil {
+		resp.Body.Close()
+		t.Fatal("metrics still served after Shutdown")
+	}
+}
+
+func TestReadinessServedOnMetricsAddr(t *testing.T) {
+	srv := newTestServer(t, serveMetrics)
+
+	// ready fetches /ready, returning its status code
+	ready := func() int {
+		resp, err := http.Get("http://" + srv.MetricsAddr().String() + "/ready")
+		if err != nil {
+			t.Fatalf("readiness check: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if code, gauge := ready(), scrape(t, srv)["mcp_ready"]; code != http.StatusOK || gauge != 1 {
+		t.Fatalf("started server: /ready %d, mcp_ready %v", code, gauge)
+	}
+
+	srv.fdPressure.Store(true)
+	if code, gauge := ready(), scrape(t, srv)["mcp_ready"]; code != http.StatusServiceUnavailable || gauge != 0 {
+		t.Fatalf("under fd pressure: /ready %d, mcp_ready %v", code, gauge)
+	}
+	srv.fdPressure.Store(false)
+
+	srv.SetLoading(true)
+	if code := ready(); code != http.StatusServiceUnavailable {
+		t.Fatalf("loading: /ready %d, want 503", code)
+	}
+	srv.SetLoading(false)
+	if code := ready(); code != http.StatusOK {
+		t.Fatalf("after loading: /ready %d, want 200", code)
+	}
+}
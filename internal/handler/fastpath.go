@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// pingLine is the raw form of a bare PING, the bulk of heartbeat traffic
+const pingLine = protocol.TypePing + ":"
+
+// Metric labels of the fast path, built once: a variadic slice passed
+// through the Metrics interface escapes and would be allocated per PING
+var (
+	pingLabels = []string{"type", protocol.TypePing}
+	pongLabels = []string{"type", protocol.TypePong}
+)
+
+// fastPing answers a bare PING directly from the raw line, skipping the map
+// allocations of Parse, NewMessage and Format. The reply carries the same
+// parameters as the one handlePing produces. It takes a token from the
+// connection's rate limit like any other message. It returns false, leaving
+// the line to the generic path, for anything but a bare PING, before the
+// handshake, when transform hooks are installed, since those expect
+// Messages, or when the rate limit is exhausted, so the generic path rejects
+// the PING and counts the drop.
+func (c *Connection) fastPing(line []byte) bool {
+	if string(bytes.Trim(line, " \t\r\n")) != pingLine {
+		return false
+	}
+	if c.version == "" {
+		// Rejected or counted as deprecated on the generic path
+		return false
+	}
+	if inbound, outbound := c.server.transforms(); inbound != nil || outbound != nil {
+		return false
+	}
+	if !c.limiter.allow(c.server.now()) {
+		return false
+	}
+
+	c.writeMu.Lock()
+	now := c.server.now()
+	c.pong = append(c.pong[:0], protocol.TypePong+":server_time="...)
+	c.pong = appendEscapedTime(c.pong, now.UTC())
+	c.pong = append(c.pong, ";time="...)
+	c.pong = strconv.AppendInt(c.pong, now.Unix(), 10)
+	c.pong = append(c.pong, ";uptime_ms="...)
+	c.pong = strconv.AppendInt(c.pong, now.Sub(c.server.startedAt).Milliseconds(), 10)
+	c.pong = append(c.pong, '\n')
+
+	c.server.metrics.Inc("mcp_messages_sent_total", pongLabels...)
+	err := c.write(c.pong)
+	c.writeMu.Unlock()
+
+	// Close takes writeMu to flush buffered messages
+	if err != nil {
+		c.logger.Error("Failed to send PONG: %v", err)
+		c.Close()
+	}
+	return true
+}
+
+// appendEscapedTime appends t in RFC 3339 form with its colons escaped as
+// protocol.EscapeValue would, without allocating
+func appendEscapedTime(buf []byte, t time.Time) []byte {
+	start := len(buf)
+	buf = t.AppendFormat(buf, time.RFC3339Nano)
+
+	colons := 0
+	for _, b := range buf[start:] {
+		if b == ':' {
+			colons++
+		}
+	}
+	if colons == 0 {
+		return buf
+	}
+
+	// Grow in place, then shift right to left inserting escapes
+	end := len(buf)
+	for i := 0; i < colons; i++ {
+		buf = append(buf, 0)
+	}
+	for r, w := end-1, len(buf)-1; r >= start; r-- {
+		buf[w] = buf[r]
+		w--
+		if buf[r] == ':' {
+			buf[w] = '\\'
+			w--
+		}
+	}
+
+	return buf
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// identity is an inbound transform that changes nothing but keeps every
+// message on the generic path
+func identity(c *Connection, msg protocol.Message) protocol.Message {
+	return msg
+}
+
+// pingServer starts a server on clock, on the generic path if generic is
+// set and otherwise eligible for the PING fast path
+func pingServer(t testing.TB, clock *testClock, generic bool) *Server {
+	srv := newUnstartedServer(t, nil)
+	srv.SetClock(clock.Now)
+	if generic {
+		srv.SetInboundTransform(identity)
+	}
+	return startTestServer(t, srv)
+}
+
+// rawReply sends line and returns the raw reply line
+func rawReply(t *testing.T, c *testConn, line string) string {
+	t.Helper()
+
+	c.send(line)
+	c.conn.SetReadDeadline(time.Now().Add(testTimeout))
+	reply, err := c.reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("%q: %v", line, err)
+	}
+	return reply
+}
+
+func TestPingFastPathConformance(t *testing.T) {
+	clock := newTestClock()
+	fast := dialHello(t, pingServer(t, clock, false), "")
+	generic := dialHello(t, pingServer(t, clock, true), "")
+
+	vectors := []struct {
+		line    string
+		advance time.Duration
+	}{
+		{"PING:", 0},
+		{"PING:", time.Second},
+		{"PING:", 1500 * time.Millisecond},
+		{"PING:", 123456789 * time.Nanosecond},
+		{"PING:\r", 10 * time.Millisecond},
+		{"  PING:\t", time.Hour},
+		{"PING:", 24*time.Hour + time.Microsecond},
+	}
+
+	for _, v := range vectors {
+		clock.Advance(v.advance)
+		want := rawReply(t, generic, v.line)
+		got := rawReply(t, fast, v.line)
+		if got != want {
+			t.Errorf("%q at %v:\nfast    %q\ngeneric %q", v.line, clock.Now(), got, want)
+		}
+		if msg, err := protocol.Parse(strings.TrimSuffix(got, "\n")); err != nil || msg.Type != protocol.TypePong {
+			t.Errorf("%q: fast reply %q does not parse as PONG: %v", v.line, got, err)
+		}
+	}
+}
+
+func TestAppendEscapedTimeMatchesEscapeValue(t *testing.T) {
+	for _, ts := range []time.Time{
+		time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 2, 29, 23, 59, 59, 999999999, time.UTC),
+		time.Date(1999, 12, 31, 12, 30, 15, 5000, time.UTC),
+	} {
+		want := protocol.EscapeValue(ts.Format(time.RFC3339Nano))
+		if got := string(appendEscapedTime([]byte("prefix"), ts)); got != "prefix"+want {
+			t.Errorf("%v: got %q, want %q", ts, got, "prefix"+want)
+		}
+	}
+}
+
+// benchmarkPing measures round trips of a bare PING, counting allocations
+// on both ends; the client side allocates nothing
+func benchmarkPing(b *testing.B, generic bool) {
+	srv := pingServer(b, newTestClock(), generic)
+	addr := srv.Addr()
+	conn, err := net.Dial(addr.Network(), addr.String())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	hello := []byte("HELLO:version=1.0\n")
+	ping := []byte("PING:\n")
+	if _, err := conn.Write(hello); err != nil {
+		b.Fatal(err)
+	}
+	if _, err := reader.ReadSlice('\n'); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := conn.Write(ping); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := reader.ReadSlice('\n'); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPingFastPath(b *testing.B) { benchmarkPing(b, false) }
+func BenchmarkPingGeneric(b *testing.B)  { benchmarkPing(b, true) }
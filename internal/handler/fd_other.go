@@ -0,0 +1,13 @@
+//go:build !unix
+
+package handler
+
+import (
+	"fmt"
+)
+
+// FDUsage returns the number of file descriptors the process has open and
+// its soft limit. It is only supported on Unix systems.
+func FDUsage() (open, limit int, err error) {
+	return 0, 0, fmt.Errorf("file descriptor usage is not available on this platform")
+}
@@ -0,0 +1,28 @@
+//go:build unix
+
+package handler
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// FDUsage returns the number of file descriptors the process has open and
+// its soft limit
+func FDUsage() (open, limit int, err error) {
+	var rlimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlimit); err != nil {
+		return 0, 0, fmt.Errorf("failed to read file descriptor limit: %v", err)
+	}
+
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		if entries, err = os.ReadDir("/dev/fd"); err != nil {
+			return 0, int(rlimit.Cur), fmt.Errorf("failed to count open file descriptors: %v", err)
+		}
+	}
+
+	// Reading the directory itself holds one descriptor
+	return len(entries) - 1, int(rlimit.Cur), nil
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/errs"
+)
+
+// fdPressurePause is how long accepting is paused after the process runs
+// out of file descriptors
+const fdPressurePause = 500 * time.Millisecond
+
+// reserveAcceptWait bounds the accept made with the reserve descriptor
+// released. The client that could not be accepted is already queued, so
+// waiting longer would only hold the reserve from the other accept loop.
+const reserveAcceptWait = 50 * time.Millisecond
+
+// isFDExhausted reports whether an accept error means the process or system
+// file descriptor limit has been reached
+func isFDExhausted(err error) bool {
+	return errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE)
+}
+
+// openReserveFD holds a spare file descriptor that can be released under fd
+// pressure to accept one more connection
+func (s *Server) openReserveFD() {
+	s.reserveMu.Lock()
+	defer s.reserveMu.Unlock()
+	s.openReserveFDLocked()
+}
+
+// openReserveFDLocked opens the spare descriptor. Callers must hold
+// s.reserveMu.
+func (s *Server) openReserveFDLocked() {
+	reserve, err := os.Open(os.DevNull)
+	if err != nil {
+		s.logger.Warning("Failed to open reserve file descriptor: %v", err)
+		return
+	}
+	s.reserveFD = reserve
+}
+
+// closeReserveFD releases the spare file descriptor
+func (s *Server) closeReserveFD() {
+	s.reserveMu.Lock()
+	defer s.reserveMu.Unlock()
+	s.closeReserveFDLocked()
+}
+
+// closeReserveFDLocked releases the spare descriptor. Callers must hold
+// s.reserveMu.
+func (s *Server) closeReserveFDLocked() {
+	if s.reserveFD != nil {
+		s.reserveFD.Close()
+		s.reserveFD = nil
+	}
+}
+
+// rejectWithReserve frees the reserve descriptor to accept one waiting
+// client from listener and turn it away with server_full, then takes the
+// reserve back. The accept is bounded by reserveAcceptWait, so a quiet
+// listener cannot keep the reserve released and later hand the freed
+// descriptor to a client it would have served.
+func (s *Server) rejectWithReserve(listener net.Listener) {
+	deadliner, ok := listener.(interface{ SetDeadline(time.Time) error })
+	if !ok {
+		return
+	}
+
+	s.reserveMu.Lock()
+	defer s.reserveMu.Unlock()
+	if s.reserveFD == nil {
+		return
+	}
+
+	s.closeReserveFDLocked()
+	if err := deadliner.SetDeadline(time.Now().Add(reserveAcceptWait)); err == nil {
+		if conn, err := listener.Accept(); err == nil {
+			rejectWith(conn, errs.New(errs.ErrServerFull, "server is out of file descriptors, retry later"))
+		}
+		deadliner.SetDeadline(time.Time{})
+	}
+	s.openReserveFDLocked()
+}
+
+// relieveFDPressure handles running out of file descriptors in the accept
+// loop. It warns once per episode, marks the server not ready, briefly
+// frees the reserve descriptor to accept and turn away one waiting client
+// with server_full, and pauses accepting. It returns false if the server
+// shuts down during the pause.
+func (s *Server) relieveFDPressure(listener net.Listener) bool {
+	if !s.fdPressure.Swap(true) {
+		// Counting open descriptors needs a descriptor itself, so the count
+		// is only reported if it could be taken
+		if open, limit, err := FDUsage(); err == nil {
+			s.logger.Warning("File descriptor limit reached (%d of %d open), pausing accepts", open, limit)
+		} else {
+			s.logger.Warning("File descriptor limit reached, pausing accepts")
+		}
+	}
+
+	s.rejectWithReserve(listener)
+
+	select {
+	case <-s.closeChan:
+		return false
+	case <-time.After(fdPressurePause):
+		return true
+	}
+}
+
+// clearFDPressure ends an fd pressure episode once accepting succeeds again
+func (s *Server) clearFDPressure() {
+	if s.fdPressure.Swap(false) {
+		s.logger.Info("File descriptors available again, accepting connections")
+	}
+}
+
+// rejectWith sends a single ERROR to a connection that is not going to be
+// served and closes it
+func rejectWith(conn net.Conn, err error) {
+	defer conn.Close()
+
+	reply := errorMessage(err)
+	conn.SetWriteDeadline(time.Now().Add(time.Second))
+	conn.Write([]byte(reply.Format() + "\n"))
+}
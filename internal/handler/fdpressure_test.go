@@ -0,0 +1,218 @@
+//go:build unix
+
+package handler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+	"github.com/Artimus100/mcp-server-go/internal/utils"
+)
+
+// fdChildEnv marks the test binary re-run as the server under fd pressure
+const fdChildEnv = "MCP_TEST_FD_CHILD"
+
+// TestFDPressureChild is the server side of TestFDExhaustion. It starts a
+// server logging to stderr, lowers its own descriptor limit to a few above
+// what is open, reports the port and limit on stdout and serves until
+// stdin closes.
+func TestFDPressureChild(t *testing.T) {
+	if os.Getenv(fdChildEnv) == "" {
+		t.Skip("run by TestFDExhaustion")
+	}
+
+	cfg := config.Default()
+	cfg.Port = 0
+	srv := NewServer(cfg, state.NewContextStore(), utils.NewLoggerTo(os.Stderr, "child"))
+	startTestServer(t, srv)
+
+	open, _, err := FDUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rlimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlimit); err != nil {
+		t.Fatal(err)
+	}
+	rlimit.Cur = uint64(open + 3)
+	if err := syscall.Setrlimit(syscall.RLIMIT_NOFILE, &rlimit); err != nil {
+		t.Fatal(err)
+	}
+
+	fmt.Printf("port=%d limit=%d\n", srv.Addr().(*net.TCPAddr).Port, rlimit.Cur)
+	io.Copy(io.Discard, os.Stdin)
+}
+
+func TestFDExhaustion(t *testing.T) {
+	if os.Getenv(fdChildEnv) != "" {
+		t.Skip("running as the child")
+	}
+
+	child := exec.Command(os.Args[0], "-test.run=^TestFDPressureChild$")
+	child.Env = append(os.Environ(), fdChildEnv+"=1")
+	var log syncBuffer
+	child.Stderr = &log
+	stdin, err := child.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := child.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := child.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer child.Wait()
+	defer stdin.Close()
+
+	var port, limit int
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if _, scanErr := fmt.Sscanf(line, "port=%d limit=%d", &port, &limit); err != nil || scanErr != nil {
+		t.Fatalf("child did not start: %q, %v\n%s", line, err, log.String())
+	}
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+
+	// hello connects and reports the reply to HELLO, if any
+	hello := func() (*testConn, protocol.Message) {
+		conn, err := net.DialTimeout("tcp", addr, testTimeout)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		c := &testConn{t: t, conn: conn, reader: bufio.NewReader(conn)}
+		return c, c.request("HELLO:version=" + config.ProtocolVersion)
+	}
+
+	// Fill the descriptors left until a client is turned away
+	var accepted []*testConn
+	for {
+		c, reply := hello()
+		if reply.Type == protocol.TypeHello {
+			accepted = append(accepted, c)
+			if len(accepted) > 10 {
+				t.Fatalf("%d connections accepted under a limit of %d", len(accepted), limit)
+			}
+			continue
+		}
+		if reply.Params["reason"] != protocol.ReasonServerFull {
+			t.Fatalf("got %s, want server_full", reply)
+		}
+		c.expectClosed()
+		break
+	}
+	if len(accepted) == 0 {
+		t.Fatal("no connection accepted before the limit")
+	}
+	// Still full: the next client is turned away too
+	if _, reply := hello(); reply.Params["reason"] != protocol.ReasonServerFull {
+		t.Fatalf("got %s, want server_full", reply)
+	}
+	// Clients accepted before the limit keep working
+	accepted[0].expect("PING:", protocol.TypePong)
+
+	for _, c := range accepted {
+		c.conn.Close()
+	}
+	c, reply := hello()
+	if reply.Type != protocol.TypeHello {
+		t.Fatalf("got %s once descriptors were freed, want HELLO", reply)
+	}
+	stats := c.expect("STATS:", protocol.TypeResult)
+	if stats.Params["fds_limit"] != strconv.Itoa(limit) || stats.Params["fds_open"] == "" {
+		t.Fatalf("STATS = %s, want fd usage against the limit of %d", stats, limit)
+	}
+
+	stdin.Close()
+	child.Wait()
+	out := log.String()
+	if n := strings.Count(out, "File descriptor limit reached"); n != 1 {
+		t.Fatalf("%d fd limit warnings, want 1:\n%s", n, out)
+	}
+	if strings.Contains(out, "Error accepting connection") {
+		t.Fatalf("accept errors logged under fd pressure:\n%s", out)
+	}
+	if !strings.Contains(out, "File descriptors available again") {
+		t.Fatalf("recovery not logged:\n%s", out)
+	}
+}
+
+func TestReserveAcceptIsBounded(t *testing.T) {
+	srv := newUnstartedServer(t, nil)
+	srv.openReserveFD()
+	defer srv.closeReserveFD()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// Nobody is waiting, so the reserve is taken back rather than held
+	// until some later client arrives
+	start := time.Now()
+	srv.rejectWithReserve(listener)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("rejectWithReserve blocked %v on a quiet listener", elapsed)
+	}
+	if srv.reserveFD == nil {
+		t.Fatal("reserve not reopened")
+	}
+
+	// A waiting client is turned away, and the listener accepts normally
+	// afterwards
+	conn, err := net.DialTimeout("tcp", listener.Addr().String(), testTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	srv.rejectWithReserve(listener)
+	c := &testConn{t: t, conn: conn, reader: bufio.NewReader(conn)}
+	if reply := c.recv(); reply.Params["reason"] != protocol.ReasonServerFull {
+		t.Fatalf("got %s, want server_full", reply)
+	}
+
+	go net.DialTimeout("tcp", listener.Addr().String(), testTimeout)
+	accepted, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Accept after the reserve was used: %v", err)
+	}
+	accepted.Close()
+}
+
+func TestReserveSharedByAcceptLoops(t *testing.T) {
+	srv := newUnstartedServer(t, nil)
+	srv.openReserveFD()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer listener.Close()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 3; j++ {
+				srv.rejectWithReserve(listener)
+			}
+		}()
+	}
+	srv.closeReserveFD()
+	wg.Wait()
+	srv.closeReserveFD()
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"fmt"
+)
+
+// flushBuffered writes out the connection's write buffer when FlushInterval
+// has passed since a message was buffered, closing the connection if that
+// fails
+func (c *Connection) flushBuffered() {
+	select {
+	case <-c.closeChan:
+		// Close flushed whatever could be
+		return
+	default:
+	}
+
+	c.writeMu.Lock()
+	c.flushPending = false
+	err := c.flushLocked()
+	c.writeMu.Unlock()
+
+	if err != nil {
+		c.logger.Error("Failed to flush buffered messages: %v", err)
+		c.Close()
+	}
+}
+
+// flushLocked writes out the connection's write buffer, if it has one.
+// Callers must hold c.writeMu.
+func (c *Connection) flushLocked() error {
+	if c.out == nil || c.out.Buffered() == 0 {
+		return nil
+	}
+	if err := c.conn.SetWriteDeadline(deadline(c.server.cfg.WriteTimeout)); err != nil {
+		return fmt.Errorf("failed to set write deadline: %v", err)
+	}
+	if err := c.out.Flush(); err != nil {
+		return fmt.Errorf("failed to send message: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+func TestFlushIntervalBoundsLatency(t *testing.T) {
+	const interval = 100 * time.Millisecond
+	srv := newTestServer(t, func(cfg *config.Config) { cfg.FlushInterval = interval })
+	c := dialHello(t, srv, "")
+
+	// One small reply never fills the buffer, so the timer flushes it
+	start := time.Now()
+	c.expect("PING:", protocol.TypePong)
+	if elapsed := time.Since(start); elapsed < interval || elapsed > 10*interval {
+		t.Fatalf("PONG after %v, want it held about %v", elapsed, interval)
+	}
+}
+
+func TestFullWriteBufferFlushedAtOnce(t *testing.T) {
+	srv := newTestServer(t, func(cfg *config.Config) { cfg.FlushInterval = time.Hour })
+	c := dial(t, srv)
+
+	// Enough PONGs to fill the buffer several times over; the replies
+	// arrive without waiting for the interval
+	pings := config.WriteBufferSize
+	c.send("HELLO:version=" + config.ProtocolVersion + "\n" + strings.Repeat("PING:\n", pings-1) + "PING:")
+	if hello := c.recv(); hello.Type != protocol.TypeHello {
+		t.Fatalf("got %s, want HELLO", hello)
+	}
+	for i := 0; i < pings/2; i++ {
+		if pong := c.recv(); pong.Type != protocol.TypePong {
+			t.Fatalf("reply %d: got %s, want PONG", i, pong)
+		}
+	}
+}
@@ -0,0 +1,733 @@
+package handler
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/errs"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+)
+
+// builtinHandlers are the handlers for the message types the server
+// understands out of the box. Every server's registry starts with them.
+var builtinHandlers = map[string]HandlerFunc{
+	protocol.TypeAuth:          (*Connection).handleAuth,
+	protocol.TypeHello:         (*Connection).handleHello,
+	protocol.TypePing:          (*Connection).handlePing,
+	protocol.TypeTime:          (*Connection).handleTime,
+	protocol.TypeContext:       (*Connection).handleContextUpdate,
+	protocol.TypeContextBegin:  (*Connection).handleContextBegin,
+	protocol.TypeContextChunk:  (*Connection).handleContextChunk,
+	protocol.TypeContextEnd:    (*Connection).handleContextEnd,
+	protocol.TypeGet:           (*Connection).handleGet,
+	protocol.TypeRemove:        (*Connection).handleRemove,
+	protocol.TypeSubscribe:     (*Connection).handleSubscribe,
+	protocol.TypeUnsubscribe:   (*Connection).handleUnsubscribe,
+	protocol.TypeSubscriptions: (*Connection).handleSubscriptions,
+	protocol.TypeWatchAll:      (*Connection).handleWatchAll,
+	protocol.TypeSpecs:         (*Connection).handleSpecs,
+	protocol.TypeQuery:         (*Connection).handleQuery,
+	protocol.TypeList:          (*Connection).handleList,
+	protocol.TypeUsage:         (*Connection).handleUsage,
+	protocol.TypeStats:         (*Connection).handleStats,
+	protocol.TypeSchedules:     (*Connection).handleSchedules,
+	protocol.TypeCancel:        (*Connection).handleCancel,
+	protocol.TypeKick:          (*Connection).handleKick,
+	protocol.TypeDrain:         (*Connection).handleDrain,
+	protocol.TypeExport:        (*Connection).handleExport,
+	protocol.TypeLogLevel:      (*Connection).handleLogLevel,
+	protocol.TypeClearAll:      (*Connection).handleClearAll,
+	protocol.TypeReload:        (*Connection).handleReload,
+}
+
+// dispatch routes a message to the handler registered for its type,
+// returning the response to send. Errors are reported to the client as
+// ERROR messages. Messages of unknown type are answered according to
+// the configured UnknownTypePolicy.
+func (c *Connection) dispatch(msg protocol.Message) (protocol.Message, error) {
+	fn, exists := c.server.handlers.lookup(msg.Type)
+	if !exists {
+		c.logger.Warning("Unknown message type: %s", msg.Type)
+		switch c.server.cfg.UnknownTypePolicy {
+		case "error":
+			return protocol.Message{}, errs.New(errs.ErrUnknownType, "unknown message type %s", msg.Type)
+		case "ack":
+			return ackMessage(), nil
+		default:
+			return protocol.Message{}, nil
+		}
+	}
+
+	return fn(c, msg)
+}
+
+// ackMessage builds the acknowledgment sent for successful requests
+func ackMessage() protocol.Message {
+	return protocol.NewMessage(protocol.TypeAck, map[string]string{
+		"status": "ok",
+	})
+}
+
+// handleHello negotiates the protocol version. The requested version must be
+// one of config.SupportedProtocolVersions; the reply echoes it along with the
+// session ID and the server clock. A client may also state the largest
+// message it accepts in max_message_size; both sides then keep to the
+// smaller of that and the server's limit, which the reply reports in
+// max_message_size. A client_id parameter gives a stable identity whose
+// context outlives the connection, so a client reconnecting with the same
+// client_id finds its context again; only one live connection may claim a
+// client_id at a time. client_id is not authenticated. With resume=true a
+// client whose previous connection has not yet been noticed as dead takes
+// the client_id over instead of being refused: the old connection's
+// subscriptions move to the new one, which the reply reports in migrated,
+// and the old connection is sent GOODBYE (reason superseded) and closed.
+// A server with an AuthFunc passes it the HELLO parameters first; it may
+// refuse the client, which is then disconnected, or bind its client ID.
+// The reply reports the ID the context is stored under in client_id.
+// A claimed client ID also gets a reconnect_token in the reply; a later
+// HELLO may pass it as reconnect_token instead of client_id to claim the
+// same ID again, within ReconnectTokenTTL and only once, as each use
+// replaces the token with a new one.
+// low_latency turns Nagle's algorithm off (true) or on (false) for the
+// connection, overriding the server's NoDelay setting; the reply echoes it
+// if it applied, which it does not on non-TCP connections.
+func (c *Connection) handleHello(msg protocol.Message) (protocol.Message, error) {
+	if c.version != "" {
+		return protocol.Message{}, errs.New(errs.ErrAlreadyNegotiated, "protocol version %s already negotiated", c.version)
+	}
+
+	version, ok := msg.Params["version"]
+	if !ok || version == "" {
+		return protocol.Message{}, errs.New(errs.ErrInvalidParams, "missing version parameter")
+	}
+
+	supported := false
+	for _, v := range config.SupportedProtocolVersions {
+		if v == version {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return protocol.Message{}, errs.New(errs.ErrVersionMismatch, "version %s not supported, supported versions: %s",
+			version, strings.Join(config.SupportedProtocolVersions, ","))
+	}
+
+	limit := c.server.messageSizeLimit()
+	if raw, ok := msg.Params["max_message_size"]; ok {
+		size, err := strconv.Atoi(raw)
+		if err != nil || size < 1 {
+			return protocol.Message{}, errs.New(errs.ErrInvalidParams, "max_message_size must be a positive integer, got %q", raw)
+		}
+		if size < limit {
+			limit = size
+		}
+	}
+
+	var lowLatency *bool
+	if raw, ok := msg.Params["low_latency"]; ok {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return protocol.Message{}, errs.New(errs.ErrInvalidParams, "low_latency must be true or false, got %q", raw)
+		}
+		lowLatency = &value
+	}
+
+	resume := false
+	if raw, ok := msg.Params["resume"]; ok {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return protocol.Message{}, errs.New(errs.ErrInvalidParams, "resume must be true or false, got %q", raw)
+		}
+		resume = value
+	}
+
+	clientID, hasClientID := msg.Params["client_id"]
+	params := msg.Params
+	if token, ok := msg.Params["reconnect_token"]; ok {
+		if hasClientID {
+			return protocol.Message{}, errs.New(errs.ErrInvalidParams, "client_id and reconnect_token are mutually exclusive")
+		}
+		resolved, ok := c.server.sessions.Resolve(token)
+		if !ok {
+			return protocol.Message{}, errs.New(errs.ErrNotFound, "reconnect token is unknown or expired")
+		}
+		clientID, hasClientID = resolved, true
+
+		// The hook judges the client ID the token stands for
+		params = make(map[string]string, len(msg.Params))
+		for key, value := range msg.Params {
+			params[key] = value
+		}
+		params["client_id"] = resolved
+	}
+	bound, err := c.authorizeHello(params)
+	if err != nil {
+		c.logger.Warning("Refusing HELLO: %v", err)
+		return protocol.Message{}, err
+	}
+	if bound != "" {
+		clientID, hasClientID = bound, true
+	}
+
+	var token string
+	if hasClientID {
+		if clientID == "" {
+			return protocol.Message{}, errs.New(errs.ErrInvalidParams, "client_id must not be empty")
+		}
+		// Issued before claiming so a failure leaves the ID unclaimed
+		token, err = c.server.sessions.Issue(clientID, config.ReconnectTokenTTL*time.Second)
+		if err != nil {
+			c.logger.Error("Refusing HELLO: %v", err)
+			return protocol.Message{}, err
+		}
+		old, err := c.server.claimClientID(c, clientID, resume)
+		if err != nil {
+			c.server.sessions.Revoke(token)
+			return protocol.Message{}, err
+		}
+		if used, ok := msg.Params["reconnect_token"]; ok {
+			// Each token resumes the client ID once
+			c.server.sessions.Revoke(used)
+		}
+		if old != nil {
+			old.retire()
+		}
+		c.logger.Info("Storing context under client ID %s", clientID)
+	} else if resume {
+		return protocol.Message{}, errs.New(errs.ErrInvalidParams, "resume needs a client_id")
+	}
+
+	c.version = version
+	c.maxMessage = limit
+	c.logger.Info("Negotiated protocol version %s with message size limit %d", version, limit)
+	c.seedTemplate()
+
+	params = c.server.timeParams(c.server.now())
+	params["version"] = version
+	params["session"] = c.id
+	params["client_id"] = c.clientID
+	params["max_message_size"] = strconv.Itoa(limit)
+	if c.resumable > 0 {
+		params["durable"] = strconv.Itoa(c.resumable)
+	}
+	if resume {
+		params["migrated"] = strconv.Itoa(c.migrated)
+	}
+	if token != "" {
+		params["reconnect_token"] = token
+	}
+
+	if lowLatency != nil && !c.admin {
+		tcp, err := setNoDelay(c.conn, *lowLatency)
+		if err != nil {
+			c.logger.Warning("Failed to set TCP no-delay: %v", err)
+		} else if tcp {
+			c.noDelay.Store(*lowLatency)
+			params["low_latency"] = strconv.FormatBool(*lowLatency)
+		}
+	}
+
+	return protocol.NewMessage(protocol.TypeHello, params), nil
+}
+
+// handlePing responds to ping messages with the server clock
+func (c *Connection) handlePing(msg protocol.Message) (protocol.Message, error) {
+	c.logger.Info("Ping received with params: %v", msg.Params)
+
+	return protocol.NewMessage(protocol.TypePong, c.server.timeParams(c.server.now())), nil
+}
+
+// handleContextUpdate processes context updates. With an _at (RFC 3339
+// time) or _in (duration) parameter the update is scheduled rather than
+// applied, and the reply carries a schedule.<key> parameter with the
+// schedule ID for each key. _persist=true keeps the client's context when
+// the connection closes even if the server is configured to clear it. A
+// _ttl (duration) parameter makes the values expire, and with
+// _notify_expiry=true the client is sent an EXPIRED notice when they do.
+//
+// The keys of one CONTEXT are applied atomically: other clients see none
+// or all of them, and the ACK is sent once all are stored. If any key or
+// value is invalid, nothing is applied.
+func (c *Connection) handleContextUpdate(msg protocol.Message) (protocol.Message, error) {
+	// Log context update
+	c.logger.Info("Context update received with params: %v", msg.Params)
+
+	var persist *bool
+	if raw, ok := msg.Params["_persist"]; ok {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return protocol.Message{}, errs.New(errs.ErrInvalidParams, "_persist must be true or false, got %q", raw)
+		}
+		delete(msg.Params, "_persist")
+		persist = &value
+	}
+
+	ttl, notifyExpiry, err := parseTTL(msg.Params)
+	if err != nil {
+		return protocol.Message{}, err
+	}
+
+	fireAt, scheduled, err := parseFireTime(msg.Params, c.server.now())
+	if err != nil {
+		return protocol.Message{}, err
+	}
+	if scheduled && ttl > 0 {
+		return protocol.Message{}, errs.New(errs.ErrInvalidParams, "_ttl cannot be combined with _at or _in")
+	}
+
+	// Every reserved parameter this handler knows has been removed, so an
+	// underscore left over is a misspelt one rather than a key to store
+	for key := range msg.Params {
+		if key == "" || strings.HasPrefix(key, "_") {
+			return protocol.Message{}, errs.New(errs.ErrInvalidParams, "invalid key %q: keys must not be empty or start with _", key)
+		}
+	}
+
+	// Apply the empty value policy before anything is stored
+	var removals []string
+	switch c.server.emptyValuePolicy() {
+	case EmptyValueReject:
+		for key, value := range msg.Params {
+			if value == "" {
+				return protocol.Message{}, errs.New(errs.ErrInvalidParams, "empty value for key %s", key)
+			}
+		}
+	case EmptyValueDelete:
+		for key, value := range msg.Params {
+			if value == "" {
+				removals = append(removals, key)
+				delete(msg.Params, key)
+			}
+		}
+	}
+
+	// Reject the whole update if any value violates a key spec
+	if err := c.server.keySpecs.validate(msg.Params); err != nil {
+		return protocol.Message{}, err
+	}
+
+	if err := c.takeMutations(len(msg.Params) + len(removals)); err != nil {
+		return protocol.Message{}, err
+	}
+
+	if persist != nil {
+		c.persist.Store(*persist)
+	}
+
+	if scheduled {
+		ack := ackMessage()
+		for key, value := range msg.Params {
+			ack.Params["schedule."+key] = c.store.ScheduleSet(c.clientID, key, value, fireAt)
+		}
+		for _, key := range removals {
+			ack.Params["schedule."+key] = c.store.ScheduleDelete(c.clientID, key, fireAt)
+		}
+		return ack, nil
+	}
+
+	c.store.Apply(c.clientID, state.Batch{
+		Set:          msg.Params,
+		Remove:       removals,
+		TTL:          ttl,
+		NotifyExpiry: notifyExpiry,
+	})
+
+	return ackMessage(), nil
+}
+
+// parseTTL removes the _ttl and _notify_expiry parameters from params and
+// returns the TTL they name, or zero if there is none
+func parseTTL(params map[string]string) (time.Duration, bool, error) {
+	rawTTL, hasTTL := params["_ttl"]
+	rawNotify, hasNotify := params["_notify_expiry"]
+	delete(params, "_ttl")
+	delete(params, "_notify_expiry")
+
+	var ttl time.Duration
+	if hasTTL {
+		var err error
+		ttl, err = time.ParseDuration(rawTTL)
+		if err != nil || ttl <= 0 {
+			return 0, false, errs.New(errs.ErrInvalidParams, "_ttl is not a positive duration: %s", rawTTL)
+		}
+	}
+
+	var notify bool
+	if hasNotify {
+		var err error
+		notify, err = strconv.ParseBool(rawNotify)
+		if err != nil {
+			return 0, false, errs.New(errs.ErrInvalidParams, "_notify_expiry must be true or false, got %q", rawNotify)
+		}
+		if notify && !hasTTL {
+			return 0, false, errs.New(errs.ErrInvalidParams, "_notify_expiry requires _ttl")
+		}
+	}
+
+	return ttl, notify, nil
+}
+
+// parseFireTime removes the _at or _in scheduling parameter from params and
+// returns the time it names, with _in counted from now. The boolean is false
+// if neither is present.
+func parseFireTime(params map[string]string, now time.Time) (time.Time, bool, error) {
+	at, hasAt := params["_at"]
+	in, hasIn := params["_in"]
+	delete(params, "_at")
+	delete(params, "_in")
+
+	switch {
+	case hasAt && hasIn:
+		return time.Time{}, false, errs.New(errs.ErrInvalidParams, "_at and _in parameters are mutually exclusive")
+	case hasAt:
+		fireAt, err := time.Parse(time.RFC3339Nano, at)
+		if err != nil {
+			return time.Time{}, false, errs.New(errs.ErrInvalidParams, "_at is not an RFC 3339 time: %s", at)
+		}
+		return fireAt, true, nil
+	case hasIn:
+		delay, err := time.ParseDuration(in)
+		if err != nil || delay < 0 {
+			return time.Time{}, false, errs.New(errs.ErrInvalidParams, "_in is not a non-negative duration: %s", in)
+		}
+		return now.Add(delay), true, nil
+	default:
+		return time.Time{}, false, nil
+	}
+}
+
+// handleRemove deletes this client's keys named by the parameter values, as
+// GET names the keys it fetches, all at once, and replies with how many
+// were set in removed. Each listed key counts against the mutation limit.
+func (c *Connection) handleRemove(msg protocol.Message) (protocol.Message, error) {
+	if len(msg.Params) == 0 {
+		return protocol.Message{}, errs.New(errs.ErrInvalidParams, "no keys to remove")
+	}
+
+	keys := make([]string, 0, len(msg.Params))
+	for _, key := range msg.Params {
+		if key == "" {
+			return protocol.Message{}, errs.New(errs.ErrInvalidParams, "empty key")
+		}
+		keys = append(keys, key)
+	}
+	if err := c.takeMutations(len(keys)); err != nil {
+		return protocol.Message{}, err
+	}
+
+	removed := c.store.DeleteMultiple(c.clientID, keys)
+	c.logger.Info("Removed %d of %d keys", removed, len(keys))
+
+	response := ackMessage()
+	response.Params["removed"] = strconv.Itoa(removed)
+	return response, nil
+}
+
+// handleGet replies with the requested context values for this client. The
+// parameter values name the keys to fetch; keys that are not set are omitted
+// from the result. A GET with no parameters returns every value. Values that
+// would push the reply over the message size limit are streamed ahead of
+// it; see chunked.go. A _client parameter of state.ServerClientID reads the
+// server's published statistics instead; other clients cannot be read.
+func (c *Connection) handleGet(msg protocol.Message) (protocol.Message, error) {
+	var values map[string]string
+
+	owner := c.clientID
+	if target, ok := msg.Params["_client"]; ok {
+		if target != state.ServerClientID {
+			return protocol.Message{}, errs.New(errs.ErrInvalidParams, "_client may only be %s", state.ServerClientID)
+		}
+		owner = target
+		delete(msg.Params, "_client")
+	}
+
+	if len(msg.Params) == 0 {
+		values, _ = c.store.GetAll(owner)
+	} else {
+		// Read the keys in one go so a concurrent multi-key write is seen
+		// either entirely or not at all
+		keys := make([]string, 0, len(msg.Params))
+		for _, key := range msg.Params {
+			keys = append(keys, key)
+		}
+		values = c.store.GetMultiple(owner, keys)
+	}
+
+	if err := c.streamLargeValues(values); err != nil {
+		return protocol.Message{}, err
+	}
+
+	return protocol.NewMessage(protocol.TypeResult, values), nil
+}
+
+// handleQuery replies with the IDs of clients whose key is set to value. The
+// IDs are sorted and encoded with protocol.JoinList in a clients parameter,
+// with count giving the total number of matches. At most limit IDs are
+// returned, capped at config.MaxQueryResults, and fewer if the reply would
+// otherwise exceed the message size limit; truncated is set when some were
+// left out. The server's published statistics are only matched when
+// include_server is true.
+func (c *Connection) handleQuery(msg protocol.Message) (protocol.Message, error) {
+	key, hasKey := msg.Params["key"]
+	value, hasValue := msg.Params["value"]
+	if !hasKey || key == "" || !hasValue {
+		return protocol.Message{}, errs.New(errs.ErrInvalidParams, "QUERY needs key and value parameters")
+	}
+
+	limit := config.MaxQueryResults
+	if raw, ok := msg.Params["limit"]; ok {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return protocol.Message{}, errs.New(errs.ErrInvalidParams, "limit must be a positive integer, got %q", raw)
+		}
+		if n < limit {
+			limit = n
+		}
+	}
+
+	includeServer := false
+	if raw, ok := msg.Params["include_server"]; ok {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return protocol.Message{}, errs.New(errs.ErrInvalidParams, "include_server must be true or false, got %q", raw)
+		}
+		includeServer = b
+	}
+
+	clients := c.store.QueryClients(key, value, includeServer)
+	sort.Strings(clients)
+
+	params := map[string]string{
+		"count": strconv.Itoa(len(clients)),
+	}
+	if len(clients) > limit {
+		clients = clients[:limit]
+		params["truncated"] = "true"
+	}
+	c.fitList(params, "clients", clients)
+
+	return protocol.NewMessage(protocol.TypeResult, params), nil
+}
+
+// handleList replies with this client's keys matching the glob in the
+// optional pattern parameter, or all its keys without one or with an empty
+// one; see state.ContextStore.MatchKeys for the syntax. The keys are sorted
+// and encoded with protocol.JoinList in a keys parameter, with count giving
+// the number of matches. Keys are dropped from the end if the reply would
+// exceed the message size limit, and truncated is then set.
+func (c *Connection) handleList(msg protocol.Message) (protocol.Message, error) {
+	keys, err := c.store.MatchKeys(c.clientID, msg.Params["pattern"])
+	if err != nil {
+		return protocol.Message{}, err
+	}
+
+	params := map[string]string{
+		"count": strconv.Itoa(len(keys)),
+	}
+	c.fitList(params, "keys", keys)
+
+	return protocol.NewMessage(protocol.TypeResult, params), nil
+}
+
+// fitList sets params[key] to items encoded with protocol.JoinList, dropping
+// items from the end if the RESULT reply would exceed the message size
+// limit, and setting truncated if it does. The reply is measured as sent,
+// with the request's correlation id and the truncated flag included.
+func (c *Connection) fitList(params map[string]string, key string, items []string) {
+	measured := make(map[string]string, len(params)+3)
+	for k, v := range params {
+		measured[k] = v
+	}
+	measured[key] = protocol.JoinList(items)
+	reply := protocol.NewResponse(c.request, protocol.TypeResult, measured)
+	maxSize := c.messageSizeLimit()
+	if len(reply.Format()) <= maxSize {
+		params[key] = measured[key]
+		return
+	}
+
+	measured["truncated"] = "true"
+	measured[key] = ""
+	n := protocol.FitList(items, maxSize-len(reply.Format()))
+	params["truncated"] = "true"
+	params[key] = protocol.JoinList(items[:n])
+}
+
+// handleSpecs lists the registered key specs so clients can discover value
+// constraints before writing. Each spec is reported as <name>.pattern and
+// <name>.rule parameters, alongside whether strict mode is enabled.
+func (c *Connection) handleSpecs(msg protocol.Message) (protocol.Message, error) {
+	params := make(map[string]string)
+	for _, spec := range c.server.KeySpecs() {
+		params[spec.Name+".pattern"] = spec.Pattern
+		params[spec.Name+".rule"] = spec.Validator.Describe()
+	}
+
+	params["strict"] = strconv.FormatBool(c.server.keySpecs.isStrict())
+
+	return protocol.NewMessage(protocol.TypeResult, params), nil
+}
+
+// handleUsage reports how many keys this client holds and the bytes their
+// values take up, and, if its key writes are limited, the limit as
+// mutation_limit and mutation_burst with the writes it may make right now
+// in mutations_available
+func (c *Connection) handleUsage(msg protocol.Message) (protocol.Message, error) {
+	usage := c.store.Usage(c.clientID)
+
+	params := map[string]string{
+		"keys":  strconv.Itoa(usage.Keys),
+		"bytes": strconv.FormatInt(usage.Bytes, 10),
+	}
+	if bucket := c.mutationBucket(); bucket != nil && !c.admin {
+		params["mutation_limit"] = strconv.Itoa(int(bucket.rate))
+		params["mutation_burst"] = strconv.Itoa(int(bucket.burst))
+		params["mutations_available"] = strconv.Itoa(bucket.available(c.server.now()))
+	}
+	return protocol.NewMessage(protocol.TypeResult, params), nil
+}
+
+// handleSchedules lists this client's pending schedules. Each is reported as
+// <id>.op, <id>.key, <id>.at and, for set operations, <id>.value parameters.
+func (c *Connection) handleSchedules(msg protocol.Message) (protocol.Message, error) {
+	params := make(map[string]string)
+	for _, sched := range c.store.Schedules(c.clientID) {
+		params[sched.ID+".op"] = string(sched.Op)
+		params[sched.ID+".key"] = sched.Key
+		params[sched.ID+".at"] = sched.FireAt.Format(time.RFC3339Nano)
+		if sched.Op == state.ScheduleOpSet {
+			params[sched.ID+".value"] = sched.Value
+		}
+	}
+
+	return protocol.NewMessage(protocol.TypeResult, params), nil
+}
+
+// handleCancel cancels a pending schedule named by the schedule parameter
+func (c *Connection) handleCancel(msg protocol.Message) (protocol.Message, error) {
+	id := msg.Params["schedule"]
+	if id == "" {
+		return protocol.Message{}, errs.New(errs.ErrInvalidParams, "missing schedule parameter")
+	}
+
+	if err := c.store.CancelSchedule(c.clientID, id); err != nil {
+		return protocol.Message{}, err
+	}
+
+	c.logger.Info("Cancelled schedule %s", id)
+	return ackMessage(), nil
+}
+
+// parseSubscription reads a subscription from SUBSCRIBE or UNSUBSCRIBE
+// params: exactly one of key (an exact key) or prefix (a key prefix), and
+// optionally client to watch a single client's changes
+func parseSubscription(params map[string]string) (state.Subscription, error) {
+	key, hasKey := params["key"]
+	prefix, hasPrefix := params["prefix"]
+
+	switch {
+	case hasKey && hasPrefix:
+		return state.Subscription{}, errs.New(errs.ErrInvalidParams, "key and prefix parameters are mutually exclusive")
+	case hasKey && key != "":
+		return state.Subscription{Key: key, ClientID: params["client"]}, nil
+	case hasPrefix:
+		return state.Subscription{Key: prefix, Prefix: true, ClientID: params["client"]}, nil
+	default:
+		return state.Subscription{}, errs.New(errs.ErrInvalidParams, "missing key or prefix parameter")
+	}
+}
+
+// handleSubscribe registers the connection for change notifications. Every
+// UPDATE carries the version of its change. A subscriber resuming after a
+// gap passes the last version it saw as since_version and is first sent the
+// current value of each selected key changed since then, and a deleted=true
+// UPDATE for each one removed since then, all marked backfill=true. The ACK
+// then reports the current version, and gap=true if the change journal no
+// longer reaches back to since_version, so removals may be missing and the
+// subscriber should refetch. since_version=0 sends every selected value.
+//
+// durable=true keeps the subscription for the client_id claimed in HELLO
+// after the connection closes. Subscribing durably again from a later
+// connection resumes it: the changes missed in between are first replayed
+// as UPDATEs marked replayed=true, and the ACK reports how many were
+// replayed and gap=true if the change journal no longer held them all.
+func (c *Connection) handleSubscribe(msg protocol.Message) (protocol.Message, error) {
+	if c.draining() {
+		return protocol.Message{}, errs.New(errs.ErrDraining, "connection is draining, reconnect to subscribe")
+	}
+
+	sub, err := parseSubscription(msg.Params)
+	if err != nil {
+		return protocol.Message{}, err
+	}
+
+	raw, resume := msg.Params["since_version"]
+	if durable, ok := msg.Params["durable"]; ok {
+		isDurable, err := strconv.ParseBool(durable)
+		if err != nil {
+			return protocol.Message{}, errs.New(errs.ErrInvalidParams, "durable must be true or false, got %q", durable)
+		}
+		if isDurable {
+			if resume {
+				return protocol.Message{}, errs.New(errs.ErrInvalidParams, "since_version cannot be combined with durable")
+			}
+			resumed, err := c.server.subscribeDurable(c, sub)
+			if err != nil {
+				return protocol.Message{}, err
+			}
+			ack := ackMessage()
+			for k, v := range resumed {
+				ack.Params[k] = v
+			}
+			return ack, nil
+		}
+	}
+
+	if !resume {
+		c.logger.Info("Subscribing to %+v", sub)
+		c.store.Subscribe(c.id, sub, c.events)
+		return ackMessage(), nil
+	}
+
+	since, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return protocol.Message{}, errs.New(errs.ErrInvalidParams, "since_version must be a non-negative integer, got %q", raw)
+	}
+
+	c.logger.Info("Subscribing to %+v since version %d", sub, since)
+	backfill, version, gap := c.store.SubscribeSince(c.id, sub, c.events, since)
+	for _, event := range backfill {
+		push := updateMessage(event)
+		push.Params["backfill"] = "true"
+		if err := c.Send(push); err != nil {
+			return protocol.Message{}, err
+		}
+	}
+
+	ack := ackMessage()
+	ack.Params["version"] = strconv.FormatUint(version, 10)
+	ack.Params["gap"] = strconv.FormatBool(gap)
+	return ack, nil
+}
+
+// handleUnsubscribe removes a subscription registered with SUBSCRIBE,
+// including a durable one not yet resumed on this connection
+func (c *Connection) handleUnsubscribe(msg protocol.Message) (protocol.Message, error) {
+	sub, err := parseSubscription(msg.Params)
+	if err != nil {
+		return protocol.Message{}, err
+	}
+
+	durable := c.clientID != c.id && c.server.unsubscribeDurable(c.clientID, sub)
+	if err := c.store.Unsubscribe(c.id, sub); err != nil && !durable {
+		return protocol.Message{}, err
+	}
+
+	c.logger.Info("Unsubscribed from %+v", sub)
+	return ackMessage(), nil
+}
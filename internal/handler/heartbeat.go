@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// touch records that a message was received from the client
+func (c *Connection) touch() {
+	c.lastSeen.Store(c.server.now().UnixNano())
+}
+
+// lastReceived returns when a message was last received from the client
+func (c *Connection) lastReceived() time.Time {
+	return time.Unix(0, c.lastSeen.Load())
+}
+
+// maxMissedHeartbeats is the number of pings in a row a client may leave
+// unanswered before its connection is closed
+const maxMissedHeartbeats = 2
+
+// heartbeatInterval returns how long a client may be silent before it is
+// pinged: HeartbeatInterval if set, otherwise IdleTimeout. Zero disables
+// heartbeats.
+func (s *Server) heartbeatInterval() time.Duration {
+	if s.cfg.HeartbeatInterval > 0 {
+		return s.cfg.HeartbeatInterval
+	}
+	return s.cfg.IdleTimeout
+}
+
+// heartbeat pings a client that has been silent for interval and closes the
+// connection once maxMissedHeartbeats pings in a row go unanswered for
+// timeout each. Any inbound traffic, PONG or otherwise, counts as an answer,
+// so busy clients are never pinged.
+func (c *Connection) heartbeat(interval, timeout time.Duration) {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-c.closeChan:
+			return
+		case <-timer.C:
+		}
+
+		if silent := c.server.now().Sub(c.lastReceived()); silent < interval {
+			timer.Reset(interval - silent)
+			continue
+		}
+		if !c.probe(timeout) {
+			c.Close()
+			return
+		}
+		timer.Reset(interval)
+	}
+}
+
+// probe pings the client until it sends something, waiting timeout after
+// each ping, and reports whether it did before maxMissedHeartbeats pings
+// went unanswered. It also returns false if a ping cannot be sent, and true
+// if the connection closes meanwhile.
+func (c *Connection) probe(timeout time.Duration) bool {
+	ping := protocol.NewMessage(protocol.TypePing, nil)
+	wait := time.NewTimer(timeout)
+	defer wait.Stop()
+
+	for missed := 0; missed < maxMissedHeartbeats; missed++ {
+		sent := c.server.now()
+		if err := c.Send(ping); err != nil {
+			c.logger.Error("Failed to send heartbeat: %v", err)
+			return false
+		}
+
+		wait.Reset(timeout)
+		select {
+		case <-c.closeChan:
+			return true
+		case <-wait.C:
+		}
+
+		if !c.lastReceived().Before(sent) {
+			return true
+		}
+	}
+
+	c.logger.Warning("Missed %d heartbeats, closing connection", maxMissedHeartbeats)
+	return false
+}
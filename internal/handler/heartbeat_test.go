@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// heartbeatConfig pings clients silent for 50ms and gives them 50ms to
+// answer each ping
+func heartbeatConfig(cfg *config.Config) {
+	cfg.HeartbeatInterval = 50 * time.Millisecond
+	cfg.HeartbeatTimeout = 50 * time.Millisecond
+}
+
+// countPings reads from c until the server closes it or timeout passes,
+// answering each PING with a PONG if answer is set. It returns the number
+// of PINGs seen and whether the connection was closed.
+func countPings(c *testConn, answer bool, timeout time.Duration) (int, bool) {
+	pings := 0
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		msg, err := c.read(time.Until(deadline))
+		if err != nil {
+			return pings, !isTimeout(err)
+		}
+		if msg.Type != protocol.TypePing {
+			continue
+		}
+		pings++
+		if answer {
+			c.send("PONG:")
+		}
+	}
+	return pings, false
+}
+
+func TestHeartbeatKeepsResponsiveClient(t *testing.T) {
+	srv := newTestServer(t, heartbeatConfig)
+	c := dialHello(t, srv, "")
+
+	pings, closed := countPings(c, true, 500*time.Millisecond)
+	if closed {
+		t.Fatal("responsive client was disconnected")
+	}
+	if pings < 2 {
+		t.Fatalf("silent but responsive client was pinged %d times", pings)
+	}
+	c.expect("PING:", protocol.TypePong)
+}
+
+func TestHeartbeatReapsSilentClient(t *testing.T) {
+	srv := newTestServer(t, heartbeatConfig)
+	c := dialHello(t, srv, "")
+
+	pings, closed := countPings(c, false, testTimeout)
+	if !closed {
+		t.Fatal("silent client was not disconnected")
+	}
+	if pings != maxMissedHeartbeats {
+		t.Fatalf("closed after %d pings, want %d", pings, maxMissedHeartbeats)
+	}
+	waitFor(t, func() bool { return srv.ConnectionCount() == 0 })
+}
+
+func TestBusyClientIsNotPinged(t *testing.T) {
+	srv := newTestServer(t, heartbeatConfig)
+	c := dialHello(t, srv, "")
+
+	for end := time.Now().Add(300 * time.Millisecond); time.Now().Before(end); {
+		if reply := c.request("GET:key=k"); reply.Type == protocol.TypePing {
+			t.Fatal("client sending every few milliseconds was pinged")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestIdleTimeoutPingsWithoutHeartbeatInterval(t *testing.T) {
+	srv := newTestServer(t, func(cfg *config.Config) {
+		cfg.HeartbeatInterval = 0
+		cfg.IdleTimeout = 50 * time.Millisecond
+		cfg.HeartbeatTimeout = 50 * time.Millisecond
+	})
+
+	responsive := dialHello(t, srv, "")
+	silent := dialHello(t, srv, "")
+
+	done := make(chan bool)
+	go func() {
+		_, closed := countPings(responsive, true, 500*time.Millisecond)
+		done <- closed
+	}()
+
+	if pings, closed := countPings(silent, false, testTimeout); !closed || pings != maxMissedHeartbeats {
+		t.Fatalf("silent client: %d pings, closed %v", pings, closed)
+	}
+	if closed := <-done; closed {
+		t.Fatal("responsive client was disconnected")
+	}
+}
+
+func TestIdleForUsesServerClock(t *testing.T) {
+	srv := newUnstartedServer(t, nil)
+	clock := newTestClock()
+	srv.SetClock(clock.Now)
+	startTestServer(t, srv)
+
+	c := dialHello(t, srv, "")
+	clock.Advance(90 * time.Second)
+
+	conns := srv.ActiveConnections()
+	if len(conns) != 1 {
+		t.Fatalf("%d connections", len(conns))
+	}
+	if idle := conns[0].IdleFor(); idle != 90*time.Second {
+		t.Fatalf("IdleFor = %v, want 90s", idle)
+	}
+
+	c.expect("PING:", protocol.TypePong)
+	if idle := srv.ActiveConnections()[0].IdleFor(); idle != 0 {
+		t.Fatalf("IdleFor after a message = %v, want 0", idle)
+	}
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/idgen"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// requireHello makes clients complete a HELLO before anything else
+func requireHello(cfg *config.Config) {
+	cfg.RequireHello = true
+}
+
+func TestHelloMatchingVersion(t *testing.T) {
+	srv := newTestServer(t, requireHello)
+	c := dial(t, srv)
+
+	reply := c.expect("HELLO:version="+config.ProtocolVersion, protocol.TypeHello)
+	if reply.Params["version"] != config.ProtocolVersion || reply.Params["session"] == "" {
+		t.Fatalf("got %s, want the version and a session", reply)
+	}
+	c.expect("PING:", protocol.TypePong)
+	c.expectError("HELLO:version="+config.ProtocolVersion, protocol.ReasonAlreadyNegotiated)
+}
+
+func TestHelloMismatchedVersion(t *testing.T) {
+	srv := newTestServer(t, requireHello)
+	c := dial(t, srv)
+
+	c.expectError("HELLO:version=0.9", protocol.ReasonUnsupportedVersion)
+	c.expectError("HELLO:", protocol.ReasonInvalidParams)
+	// A failed HELLO leaves the connection unnegotiated
+	c.expectError("PING:", protocol.ReasonHandshakeRequired)
+	c.expect("HELLO:version="+config.ProtocolVersion, protocol.TypeHello)
+}
+
+func TestMessagesBeforeHello(t *testing.T) {
+	t.Run("required", func(t *testing.T) {
+		c := dial(t, newTestServer(t, requireHello))
+
+		for _, line := range []string{"PING:", "PING:id=1", "CONTEXT:k=v", "GET:"} {
+			c.expectError(line, protocol.ReasonHandshakeRequired)
+		}
+	})
+
+	t.Run("optional", func(t *testing.T) {
+		c := dial(t, newTestServer(t, func(cfg *config.Config) {
+			cfg.RequireHello = false
+		}))
+
+		c.expect("PING:", protocol.TypePong)
+		if warning := c.recv(); warning.Type != protocol.TypeWarning || warning.Params["tag"] != DeprecatedNoHello {
+			t.Fatalf("got %s, want a no_hello deprecation warning", warning)
+		}
+		c.expect("PING:", protocol.TypePong)
+	})
+}
+
+func TestHelloReplyIsReproducible(t *testing.T) {
+	// reply starts a server with deterministic IDs and clock, and returns
+	// its HELLO reply as sent
+	reply := func() string {
+		clock := newTestClock()
+		srv := newUnstartedServer(t, nil)
+		srv.SetClock(clock.Now)
+		srv.SetIDGenerator(&idgen.Sequential{})
+		startTestServer(t, srv)
+		clock.Advance(250 * time.Millisecond)
+
+		dialHello(t, srv, "")
+		c := dial(t, srv)
+		c.send("HELLO:version=" + config.ProtocolVersion)
+		c.conn.SetReadDeadline(time.Now().Add(testTimeout))
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		return line
+	}
+
+	first := reply()
+	if second := reply(); second != first {
+		t.Fatalf("HELLO replies differ:\n%q\n%q", first, second)
+	}
+	if !strings.Contains(first, "session=conn-2;") {
+		t.Fatalf("reply %q does not carry the second connection's ID", first)
+	}
+}
+
+func TestReconnectRestoresContext(t *testing.T) {
+	srv := newTestServer(t, nil)
+	c := dialHello(t, srv, "client_id=agent-7")
+	c.expect("CONTEXT:task=index;step=3", protocol.TypeAck)
+	c.conn.Close()
+	waitFor(t, func() bool { return srv.ConnectionCount() == 0 })
+
+	c = dialHello(t, srv, "client_id=agent-7")
+	reply := c.expect("GET:", protocol.TypeResult)
+	if reply.Params["task"] != "index" || reply.Params["step"] != "3" {
+		t.Fatalf("GET after reconnecting = %s, want the earlier context", reply)
+	}
+
+	// A connection without the ID starts empty
+	if reply := dialHello(t, srv, "").expect("GET:", protocol.TypeResult); len(reply.Params) != 0 {
+		t.Fatalf("GET on a fresh connection = %s", reply)
+	}
+}
+
+func TestDuplicateClientIDRejected(t *testing.T) {
+	srv := newTestServer(t, nil)
+	first := dialHello(t, srv, "client_id=agent-7")
+	first.expect("CONTEXT:owner=first", protocol.TypeAck)
+
+	second := dial(t, srv)
+	second.expectError("HELLO:version="+config.ProtocolVersion+";client_id=agent-7", protocol.ReasonClientIDInUse)
+
+	// The live holder keeps its ID and context
+	if reply := first.expect("GET:key=owner", protocol.TypeResult); reply.Params["owner"] != "first" {
+		t.Fatalf("GET = %s after a rejected duplicate", reply)
+	}
+
+	// The ID is free again once its holder leaves
+	first.conn.Close()
+	waitFor(t, func() bool { return srv.ConnectionCount() == 1 })
+	second.expect("HELLO:version="+config.ProtocolVersion+";client_id=agent-7", protocol.TypeHello)
+}
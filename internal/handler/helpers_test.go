@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+	"github.com/Artimus100/mcp-server-go/internal/utils"
+)
+
+// testTimeout bounds every wait in the tests, so a missing reply fails the
+// test rather than hanging it
+const testTimeout = 2 * time.Second
+
+// newTestServer starts a server on an ephemeral port with the default
+// configuration as changed by configure, if given, and shuts it down when
+// the test ends
+func newTestServer(t *testing.T, configure func(*config.Config)) *Server {
+	t.Helper()
+	return startTestServer(t, newUnstartedServer(t, configure))
+}
+
+// newUnstartedServer creates a server as newTestServer does, for tests that
+// change it before Start
+func newUnstartedServer(t testing.TB, configure func(*config.Config)) *Server {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.Port = 0
+	if configure != nil {
+		configure(&cfg)
+	}
+	return NewServer(cfg, state.NewContextStore(), utils.NewLoggerTo(io.Discard, "test"))
+}
+
+// startTestServer starts srv and shuts it down when the test ends
+func startTestServer(t testing.TB, srv *Server) *Server {
+	t.Helper()
+
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+		srv.Shutdown(ctx)
+	})
+	return srv
+}
+
+// testConn is a raw protocol connection to a test server
+type testConn struct {
+	t      *testing.T
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// dial connects to srv without a handshake
+func dial(t *testing.T, srv *Server) *testConn {
+	t.Helper()
+
+	addr := srv.Addr()
+	conn, err := net.DialTimeout(addr.Network(), addr.String(), testTimeout)
+	if err != nil {
+		t.Fatalf("dial %s: %v", addr, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &testConn{t: t, conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// dialHello connects to srv and completes a HELLO with the given extra
+// parameters, such as "client_id=a"
+func dialHello(t *testing.T, srv *Server, params string) *testConn {
+	t.Helper()
+
+	c := dial(t, srv)
+	line := "HELLO:version=" + config.ProtocolVersion
+	if params != "" {
+		line += ";" + params
+	}
+	c.expect(line, protocol.TypeHello)
+	return c
+}
+
+// send writes one message line
+func (c *testConn) send(line string) {
+	c.t.Helper()
+
+	c.conn.SetWriteDeadline(time.Now().Add(testTimeout))
+	if _, err := io.WriteString(c.conn, line+"\n"); err != nil {
+		c.t.Fatalf("send %q: %v", line, err)
+	}
+}
+
+// recv reads the next message
+func (c *testConn) recv() protocol.Message {
+	c.t.Helper()
+
+	msg, err := c.read(testTimeout)
+	if err != nil {
+		c.t.Fatalf("recv: %v", err)
+	}
+	return msg
+}
+
+// read reads the next message, waiting at most timeout
+func (c *testConn) read(timeout time.Duration) (protocol.Message, error) {
+	c.conn.SetReadDeadline(time.Now().Add(timeout))
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return protocol.Message{}, err
+	}
+	return protocol.Parse(strings.TrimSuffix(line, "\n"))
+}
+
+// request sends line and returns the reply
+func (c *testConn) request(line string) protocol.Message {
+	c.t.Helper()
+
+	c.send(line)
+	return c.recv()
+}
+
+// expect sends line and fails the test unless the reply has type msgType
+func (c *testConn) expect(line, msgType string) protocol.Message {
+	c.t.Helper()
+
+	reply := c.request(line)
+	if reply.Type != msgType {
+		c.t.Fatalf("%q: got %s, want %s", line, reply, msgType)
+	}
+	return reply
+}
+
+// expectError sends line and fails the test unless the reply is an ERROR
+// with the given reason
+func (c *testConn) expectError(line, reason string) protocol.Message {
+	c.t.Helper()
+
+	reply := c.expect(line, protocol.TypeError)
+	if reply.Params["reason"] != reason {
+		c.t.Fatalf("%q: got %s, want reason %s", line, reply, reason)
+	}
+	return reply
+}
+
+// expectClosed fails the test unless the server closes the connection,
+// skipping any messages sent before it does
+func (c *testConn) expectClosed() {
+	c.t.Helper()
+
+	deadline := time.Now().Add(testTimeout)
+	for time.Now().Before(deadline) {
+		if _, err := c.read(time.Until(deadline)); err != nil {
+			if isTimeout(err) {
+				break
+			}
+			return
+		}
+	}
+	c.t.Fatal("connection was not closed")
+}
+
+// isTimeout reports whether err is a deadline expiry
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// testClock is a server clock that only moves when told to
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func newTestClock() *testClock {
+	return &testClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// waitFor polls cond until it holds, failing the test after testTimeout
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(testTimeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
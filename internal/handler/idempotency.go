@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// idemResult is the outcome of a message recorded under an idempotency key
+type idemResult struct {
+	response  protocol.Message
+	err       error
+	expiresAt time.Time
+}
+
+// idemClient holds one client's recorded outcomes, oldest key first
+type idemClient struct {
+	results map[string]idemResult
+	order   []string
+}
+
+// idempotencyCache remembers the outcome of messages carrying an idem key
+// so a retried message is answered from the cache instead of being
+// executed again, even when the retry comes on a new connection. Each
+// client ID keeps at most size keys, for at most ttl.
+type idempotencyCache struct {
+	clients   map[string]*idemClient
+	size      int
+	ttl       time.Duration
+	now       func() time.Time
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+// newIdempotencyCache creates an empty cache
+func newIdempotencyCache(size int, ttl time.Duration) *idempotencyCache {
+	return &idempotencyCache{
+		clients: make(map[string]*idemClient),
+		size:    size,
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// lookup returns the recorded outcome for a client's idempotency key
+func (c *idempotencyCache) lookup(clientID, key string) (protocol.Message, error, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	client, exists := c.clients[clientID]
+	if !exists {
+		return protocol.Message{}, nil, false
+	}
+
+	result, exists := client.results[key]
+	if !exists || !c.now().Before(result.expiresAt) {
+		return protocol.Message{}, nil, false
+	}
+
+	return result.response, result.err, true
+}
+
+// store records the outcome of a message, evicting expired keys and then
+// the oldest keys once the client is over its limit
+func (c *idempotencyCache) store(clientID, key string, response protocol.Message, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	c.sweep(now)
+
+	client, exists := c.clients[clientID]
+	if !exists {
+		client = &idemClient{results: make(map[string]idemResult)}
+		c.clients[clientID] = client
+	}
+
+	// A key stored again moves to the back, keeping order by expiry
+	if _, exists := client.results[key]; exists {
+		for i, k := range client.order {
+			if k == key {
+				client.order = append(client.order[:i], client.order[i+1:]...)
+				break
+			}
+		}
+	}
+	client.order = append(client.order, key)
+	client.results[key] = idemResult{response: response, err: err, expiresAt: now.Add(c.ttl)}
+
+	for len(client.order) > 0 {
+		oldest := client.order[0]
+		if len(client.order) <= c.size && now.Before(client.results[oldest].expiresAt) {
+			break
+		}
+		delete(client.results, oldest)
+		client.order = client.order[1:]
+	}
+}
+
+// sweep drops clients whose every key has expired, at most once per ttl.
+// Callers must hold c.mu.
+func (c *idempotencyCache) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < c.ttl {
+		return
+	}
+	c.lastSweep = now
+
+	for clientID, client := range c.clients {
+		// Keys are stored oldest first, so the newest expires last
+		if n := len(client.order); n == 0 || !now.Before(client.results[client.order[n-1]].expiresAt) {
+			delete(c.clients, clientID)
+		}
+	}
+}
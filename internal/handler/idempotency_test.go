@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+)
+
+func TestDuplicateIdemKeyReplaysResponse(t *testing.T) {
+	srv := newTestServer(t, nil)
+	c := dialHello(t, srv, "client_id=idem")
+
+	events := make(chan state.ContextEvent, 8)
+	srv.store.Subscribe("watcher", state.Subscription{Key: "counter", ClientID: "idem"}, events)
+
+	first := c.expect("CONTEXT:counter=1;idem=op-1", protocol.TypeAck)
+	srv.store.Set("idem", "counter", "changed elsewhere")
+	second := c.expect("CONTEXT:counter=1;idem=op-1", protocol.TypeAck)
+
+	if first.String() != second.String() {
+		t.Fatalf("replayed %s, want %s", second, first)
+	}
+	if value, _ := srv.store.Get("idem", "counter"); value != "changed elsewhere" {
+		t.Fatalf("retry was applied again: counter = %q", value)
+	}
+	if n := len(events); n != 2 {
+		t.Fatalf("%d changes notified, want 2", n)
+	}
+}
+
+func TestIdemKeySurvivesReconnect(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	c := dialHello(t, srv, "client_id=retrier")
+	c.expect("CONTEXT:n=1;idem=op-7", protocol.TypeAck)
+	c.conn.Close()
+	waitFor(t, func() bool { return srv.ConnectionCount() == 0 })
+	srv.store.Set("retrier", "n", "2")
+
+	// The client never saw the ACK and retries after reconnecting
+	c = dialHello(t, srv, "client_id=retrier")
+	c.expect("CONTEXT:n=1;idem=op-7", protocol.TypeAck)
+	if value, _ := srv.store.Get("retrier", "n"); value != "2" {
+		t.Fatalf("retry after reconnect was applied again: n = %q", value)
+	}
+}
+
+func TestIdemErrorIsReplayed(t *testing.T) {
+	srv := newTestServer(t, nil)
+	c := dialHello(t, srv, "")
+
+	c.expectError("CONTEXT:_bogus=1;idem=bad", protocol.ReasonInvalidParams)
+	c.expectError("CONTEXT:_bogus=1;idem=bad", protocol.ReasonInvalidParams)
+	c.expectError("CONTEXT:k=v;idem=", protocol.ReasonInvalidParams)
+}
+
+func TestIdempotencyCacheExpiry(t *testing.T) {
+	now := time.Unix(1000, 0)
+	cache := newIdempotencyCache(2, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	ack := protocol.NewMessage(protocol.TypeAck, nil)
+	cache.store("a", "k1", ack, nil)
+	cache.store("a", "k2", ack, nil)
+	cache.store("a", "k3", ack, nil)
+	if _, _, found := cache.lookup("a", "k1"); found {
+		t.Fatal("oldest key kept past the size limit")
+	}
+	if _, _, found := cache.lookup("a", "k3"); !found {
+		t.Fatal("newest key missing")
+	}
+
+	now = now.Add(time.Minute)
+	if _, _, found := cache.lookup("a", "k3"); found {
+		t.Fatal("key found after its TTL")
+	}
+	cache.store("b", "k", ack, nil)
+	if _, exists := cache.clients["a"]; exists {
+		t.Fatal("client with only expired keys not swept")
+	}
+}
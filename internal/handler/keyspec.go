@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"fmt"
+	"path"
+	"sync"
+
+	"github.com/Artimus100/mcp-server-go/internal/errs"
+)
+
+// KeySpec constrains the values that may be stored under matching keys
+type KeySpec struct {
+	// Name identifies the spec in errors and listings
+	Name string
+	// Pattern is an exact key name or a path.Match style glob such as
+	// "deadline" or "progress.*"
+	Pattern string
+	// Validator checks values written under matching keys
+	Validator Validator
+}
+
+// keySpecRegistry holds the registered key specs
+type keySpecRegistry struct {
+	specs  []KeySpec
+	strict bool
+	mu     sync.RWMutex
+}
+
+// newKeySpecRegistry creates an empty registry
+func newKeySpecRegistry() *keySpecRegistry {
+	return &keySpecRegistry{}
+}
+
+// register adds a spec, rejecting incomplete specs and malformed patterns
+func (r *keySpecRegistry) register(spec KeySpec) error {
+	if spec.Name == "" || spec.Pattern == "" || spec.Validator == nil {
+		return fmt.Errorf("key spec needs a name, pattern and validator")
+	}
+	if _, err := path.Match(spec.Pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q for key spec %s: %v", spec.Pattern, spec.Name, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.specs {
+		if existing.Name == spec.Name {
+			return fmt.Errorf("key spec %s already registered", spec.Name)
+		}
+	}
+
+	r.specs = append(r.specs, spec)
+	return nil
+}
+
+// list returns a copy of the registered specs in registration order
+func (r *keySpecRegistry) list() []KeySpec {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	specs := make([]KeySpec, len(r.specs))
+	copy(specs, r.specs)
+	return specs
+}
+
+// isStrict reports whether keys without a matching spec are rejected
+func (r *keySpecRegistry) isStrict() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.strict
+}
+
+// match finds the spec governing key. An exact pattern match takes
+// precedence over glob matches, which are tried in registration order.
+func (r *keySpecRegistry) match(key string) (KeySpec, bool) {
+	for _, spec := range r.specs {
+		if spec.Pattern == key {
+			return spec, true
+		}
+	}
+
+	for _, spec := range r.specs {
+		if matched, _ := path.Match(spec.Pattern, key); matched {
+			return spec, true
+		}
+	}
+
+	return KeySpec{}, false
+}
+
+// validate checks every key/value pair, returning the first violation. In
+// strict mode keys without a matching spec are rejected; otherwise they are
+// accepted unchecked.
+func (r *keySpecRegistry) validate(values map[string]string) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for key, value := range values {
+		spec, found := r.match(key)
+		if !found {
+			if r.strict {
+				return errs.New(errs.ErrUnregisteredKey, "key %s does not match any registered key spec", key)
+			}
+			continue
+		}
+
+		if err := spec.Validator.Validate(value); err != nil {
+			return errs.New(errs.ErrInvalidValue, "key %s fails spec %s: %v", key, spec.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// RegisterKeySpec adds a spec that CONTEXT writes to matching keys must
+// satisfy
+func (s *Server) RegisterKeySpec(spec KeySpec) error {
+	return s.keySpecs.register(spec)
+}
+
+// KeySpecs returns the registered key specs
+func (s *Server) KeySpecs() []KeySpec {
+	return s.keySpecs.list()
+}
+
+// SetStrictKeys controls whether keys without a registered spec are rejected
+func (s *Server) SetStrictKeys(strict bool) {
+	s.keySpecs.mu.Lock()
+	defer s.keySpecs.mu.Unlock()
+	s.keySpecs.strict = strict
+}
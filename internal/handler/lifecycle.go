@@ -0,0 +1,51 @@
+package handler
+
+// ServerState is a stage in the server lifecycle. A server moves strictly
+// forward through the states:
+//
+//	created → started → shutting down → stopped
+//
+// Shutdown on a server that was never started moves it straight to stopped.
+type ServerState int
+
+const (
+	// StateCreated is a server returned by NewServer that has not started
+	StateCreated ServerState = iota
+	// StateStarted is a server that is listening for connections
+	StateStarted
+	// StateShuttingDown is a server closing its listener and connections
+	StateShuttingDown
+	// StateStopped is a server that has shut down and cannot be restarted
+	StateStopped
+)
+
+// String returns the name of the state
+func (st ServerState) String() string {
+	switch st {
+	case StateCreated:
+		return "created"
+	case StateStarted:
+		return "started"
+	case StateShuttingDown:
+		return "shutting down"
+	case StateStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// State returns the current lifecycle state of the server
+func (s *Server) State() ServerState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lifecycle
+}
+
+// Ready reports whether the server should receive traffic: it is started,
+// the store has finished loading and it is not short of file descriptors.
+// Health checks use it so load balancers shed traffic from a degraded
+// server.
+func (s *Server) Ready() bool {
+	return s.State() == StateStarted && !s.Loading() && !s.fdPressure.Load()
+}
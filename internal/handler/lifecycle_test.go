@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+func TestShutdownBeforeStart(t *testing.T) {
+	srv := newUnstartedServer(t, nil)
+
+	if err := srv.Shutdown(context.Background()); err == nil {
+		t.Fatal("Shutdown of a never-started server succeeded")
+	}
+	if state := srv.State(); state != StateStopped {
+		t.Fatalf("state %s after Shutdown, want stopped", state)
+	}
+	if err := srv.Start(); err == nil {
+		t.Fatal("stopped server started")
+	}
+	if srv.Addr() != nil {
+		t.Fatalf("Addr = %v on a server that never listened", srv.Addr())
+	}
+}
+
+func TestDoubleStartRejected(t *testing.T) {
+	srv := newTestServer(t, nil)
+	addr := srv.Addr().String()
+
+	if err := srv.Start(); err == nil {
+		t.Fatal("second Start succeeded")
+	}
+	if got := srv.Addr().String(); got != addr {
+		t.Fatalf("Addr changed from %s to %s", addr, got)
+	}
+	dialHello(t, srv, "").expect("PING:", protocol.TypePong)
+}
+
+func TestLifecycleStates(t *testing.T) {
+	srv := newUnstartedServer(t, nil)
+	if state := srv.State(); state != StateCreated {
+		t.Fatalf("new server in state %s", state)
+	}
+
+	startTestServer(t, srv)
+	if state := srv.State(); state != StateStarted || !srv.Ready() {
+		t.Fatalf("started server in state %s, ready %v", state, srv.Ready())
+	}
+
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if state := srv.State(); state != StateStopped || srv.Ready() {
+		t.Fatalf("shut down server in state %s, ready %v", state, srv.Ready())
+	}
+	if err := srv.Shutdown(context.Background()); err == nil {
+		t.Fatal("second Shutdown succeeded")
+	}
+}
+
+func TestAddrReportsEphemeralPort(t *testing.T) {
+	first := newUnstartedServer(t, nil)
+	if first.Addr() != nil {
+		t.Fatalf("Addr = %v before Start", first.Addr())
+	}
+	startTestServer(t, first)
+	second := newTestServer(t, nil)
+
+	port := first.Addr().(*net.TCPAddr).Port
+	if port == 0 || port == second.Addr().(*net.TCPAddr).Port {
+		t.Fatalf("servers on port 0 bound %s and %s", first.Addr(), second.Addr())
+	}
+	// Start has bound the port by the time it returns
+	dialHello(t, first, "").expect("PING:", protocol.TypePong)
+
+	taken := newUnstartedServer(t, func(cfg *config.Config) { cfg.Port = port })
+	if err := taken.Start(); err == nil {
+		taken.Shutdown(context.Background())
+		t.Fatalf("Start on the bound port %d succeeded", port)
+	}
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net"
+
+	"github.com/Artimus100/mcp-server-go/internal/errs"
+)
+
+// ConnectionCount returns the number of open client connections, not
+// counting the admin console
+func (s *Server) ConnectionCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.clientConns
+}
+
+// atCapacity reports whether the client connection limit has been reached.
+// Callers must hold s.mu.
+func (s *Server) atCapacity() bool {
+	return s.cfg.MaxConnections > 0 && s.clientConns >= s.cfg.MaxConnections
+}
+
+// waitForCapacity blocks the accept loop while the server is at its
+// connection limit under the backlog policy, leaving new connections queued
+// in the kernel. It returns false if the server shuts down first.
+func (s *Server) waitForCapacity() bool {
+	if s.cfg.ConnectionLimitPolicy != "backlog" {
+		return true
+	}
+
+	logged := false
+	for {
+		s.mu.RLock()
+		full := s.atCapacity()
+		s.mu.RUnlock()
+		if !full {
+			return true
+		}
+
+		if !logged {
+			s.logger.Warning("Connection limit of %d reached, pausing accepts", s.cfg.MaxConnections)
+			logged = true
+		}
+
+		select {
+		case <-s.closeChan:
+			return false
+		case <-s.slotFreed:
+		}
+	}
+}
+
+// rejectConnection turns away a connection accepted beyond the limit
+func (s *Server) rejectConnection(conn net.Conn) {
+	rejectWith(conn, errs.New(errs.ErrTooManyConnections, "server is at its limit of %d connections", s.cfg.MaxConnections))
+}
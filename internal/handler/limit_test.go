@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// maxMessage configures a server to accept messages of at most size bytes
+func maxMessage(size int) func(*config.Config) {
+	return func(cfg *config.Config) {
+		cfg.MaxMessageSize = size
+	}
+}
+
+func TestOversizedLineClosesConnection(t *testing.T) {
+	for name, size := range map[string]int{
+		"within read buffer": 2000,
+		"beyond read buffer": 64 * 1024,
+	} {
+		t.Run(name, func(t *testing.T) {
+			srv := newTestServer(t, maxMessage(1024))
+			c := dialHello(t, srv, "")
+
+			// The server answers before the line ends, so write it in the
+			// background rather than wait on a peer that stopped reading
+			go func() {
+				c.conn.SetWriteDeadline(time.Now().Add(testTimeout))
+				c.conn.Write([]byte("CONTEXT:k=" + strings.Repeat("x", size) + "\n"))
+			}()
+
+			reply := c.recv()
+			if reply.Type != protocol.TypeError || reply.Params["reason"] != protocol.ReasonMessageTooLarge {
+				t.Fatalf("got %s, want message_too_large", reply)
+			}
+			if reply.Params["code"] != strconv.Itoa(http.StatusRequestEntityTooLarge) {
+				t.Fatalf("got code %s, want %d", reply.Params["code"], http.StatusRequestEntityTooLarge)
+			}
+			if !strings.Contains(reply.Params["detail"], "1024 bytes") {
+				t.Fatalf("detail %q does not mention the limit", reply.Params["detail"])
+			}
+			c.expectClosed()
+		})
+	}
+}
+
+func TestLineAtSizeLimitIsAccepted(t *testing.T) {
+	line := "CONTEXT:k=" + strings.Repeat("x", 100)
+	srv := newTestServer(t, maxMessage(len(line)))
+
+	dialHello(t, srv, "").expect(line, protocol.TypeAck)
+}
+
+// maxConnections configures a server to hold at most n client connections
+// under the given limit policy
+func maxConnections(n int, policy string) func(*config.Config) {
+	return func(cfg *config.Config) {
+		cfg.MaxConnections = n
+		cfg.ConnectionLimitPolicy = policy
+	}
+}
+
+func TestConnectionBeyondLimitRejected(t *testing.T) {
+	srv := newTestServer(t, maxConnections(3, "reject"))
+
+	var clients []*testConn
+	for i := 0; i < 3; i++ {
+		clients = append(clients, dialHello(t, srv, ""))
+	}
+
+	extra := dial(t, srv)
+	if reply := extra.recv(); reply.Params["reason"] != protocol.ReasonTooManyConnections {
+		t.Fatalf("connection 4 got %s, want too_many_connections", reply)
+	}
+	extra.expectClosed()
+
+	for i, c := range clients {
+		if reply := c.request("PING:"); reply.Type != protocol.TypePong {
+			t.Fatalf("client %d got %s after the rejection", i, reply)
+		}
+	}
+	if n := srv.ConnectionCount(); n != 3 {
+		t.Fatalf("ConnectionCount() = %d, want 3", n)
+	}
+
+	// Closing a client frees its slot
+	clients[0].conn.Close()
+	waitFor(t, func() bool { return srv.ConnectionCount() == 2 })
+	dialHello(t, srv, "")
+	if n := srv.ConnectionCount(); n != 3 {
+		t.Fatalf("ConnectionCount() = %d after reconnecting, want 3", n)
+	}
+}
+
+func TestConnectionBeyondLimitWaitsInBacklog(t *testing.T) {
+	srv := newTestServer(t, maxConnections(1, "backlog"))
+	first := dialHello(t, srv, "")
+
+	waiting := dial(t, srv)
+	waiting.send("HELLO:version=" + config.ProtocolVersion)
+	if msg, err := waiting.read(200 * time.Millisecond); !isTimeout(err) {
+		t.Fatalf("queued connection answered: %s, %v", msg, err)
+	}
+
+	first.conn.Close()
+	if reply := waiting.recv(); reply.Type != protocol.TypeHello {
+		t.Fatalf("queued connection got %s once a slot freed, want HELLO", reply)
+	}
+}
+
+func TestHelloNegotiatesSmallerMessageSize(t *testing.T) {
+	srv := newTestServer(t, maxMessage(2048))
+
+	for _, tt := range []struct {
+		client, want string
+	}{
+		{"", "2048"},
+		{"1024", "1024"},
+		{"8192", "2048"},
+		{"2048", "2048"},
+	} {
+		c := dial(t, srv)
+		line := "HELLO:version=" + config.ProtocolVersion
+		if tt.client != "" {
+			line += ";max_message_size=" + tt.client
+		}
+		if reply := c.expect(line, protocol.TypeHello); reply.Params["max_message_size"] != tt.want {
+			t.Errorf("client %q: negotiated %s, want %s", tt.client, reply.Params["max_message_size"], tt.want)
+		}
+	}
+
+	for _, bad := range []string{"0", "-5", "big"} {
+		dial(t, srv).expectError("HELLO:version="+config.ProtocolVersion+";max_message_size="+bad, protocol.ReasonInvalidParams)
+	}
+}
+
+func TestMessageOverNegotiatedSizeRejected(t *testing.T) {
+	srv := newTestServer(t, maxMessage(4096))
+	c := dialHello(t, srv, "max_message_size=512")
+
+	c.expect("CONTEXT:k="+strings.Repeat("x", 500), protocol.TypeAck)
+	reply := c.expectError("CONTEXT:k="+strings.Repeat("x", 600), protocol.ReasonMessageTooLarge)
+	if !strings.Contains(reply.Params["detail"], "512 bytes") {
+		t.Fatalf("detail %q does not cite the negotiated 512 bytes", reply.Params["detail"])
+	}
+	c.expectClosed()
+
+	// Other connections keep the server's limit
+	dialHello(t, srv, "").expect("CONTEXT:k="+strings.Repeat("x", 600), protocol.TypeAck)
+}
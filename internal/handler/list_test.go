@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+func TestListFiltersByPattern(t *testing.T) {
+	c := dialHello(t, newTestServer(t, nil), "")
+	c.expect("CONTEXT:model.name=x;model.size=7b;user.id=1", protocol.TypeAck)
+
+	reply := c.expect("LIST:pattern=model.*", protocol.TypeResult)
+	keys, err := protocol.SplitList(reply.Params["keys"])
+	if err != nil || !reflect.DeepEqual(keys, []string{"model.name", "model.size"}) || reply.Params["count"] != "2" {
+		t.Fatalf("LIST = %s, want the two model keys", reply)
+	}
+	if reply := c.expect("LIST:pattern=none.*", protocol.TypeResult); reply.Params["count"] != "0" || reply.Params["keys"] != "" {
+		t.Fatalf("LIST = %s, want no keys", reply)
+	}
+	c.expectError("LIST:pattern=[", protocol.ReasonInvalidParams)
+}
+
+func TestListReplyFitsNegotiatedSize(t *testing.T) {
+	c := dialHello(t, newTestServer(t, nil), "max_message_size=1024")
+	long := strings.Repeat("k", 200)
+	for i := 0; i < 10; i++ {
+		c.expect("CONTEXT:"+long+strconv.Itoa(i)+"=v", protocol.TypeAck)
+	}
+
+	id := strings.Repeat("i", 300)
+	reply := c.expect("LIST:id="+id, protocol.TypeResult)
+	if size := len(reply.Format()); size > 1024 {
+		t.Fatalf("reply is %d bytes, over the negotiated 1024", size)
+	}
+	keys, err := protocol.SplitList(reply.Params["keys"])
+	if err != nil || len(keys) == 0 || len(keys) >= 10 || reply.Params["truncated"] != "true" || reply.Params["count"] != "10" {
+		t.Fatalf("LIST = %s, want a truncated, non-empty list of the 10 keys", reply)
+	}
+}
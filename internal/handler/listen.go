@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// listen opens the listener for network and address. A unix socket gets
+// the permission bits mode, and replaces a stale socket left by a previous
+// run; closing the listener removes the socket file.
+func listen(network, address string, mode os.FileMode) (net.Listener, error) {
+	if network == "unix" {
+		return listenUnix(address, mode)
+	}
+
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", address, err)
+	}
+	return listener, nil
+}
+
+// listenUnix listens on the unix socket at path with the permission bits
+// mode. A socket already at path is removed only if nothing accepts
+// connections on it; one still in use makes listening fail, as does
+// anything else at path.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := removeStaleSocket(path); err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", path, err)
+	}
+
+	if err := os.Chmod(path, mode); err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("failed to set permissions of %s: %v", path, err)
+	}
+
+	return listener, nil
+}
+
+// removeStaleSocket removes the socket at path if connecting to it is
+// refused, which means the server that made it is gone
+func removeStaleSocket(path string) error {
+	conn, err := net.Dial("unix", path)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("failed to listen on %s: already in use", path)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("failed to listen on %s: cannot tell whether the socket is in use: %v", path, err)
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale socket %s: %v", path, err)
+	}
+	return nil
+}
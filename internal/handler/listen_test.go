@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// socketPath returns a socket path in a fresh temporary directory
+func socketPath(t *testing.T) string {
+	return filepath.Join(t.TempDir(), "mcp.sock")
+}
+
+// listenOn configures a server to listen on the unix socket at path
+func listenOn(path string) func(*config.Config) {
+	return func(cfg *config.Config) {
+		cfg.Listen = "unix://" + path
+	}
+}
+
+func TestPingOverUnixSocket(t *testing.T) {
+	path := socketPath(t)
+	srv := newTestServer(t, listenOn(path))
+
+	if network := srv.Addr().Network(); network != "unix" {
+		t.Fatalf("listening on %s", network)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != config.SocketMode {
+		t.Errorf("socket mode %o, want %o", mode, config.SocketMode)
+	}
+
+	c := dialHello(t, srv, "")
+	c.expect("PING:", protocol.TypePong)
+	c.expect("PING:id=7", protocol.TypePong)
+}
+
+func TestStaleSocketIsReplaced(t *testing.T) {
+	path := socketPath(t)
+
+	// A listener that exits without removing its socket leaves it behind
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	srv := newTestServer(t, listenOn(path))
+	dialHello(t, srv, "").expect("PING:", protocol.TypePong)
+}
+
+func TestSocketInUseIsKept(t *testing.T) {
+	path := socketPath(t)
+	first := newTestServer(t, listenOn(path))
+
+	second := newUnstartedServer(t, listenOn(path))
+	err := second.Start()
+	if err == nil {
+		t.Fatal("second server started on a socket in use")
+	}
+	if !strings.Contains(err.Error(), "already in use") {
+		t.Fatalf("got %v, want already in use", err)
+	}
+
+	dialHello(t, first, "").expect("PING:", protocol.TypePong)
+}
+
+func TestNonSocketFileIsKept(t *testing.T) {
+	path := socketPath(t)
+	if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := newUnstartedServer(t, listenOn(path)).Start(); err == nil {
+		t.Fatal("server started over a regular file")
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != "data" {
+		t.Fatalf("file at the socket path was disturbed: %q, %v", data, err)
+	}
+}
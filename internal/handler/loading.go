@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"github.com/Artimus100/mcp-server-go/internal/errs"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// mutatingTypes are the message types that change stored context and are
+// rejected while the store is loading
+var mutatingTypes = map[string]bool{
+	protocol.TypeContext:      true,
+	protocol.TypeRemove:       true,
+	protocol.TypeContextBegin: true,
+	protocol.TypeContextChunk: true,
+	protocol.TypeContextEnd:   true,
+	protocol.TypeCancel:       true,
+	protocol.TypeClearAll:     true,
+}
+
+// readTypes are the message types that read stored context, which are
+// rejected while loading unless reads are allowed
+var readTypes = map[string]bool{
+	protocol.TypeGet:       true,
+	protocol.TypeQuery:     true,
+	protocol.TypeSchedules: true,
+	protocol.TypeExport:    true,
+	protocol.TypeUsage:     true,
+}
+
+// SetLoading marks the store as loading. While loading, connections are
+// accepted but messages that change stored context are rejected with
+// server_loading, as are reads unless allowed with SetLoadingReads.
+// Snapshots are not saved while loading, so a partial store never
+// overwrites the file being loaded.
+func (s *Server) SetLoading(loading bool) {
+	s.loading.Store(loading)
+	if loading {
+		s.logger.Info("Store loading, rejecting writes")
+	} else {
+		s.logger.Info("Store loaded, accepting writes")
+	}
+}
+
+// Loading reports whether the store is still loading
+func (s *Server) Loading() bool {
+	return s.loading.Load()
+}
+
+// SetLoadingReads controls whether reads are served while the store is
+// loading, possibly returning incomplete results. Reads are allowed by
+// default.
+func (s *Server) SetLoadingReads(allow bool) {
+	s.denyLoadingReads.Store(!allow)
+}
+
+// checkLoading rejects messages that must wait for the store to load
+func (s *Server) checkLoading(msgType string) error {
+	if !s.loading.Load() {
+		return nil
+	}
+	if mutatingTypes[msgType] || (readTypes[msgType] && s.denyLoadingReads.Load()) {
+		return errs.New(errs.ErrLoading, "store is loading, retry %s shortly", msgType)
+	}
+	return nil
+}
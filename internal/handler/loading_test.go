@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+)
+
+func TestWritesRejectedUntilLoadCompletes(t *testing.T) {
+	srv := newUnstartedServer(t, nil)
+	srv.SetLoading(true)
+	startTestServer(t, srv)
+
+	// A slow load, finishing once the client has been turned away
+	rejected := make(chan struct{})
+	loaded := make(chan struct{})
+	go func() {
+		<-rejected
+		time.Sleep(50 * time.Millisecond)
+		srv.store.Set(state.ServerClientID, "restored", "yes")
+		srv.SetLoading(false)
+		close(loaded)
+	}()
+
+	c := dialHello(t, srv, "")
+	if srv.Ready() {
+		t.Fatal("server ready while loading")
+	}
+	c.expectError("CONTEXT:k=v", protocol.ReasonServerLoading)
+	c.expectError("REMOVE:key=k", protocol.ReasonServerLoading)
+	// Reads are served by default, and PING is never deferred
+	c.expect("GET:key=k", protocol.TypeResult)
+	c.expect("PING:", protocol.TypePong)
+	close(rejected)
+
+	<-loaded
+	c.expect("CONTEXT:k=v", protocol.TypeAck)
+	if got := c.expect("GET:key=k", protocol.TypeResult); got.Params["k"] != "v" {
+		t.Fatalf("GET after load = %s", got)
+	}
+	if !srv.Ready() {
+		t.Fatal("server not ready after loading")
+	}
+}
+
+func TestLoadingReadsDeferredByPolicy(t *testing.T) {
+	srv := newUnstartedServer(t, nil)
+	srv.SetLoading(true)
+	srv.SetLoadingReads(false)
+	startTestServer(t, srv)
+
+	c := dialHello(t, srv, "")
+	c.expectError("GET:key=k", protocol.ReasonServerLoading)
+	c.expectError("QUERY:key=k;value=v", protocol.ReasonServerLoading)
+
+	srv.SetLoading(false)
+	c.expect("GET:key=k", protocol.TypeResult)
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// takeOver moves the subscriptions of old, the live connection holding the
+// client ID c is resuming, onto c, including changes queued for old but not
+// yet pushed, and returns how many were moved. From then on old receives
+// no pushes beyond one it may already be writing. Callers must hold s.mu.
+func (s *Server) takeOver(c, old *Connection) int {
+	old.superseded.Store(true)
+	moved := s.store.MoveSubscriptions(old.id, c.id, old.events, c.events)
+	c.delivered.Store(old.delivered.Load())
+	c.logger.Info("Took over client ID %s from connection %s, moving %d subscriptions", c.clientID, old.id, moved)
+	return moved
+}
+
+// retire says GOODBYE to a connection taken over by a resuming one and
+// closes it. The old connection is often dead, so this runs apart from the
+// resuming connection rather than holding up its HELLO.
+func (old *Connection) retire() {
+	go func() {
+		goodbye := protocol.NewMessage(protocol.TypeGoodbye, map[string]string{
+			"reason": "superseded",
+		})
+		if err := old.Send(goodbye); err != nil {
+			old.logger.Debug("Failed to send GOODBYE to superseded connection: %v", err)
+		}
+		old.Close()
+	}()
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+func TestResumeMovesSubscriptions(t *testing.T) {
+	srv := newTestServer(t, nil)
+	writer := dialHello(t, srv, "client_id=writer")
+	old := dialHello(t, srv, "client_id=mon")
+	old.expect("SUBSCRIBE:key=status", protocol.TypeAck)
+	old.expect("SUBSCRIBE:prefix=job.", protocol.TypeAck)
+
+	resumed := dial(t, srv)
+	hello := resumed.expect("HELLO:version="+config.ProtocolVersion+";client_id=mon;resume=true", protocol.TypeHello)
+	if hello.Params["migrated"] != "2" {
+		t.Fatalf("HELLO = %s, want 2 subscriptions migrated", hello)
+	}
+
+	writer.expect("CONTEXT:status=busy;job.a=1", protocol.TypeAck)
+	// One CONTEXT's keys are pushed in no particular order
+	want := map[string]bool{"status": true, "job.a": true}
+	for i := 0; i < 2; i++ {
+		push := resumed.recv()
+		if push.Type != protocol.TypeUpdate || !want[push.Params["key"]] {
+			t.Fatalf("got %s on the resumed connection, want UPDATEs for status and job.a", push)
+		}
+		delete(want, push.Params["key"])
+	}
+
+	// The old connection is told why it is closed and is pushed nothing
+	for {
+		msg, err := old.read(testTimeout)
+		if err != nil {
+			if isTimeout(err) {
+				t.Fatal("superseded connection left open")
+			}
+			break
+		}
+		if msg.Type != protocol.TypeGoodbye || msg.Params["reason"] != "superseded" {
+			t.Fatalf("got %s on the superseded connection, want only GOODBYE", msg)
+		}
+	}
+	waitFor(t, func() bool { return srv.ConnectionCount() == 2 })
+	if n := srv.store.SubscriptionCount(); n != 2 {
+		t.Fatalf("SubscriptionCount() = %d after the old connection closed, want 2", n)
+	}
+}
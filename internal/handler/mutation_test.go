@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// newMutationServer starts a server allowing one key write a second with
+// the given burst, on a clock that only moves when advanced
+func newMutationServer(t *testing.T, burst int, configure func(*config.Config)) (*Server, *testClock) {
+	t.Helper()
+
+	clock := newTestClock()
+	srv := newUnstartedServer(t, func(cfg *config.Config) {
+		cfg.MutationLimit = 1
+		cfg.MutationBurst = burst
+		if configure != nil {
+			configure(cfg)
+		}
+	})
+	srv.SetClock(clock.Now)
+	return startTestServer(t, srv), clock
+}
+
+func TestMutationLimitChargesBatchesWhole(t *testing.T) {
+	srv, clock := newMutationServer(t, 5, nil)
+	c := dialHello(t, srv, "client_id=m")
+
+	c.expect("CONTEXT:a=1;b=1;c=1", protocol.TypeAck)
+	// Three writes do not fit in the two left, so none is made
+	reply := c.expectError("CONTEXT:d=1;e=1;f=1", protocol.ReasonMutationLimited)
+	if reply.Params["retry_after_ms"] != "1000" {
+		t.Errorf("retry_after_ms = %q, want 1000 for the one write short", reply.Params["retry_after_ms"])
+	}
+	if all, _ := srv.store.GetAll("m"); len(all) != 3 {
+		t.Fatalf("context = %v, want the rejected batch left out whole", all)
+	}
+	// Exactly the writes left fit
+	c.expect("CONTEXT:d=1;e=1", protocol.TypeAck)
+
+	// A batch over the burst can never fit, so no retry is suggested
+	clock.Advance(time.Minute)
+	reply = c.expectError("CONTEXT:k1=1;k2=1;k3=1;k4=1;k5=1;k6=1", protocol.ReasonMutationLimited)
+	if retry, set := reply.Params["retry_after_ms"]; set {
+		t.Errorf("retry_after_ms = %s for a batch over the burst", retry)
+	}
+	c.expect("CONTEXT:k1=1;k2=1;k3=1;k4=1;k5=1", protocol.TypeAck)
+}
+
+func TestMutationLimitPerClient(t *testing.T) {
+	srv, _ := newMutationServer(t, 1, nil)
+	srv.SetClientMutationLimit("bulk", 0, 0)
+	srv.SetClientMutationLimit("tight", 1, 2)
+
+	dialHello(t, srv, "client_id=bulk").expect("CONTEXT:a=1;b=1;c=1;d=1", protocol.TypeAck)
+
+	tight := dialHello(t, srv, "client_id=tight")
+	tight.expect("CONTEXT:a=1;b=1", protocol.TypeAck)
+	tight.expectError("CONTEXT:c=1", protocol.ReasonMutationLimited)
+
+	// Clients without an override share the server's limit
+	other := dialHello(t, srv, "client_id=other")
+	other.expectError("CONTEXT:a=1;b=1", protocol.ReasonMutationLimited)
+	other.expect("CONTEXT:a=1", protocol.TypeAck)
+}
+
+func TestMessageLimitRejectsBeforeMutationLimit(t *testing.T) {
+	srv, clock := newMutationServer(t, 3, func(cfg *config.Config) {
+		cfg.RateLimit = 1
+		cfg.RateBurst = 3
+	})
+	c := dialHello(t, srv, "client_id=m")
+
+	c.expect("CONTEXT:a=1;b=1", protocol.TypeAck)
+	c.expect("PING:", protocol.TypePong)
+	c.expectError("CONTEXT:c=1", protocol.ReasonRateLimited)
+
+	// Had the rejected message been charged a write, two would not be left
+	clock.Advance(time.Second)
+	c.expect("CONTEXT:c=1;d=1", protocol.TypeAck)
+
+	stats := srv.GetStats()
+	if stats.DroppedMessages[DropRateLimited] != 1 || stats.DroppedMessages[DropMutations] != 0 {
+		t.Fatalf("drops = %v, want one rate limited and no mutation drop", stats.DroppedMessages)
+	}
+}
+
+func TestMutationLimitExemptsAdmin(t *testing.T) {
+	srv, _ := newMutationServer(t, 1, func(cfg *config.Config) {
+		cfg.AdminSocket = filepath.Join(t.TempDir(), "admin.sock")
+	})
+
+	dialAdmin(t, srv).expect("CONTEXT:a=1;b=1;c=1", protocol.TypeAck)
+	dialHello(t, srv, "").expectError("CONTEXT:a=1;b=1;c=1", protocol.ReasonMutationLimited)
+}
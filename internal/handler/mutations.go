@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"github.com/Artimus100/mcp-server-go/internal/errs"
+)
+
+// mutationLimit is a rate of key writes and deletes, with its burst. A zero
+// rate is unlimited.
+type mutationLimit struct {
+	rate  int
+	burst int
+}
+
+// SetClientMutationLimit overrides the server's MutationLimit and
+// MutationBurst for the client storing its context under clientID, such as
+// one tenant. A zero rate lets the client write without limit. Connections
+// of the client pick the change up at their next write.
+func (s *Server) SetClientMutationLimit(clientID string, rate, burst int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mutationLimits[clientID] = mutationLimit{rate: rate, burst: burst}
+}
+
+// ClearClientMutationLimit returns the client storing its context under
+// clientID to the server's MutationLimit and MutationBurst
+func (s *Server) ClearClientMutationLimit(clientID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.mutationLimits, clientID)
+}
+
+// mutationLimitFor returns the mutation limit applying to clientID
+func (s *Server) mutationLimitFor(clientID string) mutationLimit {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if limit, exists := s.mutationLimits[clientID]; exists {
+		return limit
+	}
+	return mutationLimit{rate: s.cfg.MutationLimit, burst: s.cfg.MutationBurst}
+}
+
+// mutationBucket returns the connection's bucket of key writes, rebuilt
+// full whenever the limit applying to its client ID changes, or nil if
+// writes are unlimited
+func (c *Connection) mutationBucket() *tokenBucket {
+	limit := c.server.mutationLimitFor(c.clientID)
+	if c.mutations == nil || limit != c.mutationLimit {
+		c.mutationLimit = limit
+		c.mutations = newTokenBucket(limit.rate, limit.burst, c.server.now())
+	}
+	return c.mutations
+}
+
+// takeMutations charges n key writes or deletes against the connection's
+// mutation limit, all or none, before they reach the store. Admin
+// connections are not limited. A batch larger than the burst can never be
+// accepted; any other refusal says when the batch would fit.
+func (c *Connection) takeMutations(n int) error {
+	if c.admin || n == 0 {
+		return nil
+	}
+
+	bucket := c.mutationBucket()
+	ok, wait := bucket.take(c.server.now(), n)
+	if ok {
+		return nil
+	}
+	if wait < 0 {
+		return errs.New(errs.ErrMutationLimited, "%d key writes in one message exceed the burst of %d", n, int(bucket.burst))
+	}
+	c.logger.Warning("Rejecting %d key writes: mutation limit exceeded", n)
+	return errs.NewRetry(errs.ErrMutationLimited, wait, "%d key writes exceed the limit of %d per second", n, int(bucket.rate))
+}
@@ -0,0 +1,20 @@
+package handler
+
+import (
+	"crypto/tls"
+	"net"
+)
+
+// setNoDelay turns Nagle's algorithm off (noDelay true) or on for a TCP
+// connection, including one under TLS. It reports false, doing nothing,
+// for other kinds of connection such as the admin unix socket.
+func setNoDelay(conn net.Conn, noDelay bool) (bool, error) {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return false, nil
+	}
+	return true, tcpConn.SetNoDelay(noDelay)
+}
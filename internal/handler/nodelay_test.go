@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"bufio"
+	"net"
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// noDelayOf reports whether the connection with the given ID has Nagle's
+// algorithm disabled
+func noDelayOf(t *testing.T, srv *Server, id string) bool {
+	t.Helper()
+
+	for _, info := range srv.ActiveConnections() {
+		if info.ID == id {
+			return info.NoDelay
+		}
+	}
+	t.Fatalf("no connection %s", id)
+	return false
+}
+
+func TestLowLatencyOverridesServerDefault(t *testing.T) {
+	for _, serverDefault := range []bool{false, true} {
+		srv := newTestServer(t, func(cfg *config.Config) { cfg.NoDelay = serverDefault })
+
+		for _, requested := range []string{"", "true", "false"} {
+			hello := "HELLO:version=" + config.ProtocolVersion
+			if requested != "" {
+				hello += ";low_latency=" + requested
+			}
+			reply := dial(t, srv).expect(hello, protocol.TypeHello)
+
+			want := serverDefault
+			if requested != "" {
+				want = requested == "true"
+			}
+			if got := noDelayOf(t, srv, reply.Params["session"]); got != want {
+				t.Errorf("default %v, low_latency=%q: nodelay %v, want %v", serverDefault, requested, got, want)
+			}
+			if reply.Params["low_latency"] != requested {
+				t.Errorf("default %v, low_latency=%q: HELLO reply %s", serverDefault, requested, reply)
+			}
+		}
+	}
+}
+
+func TestLowLatencyRejectsBadValue(t *testing.T) {
+	dial(t, newTestServer(t, nil)).expectError("HELLO:version="+config.ProtocolVersion+";low_latency=fast", protocol.ReasonInvalidParams)
+}
+
+func TestLowLatencyIgnoredOffTCP(t *testing.T) {
+	srv := newAdminServer(t, &syncBuffer{})
+	conn, err := net.DialTimeout("unix", srv.cfg.AdminSocket, testTimeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	c := &testConn{t: t, conn: conn, reader: bufio.NewReader(conn)}
+
+	reply := c.expect("HELLO:version="+config.ProtocolVersion+";low_latency=true", protocol.TypeHello)
+	if _, reported := reply.Params["low_latency"]; reported {
+		t.Fatalf("HELLO reply %s reports low_latency on a unix socket", reply)
+	}
+	if noDelayOf(t, srv, reply.Params["session"]) {
+		t.Fatal("nodelay recorded for a unix socket")
+	}
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// typeConfig sets type normalization and reports unknown types as errors,
+// so a type the server does not recognise draws a reply
+func typeConfig(normalize bool) func(*config.Config) {
+	return func(cfg *config.Config) {
+		cfg.NormalizeTypes = normalize
+		cfg.UnknownTypePolicy = "error"
+	}
+}
+
+func TestLowercaseTypeNormalized(t *testing.T) {
+	c := dialHello(t, newTestServer(t, typeConfig(true)), "")
+
+	c.expect("ping:", protocol.TypePong)
+	if warning := c.recv(); warning.Type != protocol.TypeWarning || warning.Params["tag"] != DeprecatedLowercaseType {
+		t.Fatalf("got %s, want a deprecation warning", warning)
+	}
+	c.expect("Ping:id=1", protocol.TypePong)
+	c.expect("context:k=v", protocol.TypeAck)
+	if got := c.expect("get:key=k", protocol.TypeResult); got.Params["k"] != "v" {
+		t.Fatalf("got %s", got)
+	}
+}
+
+func TestLowercaseTypeUnknownWithoutNormalization(t *testing.T) {
+	c := dialHello(t, newTestServer(t, typeConfig(false)), "")
+
+	c.expectError("ping:", protocol.ReasonUnknownType)
+	c.expect("PING:", protocol.TypePong)
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// panicReport describes a panic recovered on a connection, with enough
+// context to reproduce it
+type panicReport struct {
+	ConnectionID string
+	ClientID     string
+	RemoteAddr   string
+	MessageType  string // "" if no message was being handled
+	Message      string // the message as dispatched, tokens masked
+	Value        interface{}
+	Stack        []byte
+}
+
+// String formats the report as one log entry, the stack last
+func (r panicReport) String() string {
+	messageType, message := r.MessageType, r.Message
+	if messageType == "" {
+		messageType, message = "none", "none"
+	}
+	return fmt.Sprintf("Recovered from panic: %v; connection=%s client_id=%s remote=%s type=%s message=%s\n%s",
+		r.Value, r.ConnectionID, r.ClientID, r.RemoteAddr, messageType, message, r.Stack)
+}
+
+// recoverPanic recovers a panic on the connection's read loop, logging a
+// report of it before the connection is closed. It must be deferred.
+func (c *Connection) recoverPanic() {
+	value := recover()
+	if value == nil {
+		return
+	}
+
+	report := panicReport{
+		ConnectionID: c.id,
+		ClientID:     c.clientID,
+		RemoteAddr:   c.conn.RemoteAddr().String(),
+		Value:        value,
+		Stack:        debug.Stack(),
+	}
+	if c.handling.Type != "" {
+		report.MessageType = c.handling.Type
+		report.Message = redactToken(c.handling)
+	}
+	c.logger.Error("%s", report)
+	c.server.metrics.Inc("mcp_panics_total", "type", c.server.metricType(report.MessageType))
+}
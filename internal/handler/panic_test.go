@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+	"github.com/Artimus100/mcp-server-go/internal/utils"
+)
+
+// panicking is a validator that panics, standing in for a handler bug
+type panicking struct{}
+
+func (panicking) Validate(value string) error { panic("validator bug") }
+func (panicking) Describe() string            { return "panic" }
+
+func TestPanicReportNamesConnectionAndMessage(t *testing.T) {
+	log := &syncBuffer{}
+	cfg := config.Default()
+	cfg.Port = 0
+	serveMetrics(&cfg)
+	srv := NewServer(cfg, state.NewContextStore(), utils.NewLoggerTo(log, "test"))
+	if err := srv.RegisterKeySpec(KeySpec{Name: "boom", Pattern: "boom", Validator: panicking{}}); err != nil {
+		t.Fatal(err)
+	}
+	startTestServer(t, srv)
+
+	c := dial(t, srv)
+	session := c.expect("HELLO:version="+config.ProtocolVersion+";client_id=victim", protocol.TypeHello).Params["session"]
+	c.send("CONTEXT:boom=1;id=r1")
+	c.expectClosed()
+
+	report := log.String()
+	for _, want := range []string{
+		"Recovered from panic: validator bug",
+		"connection=" + session,
+		"client_id=victim",
+		"remote=" + c.conn.LocalAddr().String(),
+		"type=CONTEXT",
+		"id:r1",
+		"runtime/debug.Stack",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("panic report missing %q:\n%s", want, report)
+		}
+	}
+	if n := scrape(t, srv)[`mcp_panics_total{type="CONTEXT"}`]; n != 1 {
+		t.Errorf("mcp_panics_total for CONTEXT = %v, want 1", n)
+	}
+
+	// Only the panicking connection is lost
+	dialHello(t, srv, "").expect("PING:", protocol.TypePong)
+}
@@ -0,0 +1,33 @@
+//go:build linux
+
+package handler
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// peerCredentials describes the process on the other end of a unix socket
+// using SO_PEERCRED
+func peerCredentials(conn net.Conn) string {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return "unknown peer"
+	}
+
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return "unknown peer"
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil || credErr != nil {
+		return "unknown peer"
+	}
+
+	return fmt.Sprintf("uid=%d gid=%d pid=%d", cred.Uid, cred.Gid, cred.Pid)
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package handler
+
+import (
+	"net"
+)
+
+// peerCredentials describes the process on the other end of a unix socket.
+// Peer credentials are only available on Linux.
+func peerCredentials(conn net.Conn) string {
+	return "unknown peer"
+}
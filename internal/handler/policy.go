@@ -0,0 +1,29 @@
+package handler
+
+// EmptyValuePolicy controls how CONTEXT parameters with empty values, such
+// as "key=", are handled
+type EmptyValuePolicy int
+
+// Empty value policies
+const (
+	// EmptyValueKeep stores the empty string as the value
+	EmptyValueKeep EmptyValuePolicy = iota
+	// EmptyValueReject rejects the whole update with an ERROR
+	EmptyValueReject
+	// EmptyValueDelete removes the key from the client's context
+	EmptyValueDelete
+)
+
+// String returns the string representation of the policy
+func (p EmptyValuePolicy) String() string {
+	switch p {
+	case EmptyValueKeep:
+		return "keep"
+	case EmptyValueReject:
+		return "reject"
+	case EmptyValueDelete:
+		return "delete"
+	default:
+		return "unknown"
+	}
+}
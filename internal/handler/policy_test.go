@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+func TestEmptyValuePolicy(t *testing.T) {
+	tests := []struct {
+		policy EmptyValuePolicy
+		check  func(t *testing.T, c *testConn)
+	}{
+		{EmptyValueKeep, func(t *testing.T, c *testConn) {
+			c.expect("CONTEXT:region=;zone=b", protocol.TypeAck)
+			got := c.expect("GET:", protocol.TypeResult)
+			if value, stored := got.Params["region"]; !stored || value != "" {
+				t.Fatalf("GET = %s, want region stored empty", got)
+			}
+		}},
+		{EmptyValueReject, func(t *testing.T, c *testConn) {
+			c.expectError("CONTEXT:region=;zone=b", protocol.ReasonInvalidParams)
+			got := c.expect("GET:", protocol.TypeResult)
+			if got.Params["region"] != "eu" || got.Params["zone"] != "a" {
+				t.Fatalf("GET = %s, want the update rejected whole", got)
+			}
+		}},
+		{EmptyValueDelete, func(t *testing.T, c *testConn) {
+			c.expect("CONTEXT:region=;zone=b", protocol.TypeAck)
+			got := c.expect("GET:", protocol.TypeResult)
+			if _, stored := got.Params["region"]; stored || got.Params["zone"] != "b" {
+				t.Fatalf("GET = %s, want region deleted and zone updated", got)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			srv := newUnstartedServer(t, nil)
+			srv.SetEmptyValuePolicy(tt.policy)
+			startTestServer(t, srv)
+
+			c := dialHello(t, srv, "")
+			c.expect("CONTEXT:region=eu;zone=a", protocol.TypeAck)
+			tt.check(t, c)
+		})
+	}
+}
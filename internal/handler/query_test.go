@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// queryClients sends a QUERY and decodes the client list of the reply
+func queryClients(t *testing.T, c *testConn, line string) ([]string, protocol.Message) {
+	t.Helper()
+
+	reply := c.expect(line, protocol.TypeResult)
+	clients, err := protocol.SplitList(reply.Params["clients"])
+	if err != nil {
+		t.Fatalf("%q: bad client list in %s: %v", line, reply, err)
+	}
+	return clients, reply
+}
+
+func TestQueryReturnsOnlyMatchingClients(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	roles := map[string]string{
+		"alice":      "admin",
+		"bob":        "viewer",
+		"carol":      "admin",
+		"dave":       "Admin",
+		"eve":        "admin ",
+		"ops,backup": "admin",
+	}
+	for id, role := range roles {
+		c := dial(t, srv)
+		hello := protocol.NewMessage(protocol.TypeHello, map[string]string{
+			"version":   config.ProtocolVersion,
+			"client_id": id,
+		})
+		c.expect(hello.Format(), protocol.TypeHello)
+		c.expect(protocol.NewMessage(protocol.TypeContext, map[string]string{"role": role}).Format(), protocol.TypeAck)
+	}
+	// A client without the key at all
+	q := dialHello(t, srv, "client_id=frank")
+
+	clients, reply := queryClients(t, q, "QUERY:key=role;value=admin")
+	if want := []string{"alice", "carol", "ops,backup"}; !reflect.DeepEqual(clients, want) {
+		t.Fatalf("clients = %q, want %q", clients, want)
+	}
+	if reply.Params["count"] != "3" || reply.Params["truncated"] != "" {
+		t.Fatalf("reply = %s, want count=3 and no truncation", reply)
+	}
+
+	if clients, _ := queryClients(t, q, "QUERY:key=role;value=owner"); len(clients) != 0 {
+		t.Fatalf("clients = %q, want none", clients)
+	}
+}
+
+func TestQueryLimitTruncates(t *testing.T) {
+	srv := newTestServer(t, nil)
+	for i := 0; i < 5; i++ {
+		c := dialHello(t, srv, "client_id=c"+strconv.Itoa(i))
+		c.expect("CONTEXT:zone=eu", protocol.TypeAck)
+	}
+	q := dialHello(t, srv, "")
+
+	clients, reply := queryClients(t, q, "QUERY:key=zone;value=eu;limit=2")
+	if want := []string{"c0", "c1"}; !reflect.DeepEqual(clients, want) {
+		t.Fatalf("clients = %q, want %q", clients, want)
+	}
+	if reply.Params["count"] != "5" || reply.Params["truncated"] != "true" {
+		t.Fatalf("reply = %s, want count=5 and truncated", reply)
+	}
+}
+
+func TestQueryReplyFitsNegotiatedSize(t *testing.T) {
+	srv := newTestServer(t, nil)
+	long := strings.Repeat("x", 200)
+	for i := 0; i < 10; i++ {
+		c := dialHello(t, srv, "client_id="+long+strconv.Itoa(i))
+		c.expect("CONTEXT:zone=eu", protocol.TypeAck)
+	}
+	q := dialHello(t, srv, "max_message_size=1024")
+
+	clients, reply := queryClients(t, q, "QUERY:key=zone;value=eu")
+	if size := len(reply.Format()); size > 1024 {
+		t.Fatalf("reply is %d bytes, over the negotiated 1024", size)
+	}
+	if len(clients) == 0 || len(clients) >= 10 || reply.Params["truncated"] != "true" {
+		t.Fatalf("got %d clients, truncated=%q; want a truncated, non-empty list", len(clients), reply.Params["truncated"])
+	}
+
+	// The echoed id counts towards the size too
+	id := strings.Repeat("i", 300)
+	clients, reply = queryClients(t, q, "QUERY:key=zone;value=eu;id="+id)
+	if size := len(reply.Format()); size > 1024 {
+		t.Fatalf("reply with id is %d bytes, over the negotiated 1024", size)
+	}
+	if got, _ := reply.ID(); got != id || len(clients) == 0 {
+		t.Fatalf("got %d clients and id %q; want some clients and the long id", len(clients), got)
+	}
+}
+
+func TestQueryRejectsBadParams(t *testing.T) {
+	q := dialHello(t, newTestServer(t, nil), "")
+
+	for _, line := range []string{
+		"QUERY:value=admin",
+		"QUERY:key=;value=admin",
+		"QUERY:key=role",
+		"QUERY:key=role;value=admin;limit=0",
+		"QUERY:key=role;value=admin;limit=many",
+		"QUERY:key=role;value=admin;include_server=maybe",
+	} {
+		q.expectError(line, protocol.ReasonInvalidParams)
+	}
+}
+
+func TestQueryByRegion(t *testing.T) {
+	srv := newTestServer(t, nil)
+	for id, region := range map[string]string{"a": "us-east", "b": "eu-west", "c": "us-east"} {
+		dialHello(t, srv, "client_id="+id).expect("CONTEXT:region="+region, protocol.TypeAck)
+	}
+	q := dialHello(t, srv, "")
+
+	for region, want := range map[string][]string{
+		"us-east":  {"a", "c"},
+		"eu-west":  {"b"},
+		"ap-south": nil,
+	} {
+		if clients, _ := queryClients(t, q, "QUERY:key=region;value="+region); !reflect.DeepEqual(clients, want) {
+			t.Errorf("%s: clients = %q, want %q", region, clients, want)
+		}
+	}
+}
+
+func TestBuiltinTypesAreValid(t *testing.T) {
+	for msgType := range builtinHandlers {
+		if !protocol.ValidateMessageType(msgType) {
+			t.Errorf("ValidateMessageType(%s) = false for a built-in type", msgType)
+		}
+	}
+	if !protocol.ValidateMessageType(protocol.TypeResult) {
+		t.Error("RESULT is not a valid type")
+	}
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"time"
+)
+
+// tokenBucket limits the rate of messages, or of key writes, from one
+// connection. It holds up to burst tokens, refilled at rate tokens per
+// second; each message or key write takes one. It is only used from the
+// connection's read loop, so it needs no locking.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newTokenBucket creates a full bucket, or returns nil if rate is not
+// positive, which disables limiting
+func newTokenBucket(rate, burst int, now time.Time) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = rate
+	}
+
+	return &tokenBucket{
+		rate:   float64(rate),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+// allow takes a token if one is available, reporting whether it did. A nil
+// bucket allows everything.
+func (b *tokenBucket) allow(now time.Time) bool {
+	ok, _ := b.take(now, 1)
+	return ok
+}
+
+// take takes n tokens at once if that many are available. Otherwise it
+// takes none and returns how long until n will be available, or a negative
+// duration if n exceeds the burst and never will. A nil bucket allows
+// everything.
+func (b *tokenBucket) take(now time.Time, n int) (bool, time.Duration) {
+	if b == nil {
+		return true, 0
+	}
+
+	b.refill(now)
+	need := float64(n)
+	if need > b.burst {
+		return false, -1
+	}
+	if b.tokens < need {
+		wait := time.Duration((need - b.tokens) / b.rate * float64(time.Second))
+		return false, wait
+	}
+	b.tokens -= need
+	return true, 0
+}
+
+// available returns the number of whole tokens in the bucket
+func (b *tokenBucket) available(now time.Time) int {
+	b.refill(now)
+	return int(b.tokens)
+}
+
+// refill adds the tokens accrued since the last refill
+func (b *tokenBucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+}
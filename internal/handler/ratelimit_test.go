@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Unix(1000, 0)
+	b := newTokenBucket(2, 3, start)
+
+	for i := 0; i < 3; i++ {
+		if !b.allow(start) {
+			t.Fatalf("message %d of the burst was rejected", i+1)
+		}
+	}
+	if b.allow(start) {
+		t.Fatal("message beyond the burst was allowed")
+	}
+	if !b.allow(start.Add(500 * time.Millisecond)) {
+		t.Fatal("no token refilled after half a second at 2/s")
+	}
+
+	var unlimited *tokenBucket
+	if !unlimited.allow(start) {
+		t.Fatal("nil bucket rejected a message")
+	}
+}
+
+// burstRejections sends n copies of line in one write and returns how many
+// were rejected as rate limited
+func burstRejections(t *testing.T, c *testConn, line string, n int) int {
+	t.Helper()
+
+	burst := ""
+	for i := 0; i < n; i++ {
+		burst += line + "\n"
+	}
+	c.send(burst[:len(burst)-1])
+
+	rejected := 0
+	for i := 0; i < n; i++ {
+		reply := c.recv()
+		if reply.Type == protocol.TypeError {
+			if reply.Params["reason"] != "rate_limited" {
+				t.Fatalf("unexpected error %s", reply)
+			}
+			rejected++
+		}
+	}
+	return rejected
+}
+
+func TestRateLimitRejectsBurst(t *testing.T) {
+	for _, line := range []string{"PING:", "PING:id=1", "GET:key=missing"} {
+		t.Run(line, func(t *testing.T) {
+			srv := newUnstartedServer(t, func(cfg *config.Config) {
+				cfg.RateLimit = 1
+				cfg.RateBurst = 5
+			})
+			// A frozen clock keeps the bucket from refilling mid-burst
+			frozen := time.Now()
+			srv.SetClock(func() time.Time { return frozen })
+			startTestServer(t, srv)
+
+			c := dialHello(t, srv, "")
+			if rejected := burstRejections(t, c, line, 10); rejected != 6 {
+				t.Fatalf("%d of 10 rejected, want 6", rejected)
+			}
+			if dropped := srv.GetStats().DroppedMessages[DropRateLimited]; dropped != 6 {
+				t.Fatalf("%d drops counted, want 6", dropped)
+			}
+		})
+	}
+}
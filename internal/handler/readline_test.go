@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/errs"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// cycleReader reads data over and over
+type cycleReader struct {
+	data []byte
+	off  int
+}
+
+func (r *cycleReader) Read(p []byte) (int, error) {
+	n := copy(p, r.data[r.off:])
+	r.off = (r.off + n) % len(r.data)
+	return n, nil
+}
+
+func TestReadLineAcrossBufferSizes(t *testing.T) {
+	lines := []string{
+		"PING:",
+		"",
+		"CONTEXT:k=" + strings.Repeat("a", 15),
+		"CONTEXT:k=" + strings.Repeat("b", 40),
+		"CONTEXT:k=" + strings.Repeat("c", 100),
+		"GET:key=k",
+		"CONTEXT:k=" + strings.Repeat("d", 40),
+	}
+	input := strings.Join(lines, "\n") + "\n"
+
+	c := &Connection{}
+	reader := bufio.NewReaderSize(strings.NewReader(input), 16)
+	for i, want := range lines {
+		line, err := c.readLine(reader, 1024)
+		if err != nil {
+			t.Fatalf("line %d: %v", i, err)
+		}
+		// A line is only valid until the next read
+		if got := string(line); got != want {
+			t.Fatalf("line %d = %q, want %q", i, got, want)
+		}
+	}
+	if _, err := c.readLine(reader, 1024); err != io.EOF {
+		t.Fatalf("after the last line: %v, want EOF", err)
+	}
+}
+
+func TestReadLineEnforcesLimit(t *testing.T) {
+	for _, size := range []int{10, 100} {
+		input := strings.Repeat("x", size+1) + "\n" + "PING:\n"
+		c := &Connection{}
+		reader := bufio.NewReaderSize(strings.NewReader(input), 16)
+
+		if _, err := c.readLine(reader, size); err != errs.ErrMessageTooLarge {
+			t.Errorf("%d-byte limit: %v, want ErrMessageTooLarge", size, err)
+		}
+	}
+}
+
+func TestReadLineReusesSpillBuffer(t *testing.T) {
+	input := "CONTEXT:k=" + strings.Repeat("v", 200) + "\n"
+	c := &Connection{}
+	reader := bufio.NewReaderSize(&cycleReader{data: []byte(input)}, 64)
+
+	// The first long line sizes the buffer; later ones reuse it
+	if _, err := c.readLine(reader, 1024); err != nil {
+		t.Fatal(err)
+	}
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := c.readLine(reader, 1024); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("%v allocations per line, want 0", allocs)
+	}
+}
+
+func TestValuesSurviveBufferReuse(t *testing.T) {
+	c := dialHello(t, newTestServer(t, maxMessage(16*1024)), "")
+
+	// Written at once, so lines sit side by side in the read buffer and
+	// long ones spill into the reused line buffer
+	var batch strings.Builder
+	want := make(map[string]string)
+	for i, size := range []int{10, 5000, 20, 9000, 3, 4090} {
+		key := fmt.Sprintf("k%d", i)
+		want[key] = strings.Repeat(string(rune('a'+i)), size)
+		batch.WriteString("CONTEXT:" + key + "=" + want[key] + "\n")
+	}
+	c.conn.Write([]byte(batch.String()))
+	for range want {
+		if reply := c.recv(); reply.Type != protocol.TypeAck {
+			t.Fatalf("got %s, want ACK", reply)
+		}
+	}
+
+	for key, value := range want {
+		if got := c.expect("GET:key="+key, protocol.TypeResult); got.Params[key] != value {
+			t.Fatalf("%s holds %d bytes, want %d intact", key, len(got.Params[key]), len(value))
+		}
+	}
+}
+
+// benchmarkReadLines reads lines of lineSize bytes through a 4 KB reader,
+// as the connection does, using read
+func benchmarkReadLines(b *testing.B, lineSize int, read func(*bufio.Reader) error) {
+	input := "CONTEXT:k=" + strings.Repeat("v", lineSize-10) + "\n"
+	reader := bufio.NewReaderSize(&cycleReader{data: []byte(input)}, 4096)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+	for i := 0; i < b.N; i++ {
+		if err := read(reader); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkReadLine(b *testing.B) {
+	for _, size := range []int{64, 1024, 16 * 1024} {
+		b.Run(fmt.Sprintf("reused/%d", size), func(b *testing.B) {
+			c := &Connection{}
+			benchmarkReadLines(b, size, func(r *bufio.Reader) error {
+				_, err := c.readLine(r, 64*1024)
+				return err
+			})
+		})
+		// The ReadString the reused buffer replaced, for comparison
+		b.Run(fmt.Sprintf("ReadString/%d", size), func(b *testing.B) {
+			benchmarkReadLines(b, size, func(r *bufio.Reader) error {
+				_, err := r.ReadString('\n')
+				return err
+			})
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// HandlerFunc handles a message, returning the response to send back. A
+// returned error is sent as an ERROR message instead; a response with an
+// empty type sends nothing.
+type HandlerFunc func(c *Connection, msg protocol.Message) (protocol.Message, error)
+
+// handlerRegistry maps message types to their handlers
+type handlerRegistry struct {
+	handlers map[string]HandlerFunc
+	mu       sync.RWMutex
+}
+
+// newHandlerRegistry creates a registry holding the built-in handlers
+func newHandlerRegistry() *handlerRegistry {
+	r := &handlerRegistry{
+		handlers: make(map[string]HandlerFunc, len(builtinHandlers)),
+	}
+	for msgType, fn := range builtinHandlers {
+		r.handlers[msgType] = fn
+	}
+	return r
+}
+
+// register adds the handler for a message type, rejecting types that
+// already have one
+func (r *handlerRegistry) register(msgType string, fn HandlerFunc) error {
+	if msgType == "" || fn == nil {
+		return fmt.Errorf("handler needs a message type and a function")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.handlers[msgType]; exists {
+		return fmt.Errorf("handler for %s already registered", msgType)
+	}
+
+	r.handlers[msgType] = fn
+	return nil
+}
+
+// lookup returns the handler for a message type
+func (r *handlerRegistry) lookup(msgType string) (HandlerFunc, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	fn, exists := r.handlers[msgType]
+	return fn, exists
+}
+
+// RegisterHandler adds a handler for a new message type. Messages of that
+// type pass through the same handshake, idempotency, sequencing and
+// transform steps as built-in ones before reaching fn. Registering a type
+// that already has a handler, built-in or not, returns an error.
+func (s *Server) RegisterHandler(msgType string, fn HandlerFunc) error {
+	return s.handlers.register(msgType, fn)
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/errs"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// echo replies with the parameters it was sent, refusing an empty message
+func echo(c *Connection, msg protocol.Message) (protocol.Message, error) {
+	if len(msg.Params) == 0 {
+		return protocol.Message{}, errs.New(errs.ErrInvalidParams, "nothing to echo")
+	}
+	return protocol.NewMessage("ECHO", msg.Params), nil
+}
+
+func TestRegisteredHandlerServesEndToEnd(t *testing.T) {
+	srv := newUnstartedServer(t, nil)
+	if err := srv.RegisterHandler("ECHO", echo); err != nil {
+		t.Fatalf("RegisterHandler: %v", err)
+	}
+	startTestServer(t, srv)
+
+	// Registered types go through the handshake like built-in ones
+	c := dial(t, srv)
+	c.expectError("ECHO:x=1", protocol.ReasonHandshakeRequired)
+	c = dialHello(t, srv, "")
+
+	reply := c.expect(`ECHO:text=a\;b;id=7`, "ECHO")
+	if reply.Params["text"] != "a;b" || reply.Params["id"] != "7" {
+		t.Fatalf("got %s, want the text back with the request id", reply)
+	}
+	reply = c.expectError("ECHO:id=8", protocol.ReasonInvalidParams)
+	if reply.Params["id"] != "8" || reply.Params["detail"] != "nothing to echo" {
+		t.Fatalf("got %s, want the handler's error correlated with id 8", reply)
+	}
+}
+
+func TestRegisterHandlerRejectsDuplicates(t *testing.T) {
+	srv := newUnstartedServer(t, nil)
+	if err := srv.RegisterHandler("ECHO", echo); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, msgType := range []string{"ECHO", protocol.TypePing, protocol.TypeContext} {
+		if err := srv.RegisterHandler(msgType, echo); err == nil {
+			t.Errorf("RegisterHandler(%s) accepted a duplicate", msgType)
+		}
+	}
+	if err := srv.RegisterHandler("", echo); err == nil {
+		t.Error("RegisterHandler accepted an empty type")
+	}
+	if err := srv.RegisterHandler("NOOP", nil); err == nil {
+		t.Error("RegisterHandler accepted a nil function")
+	}
+}
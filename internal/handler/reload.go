@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+)
+
+// Reload rereads the files the server was configured with: the auth token
+// file and the context template. Each is replaced only if it loads, so one
+// that fails leaves its current values in place; the failures are returned
+// together. The server reloads on SIGHUP and on an admin RELOAD.
+func (s *Server) Reload() error {
+	var failures []error
+
+	if s.cfg.AuthTokenFile != "" {
+		if tokens, err := s.cfg.AuthTokens(); err != nil {
+			s.logger.Error("Keeping current auth tokens: %v", err)
+			failures = append(failures, fmt.Errorf("auth tokens: %v", err))
+		} else {
+			s.SetAuthTokens(tokens)
+			s.logger.Info("Reloaded %d auth tokens from %s", len(tokens), s.cfg.AuthTokenFile)
+		}
+	}
+
+	if s.cfg.ContextTemplate != "" {
+		if template, err := config.LoadValues(s.cfg.ContextTemplate); err != nil {
+			s.logger.Error("Keeping current context template: %v", err)
+			failures = append(failures, fmt.Errorf("context template: %v", err))
+		} else {
+			s.SetContextTemplate(template)
+			s.logger.Info("Reloaded context template from %s", s.cfg.ContextTemplate)
+		}
+	}
+
+	return errors.Join(failures...)
+}
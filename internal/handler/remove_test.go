@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+func TestRemoveReportsRemovedCount(t *testing.T) {
+	srv := newTestServer(t, nil)
+	c := dialHello(t, srv, "client_id=r")
+	c.expect("CONTEXT:a=1;b=2;keep=3", protocol.TypeAck)
+
+	if ack := c.expect("REMOVE:k1=a;k2=missing;k3=b", protocol.TypeAck); ack.Params["removed"] != "2" {
+		t.Fatalf("REMOVE = %s, want removed=2", ack)
+	}
+	if got := c.expect("GET:", protocol.TypeResult); len(got.Params) != 1 || got.Params["keep"] != "3" {
+		t.Fatalf("GET = %s, want only keep left", got)
+	}
+
+	if ack := c.expect("REMOVE:key=a", protocol.TypeAck); ack.Params["removed"] != "0" {
+		t.Fatalf("REMOVE of a removed key = %s, want removed=0", ack)
+	}
+	c.expectError("REMOVE:", protocol.ReasonInvalidParams)
+	c.expectError("REMOVE:key=", protocol.ReasonInvalidParams)
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+)
+
+// selfStats turns the server counters into the values published under
+// state.ServerClientID, remembering the previous publication so message
+// counts can be reported as rates
+type selfStats struct {
+	server       *Server
+	lastAt       time.Time
+	lastMessages uint64
+}
+
+// publishSelfStats publishes the server's statistics into the context store
+// at start and then every SelfStatsInterval until shutdown, so clients can
+// read them with GET or watch them with SUBSCRIBE
+func (s *Server) publishSelfStats() {
+	ticker := time.NewTicker(s.cfg.SelfStatsInterval)
+	defer ticker.Stop()
+
+	pub := &selfStats{server: s}
+	pub.publish()
+	for {
+		select {
+		case <-s.closeChan:
+			return
+		case <-ticker.C:
+			if s.Loading() {
+				continue
+			}
+			pub.publish()
+		}
+	}
+}
+
+// publish writes the current statistics in one multi-key write. Store sizes
+// include the statistics themselves.
+func (p *selfStats) publish() {
+	s := p.server
+	now := s.now()
+	stats := s.GetStats()
+	store := s.store.Stats()
+
+	// The first publication has nothing to measure a rate against
+	rate := 0.0
+	if elapsed := now.Sub(p.lastAt).Seconds(); !p.lastAt.IsZero() && elapsed > 0 {
+		rate = float64(stats.MessagesProcessed-p.lastMessages) / elapsed
+	}
+	p.lastAt = now
+	p.lastMessages = stats.MessagesProcessed
+
+	values := s.timeParams(now)
+	values["protocol_version"] = config.ProtocolVersion
+	values["connections_active"] = strconv.Itoa(stats.ActiveConnections)
+	values["connections_accepted"] = strconv.FormatUint(stats.ConnectionsAccepted, 10)
+	values["messages_processed"] = strconv.FormatUint(stats.MessagesProcessed, 10)
+	values["messages_per_second"] = strconv.FormatFloat(rate, 'f', 2, 64)
+	values["parse_errors"] = strconv.FormatUint(stats.ParseErrors, 10)
+	values["store_clients"] = strconv.Itoa(store.Clients)
+	values["store_keys"] = strconv.Itoa(store.Keys)
+	values["store_bytes"] = strconv.FormatInt(store.RawBytes, 10)
+
+	// Only write what changed, so subscribers to a key hear of real changes
+	current, _ := s.store.GetAll(state.ServerClientID)
+	for key, value := range values {
+		if old, exists := current[key]; exists && old == value {
+			delete(values, key)
+		}
+	}
+	s.store.SetMultiple(state.ServerClientID, values)
+}
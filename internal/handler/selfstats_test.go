@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+)
+
+func TestSelfStatsUpdateOnTicks(t *testing.T) {
+	clock := newTestClock()
+	srv := newUnstartedServer(t, nil)
+	srv.SetClock(clock.Now)
+	startTestServer(t, srv)
+	c := dialHello(t, srv, "")
+
+	// Ticks are driven by hand rather than by SelfStatsInterval
+	pub := &selfStats{server: srv}
+	pub.publish()
+	first, _ := srv.store.GetAll(state.ServerClientID)
+	if first["connections_active"] != "1" || first["messages_per_second"] != "0.00" || first["protocol_version"] != config.ProtocolVersion {
+		t.Fatalf("first publication = %v", first)
+	}
+
+	for i := 0; i < 10; i++ {
+		c.expect("PING:", protocol.TypePong)
+	}
+	clock.Advance(5 * time.Second)
+	pub.publish()
+	second, _ := srv.store.GetAll(state.ServerClientID)
+
+	before, _ := strconv.ParseUint(first["messages_processed"], 10, 64)
+	after, _ := strconv.ParseUint(second["messages_processed"], 10, 64)
+	if after-before != 10 || second["messages_per_second"] != "2.00" {
+		t.Fatalf("after 10 PINGs in 5s: messages %s -> %s at %s/s, want 10 more at 2.00/s",
+			first["messages_processed"], second["messages_processed"], second["messages_per_second"])
+	}
+	if second["uptime_ms"] == first["uptime_ms"] {
+		t.Fatalf("uptime_ms stuck at %s across a tick", second["uptime_ms"])
+	}
+
+	// Any client reads them with an ordinary GET
+	reply := c.expect("GET:_client=__server__;key=messages_processed", protocol.TypeResult)
+	if reply.Params["messages_processed"] != second["messages_processed"] {
+		t.Fatalf("GET = %s, want messages_processed=%s", reply, second["messages_processed"])
+	}
+}
+
+func TestSelfStatsPublishedOnInterval(t *testing.T) {
+	srv := newTestServer(t, func(cfg *config.Config) { cfg.SelfStatsInterval = 10 * time.Millisecond })
+	dialHello(t, srv, "")
+
+	waitFor(t, func() bool {
+		values, _ := srv.store.GetAll(state.ServerClientID)
+		return values["connections_active"] == "1"
+	})
+}
+
+func TestServerClientIDIsReserved(t *testing.T) {
+	srv := newTestServer(t, nil)
+	srv.store.Set(state.ServerClientID, "version", "1")
+
+	// No connection can take the ID and write under it
+	c := dial(t, srv)
+	c.expectError("HELLO:version="+config.ProtocolVersion+";client_id="+state.ServerClientID, protocol.ReasonReadOnly)
+
+	c = dialHello(t, srv, "client_id=ordinary")
+	c.expect("CONTEXT:version=2", protocol.TypeAck)
+	c.expect("REMOVE:key=version", protocol.TypeAck)
+	if got, _ := srv.store.Get(state.ServerClientID, "version"); got != "1" {
+		t.Fatalf("published value = %q after another client's writes, want 1", got)
+	}
+	c.expectError("GET:_client=other", protocol.ReasonInvalidParams)
+
+	// QUERY leaves the server out unless asked
+	if clients, _ := queryClients(t, c, "QUERY:key=version;value=1"); len(clients) != 0 {
+		t.Fatalf("QUERY matched %q, want the server left out", clients)
+	}
+	clients, _ := queryClients(t, c, "QUERY:key=version;value=1;include_server=true")
+	if len(clients) != 1 || clients[0] != state.ServerClientID {
+		t.Fatalf("QUERY with include_server matched %q", clients)
+	}
+}
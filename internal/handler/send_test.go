@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// onlyConnection returns the server side of the single open connection
+func onlyConnection(t *testing.T, srv *Server) *Connection {
+	t.Helper()
+
+	srv.mu.RLock()
+	defer srv.mu.RUnlock()
+
+	if len(srv.connections) != 1 {
+		t.Fatalf("%d connections open, want 1", len(srv.connections))
+	}
+	for _, conn := range srv.connections {
+		return conn
+	}
+	return nil
+}
+
+func TestConcurrentSendWritesWholeLines(t *testing.T) {
+	const senders, perSender = 10, 200
+
+	srv := newTestServer(t, nil)
+	c := dialHello(t, srv, "")
+	conn := onlyConnection(t, srv)
+
+	// Values long enough that an unguarded write would be split
+	padding := strings.Repeat("x", 4096)
+	var wg sync.WaitGroup
+	for g := 0; g < senders; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < perSender; i++ {
+				msg := protocol.NewMessage(protocol.TypeContext, map[string]string{
+					"sender": fmt.Sprint(g),
+					"seq":    fmt.Sprint(i),
+					"pad":    padding,
+				})
+				if err := conn.Send(msg); err != nil {
+					t.Errorf("Send: %v", err)
+					return
+				}
+			}
+		}(g)
+	}
+
+	next := make([]int, senders)
+	for n := 0; n < senders*perSender; n++ {
+		msg := c.recv()
+		var g, i int
+		if _, err := fmt.Sscan(msg.Params["sender"], &g); err != nil || g < 0 || g >= senders {
+			t.Fatalf("line %d is not a whole message: %s", n, msg)
+		}
+		fmt.Sscan(msg.Params["seq"], &i)
+		if i != next[g] || msg.Params["pad"] != padding {
+			t.Fatalf("line %d from sender %d is torn or out of order: seq %d, want %d", n, g, i, next[g])
+		}
+		next[g]++
+	}
+	wg.Wait()
+}
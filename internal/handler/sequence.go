@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"sync"
+	"time"
+)
+
+// sequenceTracker records the highest inbound sequence number processed for
+// each client ID, so messages that arrive late from a previous connection of
+// the same client cannot clobber newer state. The record outlives the
+// connection, so a client resuming under its claimed ID keeps counting
+// where it left off; a record unused for ttl is forgotten.
+type sequenceTracker struct {
+	last      map[string]sequenceRecord
+	ttl       time.Duration
+	now       func() time.Time
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+// sequenceRecord is the highest sequence number a client has had accepted
+type sequenceRecord struct {
+	seq    uint64
+	seenAt time.Time
+}
+
+// newSequenceTracker creates an empty sequence tracker forgetting clients
+// idle for ttl
+func newSequenceTracker(ttl time.Duration) *sequenceTracker {
+	return &sequenceTracker{
+		last: make(map[string]sequenceRecord),
+		ttl:  ttl,
+		now:  time.Now,
+	}
+}
+
+// Accept reports whether seq is newer than anything already processed for
+// the client and, if so, records it as processed. The check and update are
+// atomic so concurrent connections of one client cannot both accept the
+// same sequence number.
+func (t *sequenceTracker) Accept(clientID string, seq uint64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	t.sweep(now)
+
+	if last, exists := t.last[clientID]; exists && now.Sub(last.seenAt) < t.ttl && seq <= last.seq {
+		return false
+	}
+
+	t.last[clientID] = sequenceRecord{seq: seq, seenAt: now}
+	return true
+}
+
+// sweep forgets clients idle for ttl, at most once per ttl. Callers must
+// hold t.mu.
+func (t *sequenceTracker) sweep(now time.Time) {
+	if now.Sub(t.lastSweep) < t.ttl {
+		return
+	}
+	t.lastSweep = now
+
+	for clientID, last := range t.last {
+		if now.Sub(last.seenAt) >= t.ttl {
+			delete(t.last, clientID)
+		}
+	}
+}
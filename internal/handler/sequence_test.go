@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+func TestResumedSessionRejectsStaleSequence(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	old := dialHello(t, srv, "client_id=seq-client")
+	old.expect("CONTEXT:k=1;seq=1", protocol.TypeAck)
+	old.expect("CONTEXT:k=2;seq=2", protocol.TypeAck)
+	old.conn.Close()
+	waitFor(t, func() bool { return srv.ConnectionCount() == 0 })
+
+	resumed := dialHello(t, srv, "client_id=seq-client")
+	// A write the old connection sent before it died arrives late
+	resumed.expectError("CONTEXT:k=stale;seq=2", protocol.ReasonStaleSequence)
+	resumed.expectError("CONTEXT:k=stale;seq=1", protocol.ReasonStaleSequence)
+	resumed.expect("CONTEXT:k=3;seq=3", protocol.TypeAck)
+
+	if value, _ := srv.store.Get("seq-client", "k"); value != "3" {
+		t.Fatalf("k = %q, want 3", value)
+	}
+}
+
+func TestLiveConnectionsOfOneClientShareSequence(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	old := dialHello(t, srv, "client_id=shared")
+	old.expect("CONTEXT:k=1;seq=5", protocol.TypeAck)
+
+	// The old connection is still open when the client resumes elsewhere
+	resumed := dialHello(t, srv, "client_id=shared;resume=true")
+	resumed.expect("CONTEXT:k=2;seq=6", protocol.TypeAck)
+	resumed.expectError("CONTEXT:k=0;seq=4", protocol.ReasonStaleSequence)
+}
+
+func TestSequenceForgottenAfterTTL(t *testing.T) {
+	now := time.Unix(1000, 0)
+	tracker := newSequenceTracker(time.Minute)
+	tracker.now = func() time.Time { return now }
+
+	if !tracker.Accept("a", 10) {
+		t.Fatal("first sequence rejected")
+	}
+	if tracker.Accept("a", 9) {
+		t.Fatal("lower sequence accepted")
+	}
+
+	now = now.Add(time.Minute)
+	if !tracker.Accept("a", 1) {
+		t.Fatal("sequence rejected after the record expired")
+	}
+	if len(tracker.last) != 1 {
+		t.Fatalf("tracker holds %d clients, want 1", len(tracker.last))
+	}
+}
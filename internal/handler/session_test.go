@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+func TestReconnectTokenResumesClientID(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	first := dial(t, srv)
+	hello := first.expect("HELLO:version="+config.ProtocolVersion+";client_id=agent-7", protocol.TypeHello)
+	token := hello.Params["reconnect_token"]
+	if token == "" {
+		t.Fatalf("HELLO with client_id got no reconnect_token: %s", hello)
+	}
+	first.expect("CONTEXT:task=42", protocol.TypeAck)
+	first.conn.Close()
+	waitFor(t, func() bool { return srv.ConnectionCount() == 0 })
+
+	second := dial(t, srv)
+	resumed := second.expect("HELLO:version="+config.ProtocolVersion+";reconnect_token="+token, protocol.TypeHello)
+	if resumed.Params["client_id"] != "agent-7" {
+		t.Fatalf("token resumed client ID %q", resumed.Params["client_id"])
+	}
+	if next := resumed.Params["reconnect_token"]; next == "" || next == token {
+		t.Fatalf("resumed HELLO got reconnect_token %q, want a new one", next)
+	}
+
+	// Tokens are single use
+	dial(t, srv).expectError("HELLO:version="+config.ProtocolVersion+";reconnect_token="+token, protocol.ReasonNotFound)
+}
+
+func TestReconnectTokenParams(t *testing.T) {
+	srv := newTestServer(t, nil)
+
+	anonymous := dial(t, srv).expect("HELLO:version="+config.ProtocolVersion, protocol.TypeHello)
+	if _, ok := anonymous.Params["reconnect_token"]; ok {
+		t.Fatal("HELLO without a client_id got a reconnect token")
+	}
+
+	dial(t, srv).expectError("HELLO:version="+config.ProtocolVersion+";client_id=a;reconnect_token=x", protocol.ReasonInvalidParams)
+	dial(t, srv).expectError("HELLO:version="+config.ProtocolVersion+";reconnect_token=unknown", protocol.ReasonNotFound)
+}
+
+func TestReconnectTokenIsRedacted(t *testing.T) {
+	msg := protocol.NewMessage(protocol.TypeHello, map[string]string{"reconnect_token": "secret"})
+	if logged := redactToken(msg); logged == msg.String() {
+		t.Fatalf("reconnect token logged as %s", logged)
+	}
+}
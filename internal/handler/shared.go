@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+)
+
+// encodedUpdate is the UPDATE for one store version, encoded once and
+// shared by the connections it is pushed to
+type encodedUpdate struct {
+	version uint64
+	frame   []byte
+}
+
+// sendShared sends msg, already encoded as frame for sending to many
+// connections. An outbound transform may rewrite the message differently
+// per connection, so with one installed msg is encoded for c alone.
+func (c *Connection) sendShared(msg protocol.Message, frame []byte) error {
+	if _, outbound := c.server.transforms(); outbound != nil {
+		return c.Send(msg)
+	}
+	return c.sendFrame(msg.Type, frame)
+}
+
+// sendUpdate pushes the UPDATE for a context event. Every subscriber to a
+// change is sent identical bytes, so the most recent change's UPDATE is
+// kept encoded and reused by the other subscribers' forwarders rather than
+// formatted once per subscriber.
+func (c *Connection) sendUpdate(event state.ContextEvent) error {
+	if _, outbound := c.server.transforms(); outbound != nil {
+		return c.Send(updateMessage(event))
+	}
+	return c.sendFrame(protocol.TypeUpdate, c.server.updateFrame(event))
+}
+
+// updateFrame returns the encoded UPDATE for event, from the shared
+// encoding of the latest change if it is the same one
+func (s *Server) updateFrame(event state.ContextEvent) []byte {
+	// Replayed events are marked as such for one subscriber only
+	if event.Version == 0 || event.Replayed {
+		return updateMessage(event).Encode()
+	}
+
+	if cached := s.lastUpdate.Load(); cached != nil && cached.version == event.Version {
+		return cached.frame
+	}
+	frame := updateMessage(event).Encode()
+	s.lastUpdate.Store(&encodedUpdate{version: event.Version, frame: frame})
+	return frame
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+)
+
+func TestUpdateFrameEncodedOncePerVersion(t *testing.T) {
+	srv := newUnstartedServer(t, nil)
+	event := state.ContextEvent{ClientID: "w", Key: "k", Value: "1", Version: 7}
+
+	first := srv.updateFrame(event)
+	if again := srv.updateFrame(event); &again[0] != &first[0] {
+		t.Fatal("a second subscriber to version 7 was encoded a new frame")
+	}
+	if want := string(updateMessage(event).Encode()); string(first) != want {
+		t.Fatalf("frame = %q, want %q", first, want)
+	}
+
+	// A replay is marked for its one subscriber and not shared
+	replayed := event
+	replayed.Replayed = true
+	if frame := srv.updateFrame(replayed); string(frame) != string(updateMessage(replayed).Encode()) {
+		t.Fatalf("replayed frame = %q, want it marked replayed", frame)
+	}
+	if again := srv.updateFrame(event); &again[0] != &first[0] {
+		t.Fatal("a replay displaced the shared frame")
+	}
+
+	next := state.ContextEvent{ClientID: "w", Key: "k", OldValue: "1", Value: "2", Version: 8}
+	if frame := srv.updateFrame(next); string(frame) != string(updateMessage(next).Encode()) {
+		t.Fatalf("frame for version 8 = %q", frame)
+	}
+}
+
+func TestSubscribersReceiveSharedUpdate(t *testing.T) {
+	srv := newTestServer(t, nil)
+	writer := dialHello(t, srv, "client_id=writer")
+	var subscribers []*testConn
+	for i := 0; i < 3; i++ {
+		c := dialHello(t, srv, "")
+		c.expect("SUBSCRIBE:key=status", protocol.TypeAck)
+		subscribers = append(subscribers, c)
+	}
+
+	writer.expect("CONTEXT:status=busy", protocol.TypeAck)
+	for i, c := range subscribers {
+		push := c.recv()
+		if push.Type != protocol.TypeUpdate || push.Params["key"] != "status" || push.Params["new"] != "busy" || push.Params["client"] != "writer" {
+			t.Fatalf("subscriber %d: got %s, want the UPDATE for status", i, push)
+		}
+	}
+}
+
+// tagUpdates marks each UPDATE with the connection it is sent to
+func tagUpdates(c *Connection, msg protocol.Message) protocol.Message {
+	if msg.Type != protocol.TypeUpdate {
+		return msg
+	}
+	params := map[string]string{"to": c.id}
+	for key, value := range msg.Params {
+		params[key] = value
+	}
+	return protocol.NewMessage(msg.Type, params)
+}
+
+func TestOutboundTransformEncodesPerConnection(t *testing.T) {
+	srv := newUnstartedServer(t, nil)
+	srv.SetOutboundTransform(tagUpdates)
+	startTestServer(t, srv)
+
+	writer := dialHello(t, srv, "client_id=writer")
+	sessions := make(map[*testConn]string)
+	for i := 0; i < 2; i++ {
+		c := dial(t, srv)
+		hello := c.expect("HELLO:version="+config.ProtocolVersion, protocol.TypeHello)
+		c.expect("SUBSCRIBE:key=status", protocol.TypeAck)
+		sessions[c] = hello.Params["session"]
+	}
+
+	writer.expect("CONTEXT:status=busy", protocol.TypeAck)
+	for c, session := range sessions {
+		if push := c.recv(); push.Type != protocol.TypeUpdate || push.Params["to"] != session {
+			t.Fatalf("got %s, want an UPDATE tagged for %s", push, session)
+		}
+	}
+
+	// Broadcasts are transformed per connection too
+	srv.BroadcastMessage(protocol.NewMessage(protocol.TypeUpdate, map[string]string{"key": "all"}))
+	for c, session := range sessions {
+		if push := c.recv(); push.Params["key"] != "all" || push.Params["to"] != session {
+			t.Fatalf("got %s, want the broadcast tagged for %s", push, session)
+		}
+	}
+}
+
+func BenchmarkUpdateFrame1kSubscribers(b *testing.B) {
+	srv := newUnstartedServer(b, nil)
+	event := state.ContextEvent{ClientID: "w", Key: "status", Value: "busy"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		event.Version = uint64(i + 1)
+		for j := 0; j < 1000; j++ {
+			srv.updateFrame(event)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+)
+
+// slowServer starts a server with a SLOW message type whose handler signals
+// on started and then takes delay to ACK
+func slowServer(t *testing.T, delay time.Duration) (*Server, <-chan struct{}) {
+	t.Helper()
+
+	started := make(chan struct{}, 1)
+	srv := newUnstartedServer(t, nil)
+	srv.RegisterHandler("SLOW", func(c *Connection, msg protocol.Message) (protocol.Message, error) {
+		started <- struct{}{}
+		time.Sleep(delay)
+		return protocol.NewMessage(protocol.TypeAck, nil), nil
+	})
+	return startTestServer(t, srv), started
+}
+
+// shutdownAsync shuts srv down in the background, bounded by timeout
+func shutdownAsync(srv *Server, timeout time.Duration) <-chan error {
+	result := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		result <- srv.Shutdown(ctx)
+	}()
+	return result
+}
+
+func TestShutdownDeliversInFlightReply(t *testing.T) {
+	srv, started := slowServer(t, 200*time.Millisecond)
+	c := dialHello(t, srv, "")
+
+	c.send("SLOW:id=1")
+	<-started
+	result := shutdownAsync(srv, testTimeout)
+
+	// GOODBYE goes out at once; the ACK follows when the handler finishes
+	var gotGoodbye, gotAck bool
+	for !gotGoodbye || !gotAck {
+		msg := c.recv()
+		switch {
+		case msg.Type == protocol.TypeGoodbye && msg.Params["reason"] == "shutdown":
+			gotGoodbye = true
+		case msg.Type == protocol.TypeAck && msg.Params["id"] == "1":
+			gotAck = true
+		default:
+			t.Fatalf("unexpected %s", msg)
+		}
+	}
+	c.expectClosed()
+
+	if err := <-result; err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
+
+func TestShutdownRefusesNewConnections(t *testing.T) {
+	srv := newTestServer(t, nil)
+	addr := srv.Addr()
+
+	if err := <-shutdownAsync(srv, testTimeout); err != nil {
+		t.Fatal(err)
+	}
+	if conn, err := net.DialTimeout(addr.Network(), addr.String(), testTimeout); err == nil {
+		conn.Close()
+		t.Fatal("connection accepted after shutdown")
+	}
+}
+
+func TestSlowClientFinishesWithinDrainWindow(t *testing.T) {
+	srv, started := slowServer(t, 500*time.Millisecond)
+	slow := dialHello(t, srv, "")
+	idle := dialHello(t, srv, "")
+
+	slow.send("SLOW:id=7")
+	<-started
+	result := shutdownAsync(srv, 2*time.Second)
+
+	// The idle connection is let go at once
+	if msg := idle.recv(); msg.Type != protocol.TypeGoodbye {
+		t.Fatalf("idle client got %s, want GOODBYE", msg)
+	}
+	idle.expectClosed()
+
+	for {
+		msg := slow.recv()
+		if msg.Type == protocol.TypeAck && msg.Params["id"] == "7" {
+			break
+		}
+		if msg.Type != protocol.TypeGoodbye {
+			t.Fatalf("unexpected %s", msg)
+		}
+	}
+	slow.expectClosed()
+
+	if err := <-result; err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+}
+
+func TestDrainWindowExpiryForceCloses(t *testing.T) {
+	srv, started := slowServer(t, time.Second)
+	c := dialHello(t, srv, "")
+
+	c.send("SLOW:")
+	<-started
+	err := <-shutdownAsync(srv, 100*time.Millisecond)
+	if err == nil {
+		t.Fatal("Shutdown past its deadline reported no error")
+	}
+	if !strings.Contains(err.Error(), "force-closed 1 connection") {
+		t.Fatalf("Shutdown: %v, want the force-closed count", err)
+	}
+	c.expectClosed()
+}
+
+func TestClosedConnectionsNotCountedAsKilled(t *testing.T) {
+	srv := newUnstartedServer(t, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Both are ready at once: the connection's close must win every time
+	conns := make([]*Connection, 50)
+	for i := range conns {
+		conns[i] = &Connection{id: strconv.Itoa(i), closeChan: make(chan struct{})}
+		close(conns[i].closeChan)
+	}
+	if killed := srv.awaitConnections(ctx, conns); killed != 0 {
+		t.Fatalf("%d closed connections counted as force-closed", killed)
+	}
+}
+
+// failingCloseListener closes the listener it wraps but reports an error
+type failingCloseListener struct {
+	net.Listener
+}
+
+func (l failingCloseListener) Close() error {
+	l.Listener.Close()
+	return errors.New("close failed")
+}
+
+func TestShutdownContinuesAfterListenerCloseError(t *testing.T) {
+	dataFile := filepath.Join(t.TempDir(), "store.json")
+	srv := newTestServer(t, func(cfg *config.Config) { cfg.DataFile = dataFile })
+	c := dialHello(t, srv, "client_id=kept")
+	c.expect("CONTEXT:k=v", protocol.TypeAck)
+
+	srv.mu.Lock()
+	srv.listener = failingCloseListener{srv.listener}
+	srv.mu.Unlock()
+
+	err := <-shutdownAsync(srv, testTimeout)
+	if err == nil || !strings.Contains(err.Error(), "close failed") {
+		t.Fatalf("Shutdown = %v, want the listener error reported", err)
+	}
+	if msg := c.recv(); msg.Type != protocol.TypeGoodbye {
+		t.Fatalf("got %s, want GOODBYE", msg)
+	}
+	c.expectClosed()
+
+	saved := state.NewContextStore()
+	if err := saved.Load(dataFile); err != nil {
+		t.Fatalf("store not saved: %v", err)
+	}
+	if value, _ := saved.Get("kept", "k"); value != "v" {
+		t.Fatalf("saved k = %q, want v", value)
+	}
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"errors"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/Artimus100/mcp-server-go/internal/errs"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+	"github.com/Artimus100/mcp-server-go/internal/utils"
+)
+
+// serverCounters are the running totals reported by GetStats
+type serverCounters struct {
+	accepted    atomic.Uint64
+	messages    atomic.Uint64
+	parseErrors atomic.Uint64
+
+	// Messages dropped or rejected, by reason
+	rateLimited  atomic.Uint64
+	mutations    atomic.Uint64
+	tooLarge     atomic.Uint64
+	queueFull    atomic.Uint64
+	unauthorized atomic.Uint64
+}
+
+// Reasons a message is dropped, as reported in ServerStats.DroppedMessages
+// and the reason label of mcp_messages_dropped_total
+const (
+	DropRateLimited  = "rate_limited"
+	DropMutations    = "mutation_rate_limited"
+	DropTooLarge     = "too_large"
+	DropQueueFull    = "queue_full"
+	DropUnauthorized = "unauthorized"
+)
+
+// recordDrop counts a message dropped or rejected for reason
+func (s *Server) recordDrop(reason string) {
+	switch reason {
+	case DropRateLimited:
+		s.counters.rateLimited.Add(1)
+	case DropMutations:
+		s.counters.mutations.Add(1)
+	case DropTooLarge:
+		s.counters.tooLarge.Add(1)
+	case DropQueueFull:
+		s.counters.queueFull.Add(1)
+	case DropUnauthorized:
+		s.counters.unauthorized.Add(1)
+	}
+	s.metrics.Inc("mcp_messages_dropped_total", "reason", reason)
+}
+
+// dropReason returns the drop reason for a message rejected with err, or ""
+// if err does not count as a drop
+func dropReason(err error) string {
+	switch {
+	case errors.Is(err, errs.ErrRateLimited):
+		return DropRateLimited
+	case errors.Is(err, errs.ErrMutationLimited):
+		return DropMutations
+	case errors.Is(err, errs.ErrMessageTooLarge):
+		return DropTooLarge
+	case errors.Is(err, errs.ErrUnauthorized), errors.Is(err, errs.ErrUnauthenticated):
+		return DropUnauthorized
+	default:
+		return ""
+	}
+}
+
+// ServerStats is a point-in-time view of server activity. Connections to the
+// admin console are not counted, but messages sent over them are.
+type ServerStats struct {
+	// ConnectionsAccepted counts client connections accepted since start,
+	// including those turned away at the connection limit
+	ConnectionsAccepted uint64
+	// ActiveConnections is the number of open client connections
+	ActiveConnections int
+	// MessagesProcessed counts messages received, parsed or not
+	MessagesProcessed uint64
+	// ParseErrors counts received lines that were not valid messages
+	ParseErrors uint64
+	// DroppedMessages counts messages rejected or discarded, keyed by
+	// reason: rate_limited, mutation_rate_limited, too_large, queue_full
+	// (subscription updates a slow subscriber missed) and unauthorized
+	DroppedMessages map[string]uint64
+	// Deprecations counts uses of deprecated constructs, keyed by tag such
+	// as lowercase_type; tags never used are absent
+	Deprecations map[string]uint64
+	// ClockOutliers counts TIME requests from clients whose clocks are
+	// beyond config.MaxClockSkew
+	ClockOutliers uint64
+}
+
+// GetStats returns the current server statistics
+func (s *Server) GetStats() ServerStats {
+	return ServerStats{
+		ConnectionsAccepted: s.counters.accepted.Load(),
+		ActiveConnections:   s.ConnectionCount(),
+		MessagesProcessed:   s.counters.messages.Load(),
+		ParseErrors:         s.counters.parseErrors.Load(),
+		DroppedMessages: map[string]uint64{
+			DropRateLimited:  s.counters.rateLimited.Load(),
+			DropMutations:    s.counters.mutations.Load(),
+			DropTooLarge:     s.counters.tooLarge.Load(),
+			DropQueueFull:    s.counters.queueFull.Load(),
+			DropUnauthorized: s.counters.unauthorized.Load(),
+		},
+		Deprecations:  s.deprecationCounts(),
+		ClockOutliers: s.ClockOutliers(),
+	}
+}
+
+// SetMetrics directs instrumentation to m: connections accepted, messages
+// received and sent by type, parse errors, dropped messages by reason,
+// uses of deprecated constructs by tag and handler latency by type. It
+// must be called before Start. With MetricsAddr set, Start serves m, which
+// must then be a *utils.MetricsRegistry, or a registry of its own if
+// SetMetrics was not called.
+func (s *Server) SetMetrics(m utils.Metrics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics = m
+}
+
+// metricType returns the type label recorded for a message. Types without a
+// handler are recorded as "other" so clients cannot create unbounded series.
+func (s *Server) metricType(msgType string) string {
+	if msgType == protocol.TypePong {
+		return msgType
+	}
+	if _, exists := s.handlers.lookup(msgType); exists {
+		return msgType
+	}
+	return "other"
+}
+
+// handleStats replies with the server statistics, including the progress
+// of the latest drain
+func (c *Connection) handleStats(msg protocol.Message) (protocol.Message, error) {
+	stats := c.server.GetStats()
+
+	reply := protocol.NewMessage(protocol.TypeResult, map[string]string{
+		"connections_accepted": strconv.FormatUint(stats.ConnectionsAccepted, 10),
+		"connections_active":   strconv.Itoa(stats.ActiveConnections),
+		"messages_processed":   strconv.FormatUint(stats.MessagesProcessed, 10),
+		"parse_errors":         strconv.FormatUint(stats.ParseErrors, 10),
+		"clock_outliers":       strconv.FormatUint(stats.ClockOutliers, 10),
+	})
+	for reason, count := range stats.DroppedMessages {
+		reply.Params["dropped."+reason] = strconv.FormatUint(count, 10)
+	}
+	for tag, count := range stats.Deprecations {
+		reply.Params["deprecated."+tag] = strconv.FormatUint(count, 10)
+	}
+	drain := c.server.DrainProgress()
+	reply.Params["drain.matched"] = strconv.Itoa(drain.Matched)
+	reply.Params["drain.closed"] = strconv.Itoa(drain.Closed)
+	reply.Params["drain.remaining"] = strconv.Itoa(drain.Remaining)
+	// Left out when they cannot be read, as under fd pressure
+	if open, limit, err := FDUsage(); err == nil {
+		reply.Params["fds_open"] = strconv.Itoa(open)
+		reply.Params["fds_limit"] = strconv.Itoa(limit)
+	}
+	return reply, nil
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+func TestStatsCountsMessages(t *testing.T) {
+	srv := newTestServer(t, nil)
+	a := dialHello(t, srv, "")
+	b := dialHello(t, srv, "")
+
+	a.expect("CONTEXT:k=v", protocol.TypeAck)
+	a.expect("PING:", protocol.TypePong)
+	b.expect("GET:key=k", protocol.TypeResult)
+	b.expectError("not a message", protocol.ReasonParseFailed)
+
+	// A closed connection stays counted as accepted
+	gone := dialHello(t, srv, "")
+	gone.conn.Close()
+	waitFor(t, func() bool { return srv.ConnectionCount() == 2 })
+
+	// Counted so far: three HELLOs, four messages and the STATS itself
+	stats := a.expect("STATS:", protocol.TypeResult)
+	want := map[string]string{
+		"connections_accepted": "3",
+		"connections_active":   "2",
+		"messages_processed":   "8",
+		"parse_errors":         "1",
+	}
+	for param, value := range want {
+		if stats.Params[param] != value {
+			t.Errorf("%s = %q, want %s", param, stats.Params[param], value)
+		}
+	}
+
+	got := srv.GetStats()
+	if got.ConnectionsAccepted != 3 || got.ActiveConnections != 2 || got.MessagesProcessed != 8 || got.ParseErrors != 1 {
+		t.Errorf("GetStats() = %+v", got)
+	}
+}
+
+func TestStatsCountsDropsByReason(t *testing.T) {
+	srv := newUnstartedServer(t, func(cfg *config.Config) {
+		serveMetrics(cfg)
+		cfg.MaxMessageSize = 1024
+		cfg.MutationLimit = 1
+		cfg.MutationBurst = 1
+	})
+	// Stopped, so the mutation budget never refills
+	srv.SetClock(newTestClock().Now)
+	startTestServer(t, srv)
+	c := dialHello(t, srv, "")
+
+	c.expectError(protocol.TypeClearAll+":", protocol.ReasonUnauthorized)
+	c.expectError(protocol.TypeClearAll+":", protocol.ReasonUnauthorized)
+	c.expect("CONTEXT:a=1", protocol.TypeAck)
+	c.expectError("CONTEXT:b=2", protocol.ReasonMutationLimited)
+
+	big := dialHello(t, srv, "")
+	go func() {
+		big.conn.SetWriteDeadline(time.Now().Add(testTimeout))
+		big.conn.Write([]byte("CONTEXT:k=" + strings.Repeat("x", 2000) + "\n"))
+	}()
+	if reply := big.recv(); reply.Params["reason"] != protocol.ReasonMessageTooLarge {
+		t.Fatalf("got %s, want message_too_large", reply)
+	}
+	big.expectClosed()
+
+	want := map[string]uint64{
+		DropUnauthorized: 2,
+		DropMutations:    1,
+		DropTooLarge:     1,
+		DropRateLimited:  0,
+		DropQueueFull:    0,
+	}
+	dropped := srv.GetStats().DroppedMessages
+	stats := c.expect("STATS:", protocol.TypeResult)
+	samples := scrape(t, srv)
+	for reason, count := range want {
+		if dropped[reason] != count {
+			t.Errorf("DroppedMessages[%s] = %d, want %d", reason, dropped[reason], count)
+		}
+		if got := stats.Params["dropped."+reason]; got != strconv.FormatUint(count, 10) {
+			t.Errorf("STATS dropped.%s = %q, want %d", reason, got, count)
+		}
+		if count == 0 {
+			continue
+		}
+		if got := samples[`mcp_messages_dropped_total{reason="`+reason+`"}`]; got != float64(count) {
+			t.Errorf("mcp_messages_dropped_total for %s = %v, want %d", reason, got, count)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+func TestSubscribeSinceVersionBackfillsChangesAndRemovals(t *testing.T) {
+	srv := newTestServer(t, nil)
+	writer := dialHello(t, srv, "")
+	writer.expect("CONTEXT:k1=a", protocol.TypeAck)
+	writer.expect("CONTEXT:k2=b", protocol.TypeAck)
+
+	first := dialHello(t, srv, "")
+	first.send("SUBSCRIBE:prefix=k;since_version=0")
+	first.recv()
+	first.recv()
+	seen := first.recv()
+	if seen.Type != protocol.TypeAck {
+		t.Fatalf("got %s after the backfill, want ACK", seen)
+	}
+
+	writer.expect("CONTEXT:k2=b2", protocol.TypeAck)
+	writer.expect("REMOVE:keys=k1", protocol.TypeAck)
+
+	again := dialHello(t, srv, "")
+	again.send("SUBSCRIBE:prefix=k;since_version=" + seen.Params["version"])
+
+	changed := again.recv()
+	if changed.Type != protocol.TypeUpdate || changed.Params["key"] != "k2" || changed.Params["new"] != "b2" || changed.Params["backfill"] != "true" {
+		t.Fatalf("first backfill %s, want k2=b2", changed)
+	}
+	removed := again.recv()
+	if removed.Type != protocol.TypeUpdate || removed.Params["key"] != "k1" || removed.Params["deleted"] != "true" {
+		t.Fatalf("second backfill %s, want removal of k1", removed)
+	}
+	ack := again.recv()
+	if ack.Type != protocol.TypeAck || ack.Params["gap"] != "false" {
+		t.Fatalf("got %s, want ACK without gap", ack)
+	}
+}
+
+func TestSubscribePushesUpdatesUntilUnsubscribed(t *testing.T) {
+	srv := newTestServer(t, nil)
+	writer := dialHello(t, srv, "client_id=writer")
+	watcher := dialHello(t, srv, "client_id=watcher")
+
+	watcher.expect("SUBSCRIBE:prefix=region", protocol.TypeAck)
+	writer.expect("CONTEXT:region=eu", protocol.TypeAck)
+	writer.expect("CONTEXT:region=us;zone=b", protocol.TypeAck)
+
+	for _, want := range []map[string]string{
+		{"client": "writer", "key": "region", "old": "", "new": "eu"},
+		{"client": "writer", "key": "region", "old": "eu", "new": "us"},
+	} {
+		push := watcher.recv()
+		if push.Type != protocol.TypeUpdate {
+			t.Fatalf("got %s, want UPDATE", push)
+		}
+		for param, value := range want {
+			if push.Params[param] != value {
+				t.Fatalf("got %s, want %s=%q", push, param, value)
+			}
+		}
+	}
+
+	watcher.expect("UNSUBSCRIBE:prefix=region", protocol.TypeAck)
+	writer.expect("CONTEXT:region=ap", protocol.TypeAck)
+
+	// Anything pushed after the unsubscribe would arrive before the PONG
+	watcher.expect("PING:", protocol.TypePong)
+}
+
+func TestSubscribeToOneClient(t *testing.T) {
+	srv := newTestServer(t, nil)
+	a := dialHello(t, srv, "client_id=a")
+	b := dialHello(t, srv, "client_id=b")
+	watcher := dialHello(t, srv, "")
+
+	watcher.expect("SUBSCRIBE:key=status;client=b", protocol.TypeAck)
+	a.expect("CONTEXT:status=busy", protocol.TypeAck)
+	b.expect("CONTEXT:status=idle", protocol.TypeAck)
+
+	if push := watcher.recv(); push.Params["client"] != "b" || push.Params["new"] != "idle" {
+		t.Fatalf("got %s, want only b's change", push)
+	}
+	watcher.expect("PING:", protocol.TypePong)
+}
+
+func TestUnsubscribeUnknownSubscription(t *testing.T) {
+	c := dialHello(t, newTestServer(t, nil), "")
+
+	c.expectError("UNSUBSCRIBE:key=never", protocol.ReasonNotFound)
+	c.expectError("SUBSCRIBE:key=a;prefix=b", protocol.ReasonInvalidParams)
+}
+
+func TestClosingConnectionDropsItsSubscriptions(t *testing.T) {
+	srv := newTestServer(t, nil)
+	baseline := runtime.NumGoroutine()
+
+	writer := dialHello(t, srv, "client_id=writer")
+	var watchers []*testConn
+	for i := 0; i < 3; i++ {
+		c := dialHello(t, srv, "")
+		c.expect("SUBSCRIBE:key=status", protocol.TypeAck)
+		c.expect("SUBSCRIBE:prefix=region", protocol.TypeAck)
+		c.expect("SUBSCRIBE:key=load;client=writer", protocol.TypeAck)
+		c.expect("WATCH_ALL:", protocol.TypeAck)
+		watchers = append(watchers, c)
+	}
+	if n := srv.store.SubscriptionCount(); n != 9 {
+		t.Fatalf("SubscriptionCount() = %d, want 9", n)
+	}
+
+	// Leave without unsubscribing, with updates still on their way
+	writer.expect("CONTEXT:status=busy;region=eu;load=3", protocol.TypeAck)
+	for _, c := range watchers {
+		c.conn.Close()
+	}
+	waitFor(t, func() bool { return srv.store.SubscriptionCount() == 0 })
+
+	writer.conn.Close()
+	waitFor(t, func() bool { return srv.ConnectionCount() == 0 && runtime.NumGoroutine() <= baseline })
+}
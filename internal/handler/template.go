@@ -0,0 +1,45 @@
+package handler
+
+// SetContextTemplate sets the default values seeded into the context of
+// each client when its connection first uses it, for keys the client has
+// not set. It may be called while the server is running, as on a reload;
+// clients already seeded keep the values they were given. A nil or empty
+// template disables seeding.
+func (s *Server) SetContextTemplate(values map[string]string) {
+	template := make(map[string]string, len(values))
+	for key, value := range values {
+		template[key] = value
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.template = template
+}
+
+// contextTemplate returns the configured context template, which callers
+// must not modify
+func (s *Server) contextTemplate() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.template
+}
+
+// seedTemplate applies the context template to the connection's client
+// once, when the client ID it stores under is settled: after HELLO, or at
+// the first other message from a client that skips it. While the store is
+// loading, seeding waits for a later message so the snapshot cannot
+// replace it.
+func (c *Connection) seedTemplate() {
+	if c.seeded || c.admin || c.server.Loading() {
+		return
+	}
+	c.seeded = true
+
+	template := c.server.contextTemplate()
+	if len(template) == 0 {
+		return
+	}
+	if n := c.store.SetDefaults(c.clientID, template); n > 0 {
+		c.logger.Info("Seeded %d context values from the template", n)
+	}
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+func TestTemplateSeedsUnsetKeys(t *testing.T) {
+	srv := newTestServer(t, nil)
+	srv.SetContextTemplate(map[string]string{"region": "eu", "tier": "free"})
+
+	c := dialHello(t, srv, "client_id=agent")
+	reply := c.expect("GET:", protocol.TypeResult)
+	if reply.Params["region"] != "eu" || reply.Params["tier"] != "free" {
+		t.Fatalf("GET = %s, want the template values", reply)
+	}
+
+	// A value the client set beats the template, including when it
+	// reconnects and the template is applied again
+	c.expect("CONTEXT:tier=paid", protocol.TypeAck)
+	c.expect("REMOVE:key=region", protocol.TypeAck)
+	c.conn.Close()
+	waitFor(t, func() bool { return srv.ConnectionCount() == 0 })
+
+	reply = dialHello(t, srv, "client_id=agent").expect("GET:", protocol.TypeResult)
+	if reply.Params["tier"] != "paid" || reply.Params["region"] != "eu" {
+		t.Fatalf("GET after reconnecting = %s, want tier kept and region reseeded", reply)
+	}
+}
+
+func TestTemplateReloadAffectsOnlyNewClients(t *testing.T) {
+	srv := newTestServer(t, nil)
+	srv.SetContextTemplate(map[string]string{"region": "eu"})
+	before := dialHello(t, srv, "client_id=before")
+
+	srv.SetContextTemplate(map[string]string{"region": "us", "tier": "free"})
+	after := dialHello(t, srv, "client_id=after")
+
+	if reply := before.expect("GET:", protocol.TypeResult); reply.Params["region"] != "eu" || reply.Params["tier"] != "" {
+		t.Fatalf("existing client's context = %s, want the old template only", reply)
+	}
+	if reply := after.expect("GET:", protocol.TypeResult); reply.Params["region"] != "us" || reply.Params["tier"] != "free" {
+		t.Fatalf("new client's context = %s, want the reloaded template", reply)
+	}
+
+	// An empty template stops seeding
+	srv.SetContextTemplate(nil)
+	if reply := dialHello(t, srv, "client_id=later").expect("GET:", protocol.TypeResult); len(reply.Params) != 0 {
+		t.Fatalf("context with no template = %s", reply)
+	}
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+	"github.com/Artimus100/mcp-server-go/internal/utils"
+)
+
+func TestHandlerTimeoutPerType(t *testing.T) {
+	cfg := config.Default()
+	cfg.Port = 0
+	var log syncBuffer
+	srv := NewServer(cfg, state.NewContextStore(), utils.NewLoggerTo(&log, "test"))
+
+	// BATCH stands in for a slow message type; there is no built-in one
+	srv.RegisterHandler("BATCH", func(c *Connection, msg protocol.Message) (protocol.Message, error) {
+		time.Sleep(20 * time.Millisecond)
+		return protocol.NewMessage(protocol.TypeAck, nil), nil
+	})
+	srv.SetHandlerTimeout(protocol.TypePing, time.Nanosecond)
+	srv.SetHandlerTimeout("BATCH", time.Minute)
+	startTestServer(t, srv)
+
+	c := dialHello(t, srv, "")
+	c.expect("BATCH:", protocol.TypeAck)
+	// An id keeps the PING off the fast path, which is not timed
+	c.expect("PING:id=1", protocol.TypePong)
+
+	waitFor(t, func() bool { return strings.Contains(log.String(), "Handler for PING took") })
+	if strings.Contains(log.String(), "Handler for BATCH took") {
+		t.Fatal("BATCH under its type timeout was flagged")
+	}
+}
+
+func TestHandlerTimeoutFallsBackToDefault(t *testing.T) {
+	srv := newUnstartedServer(t, nil)
+	srv.SetDefaultHandlerTimeout(3 * time.Second)
+	srv.SetHandlerTimeout(protocol.TypePing, time.Millisecond)
+
+	if got := srv.handlerTimeout(protocol.TypePing); got != time.Millisecond {
+		t.Errorf("PING timeout %v, want its override", got)
+	}
+	if got := srv.handlerTimeout(protocol.TypeGet); got != 3*time.Second {
+		t.Errorf("GET timeout %v, want the default", got)
+	}
+}
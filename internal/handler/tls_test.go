@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// selfSignedCert writes a certificate for 127.0.0.1 and its key as PEM files
+// in a temporary directory, returning their paths and a pool trusting it
+func selfSignedCert(t *testing.T) (certFile, keyFile string, pool *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mcp-server test"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
+
+func TestTLSFromConfig(t *testing.T) {
+	certFile, keyFile, pool := selfSignedCert(t)
+	srv := newTestServer(t, func(cfg *config.Config) {
+		cfg.TLSCert = certFile
+		cfg.TLSKey = keyFile
+	})
+
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(srv.Addr().(*net.TCPAddr).Port))
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: testTimeout}, "tcp", addr, &tls.Config{RootCAs: pool})
+	if err != nil {
+		t.Fatalf("TLS dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	c := &testConn{t: t, conn: conn, reader: bufio.NewReader(conn)}
+	c.expect("HELLO:version="+config.ProtocolVersion, protocol.TypeHello)
+	c.expect("CONTEXT:k=v", protocol.TypeAck)
+
+	// The connection is identified by the client's address, not the TLS
+	// wrapper's
+	var found bool
+	for _, info := range srv.ActiveConnections() {
+		found = found || info.RemoteAddr == conn.LocalAddr().String()
+	}
+	if !found {
+		t.Fatalf("no connection from %s in %+v", conn.LocalAddr(), srv.ActiveConnections())
+	}
+
+	// A plaintext client gets no protocol reply
+	plain := dial(t, srv)
+	plain.send("PING:")
+	if msg, err := plain.read(testTimeout); err == nil {
+		t.Fatalf("plaintext PING answered with %s", msg)
+	}
+}
+
+func TestTLSRejectsMissingCertificate(t *testing.T) {
+	srv := newUnstartedServer(t, func(cfg *config.Config) {
+		cfg.TLSCert = filepath.Join(t.TempDir(), "missing.pem")
+		cfg.TLSKey = cfg.TLSCert
+	})
+	if err := srv.Start(); err == nil {
+		srv.Shutdown(context.Background())
+		t.Fatal("Start served without the certificate")
+	}
+}
@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// TransformFunc rewrites a message on its way into or out of a connection,
+// for example to namespace context keys per tenant
+type TransformFunc func(c *Connection, msg protocol.Message) protocol.Message
+
+// SetInboundTransform installs a hook applied to every message received from
+// a client before it is dispatched. Passing nil removes the hook.
+func (s *Server) SetInboundTransform(fn TransformFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inbound = fn
+}
+
+// SetOutboundTransform installs a hook applied to every message before it is
+// sent to a client. Passing nil removes the hook.
+func (s *Server) SetOutboundTransform(fn TransformFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.outbound = fn
+}
+
+// transforms returns the installed inbound and outbound hooks
+func (s *Server) transforms() (inbound, outbound TransformFunc) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.inbound, s.outbound
+}
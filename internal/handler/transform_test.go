@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+const tenantPrefix = "tenant1."
+
+// prefixKeys namespaces the keys a client writes and the keys it asks for
+func prefixKeys(c *Connection, msg protocol.Message) protocol.Message {
+	params := make(map[string]string, len(msg.Params))
+	for key, value := range msg.Params {
+		switch msg.Type {
+		case protocol.TypeContext:
+			key = tenantPrefix + key
+		case protocol.TypeGet:
+			value = tenantPrefix + value
+		}
+		params[key] = value
+	}
+	return protocol.NewMessage(msg.Type, params)
+}
+
+// stripKeys removes the namespace from keys in results
+func stripKeys(c *Connection, msg protocol.Message) protocol.Message {
+	if msg.Type != protocol.TypeResult {
+		return msg
+	}
+	params := make(map[string]string, len(msg.Params))
+	for key, value := range msg.Params {
+		params[strings.TrimPrefix(key, tenantPrefix)] = value
+	}
+	return protocol.NewMessage(msg.Type, params)
+}
+
+func TestTransformsNamespaceKeys(t *testing.T) {
+	srv := newUnstartedServer(t, nil)
+	srv.SetInboundTransform(prefixKeys)
+	srv.SetOutboundTransform(stripKeys)
+	startTestServer(t, srv)
+
+	c := dialHello(t, srv, "client_id=t1")
+	c.expect("CONTEXT:region=eu", protocol.TypeAck)
+
+	if value, ok := srv.store.Get("t1", "tenant1.region"); !ok || value != "eu" {
+		t.Fatalf("stored under the prefixed key: %q, %v", value, ok)
+	}
+	if _, ok := srv.store.Get("t1", "region"); ok {
+		t.Fatal("stored under the unprefixed key")
+	}
+
+	got := c.expect("GET:key=region", protocol.TypeResult)
+	if got.Params["region"] != "eu" || len(got.Params) != 1 {
+		t.Fatalf("GET = %s, want region=eu without the prefix", got)
+	}
+	// Transforms keep bare PINGs off the fast path, but they still work
+	c.expect("PING:", protocol.TypePong)
+
+	srv.SetOutboundTransform(nil)
+	if got := c.expect("GET:key=region", protocol.TypeResult); got.Params["tenant1.region"] != "eu" {
+		t.Fatalf("GET without the outbound hook = %s", got)
+	}
+}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+func TestUnknownTypePolicies(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   string // reply type, or "" for none
+	}{
+		{"ignore", ""},
+		{"error", protocol.TypeError},
+		{"ack", protocol.TypeAck},
+	}
+
+	for _, tt := range tests {
+		srv := newTestServer(t, func(cfg *config.Config) { cfg.UnknownTypePolicy = tt.policy })
+		c := dialHello(t, srv, "")
+
+		// Whatever the unknown type gets, the PING behind it is answered next
+		c.send("FROB:x=1;id=5")
+		reply := c.request("PING:id=6")
+		if tt.want != "" {
+			if reply.Type != tt.want || reply.Params["id"] != "5" {
+				t.Errorf("%s: got %s, want %s for id 5", tt.policy, reply, tt.want)
+				continue
+			}
+			if tt.want == protocol.TypeError && reply.Params["reason"] != protocol.ReasonUnknownType {
+				t.Errorf("%s: got %s, want reason %s", tt.policy, reply, protocol.ReasonUnknownType)
+			}
+			reply = c.recv()
+		}
+		if reply.Type != protocol.TypePong || reply.Params["id"] != "6" {
+			t.Errorf("%s: got %s, want the PONG for id 6", tt.policy, reply)
+		}
+
+		// Known types are unaffected
+		c.expect("CONTEXT:k=v", protocol.TypeAck)
+		if got := c.expect("GET:key=k", protocol.TypeResult); got.Params["k"] != "v" {
+			t.Errorf("%s: GET = %s", tt.policy, got)
+		}
+	}
+}
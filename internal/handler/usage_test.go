@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+	"github.com/Artimus100/mcp-server-go/internal/utils"
+)
+
+// expectUsage sends USAGE and fails the test unless it reports keys and
+// bytes
+func expectUsage(t *testing.T, c *testConn, keys, bytes int) protocol.Message {
+	t.Helper()
+
+	reply := c.expect("USAGE:", protocol.TypeResult)
+	if reply.Params["keys"] != strconv.Itoa(keys) || reply.Params["bytes"] != strconv.Itoa(bytes) {
+		t.Fatalf("USAGE = %s, want keys=%d bytes=%d", reply, keys, bytes)
+	}
+	return reply
+}
+
+func TestUsageTracksSetsDeletesAndExpiry(t *testing.T) {
+	clock := newTestClock()
+	cfg := config.Default()
+	cfg.Port = 0
+	store := state.NewContextStore(state.WithClock(clock.Now), state.WithCompression(64))
+	srv := NewServer(cfg, store, utils.NewLoggerTo(io.Discard, "test"))
+	srv.SetClock(clock.Now)
+	startTestServer(t, srv)
+
+	c := dialHello(t, srv, "")
+	other := dialHello(t, srv, "")
+	expectUsage(t, c, 0, 0)
+
+	c.expect("CONTEXT:a=123;b=xy", protocol.TypeAck)
+	expectUsage(t, c, 2, 5)
+
+	// Overwriting replaces the old size rather than adding to it
+	c.expect("CONTEXT:a=1", protocol.TypeAck)
+	expectUsage(t, c, 2, 3)
+
+	// Compressed values count at their uncompressed size
+	big := strings.Repeat("z", 1000)
+	c.expect("CONTEXT:big="+big, protocol.TypeAck)
+	expectUsage(t, c, 3, 1003)
+
+	c.expect("REMOVE:key=big", protocol.TypeAck)
+	expectUsage(t, c, 2, 3)
+
+	// Expired keys stop counting before the sweeper removes them
+	c.expect("CONTEXT:temp=abcd;_ttl=10s", protocol.TypeAck)
+	expectUsage(t, c, 3, 7)
+	clock.Advance(11 * time.Second)
+	expectUsage(t, c, 2, 3)
+
+	// Another client's keys are its own
+	other.expect("CONTEXT:a=elsewhere", protocol.TypeAck)
+	expectUsage(t, c, 2, 3)
+	expectUsage(t, other, 1, 9)
+}
+
+func TestUsageReportsMutationLimit(t *testing.T) {
+	clock := newTestClock()
+	srv := newUnstartedServer(t, func(cfg *config.Config) {
+		cfg.MutationLimit = 2
+		cfg.MutationBurst = 5
+	})
+	srv.SetClock(clock.Now)
+	startTestServer(t, srv)
+	c := dialHello(t, srv, "")
+
+	c.expect("CONTEXT:a=1;b=2;c=3", protocol.TypeAck)
+	reply := expectUsage(t, c, 3, 3)
+	if reply.Params["mutation_limit"] != "2" || reply.Params["mutation_burst"] != "5" || reply.Params["mutations_available"] != "2" {
+		t.Fatalf("USAGE = %s, want limit 2, burst 5 and 2 writes left", reply)
+	}
+
+	clock.Advance(time.Second)
+	if reply := c.expect("USAGE:", protocol.TypeResult); reply.Params["mutations_available"] != "4" {
+		t.Fatalf("USAGE = %s a second later, want 4 writes left", reply)
+	}
+}
+
+func TestUsageOmitsMutationsWhenUnlimited(t *testing.T) {
+	c := dialHello(t, newTestServer(t, nil), "")
+	if reply := expectUsage(t, c, 0, 0); reply.Params["mutation_limit"] != "" {
+		t.Fatalf("USAGE = %s, want no mutation limit", reply)
+	}
+}
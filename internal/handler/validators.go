@@ -0,0 +1,335 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Validator checks context values written under a key spec
+type Validator interface {
+	// Validate returns an error describing why value is not acceptable
+	Validate(value string) error
+	// Describe returns the rule in the form accepted by ParseValidator
+	Describe() string
+}
+
+// ParseValidator builds a validator from its textual rule, as used in
+// configuration. Supported rules are:
+//
+//	integer               a base-10 integer
+//	float                 any finite floating point number
+//	float:MIN:MAX         a floating point number in [MIN, MAX]
+//	enum:A,B,C            one of the listed values
+//	rfc3339               an RFC 3339 timestamp
+//	maxlen:N              at most N characters
+//	json                  any well-formed JSON document
+//	jsonschema:SCHEMA     a JSON document matching a minimal JSON schema
+func ParseValidator(rule string) (Validator, error) {
+	name, arg, hasArg := strings.Cut(rule, ":")
+
+	switch name {
+	case "integer":
+		return IntegerValidator(), nil
+
+	case "float":
+		if !hasArg {
+			return FloatRangeValidator(math.Inf(-1), math.Inf(1)), nil
+		}
+		bounds := strings.Split(arg, ":")
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("float rule needs MIN:MAX bounds: %s", rule)
+		}
+		min, err := strconv.ParseFloat(bounds[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float minimum %q: %v", bounds[0], err)
+		}
+		max, err := strconv.ParseFloat(bounds[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid float maximum %q: %v", bounds[1], err)
+		}
+		if min > max {
+			return nil, fmt.Errorf("float minimum %v exceeds maximum %v", min, max)
+		}
+		return FloatRangeValidator(min, max), nil
+
+	case "enum":
+		if arg == "" {
+			return nil, fmt.Errorf("enum rule needs at least one value")
+		}
+		return EnumValidator(strings.Split(arg, ",")...), nil
+
+	case "rfc3339":
+		return TimeValidator(), nil
+
+	case "maxlen":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid maxlen %q", arg)
+		}
+		return MaxLengthValidator(n), nil
+
+	case "json":
+		return JSONValidator(), nil
+
+	case "jsonschema":
+		return JSONSchemaValidator([]byte(arg))
+
+	default:
+		return nil, fmt.Errorf("unknown validator %q", name)
+	}
+}
+
+// integerValidator accepts base-10 integers
+type integerValidator struct{}
+
+// IntegerValidator accepts base-10 integers
+func IntegerValidator() Validator {
+	return integerValidator{}
+}
+
+func (integerValidator) Validate(value string) error {
+	if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+		return fmt.Errorf("%q is not an integer", value)
+	}
+	return nil
+}
+
+func (integerValidator) Describe() string {
+	return "integer"
+}
+
+// floatRangeValidator accepts floating point numbers within bounds
+type floatRangeValidator struct {
+	min, max float64
+}
+
+// FloatRangeValidator accepts finite floating point numbers in [min, max]
+func FloatRangeValidator(min, max float64) Validator {
+	return floatRangeValidator{min: min, max: max}
+}
+
+func (v floatRangeValidator) Validate(value string) error {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("%q is not a finite number", value)
+	}
+	if f < v.min || f > v.max {
+		return fmt.Errorf("%v is outside [%v, %v]", f, v.min, v.max)
+	}
+	return nil
+}
+
+func (v floatRangeValidator) Describe() string {
+	if math.IsInf(v.min, -1) && math.IsInf(v.max, 1) {
+		return "float"
+	}
+	return fmt.Sprintf("float:%v:%v", v.min, v.max)
+}
+
+// enumValidator accepts a fixed set of values
+type enumValidator struct {
+	values []string
+}
+
+// EnumValidator accepts exactly one of the given values
+func EnumValidator(values ...string) Validator {
+	return enumValidator{values: values}
+}
+
+func (v enumValidator) Validate(value string) error {
+	for _, allowed := range v.values {
+		if value == allowed {
+			return nil
+		}
+	}
+	return fmt.Errorf("%q is not one of %s", value, strings.Join(v.values, ", "))
+}
+
+func (v enumValidator) Describe() string {
+	return "enum:" + strings.Join(v.values, ",")
+}
+
+// timeValidator accepts RFC 3339 timestamps
+type timeValidator struct{}
+
+// TimeValidator accepts RFC 3339 timestamps
+func TimeValidator() Validator {
+	return timeValidator{}
+}
+
+func (timeValidator) Validate(value string) error {
+	if _, err := time.Parse(time.RFC3339Nano, value); err != nil {
+		return fmt.Errorf("%q is not an RFC 3339 timestamp", value)
+	}
+	return nil
+}
+
+func (timeValidator) Describe() string {
+	return "rfc3339"
+}
+
+// maxLengthValidator bounds the number of characters in a value
+type maxLengthValidator struct {
+	max int
+}
+
+// MaxLengthValidator accepts values of at most max characters
+func MaxLengthValidator(max int) Validator {
+	return maxLengthValidator{max: max}
+}
+
+func (v maxLengthValidator) Validate(value string) error {
+	if n := utf8.RuneCountInString(value); n > v.max {
+		return fmt.Errorf("length %d exceeds maximum of %d", n, v.max)
+	}
+	return nil
+}
+
+func (v maxLengthValidator) Describe() string {
+	return fmt.Sprintf("maxlen:%d", v.max)
+}
+
+// jsonValidator accepts well-formed JSON documents
+type jsonValidator struct{}
+
+// JSONValidator accepts any well-formed JSON document
+func JSONValidator() Validator {
+	return jsonValidator{}
+}
+
+func (jsonValidator) Validate(value string) error {
+	if !json.Valid([]byte(value)) {
+		return fmt.Errorf("value is not valid JSON")
+	}
+	return nil
+}
+
+func (jsonValidator) Describe() string {
+	return "json"
+}
+
+// jsonSchema is the subset of JSON Schema understood by JSONSchemaValidator
+type jsonSchema struct {
+	Type       string                 `json:"type,omitempty"`
+	Enum       []interface{}          `json:"enum,omitempty"`
+	Minimum    *float64               `json:"minimum,omitempty"`
+	Maximum    *float64               `json:"maximum,omitempty"`
+	MaxLength  *int                   `json:"maxLength,omitempty"`
+	Required   []string               `json:"required,omitempty"`
+	Properties map[string]*jsonSchema `json:"properties,omitempty"`
+	Items      *jsonSchema            `json:"items,omitempty"`
+}
+
+// jsonSchemaValidator accepts JSON documents matching a schema
+type jsonSchemaValidator struct {
+	raw    string
+	schema *jsonSchema
+}
+
+// JSONSchemaValidator accepts JSON documents matching a minimal JSON schema.
+// Only the type, enum, minimum, maximum, maxLength, required, properties and
+// items keywords are checked; other keywords are ignored.
+func JSONSchemaValidator(schema []byte) (Validator, error) {
+	var parsed jsonSchema
+	if err := json.Unmarshal(schema, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid JSON schema: %v", err)
+	}
+	return jsonSchemaValidator{raw: string(schema), schema: &parsed}, nil
+}
+
+func (v jsonSchemaValidator) Validate(value string) error {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(value), &doc); err != nil {
+		return fmt.Errorf("value is not valid JSON")
+	}
+	return v.schema.check("$", doc)
+}
+
+func (v jsonSchemaValidator) Describe() string {
+	return "jsonschema:" + v.raw
+}
+
+// check validates a decoded JSON value against the schema
+func (s *jsonSchema) check(path string, doc interface{}) error {
+	if s.Type != "" && !jsonTypeMatches(s.Type, doc) {
+		return fmt.Errorf("%s: expected %s", path, s.Type)
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, allowed := range s.Enum {
+			if fmt.Sprint(allowed) == fmt.Sprint(doc) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value is not one of the allowed values", path)
+		}
+	}
+
+	switch val := doc.(type) {
+	case float64:
+		if s.Minimum != nil && val < *s.Minimum {
+			return fmt.Errorf("%s: %v is below minimum %v", path, val, *s.Minimum)
+		}
+		if s.Maximum != nil && val > *s.Maximum {
+			return fmt.Errorf("%s: %v is above maximum %v", path, val, *s.Maximum)
+		}
+
+	case string:
+		if s.MaxLength != nil && utf8.RuneCountInString(val) > *s.MaxLength {
+			return fmt.Errorf("%s: length exceeds maximum of %d", path, *s.MaxLength)
+		}
+
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, exists := val[name]; !exists {
+				return fmt.Errorf("%s: missing required property %q", path, name)
+			}
+		}
+		for name, prop := range s.Properties {
+			if field, exists := val[name]; exists {
+				if err := prop.check(path+"."+name, field); err != nil {
+					return err
+				}
+			}
+		}
+
+	case []interface{}:
+		if s.Items != nil {
+			for i, item := range val {
+				if err := s.Items.check(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// jsonTypeMatches reports whether a decoded JSON value has the named type
+func jsonTypeMatches(typeName string, doc interface{}) bool {
+	switch val := doc.(type) {
+	case nil:
+		return typeName == "null"
+	case bool:
+		return typeName == "boolean"
+	case float64:
+		return typeName == "number" || (typeName == "integer" && val == math.Trunc(val))
+	case string:
+		return typeName == "string"
+	case []interface{}:
+		return typeName == "array"
+	case map[string]interface{}:
+		return typeName == "object"
+	default:
+		return false
+	}
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+func TestValidators(t *testing.T) {
+	tests := []struct {
+		rule  string
+		valid []string
+		bad   []string
+	}{
+		{"integer", []string{"0", "-17", "9223372036854775807"}, []string{"", "1.5", "ten", "9223372036854775808"}},
+		{"float", []string{"0", "-2.5", "1e9"}, []string{"", "NaN", "Inf", "one"}},
+		{"float:0:100", []string{"0", "42.5", "100"}, []string{"-0.1", "100.01", "x"}},
+		{"enum:low,medium,high", []string{"low", "high"}, []string{"", "LOW", "urgent"}},
+		{"rfc3339", []string{"2024-01-01T00:00:00Z", "2024-06-30T12:30:00.5+02:00"}, []string{"2024-01-01", "yesterday"}},
+		{"maxlen:3", []string{"", "abc", "日本語"}, []string{"abcd", "日本語!"}},
+		{"json", []string{`{}`, `[1,2]`, `"s"`, `null`}, []string{`{`, `{'a':1}`, ``}},
+		{
+			`jsonschema:{"type":"object","required":["id"],"properties":{"id":{"type":"integer","minimum":1},"tags":{"type":"array","items":{"type":"string","maxLength":2}}}}`,
+			[]string{`{"id":1}`, `{"id":7,"tags":["a","bc"]}`},
+			[]string{`[]`, `{}`, `{"id":0}`, `{"id":1.5}`, `{"id":1,"tags":["abc"]}`, `{"id":1,"tags":[1]}`},
+		},
+		{`jsonschema:{"enum":["on","off"]}`, []string{`"on"`}, []string{`"auto"`, `true`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			v, err := ParseValidator(tt.rule)
+			if err != nil {
+				t.Fatalf("ParseValidator: %v", err)
+			}
+			if got := v.Describe(); got != tt.rule {
+				t.Errorf("Describe() = %q, want the rule back", got)
+			}
+			for _, value := range tt.valid {
+				if err := v.Validate(value); err != nil {
+					t.Errorf("rejected %q: %v", value, err)
+				}
+			}
+			for _, value := range tt.bad {
+				if err := v.Validate(value); err == nil {
+					t.Errorf("accepted %q", value)
+				}
+			}
+		})
+	}
+}
+
+func TestParseValidatorRejectsBadRules(t *testing.T) {
+	for _, rule := range []string{
+		"",
+		"number",
+		"float:1",
+		"float:a:2",
+		"float:1:b",
+		"float:5:1",
+		"enum:",
+		"maxlen:",
+		"maxlen:-1",
+		"jsonschema:{",
+	} {
+		if _, err := ParseValidator(rule); err == nil {
+			t.Errorf("ParseValidator(%q) accepted a bad rule", rule)
+		}
+	}
+}
+
+func TestKeySpecsEnforcedOnContext(t *testing.T) {
+	srv := newUnstartedServer(t, nil)
+	progress, _ := ParseValidator("float:0:100")
+	if err := srv.RegisterKeySpec(KeySpec{Name: "progress", Pattern: "progress.*", Validator: progress}); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.RegisterKeySpec(KeySpec{Name: "progress", Pattern: "other", Validator: progress}); err == nil {
+		t.Fatal("duplicate spec name accepted")
+	}
+	if err := srv.RegisterKeySpec(KeySpec{Name: "bad", Pattern: "[", Validator: progress}); err == nil {
+		t.Fatal("malformed pattern accepted")
+	}
+	startTestServer(t, srv)
+	c := dialHello(t, srv, "")
+
+	c.expect("CONTEXT:progress.build=42", protocol.TypeAck)
+	reply := c.expectError("CONTEXT:progress.build=120;note=x", protocol.ReasonInvalidValue)
+	if !strings.Contains(reply.Params["detail"], "progress") {
+		t.Fatalf("detail %q does not name the spec", reply.Params["detail"])
+	}
+	// The rejected update is not applied in part
+	if got := c.expect("GET:key=note", protocol.TypeResult); got.Params["note"] != "" {
+		t.Fatalf("rejected update stored note: %s", got)
+	}
+
+	c.expect("CONTEXT:anything=goes", protocol.TypeAck)
+	srv.SetStrictKeys(true)
+	c.expectError("CONTEXT:anything=else", protocol.ReasonUnregisteredKey)
+
+	specs := c.expect("SPECS:", protocol.TypeResult)
+	if specs.Params["progress.pattern"] != "progress.*" || specs.Params["progress.rule"] != "float:0:100" || specs.Params["strict"] != "true" {
+		t.Fatalf("SPECS = %s", specs)
+	}
+}
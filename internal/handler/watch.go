@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/errs"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+)
+
+// handleWatchAll registers the connection to be pushed a CONTEXT message
+// for every change to any client's context, for auditing. Each push names
+// the changed key with its new value, alongside _client (the client that
+// owns it), _version and, for removals, _deleted=true. A watcher that does
+// not keep up misses changes rather than slowing writers down; the next
+// push reports how many were missed in _dropped. Watching lasts until the
+// connection closes, and repeating WATCH_ALL has no further effect.
+func (c *Connection) handleWatchAll(msg protocol.Message) (protocol.Message, error) {
+	if c.draining() {
+		return protocol.Message{}, errs.New(errs.ErrDraining, "connection is draining, reconnect to watch")
+	}
+	if c.watching {
+		return ackMessage(), nil
+	}
+
+	ch := make(chan state.ContextEvent, config.SubscriptionBufferSize)
+	c.store.Watch(c.id, ch)
+	c.watching = true
+	go c.forwardWatched(ch)
+
+	c.logger.Info("Watching all context changes")
+	return ackMessage(), nil
+}
+
+// forwardWatched pushes every context change received on ch to the client
+// until the connection is closed
+func (c *Connection) forwardWatched(ch <-chan state.ContextEvent) {
+	for {
+		select {
+		case <-c.closeChan:
+			return
+		case event := <-ch:
+			if err := c.Send(watchedMessage(event)); err != nil {
+				c.logger.Error("Failed to push watched context change: %v", err)
+				c.Close()
+				return
+			}
+		}
+	}
+}
+
+// watchedMessage builds the CONTEXT pushed to watchers for a context event
+func watchedMessage(event state.ContextEvent) protocol.Message {
+	push := protocol.NewMessage(protocol.TypeContext, map[string]string{
+		event.Key:  event.Value,
+		"_client":  event.ClientID,
+		"_version": strconv.FormatUint(event.Version, 10),
+	})
+	if event.Deleted {
+		push.Params["_deleted"] = "true"
+	}
+	if event.Dropped > 0 {
+		push.Params["_dropped"] = strconv.FormatUint(event.Dropped, 10)
+	}
+	return push
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+func TestWatchAllSeesOtherClientsChanges(t *testing.T) {
+	srv := newTestServer(t, nil)
+	watcher := dialHello(t, srv, "")
+	watcher.expect("WATCH_ALL:", protocol.TypeAck)
+	// Repeating WATCH_ALL must not double the pushes
+	watcher.expect("WATCH_ALL:", protocol.TypeAck)
+
+	a := dialHello(t, srv, "client_id=a")
+	b := dialHello(t, srv, "client_id=b")
+	a.expect("CONTEXT:region=eu", protocol.TypeAck)
+	b.expect("CONTEXT:load=3", protocol.TypeAck)
+	a.expect("REMOVE:key=region", protocol.TypeAck)
+
+	for _, want := range []struct{ client, key, value, deleted string }{
+		{"a", "region", "eu", ""},
+		{"b", "load", "3", ""},
+		{"a", "region", "", "true"},
+	} {
+		push := watcher.recv()
+		if push.Type != protocol.TypeContext || push.Params["_client"] != want.client ||
+			push.Params[want.key] != want.value || push.Params["_deleted"] != want.deleted || push.Params["_version"] == "" {
+			t.Fatalf("got %s, want %s's change to %s", push, want.client, want.key)
+		}
+	}
+	watcher.expect("PING:", protocol.TypePong)
+
+	// Other connections are not pushed anything
+	a.expect("PING:", protocol.TypePong)
+}
@@ -0,0 +1,44 @@
+// Package idgen generates the identifiers the server hands out. Production
+// servers use random IDs; tests can substitute a Sequential generator so
+// IDs, and everything that embeds them, are reproducible.
+package idgen
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync/atomic"
+)
+
+// Generator creates connection identifiers
+type Generator interface {
+	// ConnectionID returns a new unique connection ID. prefix describes
+	// where the connection came from, such as its remote address.
+	ConnectionID(prefix string) string
+}
+
+// Random generates IDs carrying 64 random bits from crypto/rand, so they
+// cannot be guessed by other clients
+type Random struct{}
+
+// ConnectionID returns prefix followed by a random suffix
+func (Random) ConnectionID(prefix string) string {
+	var suffix [8]byte
+	if _, err := rand.Read(suffix[:]); err != nil {
+		// The system random source failing leaves nothing safe to fall
+		// back to
+		panic(fmt.Sprintf("idgen: reading random bytes: %v", err))
+	}
+	return prefix + "-" + hex.EncodeToString(suffix[:])
+}
+
+// Sequential generates predictable IDs numbered from 1, for tests. The
+// prefix is ignored, so IDs do not depend on ephemeral ports.
+type Sequential struct {
+	n atomic.Uint64
+}
+
+// ConnectionID returns conn-1, conn-2 and so on
+func (s *Sequential) ConnectionID(prefix string) string {
+	return fmt.Sprintf("conn-%d", s.n.Add(1))
+}
@@ -0,0 +1,64 @@
+package idgen
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+func TestSequentialIsReproducible(t *testing.T) {
+	a, b := &Sequential{}, &Sequential{}
+	for i := 1; i <= 3; i++ {
+		want := fmt.Sprintf("conn-%d", i)
+		if got := a.ConnectionID("10.0.0.1:5000"); got != want {
+			t.Fatalf("ConnectionID = %q, want %q", got, want)
+		}
+		// The prefix does not leak into the ID
+		if got := b.ConnectionID(fmt.Sprintf("10.0.0.%d:%d", i, 40000+i)); got != want {
+			t.Fatalf("second generator gave %q, want %q", got, want)
+		}
+	}
+}
+
+func TestSequentialIsUniqueAcrossGoroutines(t *testing.T) {
+	gen := &Sequential{}
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				id := gen.ConnectionID("")
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("duplicate ID %s", id)
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != 4000 {
+		t.Fatalf("%d IDs, want 4000", len(seen))
+	}
+}
+
+func TestRandomIDs(t *testing.T) {
+	format := regexp.MustCompile(`^127\.0\.0\.1:9000-[0-9a-f]{16}$`)
+	seen := make(map[string]bool)
+	for i := 0; i < 1000; i++ {
+		id := Random{}.ConnectionID("127.0.0.1:9000")
+		if !format.MatchString(id) {
+			t.Fatalf("ID %q is not the prefix and 64 random bits in hex", id)
+		}
+		if seen[id] {
+			t.Fatalf("duplicate ID %s", id)
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,43 @@
+package protocol
+
+import "strconv"
+
+// Reasons carried in the reason parameter of ERROR messages, which clients
+// can switch on
+const (
+	ReasonNotFound           = "not_found"
+	ReasonQuotaExceeded      = "quota_exceeded"
+	ReasonUnsupportedVersion = "unsupported_version"
+	ReasonReadOnly           = "read_only"
+	ReasonRateLimited        = "rate_limited"
+	ReasonMutationLimited    = "mutation_rate_limited"
+	ReasonUnauthorized       = "unauthorized"
+	ReasonUnauthenticated    = "unauthenticated"
+	ReasonShuttingDown       = "shutting_down"
+	ReasonInvalidParams      = "invalid_params"
+	ReasonInvalidValue       = "invalid_value"
+	ReasonUnregisteredKey    = "unregistered_key"
+	ReasonMessageTooLarge    = "message_too_large"
+	ReasonHandshakeRequired  = "handshake_required"
+	ReasonAlreadyNegotiated  = "already_negotiated"
+	ReasonClientIDInUse      = "client_id_in_use"
+	ReasonStaleSequence      = "stale_sequence"
+	ReasonDraining           = "draining"
+	ReasonUnknownType        = "unknown_type"
+	ReasonTooManyConnections = "too_many_connections"
+	ReasonServerLoading      = "server_loading"
+	ReasonServerFull         = "server_full"
+	ReasonParseFailed        = "parse_failed"
+	ReasonInternal           = "internal_error"
+)
+
+// NewError creates an ERROR message with a numeric code and a
+// machine-readable reason. Codes follow HTTP status semantics: 4xx codes mean
+// the client should change its request, 5xx codes mean the server could not
+// serve it. The reason distinguishes errors sharing a code.
+func NewError(code int, reason string) Message {
+	return NewMessage(TypeError, map[string]string{
+		"code":   strconv.Itoa(code),
+		"reason": reason,
+	})
+}
@@ -0,0 +1,13 @@
+package protocol
+
+import "testing"
+
+func TestNewErrorRoundTrips(t *testing.T) {
+	msg := NewError(400, ReasonParseFailed)
+	if want := "ERROR:code=400;reason=parse_failed"; msg.Format() != want {
+		t.Fatalf("Format = %q, want %q", msg.Format(), want)
+	}
+	if got := roundTrip(t, msg); got.Type != TypeError || got.Params["code"] != "400" || got.Params["reason"] != ReasonParseFailed {
+		t.Fatalf("round trip = %s", got)
+	}
+}
@@ -2,16 +2,53 @@ package protocol
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
 // Message types
 const (
-	TypePing    = "PING"
-	TypePong    = "PONG"
-	TypeContext = "CONTEXT"
-	TypeAck     = "ACK"
-	TypeError   = "ERROR"
+	TypePing          = "PING"
+	TypePong          = "PONG"
+	TypeContext       = "CONTEXT"
+	TypeAck           = "ACK"
+	TypeError         = "ERROR"
+	TypeSubscribe     = "SUBSCRIBE"
+	TypeGet           = "GET"
+	TypeResult        = "RESULT"
+	TypeSpecs         = "SPECS"
+	TypeGoAway        = "GOAWAY"
+	TypeUnsubscribe   = "UNSUBSCRIBE"
+	TypeUpdate        = "UPDATE"
+	TypeHello         = "HELLO"
+	TypeGoodbye       = "GOODBYE"
+	TypeSchedules     = "SCHEDULES"
+	TypeCancel        = "CANCEL"
+	TypeQuery         = "QUERY"
+	TypeKick          = "KICK"
+	TypeDrain         = "DRAIN"
+	TypeExport        = "EXPORT"
+	TypeLogLevel      = "LOGLEVEL"
+	TypeClearAll      = "CLEARALL"
+	TypeReload        = "RELOAD"
+	TypeTime          = "TIME"
+	TypeUsage         = "USAGE"
+	TypeStats         = "STATS"
+	TypeExpired       = "EXPIRED"
+	TypeWarning       = "WARNING"
+	TypeList          = "LIST"
+	TypeSubscriptions = "SUBSCRIPTIONS"
+	TypeWatchAll      = "WATCH_ALL"
+	TypeAuth          = "AUTH"
+	TypeRemove        = "REMOVE"
+
+	// Chunked transfers of values too large for one message
+	TypeValueBegin   = "VALUE_BEGIN"
+	TypeValueChunk   = "VALUE_CHUNK"
+	TypeValueEnd     = "VALUE_END"
+	TypeContextBegin = "CONTEXT_BEGIN"
+	TypeContextChunk = "CONTEXT_CHUNK"
+	TypeContextEnd   = "CONTEXT_END"
 	// TODO: Add more message types as needed
 )
 
@@ -33,11 +70,32 @@ func NewMessage(msgType string, params map[string]string) Message {
 	}
 }
 
+// NewResponse creates a reply to req with the given type and parameters,
+// echoing the request's optional id parameter so clients that pipeline
+// requests can tell which reply answers which
+func NewResponse(req Message, msgType string, params map[string]string) Message {
+	msg := NewMessage(msgType, params)
+	if id, exists := req.ID(); exists {
+		msg.Params["id"] = id
+	}
+	return msg
+}
+
+// ID returns the message's correlation id, the optional id parameter that
+// replies echo, and whether it has one
+func (m Message) ID() (string, bool) {
+	id, exists := m.Params["id"]
+	return id, exists
+}
+
 // Parse converts a raw message string into a Message struct
 // Format: TYPE:key=value;key2=value2
+//
+// Keys and values may contain escaped characters as produced by Format; see
+// EscapeValue for the escaping scheme.
 func Parse(raw string) (Message, error) {
 	// Trim whitespace and any trailing newlines
-	raw = strings.TrimSpace(raw)
+	raw = strings.Trim(raw, trimSet)
 
 	// Check for empty message
 	if raw == "" {
@@ -58,15 +116,21 @@ func Parse(raw string) (Message, error) {
 	// Parse parameters
 	params := make(map[string]string)
 	if parts[1] != "" {
-		paramPairs := strings.Split(parts[1], ";")
+		paramPairs := splitUnescaped(parts[1], ';')
 		for _, pair := range paramPairs {
-			kv := strings.SplitN(pair, "=", 2)
-			if len(kv) != 2 {
+			sep := indexUnescaped(pair, '=')
+			if sep < 0 {
 				return Message{}, fmt.Errorf("invalid parameter format: %s", pair)
 			}
 
-			key := strings.TrimSpace(kv[0])
-			value := strings.TrimSpace(kv[1])
+			key, err := UnescapeValue(strings.Trim(pair[:sep], trimSet))
+			if err != nil {
+				return Message{}, fmt.Errorf("invalid parameter key %q: %v", pair[:sep], err)
+			}
+			value, err := UnescapeValue(strings.Trim(pair[sep+1:], trimSet))
+			if err != nil {
+				return Message{}, fmt.Errorf("invalid value for parameter %s: %v", key, err)
+			}
 
 			if key == "" {
 				return Message{}, fmt.Errorf("empty parameter key")
@@ -82,31 +146,114 @@ func Parse(raw string) (Message, error) {
 	}, nil
 }
 
-// Format converts a Message struct back into a protocol string
+// Format converts a Message struct back into a protocol string. Keys and
+// values are escaped so that Parse(m.Format()) reproduces m for arbitrary
+// parameters, and parameters are written in key order so equal messages
+// format identically.
 func (m Message) Format() string {
-	var params []string
+	keys := make([]string, 0, len(m.Params))
+	for key := range m.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
 
-	for key, value := range m.Params {
-		params = append(params, fmt.Sprintf("%s=%s", key, value))
+	params := make([]string, 0, len(keys))
+	for _, key := range keys {
+		params = append(params, fmt.Sprintf("%s=%s", EscapeValue(key), EscapeValue(m.Params[key])))
 	}
 
 	paramStr := strings.Join(params, ";")
 	return fmt.Sprintf("%s:%s", m.Type, paramStr)
 }
 
+// Encode returns the message formatted and terminated by the message
+// delimiter, ready to be written to a connection. The result can be written
+// to any number of connections, so a message pushed to many is formatted
+// once.
+func (m Message) Encode() []byte {
+	return append([]byte(m.Format()), '\n')
+}
+
 // String returns a string representation of the message for logging
 func (m Message) String() string {
 	return fmt.Sprintf("Message{Type: %s, Params: %v}", m.Type, m.Params)
 }
 
+// RecoverID returns the id parameter of a line Parse rejected, if it can be
+// found among the parameters that are well-formed, so the ERROR reporting
+// the failure can still be correlated with the request
+func RecoverID(raw string) (string, bool) {
+	_, params, found := strings.Cut(strings.Trim(raw, trimSet), ":")
+	if !found {
+		return "", false
+	}
+
+	for _, pair := range splitUnescaped(params, ';') {
+		sep := indexUnescaped(pair, '=')
+		if sep < 0 {
+			continue
+		}
+		key, err := UnescapeValue(strings.Trim(pair[:sep], trimSet))
+		if err != nil || key != "id" {
+			continue
+		}
+		value, err := UnescapeValue(strings.Trim(pair[sep+1:], trimSet))
+		if err != nil {
+			continue
+		}
+		return value, true
+	}
+	return "", false
+}
+
+// NormalizeType returns the canonical uppercase form of a message type, for
+// compatibility with clients that send types in lowercase
+func NormalizeType(msgType string) string {
+	return strings.ToUpper(msgType)
+}
+
 // ValidateMessageType checks if a message type is valid
 func ValidateMessageType(msgType string) bool {
 	validTypes := map[string]bool{
-		TypePing:    true,
-		TypePong:    true,
-		TypeContext: true,
-		TypeAck:     true,
-		TypeError:   true,
+		TypePing:          true,
+		TypePong:          true,
+		TypeContext:       true,
+		TypeAck:           true,
+		TypeError:         true,
+		TypeSubscribe:     true,
+		TypeGet:           true,
+		TypeResult:        true,
+		TypeSpecs:         true,
+		TypeGoAway:        true,
+		TypeUnsubscribe:   true,
+		TypeUpdate:        true,
+		TypeHello:         true,
+		TypeGoodbye:       true,
+		TypeSchedules:     true,
+		TypeCancel:        true,
+		TypeQuery:         true,
+		TypeKick:          true,
+		TypeDrain:         true,
+		TypeExport:        true,
+		TypeLogLevel:      true,
+		TypeClearAll:      true,
+		TypeReload:        true,
+		TypeTime:          true,
+		TypeUsage:         true,
+		TypeStats:         true,
+		TypeExpired:       true,
+		TypeWarning:       true,
+		TypeList:          true,
+		TypeSubscriptions: true,
+		TypeWatchAll:      true,
+		TypeAuth:          true,
+		TypeRemove:        true,
+		TypeValueBegin:    true,
+		TypeValueChunk:    true,
+		TypeValueEnd:      true,
+		TypeContextBegin:  true,
+		TypeContextChunk:  true,
+		TypeContextEnd:    true,
 		// Add other valid types here
 	}
 
@@ -114,6 +261,177 @@ func ValidateMessageType(msgType string) bool {
 	return valid
 }
 
+// trimSet is the whitespace trimmed from messages, keys and values. Other
+// whitespace is preserved so it survives a round trip unescaped.
+const trimSet = " \t\r\n"
+
+// escapeReplacer escapes characters that have meaning in the wire format
+var escapeReplacer = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	"=", `\=`,
+	":", `\:`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\t", `\t`,
+)
+
+// EscapeValue escapes a parameter key or value for the wire format. Backslash,
+// ';', '=' and ':' are prefixed with a backslash, newline, carriage return
+// and tab become \n, \r and \t, and a leading or trailing space becomes \s
+// so it is not lost to trimming.
+func EscapeValue(value string) string {
+	escaped := escapeReplacer.Replace(value)
+
+	if strings.HasPrefix(escaped, " ") {
+		escaped = `\s` + escaped[1:]
+	}
+	if strings.HasSuffix(escaped, " ") {
+		escaped = escaped[:len(escaped)-1] + `\s`
+	}
+
+	return escaped
+}
+
+// UnescapeValue reverses EscapeValue, rejecting unknown or truncated escape
+// sequences
+func UnescapeValue(escaped string) (string, error) {
+	if !strings.Contains(escaped, `\`) {
+		return escaped, nil
+	}
+
+	var b strings.Builder
+	b.Grow(len(escaped))
+
+	for i := 0; i < len(escaped); i++ {
+		ch := escaped[i]
+		if ch != '\\' {
+			b.WriteByte(ch)
+			continue
+		}
+
+		i++
+		if i == len(escaped) {
+			return "", fmt.Errorf("truncated escape sequence at end of %q", escaped)
+		}
+
+		switch escaped[i] {
+		case '\\', ';', '=', ':':
+			b.WriteByte(escaped[i])
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 's':
+			b.WriteByte(' ')
+		default:
+			return "", fmt.Errorf("invalid escape sequence \\%c in %q", escaped[i], escaped)
+		}
+	}
+
+	return b.String(), nil
+}
+
+// listEscaper escapes the separator of list-valued parameters
+var listEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`)
+
+// JoinList encodes items as a single comma-separated parameter value. Commas
+// and backslashes within items are backslash-escaped, so any items survive a
+// round trip through SplitList; the result is escaped again by Format like
+// any other value.
+func JoinList(items []string) string {
+	escaped := make([]string, len(items))
+	for i, item := range items {
+		escaped[i] = listEscaper.Replace(item)
+	}
+	return strings.Join(escaped, ",")
+}
+
+// FitList returns how many items from the front of items can be joined by
+// JoinList and still be at most budget bytes once escaped by Format. It
+// measures each item once, so a caller trimming a list to fit a size limit
+// need not re-encode it per dropped item.
+func FitList(items []string, budget int) int {
+	size := 0
+	for i, item := range items {
+		escaped := escapeReplacer.Replace(listEscaper.Replace(item))
+		size += len(escaped)
+		if i > 0 {
+			size++
+		}
+
+		// A leading or trailing space of the whole value costs one more
+		// byte, as \s, unless it is the same single space
+		padding := 0
+		if strings.HasPrefix(items[0], " ") {
+			padding++
+		}
+		if strings.HasSuffix(escaped, " ") && size > 1 {
+			padding++
+		}
+		if size+padding > budget {
+			return i
+		}
+	}
+	return len(items)
+}
+
+// SplitList reverses JoinList. An empty value is an empty list.
+func SplitList(value string) ([]string, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	var items []string
+	for _, part := range splitUnescaped(value, ',') {
+		var b strings.Builder
+		for i := 0; i < len(part); i++ {
+			if part[i] == '\\' {
+				i++
+				if i == len(part) || (part[i] != ',' && part[i] != '\\') {
+					return nil, fmt.Errorf("invalid list escape in %q", value)
+				}
+			}
+			b.WriteByte(part[i])
+		}
+		items = append(items, b.String())
+	}
+
+	return items, nil
+}
+
+// indexUnescaped returns the index of the first occurrence of sep in s that
+// is not preceded by an escaping backslash, or -1 if there is none
+func indexUnescaped(s string, sep byte) int {
+	escaped := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case s[i] == '\\':
+			escaped = true
+		case s[i] == sep:
+			return i
+		}
+	}
+	return -1
+}
+
+// splitUnescaped splits s around every unescaped occurrence of sep
+func splitUnescaped(s string, sep byte) []string {
+	var parts []string
+	for {
+		i := indexUnescaped(s, sep)
+		if i < 0 {
+			return append(parts, s)
+		}
+		parts = append(parts, s[:i])
+		s = s[i+1:]
+	}
+}
+
 // TODO: Add more protocol helpers as needed, such as:
 // - Message validation
 // - Special message constructors for common message types
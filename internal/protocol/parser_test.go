@@ -0,0 +1,182 @@
+package protocol
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// roundTrip formats m and parses the result back
+func roundTrip(t *testing.T, m Message) Message {
+	t.Helper()
+
+	got, err := Parse(m.Format())
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", m.Format(), err)
+	}
+	return got
+}
+
+// specials are the characters with meaning in the wire format, weighted
+// heavily in generated strings so every escape is exercised
+var specials = []rune{';', '=', ':', '\\', '\n', '\r', '\t', ' ', ','}
+
+// randomString returns a string of up to 12 runes mixing specials, ASCII
+// letters and arbitrary Unicode
+func randomString(r *rand.Rand) string {
+	var b strings.Builder
+	for n := r.Intn(13); n > 0; n-- {
+		switch r.Intn(3) {
+		case 0:
+			b.WriteRune(specials[r.Intn(len(specials))])
+		case 1:
+			b.WriteRune(rune('a' + r.Intn(26)))
+		default:
+			b.WriteRune(rune(r.Intn(0xD7FF) + 1))
+		}
+	}
+	return b.String()
+}
+
+func TestFormatParseRoundTripsRandomParams(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		m := NewMessage(TypeContext, nil)
+		for n := r.Intn(5); n > 0; n-- {
+			if key := randomString(r); key != "" {
+				m.Params[key] = randomString(r)
+			}
+		}
+		if got := roundTrip(t, m); !reflect.DeepEqual(got, m) {
+			t.Fatalf("Parse(Format(m)) = %#v, want %#v", got.Params, m.Params)
+		}
+	}
+}
+
+func TestParseRejectsMalformedEscapes(t *testing.T) {
+	for _, raw := range []string{
+		`CONTEXT:k=a\`,
+		`CONTEXT:k=a\x`,
+		`CONTEXT:k=\q;j=b`,
+		`CONTEXT:k\z=v`,
+	} {
+		if _, err := Parse(raw); err == nil {
+			t.Errorf("Parse(%q) accepted a malformed escape", raw)
+		} else if !strings.Contains(err.Error(), "escape") {
+			t.Errorf("Parse(%q): %v does not name the escape", raw, err)
+		}
+	}
+}
+
+func TestEachSpecialCharacterRoundTrips(t *testing.T) {
+	for _, special := range []string{";", "=", ":", "\\", "\n", "\r", "\t", " ", ","} {
+		for _, value := range []string{
+			special,
+			"a" + special + "b",
+			special + special + "x" + special,
+		} {
+			m := NewMessage(TypeContext, map[string]string{
+				value:   value,
+				"plain": value,
+			})
+			if got := roundTrip(t, m); !reflect.DeepEqual(got, m) {
+				t.Errorf("%q: Parse(Format(m)) = %q", value, got.Params)
+			}
+		}
+	}
+}
+
+func TestEscapedFormKeepsOneLine(t *testing.T) {
+	formatted := NewMessage(TypeContext, map[string]string{
+		"note": "a;b=c:d\ne\\f",
+	}).Format()
+
+	if want := `CONTEXT:note=a\;b\=c\:d\ne\\f`; formatted != want {
+		t.Fatalf("Format = %q, want %q", formatted, want)
+	}
+}
+
+func TestFormatIsDeterministic(t *testing.T) {
+	params := make(map[string]string)
+	for i := 0; i < 10; i++ {
+		params[fmt.Sprintf("k%d", 9-i)] = fmt.Sprintf("v;%d", i)
+	}
+	m := NewMessage(TypeContext, params)
+
+	first := m.Format()
+	for i := 0; i < 100; i++ {
+		// A fresh map each time, so its iteration order differs too
+		copied := NewMessage(TypeContext, nil)
+		for k, v := range params {
+			copied.Params[k] = v
+		}
+		if got := copied.Format(); got != first {
+			t.Fatalf("Format call %d = %q, want %q", i, got, first)
+		}
+		if got := string(m.Encode()); got != first+"\n" {
+			t.Fatalf("Encode call %d = %q", i, got)
+		}
+	}
+
+	if want := `CONTEXT:k0=v\;9;k1=v\;8;k2=v\;7;k3=v\;6;k4=v\;5;k5=v\;4;k6=v\;3;k7=v\;2;k8=v\;1;k9=v\;0`; first != want {
+		t.Fatalf("Format = %q, want keys sorted: %q", first, want)
+	}
+}
+
+func TestNewResponseCopiesID(t *testing.T) {
+	req, err := Parse(`PING:id=req\;7`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := NewResponse(req, TypePong, nil)
+	if id, _ := resp.ID(); id != "req;7" || resp.Type != TypePong {
+		t.Fatalf("NewResponse = %s, want a PONG with id req;7", resp)
+	}
+
+	resp = NewResponse(NewMessage(TypePing, nil), TypePong, map[string]string{"status": "ok"})
+	if _, exists := resp.ID(); exists || resp.Params["status"] != "ok" {
+		t.Fatalf("NewResponse = %s, want status and no id", resp)
+	}
+}
+
+func TestRecoverID(t *testing.T) {
+	tests := []struct {
+		raw, id string
+		found   bool
+	}{
+		{`CONTEXT:k;id=7`, "7", true},
+		{`CONTEXT:bad=\q;id=a\;b`, "a;b", true},
+		{`CONTEXT:id=\q`, "", false},
+		{"CONTEXT:k", "", false},
+		{"no separator", "", false},
+	}
+	for _, tt := range tests {
+		if id, found := RecoverID(tt.raw); id != tt.id || found != tt.found {
+			t.Errorf("RecoverID(%q) = %q, %v; want %q, %v", tt.raw, id, found, tt.id, tt.found)
+		}
+	}
+}
+
+func TestFitListMatchesEncodedLength(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		items := make([]string, r.Intn(6))
+		for j := range items {
+			items[j] = randomString(r)
+		}
+		budget := r.Intn(40)
+
+		// The longest prefix whose encoding fits, found the slow way
+		want := 0
+		for n := 1; n <= len(items); n++ {
+			if len(EscapeValue(JoinList(items[:n]))) <= budget {
+				want = n
+			}
+		}
+		if got := FitList(items, budget); got != want {
+			t.Fatalf("FitList(%q, %d) = %d, want %d", items, budget, got, want)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package state
+
+import "time"
+
+// Batch is a set of changes to one client's context applied together by
+// Apply
+type Batch struct {
+	Set    map[string]string
+	Remove []string
+
+	// TTL makes the values in Set expire, if positive; NotifyExpiry has
+	// their expiry reported as SetWithTTL does
+	TTL          time.Duration
+	NotifyExpiry bool
+}
+
+// Apply makes every change in b under a single acquisition of the store
+// lock, so readers see either none of them or all of them, and returns once
+// all are made. Subscribers are notified of each change.
+func (s *ContextStore) Apply(clientID string, b Batch) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for key, value := range b.Set {
+		if b.TTL > 0 {
+			s.setWithTTL(clientID, key, value, now, b.TTL, b.NotifyExpiry)
+		} else {
+			s.set(clientID, key, value, time.Time{})
+		}
+	}
+	for _, key := range b.Remove {
+		s.remove(clientID, key)
+	}
+}
@@ -0,0 +1,68 @@
+package state
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestApplyIsAtomic(t *testing.T) {
+	s := NewContextStore()
+	keys := []string{"k0", "k1", "k2", "k3", "k4", "k5", "k6", "k7", "k8", "k9"}
+	batch := func(v string) Batch {
+		b := Batch{Set: make(map[string]string, len(keys))}
+		for _, key := range keys {
+			b.Set[key] = v
+		}
+		return b
+	}
+	s.Apply("c", batch("0"))
+
+	var stop atomic.Bool
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; !stop.Load(); i++ {
+			s.Apply("c", batch(strconv.Itoa(i)))
+		}
+	}()
+
+	// Every batch sets all ten keys alike, so a read mixing values saw a
+	// batch part way through
+read:
+	for i := 0; i < 5000; i++ {
+		all, _ := s.GetAll("c")
+		for _, key := range keys {
+			if all[key] != all["k0"] {
+				t.Errorf("GetAll saw k0=%s, %s=%s", all["k0"], key, all[key])
+				break read
+			}
+		}
+	}
+	stop.Store(true)
+	wg.Wait()
+}
+
+func TestApplySetsAndRemoves(t *testing.T) {
+	clock := newFakeClock()
+	s := NewContextStore(WithClock(clock.Now))
+	s.SetMultiple("c", map[string]string{"old": "1", "kept": "2"})
+
+	s.Apply("c", Batch{Set: map[string]string{"new": "3"}, Remove: []string{"old"}, TTL: time.Minute})
+	all, _ := s.GetAll("c")
+	if len(all) != 2 || all["new"] != "3" || all["kept"] != "2" {
+		t.Fatalf("GetAll = %v, want kept and new", all)
+	}
+
+	// The TTL applies to the batch's sets only
+	clock.Advance(2 * time.Minute)
+	if _, ok := s.Get("c", "new"); ok {
+		t.Error("new outlived the batch TTL")
+	}
+	if _, ok := s.Get("c", "kept"); !ok {
+		t.Error("kept expired with the batch")
+	}
+}
@@ -0,0 +1,42 @@
+package state
+
+// ValueCodec converts context values between the plaintext seen by clients
+// and the form held in memory and written to snapshots, allowing embedders
+// to encrypt or obfuscate sensitive values at rest. Values are encoded
+// before compression and decoded after decompression.
+type ValueCodec interface {
+	// Encode returns the at-rest form of a value. It must not fail; a codec
+	// that cannot encode should panic rather than store plaintext.
+	Encode(value string) string
+	// Decode reverses Encode
+	Decode(stored string) (string, error)
+}
+
+// nopCodec stores values as is
+type nopCodec struct{}
+
+func (nopCodec) Encode(value string) string {
+	return value
+}
+
+func (nopCodec) Decode(stored string) (string, error) {
+	return stored, nil
+}
+
+// WithCodec sets the codec used to encode values at rest. Snapshots hold
+// encoded values, so a snapshot must be loaded by a store using the same
+// codec. The default codec stores values as is.
+func WithCodec(codec ValueCodec) Option {
+	return func(s *ContextStore) {
+		s.codec = codec
+	}
+}
+
+// load returns the plaintext value of an entry
+func (s *ContextStore) load(e *entry) (string, error) {
+	stored, err := e.decompress()
+	if err != nil {
+		return "", err
+	}
+	return s.codec.Decode(stored)
+}
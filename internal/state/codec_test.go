@@ -0,0 +1,85 @@
+package state
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// base64Codec stands in for an encrypting codec
+type base64Codec struct{}
+
+func (base64Codec) Encode(value string) string {
+	return base64.StdEncoding.EncodeToString([]byte(value))
+}
+
+func (base64Codec) Decode(stored string) (string, error) {
+	value, err := base64.StdEncoding.DecodeString(stored)
+	return string(value), err
+}
+
+// storedValue returns the at-rest form of a key
+func storedValue(s *ContextStore, clientID, key string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.contexts[clientID].entries[key].value
+}
+
+func TestCodecEncodesAtRest(t *testing.T) {
+	s := NewContextStore(WithCodec(base64Codec{}))
+	s.Set("c", "secret", "hunter2")
+
+	if stored := storedValue(s, "c", "secret"); stored != (base64Codec{}).Encode("hunter2") {
+		t.Fatalf("stored %q, want the encoded form", stored)
+	}
+	if value, _ := s.Get("c", "secret"); value != "hunter2" {
+		t.Fatalf("Get = %q, want the plaintext", value)
+	}
+	if all, _ := s.GetAll("c"); all["secret"] != "hunter2" {
+		t.Fatalf("GetAll = %v, want the plaintext", all)
+	}
+}
+
+func TestSnapshotHoldsEncodedValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+
+	s := NewContextStore(WithCodec(base64Codec{}))
+	s.Set("c", "secret", "hunter2")
+	if err := s.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "hunter2") {
+		t.Fatal("snapshot holds the plaintext")
+	}
+	if !strings.Contains(string(data), (base64Codec{}).Encode("hunter2")) {
+		t.Fatal("snapshot does not hold the encoded value")
+	}
+
+	loaded := NewContextStore(WithCodec(base64Codec{}))
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := loaded.Get("c", "secret"); value != "hunter2" {
+		t.Fatalf("Get after load = %q", value)
+	}
+}
+
+func TestCodecComposesWithCompression(t *testing.T) {
+	value := compressible(8 * 1024)
+	s := NewContextStore(WithCodec(base64Codec{}), WithCompression(1024))
+	s.Set("c", "blob", value)
+
+	if !isCompressed(s, "c", "blob") {
+		t.Fatal("encoded value was not compressed")
+	}
+	if got, _ := s.Get("c", "blob"); got != value {
+		t.Fatal("value changed through encoding and compression")
+	}
+}
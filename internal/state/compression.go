@@ -0,0 +1,133 @@
+package state
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// WithCompression stores values longer than threshold bytes gzip-compressed,
+// decompressing them transparently on read. Values that do not shrink when
+// compressed are stored as is. A threshold of zero disables compression.
+func WithCompression(threshold int) Option {
+	return func(s *ContextStore) {
+		s.compressThreshold = threshold
+	}
+}
+
+// StoreStats reports the size of the store
+type StoreStats struct {
+	Clients int
+	Keys    int
+	// RawBytes is the total size of all values before compression
+	RawBytes int64
+	// StoredBytes is the total size of all values as held in memory
+	StoredBytes int64
+	// CompressionRatio is RawBytes / StoredBytes, or 1 for an empty store
+	CompressionRatio float64
+}
+
+// Stats returns the current size of the store
+func (s *ContextStore) Stats() StoreStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := StoreStats{
+		Clients:          len(s.contexts),
+		RawBytes:         s.rawBytes,
+		StoredBytes:      s.storedBytes,
+		CompressionRatio: 1,
+	}
+	for _, client := range s.contexts {
+		stats.Keys += len(client.entries)
+	}
+	if s.storedBytes > 0 {
+		stats.CompressionRatio = float64(s.rawBytes) / float64(s.storedBytes)
+	}
+
+	return stats
+}
+
+// ClientUsage reports the share of the store held by one client
+type ClientUsage struct {
+	Keys int
+	// Bytes is the total size of the client's values before compression
+	Bytes int64
+}
+
+// Usage returns the number of live keys a client holds and the size of their
+// values. Expired keys awaiting the sweeper are not counted.
+func (s *ContextStore) Usage(clientID string) ClientUsage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var usage ClientUsage
+	client, exists := s.contexts[clientID]
+	if !exists {
+		return usage
+	}
+
+	now := s.now()
+	for _, e := range client.entries {
+		if e.expired(now) {
+			continue
+		}
+		usage.Keys++
+		usage.Bytes += int64(e.rawSize)
+	}
+	return usage
+}
+
+// newEntry builds the stored form of a value, encoding it with the store's
+// codec and then compressing it if it is above the threshold and compression
+// actually saves space
+func (s *ContextStore) newEntry(value string) *entry {
+	value = s.codec.Encode(value)
+	e := &entry{
+		value:   value,
+		rawSize: len(value),
+	}
+
+	if s.compressThreshold <= 0 || len(value) <= s.compressThreshold {
+		return e
+	}
+
+	var buf bytes.Buffer
+	// BestSpeed is a valid level, so this cannot fail
+	zw, _ := gzip.NewWriterLevel(&buf, gzip.BestSpeed)
+	if _, err := zw.Write([]byte(value)); err != nil {
+		return e
+	}
+	if err := zw.Close(); err != nil {
+		return e
+	}
+
+	if buf.Len() < len(value) {
+		e.value = buf.String()
+		e.compressed = true
+	}
+
+	return e
+}
+
+// decompress returns the encoded value of an entry, decompressing it if
+// needed
+func (e *entry) decompress() (string, error) {
+	if !e.compressed {
+		return e.value, nil
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader([]byte(e.value)))
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress value: %v", err)
+	}
+	defer zr.Close()
+
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress value: %v", err)
+	}
+
+	return string(raw), nil
+}
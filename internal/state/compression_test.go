@@ -0,0 +1,141 @@
+package state
+
+import (
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// compressible returns n bytes of repetitive JSON-like text
+func compressible(n int) string {
+	return strings.Repeat(`{"k":"v"}`, n/9+1)[:n]
+}
+
+// incompressible returns n random bytes
+func incompressible(n int) string {
+	buf := make([]byte, n)
+	rand.New(rand.NewSource(int64(n))).Read(buf)
+	return string(buf)
+}
+
+// isCompressed reports whether the store holds a key compressed
+func isCompressed(s *ContextStore, clientID, key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.contexts[clientID].entries[key].compressed
+}
+
+func TestCompressionThresholdIsExclusive(t *testing.T) {
+	s := NewContextStore(WithCompression(1024))
+
+	s.Set("c", "at", compressible(1024))
+	s.Set("c", "above", compressible(1025))
+
+	if isCompressed(s, "c", "at") {
+		t.Error("value of exactly the threshold was compressed")
+	}
+	if !isCompressed(s, "c", "above") {
+		t.Error("value one byte over the threshold was not compressed")
+	}
+}
+
+func TestIncompressibleValueStoredAsIs(t *testing.T) {
+	s := NewContextStore(WithCompression(64))
+	value := incompressible(4096)
+
+	s.Set("c", "noise", value)
+
+	if isCompressed(s, "c", "noise") {
+		t.Fatal("incompressible value stored compressed")
+	}
+	if got, _ := s.Get("c", "noise"); got != value {
+		t.Fatal("incompressible value changed on the way through")
+	}
+	if stats := s.Stats(); stats.StoredBytes != stats.RawBytes || stats.CompressionRatio != 1 {
+		t.Fatalf("stats = %+v, want nothing saved", stats)
+	}
+}
+
+func TestMixedCompressedAndPlainKeys(t *testing.T) {
+	s := NewContextStore(WithCompression(256))
+	values := map[string]string{
+		"small": "tiny",
+		"blob":  compressible(64 * 1024),
+		"noise": incompressible(2048),
+	}
+	s.SetMultiple("c", values)
+
+	all, _ := s.GetAll("c")
+	for key, want := range values {
+		if all[key] != want {
+			t.Errorf("GetAll[%s] differs from what was stored", key)
+		}
+	}
+	if !isCompressed(s, "c", "blob") || isCompressed(s, "c", "small") || isCompressed(s, "c", "noise") {
+		t.Error("wrong keys compressed")
+	}
+
+	stats := s.Stats()
+	if stats.RawBytes != int64(len("tiny")+64*1024+2048) {
+		t.Errorf("RawBytes = %d", stats.RawBytes)
+	}
+	if stats.CompressionRatio <= 5 {
+		t.Errorf("CompressionRatio = %.2f, want the blob to dominate", stats.CompressionRatio)
+	}
+	if usage := s.Usage("c"); usage.Bytes != stats.RawBytes {
+		t.Errorf("Usage counts %d bytes, want the uncompressed %d", usage.Bytes, stats.RawBytes)
+	}
+
+	// Overwriting with a small value releases the compressed bytes
+	s.Set("c", "blob", "gone")
+	if stats := s.Stats(); stats.StoredBytes != stats.RawBytes {
+		t.Errorf("stats after overwrite = %+v", stats)
+	}
+}
+
+func TestSnapshotKeepsCompressedForm(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	value := compressible(32 * 1024)
+
+	s := NewContextStore(WithCompression(1024))
+	s.Set("c", "blob", value)
+	if err := s.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewContextStore(WithCompression(1024))
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if !isCompressed(loaded, "c", "blob") {
+		t.Fatal("value decompressed on load")
+	}
+	if got, _ := loaded.Get("c", "blob"); got != value {
+		t.Fatal("value changed across save and load")
+	}
+}
+
+func benchmarkCompression(b *testing.B, size int, read bool) {
+	s := NewContextStore(WithCompression(1024))
+	value := compressible(size)
+	s.Set("c", "k", value)
+
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if read {
+			s.Get("c", "k")
+		} else {
+			s.Set("c", "k", value)
+		}
+	}
+}
+
+func BenchmarkSetAtThreshold(b *testing.B)    { benchmarkCompression(b, 1024, false) }
+func BenchmarkSetAboveThreshold(b *testing.B) { benchmarkCompression(b, 1025, false) }
+func BenchmarkSet64KB(b *testing.B)           { benchmarkCompression(b, 64*1024, false) }
+func BenchmarkGetAtThreshold(b *testing.B)    { benchmarkCompression(b, 1024, true) }
+func BenchmarkGetAboveThreshold(b *testing.B) { benchmarkCompression(b, 1025, true) }
+func BenchmarkGet64KB(b *testing.B)           { benchmarkCompression(b, 64*1024, true) }
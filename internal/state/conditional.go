@@ -0,0 +1,82 @@
+package state
+
+import (
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/errs"
+)
+
+// ConditionOp is the test a Condition applies to a key
+type ConditionOp string
+
+// Condition tests
+const (
+	// CondEquals holds if the key is set to Value
+	CondEquals ConditionOp = "equals"
+	// CondExists holds if the key is set to any value
+	CondExists ConditionOp = "exists"
+	// CondAbsent holds if the key is not set
+	CondAbsent ConditionOp = "absent"
+)
+
+// Condition is a precondition on one of a client's keys. Expired keys count
+// as not set.
+type Condition struct {
+	Key   string
+	Op    ConditionOp
+	Value string // compared by CondEquals, ignored otherwise
+}
+
+// ApplyIf sets every value in writes for a client if, and only if, every
+// condition holds. Conditions are checked and writes applied under a single
+// lock acquisition, so no other change can slip in between and readers see
+// either all of the writes or none. It reports whether the writes were
+// applied; an error means a condition was malformed and nothing was
+// checked.
+func (s *ContextStore) ApplyIf(clientID string, conditions []Condition, writes map[string]string) (bool, error) {
+	for _, cond := range conditions {
+		switch cond.Op {
+		case CondEquals, CondExists, CondAbsent:
+		default:
+			return false, errs.New(errs.ErrInvalidParams, "unknown condition %q on key %s", cond.Op, cond.Key)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, cond := range conditions {
+		if !s.holds(clientID, cond) {
+			return false, nil
+		}
+	}
+
+	for k, v := range writes {
+		s.set(clientID, k, v, time.Time{})
+	}
+	return true, nil
+}
+
+// holds reports whether a condition is met. Callers must hold s.mu.
+func (s *ContextStore) holds(clientID string, cond Condition) bool {
+	var (
+		value string
+		set   bool
+	)
+	if client, exists := s.contexts[clientID]; exists {
+		if e, exists := client.entries[cond.Key]; exists && !e.expired(s.now()) {
+			var err error
+			value, err = s.load(e)
+			set = err == nil
+		}
+	}
+
+	switch cond.Op {
+	case CondEquals:
+		return set && value == cond.Value
+	case CondExists:
+		return set
+	default:
+		return !set
+	}
+}
@@ -0,0 +1,101 @@
+package state
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestApplyIf(t *testing.T) {
+	writes := map[string]string{"owner": "b", "lease": "2"}
+	tests := []struct {
+		name       string
+		conditions []Condition
+		applied    bool
+	}{
+		{"no conditions", nil, true},
+		{"equals holds", []Condition{{Key: "owner", Op: CondEquals, Value: "a"}}, true},
+		{"equals differs", []Condition{{Key: "owner", Op: CondEquals, Value: "z"}}, false},
+		{"equals on unset key", []Condition{{Key: "nope", Op: CondEquals, Value: ""}}, false},
+		{"exists holds", []Condition{{Key: "lease", Op: CondExists}}, true},
+		{"exists fails", []Condition{{Key: "nope", Op: CondExists}}, false},
+		{"absent holds", []Condition{{Key: "nope", Op: CondAbsent}}, true},
+		{"absent fails", []Condition{{Key: "owner", Op: CondAbsent}}, false},
+		{"expired key is absent", []Condition{{Key: "temp", Op: CondAbsent}}, true},
+		{"expired key does not exist", []Condition{{Key: "temp", Op: CondExists}}, false},
+		{"all hold", []Condition{
+			{Key: "owner", Op: CondEquals, Value: "a"},
+			{Key: "lease", Op: CondExists},
+			{Key: "nope", Op: CondAbsent},
+		}, true},
+		{"one of several fails", []Condition{
+			{Key: "owner", Op: CondEquals, Value: "a"},
+			{Key: "lease", Op: CondAbsent},
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := newFakeClock()
+			s := NewContextStore(WithClock(clock.Now))
+			s.SetMultiple("c", map[string]string{"owner": "a", "lease": "1"})
+			s.SetWithTTL("c", "temp", "x", time.Second, false)
+			clock.Advance(2 * time.Second)
+			before, _ := s.GetAll("c")
+
+			applied, err := s.ApplyIf("c", tt.conditions, writes)
+			if err != nil {
+				t.Fatalf("ApplyIf: %v", err)
+			}
+			if applied != tt.applied {
+				t.Fatalf("applied = %v, want %v", applied, tt.applied)
+			}
+
+			got, _ := s.GetAll("c")
+			want := before
+			if tt.applied {
+				want = map[string]string{"owner": "b", "lease": "2"}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("values = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestApplyIfRejectsUnknownCondition(t *testing.T) {
+	s := NewContextStore()
+	applied, err := s.ApplyIf("c", []Condition{{Key: "k", Op: "greater"}}, map[string]string{"k": "v"})
+	if err == nil || applied {
+		t.Fatalf("ApplyIf = %v, %v; want an error", applied, err)
+	}
+	if got, _ := s.GetAll("c"); len(got) != 0 {
+		t.Fatalf("values = %v after a malformed condition", got)
+	}
+}
+
+func TestApplyIfAdmitsOneWinner(t *testing.T) {
+	s := NewContextStore()
+	var wg sync.WaitGroup
+	wins := make(chan string, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			owner := string(rune('A' + i%26))
+			if ok, _ := s.ApplyIf("c", []Condition{{Key: "lock", Op: CondAbsent}}, map[string]string{"lock": owner}); ok {
+				wins <- owner
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(wins)
+
+	if len(wins) != 1 {
+		t.Fatalf("%d writers took the lock, want 1", len(wins))
+	}
+	if got, _ := s.Get("c", "lock"); got != <-wins {
+		t.Fatalf("lock held by %q, not the winner", got)
+	}
+}
@@ -0,0 +1,116 @@
+package state
+
+import "sort"
+
+// Read consistency
+//
+// Every read and write of the store runs under a single lock, so a
+// multi-key read of one client (GetAll, GetMultiple, SnapshotClient) never
+// observes a multi-key write (SetMultiple) half applied. Separate calls are
+// not isolated from each other: a client's values may change between two
+// reads, and nothing is guaranteed about the relative order in which
+// different clients' changes become visible. Callers needing repeatable
+// reads across several calls should read from a ClientSnapshot.
+
+// ClientSnapshot is an immutable copy of one client's context taken at a
+// single revision
+type ClientSnapshot struct {
+	ClientID string
+	// Revision identifies the state the snapshot was taken at; see
+	// ContextStore.Revision
+	Revision uint64
+	values   map[string]string
+}
+
+// Get returns the value of a key in the snapshot
+func (snap ClientSnapshot) Get(key string) (string, bool) {
+	value, exists := snap.values[key]
+	return value, exists
+}
+
+// Keys returns the keys in the snapshot, sorted
+func (snap ClientSnapshot) Keys() []string {
+	keys := make([]string, 0, len(snap.values))
+	for key := range snap.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Values returns a copy of the values in the snapshot
+func (snap ClientSnapshot) Values() map[string]string {
+	values := make(map[string]string, len(snap.values))
+	for key, value := range snap.values {
+		values[key] = value
+	}
+	return values
+}
+
+// SnapshotClient returns a copy of a client's live values along with the
+// client's revision. The snapshot is unaffected by later changes, and
+// comparing revisions tells whether the client has changed since.
+func (s *ContextStore) SnapshotClient(clientID string) ClientSnapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snap := ClientSnapshot{ClientID: clientID, values: make(map[string]string)}
+
+	client, exists := s.contexts[clientID]
+	if !exists {
+		return snap
+	}
+
+	snap.Revision = client.revision
+	now := s.now()
+	for key, e := range client.entries {
+		if e.expired(now) {
+			continue
+		}
+		if value, err := s.load(e); err == nil {
+			snap.values[key] = value
+		}
+	}
+	return snap
+}
+
+// Revision returns a number that changes whenever a key of the client is
+// set or removed, or zero if the client holds no keys. Revisions only
+// increase, even across a client being cleared and written again. A key
+// expiring changes the revision once the sweeper removes it.
+func (s *ContextStore) Revision(clientID string) uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if client, exists := s.contexts[clientID]; exists {
+		return client.revision
+	}
+	return 0
+}
+
+// GetMultiple returns the live values of the given keys for a client, read
+// atomically with respect to writes. Keys that are not set are left out of
+// the result.
+func (s *ContextStore) GetMultiple(clientID string, keys []string) map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]string, len(keys))
+
+	client, exists := s.contexts[clientID]
+	if !exists {
+		return result
+	}
+
+	now := s.now()
+	for _, key := range keys {
+		e, exists := client.entries[key]
+		if !exists || e.expired(now) {
+			continue
+		}
+		if value, err := s.load(e); err == nil {
+			result[key] = value
+		}
+	}
+	return result
+}
@@ -0,0 +1,76 @@
+package state
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMultiKeyReadsNeverTorn(t *testing.T) {
+	s := NewContextStore()
+	s.SetMultiple("c", map[string]string{"a": "1", "b": "1"})
+
+	var stop atomic.Bool
+	var wg sync.WaitGroup
+	for w := 0; w < 2; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; !stop.Load(); i++ {
+				v := strconv.Itoa(1 + i%2)
+				s.SetMultiple("c", map[string]string{"a": v, "b": v})
+			}
+		}()
+	}
+
+	// Each write sets a and b alike, so differing values mean a read saw
+	// one write's a with another's b
+	for i := 0; i < 20000; i++ {
+		got := s.GetMultiple("c", []string{"a", "b"})
+		if got["a"] != got["b"] {
+			t.Errorf("GetMultiple saw a=%s, b=%s", got["a"], got["b"])
+			break
+		}
+		all, _ := s.GetAll("c")
+		if all["a"] != all["b"] {
+			t.Errorf("GetAll saw a=%s, b=%s", all["a"], all["b"])
+			break
+		}
+		snap := s.SnapshotClient("c")
+		a, _ := snap.Get("a")
+		b, _ := snap.Get("b")
+		if a != b {
+			t.Errorf("SnapshotClient saw a=%s, b=%s", a, b)
+			break
+		}
+	}
+	stop.Store(true)
+	wg.Wait()
+}
+
+func TestClientSnapshotIsRepeatable(t *testing.T) {
+	s := NewContextStore()
+	s.SetMultiple("c", map[string]string{"a": "1", "b": "2"})
+
+	snap := s.SnapshotClient("c")
+	s.Set("c", "a", "changed")
+	s.Remove("c", "b")
+	snap.Values()["a"] = "edited"
+
+	if a, _ := snap.Get("a"); a != "1" {
+		t.Errorf("snapshot a = %q after later writes", a)
+	}
+	if _, ok := snap.Get("b"); !ok {
+		t.Error("snapshot lost b after it was removed from the store")
+	}
+	if keys := snap.Keys(); len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Errorf("Keys() = %q", keys)
+	}
+	if s.Revision("c") == snap.Revision {
+		t.Error("revision unchanged by later writes")
+	}
+	if empty := s.SnapshotClient("nobody"); empty.Revision != 0 || len(empty.Keys()) != 0 {
+		t.Errorf("snapshot of an unknown client = %+v", empty)
+	}
+}
@@ -2,24 +2,107 @@ package state
 
 import (
 	"sync"
+	"time"
 )
 
+// entry is a single stored context value
+type entry struct {
+	value      string    // stored form, compressed if compressed is set
+	compressed bool      // value holds gzip-compressed bytes
+	rawSize    int       // length of the encoded value before compression
+	expiresAt  time.Time // zero if the value never expires
+	lease      uint64    // lease version set by SetWithLease, zero otherwise
+	revision   uint64    // store revision at which the value was set
+
+	// notifyExpiry asks for an Expiry when the sweeper removes the value;
+	// setAt is when it was set
+	notifyExpiry bool
+	setAt        time.Time
+}
+
+// expired reports whether the entry has expired at the given time
+func (e *entry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
 // ClientContext represents the context data for a client connection
 type ClientContext struct {
-	Values map[string]string
+	entries  map[string]*entry
+	revision uint64 // store revision of the client's last change
+}
+
+// newClientContext creates an empty client context
+func newClientContext() *ClientContext {
+	return &ClientContext{
+		entries: make(map[string]*entry),
+	}
 }
 
 // ContextStore provides a thread-safe store for client context information
 type ContextStore struct {
 	contexts map[string]*ClientContext
+	subs     map[string][]subscription // subscriber ID -> subscriptions
+	watchers map[string]*watcher       // watcher ID -> channel receiving every change
+	now      func() time.Time
 	mu       sync.RWMutex
+
+	// Values longer than compressThreshold bytes are stored compressed;
+	// zero disables compression
+	compressThreshold int
+	rawBytes          int64 // total size of stored values before compression
+	storedBytes       int64 // total size of stored values as held in memory
+
+	codec ValueCodec // encodes values at rest
+
+	leaseSeq uint64 // last lease version handed out by SetWithLease
+	revision uint64 // incremented on every change to any client
+
+	schedules   map[string]Schedule // schedule ID -> pending schedule
+	scheduleSeq uint64              // last schedule number handed out
+
+	history      map[string]map[string]*historyRing // client ID -> key -> recent values
+	historyDepth int                                // values kept per key; zero disables history
+
+	journal journal // recent changes, for ResumeSubscriptions
+
+	onExpiry  func(Expiry) // called for expired keys that asked for notification
+	onDropped func(string) // called with the subscriber ID of each dropped event
+
+	stopSweeper chan struct{}
+	startOnce   sync.Once
+	stopOnce    sync.Once
+}
+
+// Option configures a ContextStore
+type Option func(*ContextStore)
+
+// WithClock replaces the clock used for expiry, allowing tests to control
+// time instead of sleeping
+func WithClock(now func() time.Time) Option {
+	return func(s *ContextStore) {
+		s.now = now
+	}
 }
 
 // NewContextStore creates a new empty context store
-func NewContextStore() *ContextStore {
-	return &ContextStore{
-		contexts: make(map[string]*ClientContext),
+func NewContextStore(opts ...Option) *ContextStore {
+	s := &ContextStore{
+		contexts:    make(map[string]*ClientContext),
+		subs:        make(map[string][]subscription),
+		watchers:    make(map[string]*watcher),
+		schedules:   make(map[string]Schedule),
+		history:     make(map[string]map[string]*historyRing),
+		now:         time.Now,
+		codec:       nopCodec{},
+		journal:     journal{size: DefaultJournalSize},
+		stopSweeper: make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(s)
 	}
+
+	return s
 }
 
 // Get retrieves a specific context value for a client
@@ -32,8 +115,17 @@ func (s *ContextStore) Get(clientID, key string) (string, bool) {
 		return "", false
 	}
 
-	val, exists := client.Values[key]
-	return val, exists
+	e, exists := client.entries[key]
+	if !exists || e.expired(s.now()) {
+		return "", false
+	}
+
+	value, err := s.load(e)
+	if err != nil {
+		return "", false
+	}
+
+	return value, true
 }
 
 // GetAll returns all context values for a client
@@ -46,70 +138,203 @@ func (s *ContextStore) GetAll(clientID string) (map[string]string, bool) {
 		return nil, false
 	}
 
-	// Copy the map to avoid external modification
+	// Copy the values to avoid external modification
+	now := s.now()
 	result := make(map[string]string)
-	for k, v := range client.Values {
-		result[k] = v
+	for k, e := range client.entries {
+		if e.expired(now) {
+			continue
+		}
+		if value, err := s.load(e); err == nil {
+			result[k] = value
+		}
 	}
 
 	return result, true
 }
 
-// Set updates a context value for a client
+// Set updates a context value for a client. The value does not expire,
+// replacing any TTL set previously.
 func (s *ContextStore) Set(clientID, key, value string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	client, exists := s.contexts[clientID]
-	if !exists {
-		client = &ClientContext{
-			Values: make(map[string]string),
-		}
-		s.contexts[clientID] = client
-	}
-
-	client.Values[key] = value
+	s.set(clientID, key, value, time.Time{})
 }
 
-// SetMultiple updates multiple context values for a client
+// SetMultiple updates multiple context values for a client atomically:
+// readers see either none or all of them
 func (s *ContextStore) SetMultiple(clientID string, values map[string]string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	for k, v := range values {
+		s.set(clientID, k, v, time.Time{})
+	}
+}
+
+// SetDefaults sets each of values for a client that does not already have
+// the key set, leaving existing values alone, and returns how many were set
+func (s *ContextStore) SetDefaults(clientID string, values map[string]string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	set := 0
+	for key, value := range values {
+		if client, exists := s.contexts[clientID]; exists {
+			if e, exists := client.entries[key]; exists && !e.expired(now) {
+				continue
+			}
+		}
+		s.set(clientID, key, value, time.Time{})
+		set++
+	}
+	return set
+}
+
+// set stores a value written directly by a client, cancelling schedules
+// pending for the key, and notifies subscribers. Callers must hold s.mu.
+func (s *ContextStore) set(clientID, key, value string, expiresAt time.Time) {
+	if len(s.schedules) > 0 {
+		s.supersedeSchedules(clientID, key)
+	}
+
+	oldValue := s.setEntry(clientID, key, value, expiresAt)
+	s.notify(ContextEvent{ClientID: clientID, Key: key, OldValue: oldValue, Value: value})
+}
+
+// setEntry stores a value and returns the previous value if subscribers
+// could be interested in it. Callers must hold s.mu.
+func (s *ContextStore) setEntry(clientID, key, value string, expiresAt time.Time) string {
 	client, exists := s.contexts[clientID]
 	if !exists {
-		client = &ClientContext{
-			Values: make(map[string]string),
-		}
+		client = newClientContext()
 		s.contexts[clientID] = client
 	}
 
-	for k, v := range values {
-		client.Values[k] = v
+	// Only pay for loading the previous value if someone will see it
+	var oldValue string
+	interested := len(s.subs) > 0 || len(s.watchers) > 0
+	if old, exists := client.entries[key]; exists && interested && !old.expired(s.now()) {
+		oldValue, _ = s.load(old)
 	}
+
+	e := s.newEntry(value)
+	e.expiresAt = expiresAt
+	s.putEntry(client, key, e)
+	s.recordHistory(clientID, key, value)
+
+	return oldValue
 }
 
-// Remove deletes a context value for a client
+// Remove deletes a context value for a client, cancelling schedules pending
+// for the key, and notifies subscribers if the key was set
 func (s *ContextStore) Remove(clientID, key string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	s.remove(clientID, key)
+}
+
+// DeleteMultiple removes the listed keys for a client as Remove does, all
+// under a single acquisition of the store lock, and returns how many were
+// set. Expired values awaiting the sweeper are removed but not counted.
+func (s *ContextStore) DeleteMultiple(clientID string, keys []string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for _, key := range keys {
+		if s.remove(clientID, key) {
+			removed++
+		}
+	}
+	return removed
+}
+
+// remove deletes a value as Remove does, reporting whether it was set.
+// Callers must hold s.mu.
+func (s *ContextStore) remove(clientID, key string) bool {
+	s.supersedeSchedules(clientID, key)
+
 	client, exists := s.contexts[clientID]
 	if !exists {
-		return
+		return false
+	}
+	e, exists := client.entries[key]
+	if !exists {
+		return false
 	}
 
-	delete(client.Values, key)
+	event := ContextEvent{ClientID: clientID, Key: key, Deleted: true}
+	live := !e.expired(s.now())
+	if live {
+		event.OldValue, _ = s.load(e)
+	}
+	s.deleteEntry(client, key)
+	s.notify(event)
+	return live
 }
 
-// Clear removes all context values for a client
+// Clear removes all context values, pending schedules and history for a
+// client
 func (s *ContextStore) Clear(clientID string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	delete(s.history, clientID)
+
+	for id, sched := range s.schedules {
+		if sched.ClientID == clientID {
+			delete(s.schedules, id)
+		}
+	}
+
+	client, exists := s.contexts[clientID]
+	if !exists {
+		return
+	}
+
+	for key := range client.entries {
+		s.deleteEntry(client, key)
+	}
 	delete(s.contexts, clientID)
 }
 
+// putEntry stores an entry, keeping byte accounting up to date. Callers
+// must hold s.mu.
+func (s *ContextStore) putEntry(client *ClientContext, key string, e *entry) {
+	s.deleteEntry(client, key)
+
+	client.entries[key] = e
+	s.rawBytes += int64(e.rawSize)
+	s.storedBytes += int64(len(e.value))
+	s.revision++
+	client.revision = s.revision
+	e.revision = s.revision
+}
+
+// deleteEntry removes an entry, keeping byte accounting up to date.
+// Callers must hold s.mu.
+func (s *ContextStore) deleteEntry(client *ClientContext, key string) {
+	e, exists := client.entries[key]
+	if !exists {
+		return
+	}
+
+	delete(client.entries, key)
+	s.rawBytes -= int64(e.rawSize)
+	s.storedBytes -= int64(len(e.value))
+	s.revision++
+	client.revision = s.revision
+}
+
+// ServerClientID is the reserved client ID under which the server publishes
+// its own statistics. Its context is not persisted, and QueryClients leaves
+// it out unless asked to include it.
+const ServerClientID = "__server__"
+
 // ListClients returns a list of all client IDs in the store
 func (s *ContextStore) ListClients() []string {
 	s.mu.RLock()
@@ -123,25 +348,27 @@ func (s *ContextStore) ListClients() []string {
 	return clients
 }
 
-// QueryClients finds clients that match a given key-value condition
-// TODO: Implement more sophisticated query capabilities
-func (s *ContextStore) QueryClients(key, value string) []string {
+// QueryClients finds clients that match a given key-value condition.
+// ServerClientID is only matched if includeServer is set.
+func (s *ContextStore) QueryClients(key, value string, includeServer bool) []string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	var matches []string
 
+	now := s.now()
 	for clientID, ctx := range s.contexts {
-		if v, exists := ctx.Values[key]; exists && v == value {
+		if clientID == ServerClientID && !includeServer {
+			continue
+		}
+		e, exists := ctx.entries[key]
+		if !exists || e.expired(now) {
+			continue
+		}
+		if v, err := s.load(e); err == nil && v == value {
 			matches = append(matches, clientID)
 		}
 	}
 
 	return matches
 }
-
-// TODO: Add more advanced context operations:
-// - Context expiration/TTL
-// - Context snapshots/history
-// - Subscription to context changes
-// - Context serialization/persistence
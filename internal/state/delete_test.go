@@ -0,0 +1,35 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDeleteMultipleCountsRemovedKeys(t *testing.T) {
+	clock := newFakeClock()
+	s := NewContextStore(WithClock(clock.Now))
+	s.SetMultiple("c", map[string]string{"a": "1", "b": "2", "keep": "3"})
+	s.SetWithTTL("c", "stale", "4", time.Second, false)
+	s.Set("other", "a", "5")
+	clock.Advance(2 * time.Second)
+
+	// Absent, repeated and expired keys are not counted
+	if n := s.DeleteMultiple("c", []string{"a", "b", "missing", "a", "stale"}); n != 2 {
+		t.Fatalf("DeleteMultiple = %d, want 2", n)
+	}
+	all, _ := s.GetAll("c")
+	if want := map[string]string{"keep": "3"}; !reflect.DeepEqual(all, want) {
+		t.Fatalf("GetAll = %v, want %v", all, want)
+	}
+	if value, _ := s.Get("other", "a"); value != "5" {
+		t.Fatalf("another client's a = %q, want it untouched", value)
+	}
+
+	if n := s.DeleteMultiple("nobody", []string{"a"}); n != 0 {
+		t.Fatalf("DeleteMultiple for an unknown client = %d", n)
+	}
+	if n := s.DeleteMultiple("c", nil); n != 0 {
+		t.Fatalf("DeleteMultiple of no keys = %d", n)
+	}
+}
@@ -0,0 +1,180 @@
+package state
+
+import (
+	"sort"
+	"time"
+)
+
+// NoExpiry is the remaining TTL reported for keys that never expire
+const NoExpiry time.Duration = -1
+
+// Expiry describes a value removed by the sweeper after its TTL ran out
+type Expiry struct {
+	ClientID  string
+	Key       string
+	Value     string // the value that expired
+	SetAt     time.Time
+	ExpiredAt time.Time
+}
+
+// SetWithTTL updates a context value for a client that expires after ttl.
+// Expired values are treated as absent immediately and removed by the
+// sweeper. With notifyExpiry set, the function registered with OnExpiry is
+// told when the sweeper removes the value; a value overwritten first is
+// not reported.
+func (s *ContextStore) SetWithTTL(clientID, key, value string, ttl time.Duration, notifyExpiry bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.setWithTTL(clientID, key, value, s.now(), ttl, notifyExpiry)
+}
+
+// setWithTTL stores a value set at now that expires after ttl. Callers must
+// hold s.mu.
+func (s *ContextStore) setWithTTL(clientID, key, value string, now time.Time, ttl time.Duration, notifyExpiry bool) {
+	s.set(clientID, key, value, now.Add(ttl))
+
+	if notifyExpiry {
+		e := s.contexts[clientID].entries[key]
+		e.notifyExpiry = true
+		e.setAt = now
+	}
+}
+
+// OnExpiry registers fn to be called for each value set with notifyExpiry
+// that the sweeper removes. It is called from the sweeper without the store
+// lock held, so it may use the store. Only the last function registered is
+// called.
+func (s *ContextStore) OnExpiry(fn func(Expiry)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onExpiry = fn
+}
+
+// SetWithLease updates a context value for a client that expires after ttl
+// unless it is refreshed first. Each call takes a new lease version and
+// moves the expiry, so a refresh cancels the pending one; any other write
+// replaces the entry and ends the lease. The sweeper removes the key once
+// the lease runs out and notifies subscribers of the removal.
+func (s *ContextStore) SetWithLease(clientID, key, value string, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.set(clientID, key, value, s.now().Add(ttl))
+
+	s.leaseSeq++
+	s.contexts[clientID].entries[key].lease = s.leaseSeq
+}
+
+// TTL returns the time remaining before a key expires, or NoExpiry if it
+// never does. The boolean is false if the key is not set or has expired.
+func (s *ContextStore) TTL(clientID, key string) (time.Duration, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	client, exists := s.contexts[clientID]
+	if !exists {
+		return 0, false
+	}
+
+	e, exists := client.entries[key]
+	if !exists {
+		return 0, false
+	}
+
+	now := s.now()
+	if e.expired(now) {
+		return 0, false
+	}
+	if e.expiresAt.IsZero() {
+		return NoExpiry, true
+	}
+
+	return e.expiresAt.Sub(now), true
+}
+
+// Sweep removes expired keys, dropping clients left with no keys, and
+// returns how many keys were removed. It also runs schedules that have
+// fallen due, and reports expiries to the OnExpiry function once the store
+// is unlocked.
+func (s *ContextStore) Sweep() int {
+	s.mu.Lock()
+
+	now := s.now()
+	s.runSchedules(now, false)
+
+	removed := 0
+	var expiries []Expiry
+	for clientID, client := range s.contexts {
+		for key, e := range client.entries {
+			if !e.expired(now) {
+				continue
+			}
+			if e.notifyExpiry {
+				value, _ := s.load(e)
+				expiries = append(expiries, Expiry{
+					ClientID:  clientID,
+					Key:       key,
+					Value:     value,
+					SetAt:     e.setAt,
+					ExpiredAt: e.expiresAt,
+				})
+			}
+			s.deleteEntry(client, key)
+			removed++
+
+			// A lapsed lease is a removal subscribers are waiting for
+			if e.lease != 0 {
+				event := ContextEvent{ClientID: clientID, Key: key, Deleted: true}
+				event.OldValue, _ = s.load(e)
+				s.notify(event)
+			}
+		}
+
+		if len(client.entries) == 0 {
+			delete(s.contexts, clientID)
+		}
+	}
+
+	onExpiry := s.onExpiry
+	s.mu.Unlock()
+
+	// Report in the order the values expired, not map order
+	sort.Slice(expiries, func(i, j int) bool {
+		return expiries[i].ExpiredAt.Before(expiries[j].ExpiredAt)
+	})
+
+	if onExpiry != nil {
+		for _, exp := range expiries {
+			onExpiry(exp)
+		}
+	}
+	return removed
+}
+
+// StartSweeper periodically removes expired keys and runs due schedules in
+// the background until StopSweeper is called
+func (s *ContextStore) StartSweeper(interval time.Duration) {
+	s.startOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-s.stopSweeper:
+					return
+				case <-ticker.C:
+					s.Sweep()
+				}
+			}
+		}()
+	})
+}
+
+// StopSweeper stops the background sweeper
+func (s *ContextStore) StopSweeper() {
+	s.stopOnce.Do(func() {
+		close(s.stopSweeper)
+	})
+}
@@ -0,0 +1,175 @@
+package state
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock that only moves when told to
+type fakeClock struct {
+	now time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func TestLeaseRefreshKeepsKey(t *testing.T) {
+	clock := newFakeClock()
+	s := NewContextStore(WithClock(clock.Now))
+
+	s.SetWithLease("c", "lock", "a", 10*time.Second)
+	clock.Advance(8 * time.Second)
+	s.SetWithLease("c", "lock", "b", 10*time.Second)
+
+	// Past the first lease but within the refreshed one
+	clock.Advance(8 * time.Second)
+	if removed := s.Sweep(); removed != 0 {
+		t.Fatalf("Sweep removed %d keys, want 0", removed)
+	}
+	if value, ok := s.Get("c", "lock"); !ok || value != "b" {
+		t.Fatalf("Get = %q, %v; want refreshed value", value, ok)
+	}
+}
+
+func TestUnrefreshedLeaseExpires(t *testing.T) {
+	clock := newFakeClock()
+	s := NewContextStore(WithClock(clock.Now))
+	events := make(chan ContextEvent, 4)
+	s.Subscribe("sub", Subscription{Key: "lock"}, events)
+
+	s.SetWithLease("c", "lock", "a", 10*time.Second)
+	<-events
+
+	clock.Advance(9 * time.Second)
+	if removed := s.Sweep(); removed != 0 {
+		t.Fatalf("Sweep before the lease ran out removed %d keys", removed)
+	}
+
+	clock.Advance(time.Second)
+	if removed := s.Sweep(); removed != 1 {
+		t.Fatalf("Sweep removed %d keys, want 1", removed)
+	}
+	if _, ok := s.Get("c", "lock"); ok {
+		t.Fatal("key still set after its lease ran out")
+	}
+
+	select {
+	case event := <-events:
+		if !event.Deleted || event.OldValue != "a" {
+			t.Fatalf("got %+v, want deletion of a", event)
+		}
+	default:
+		t.Fatal("subscriber not told of the expired lease")
+	}
+}
+
+func TestPlainWriteEndsLease(t *testing.T) {
+	clock := newFakeClock()
+	s := NewContextStore(WithClock(clock.Now))
+
+	s.SetWithLease("c", "lock", "a", 10*time.Second)
+	s.Set("c", "lock", "b")
+
+	clock.Advance(time.Minute)
+	s.Sweep()
+	if value, ok := s.Get("c", "lock"); !ok || value != "b" {
+		t.Fatalf("Get = %q, %v; want value written over the lease", value, ok)
+	}
+}
+
+func TestTTLBoundaries(t *testing.T) {
+	clock := newFakeClock()
+	s := NewContextStore(WithClock(clock.Now))
+
+	s.SetWithTTL("c", "presence", "online", 10*time.Second, false)
+	s.Set("c", "name", "alice")
+
+	if ttl, ok := s.TTL("c", "presence"); !ok || ttl != 10*time.Second {
+		t.Fatalf("TTL = %v, %v; want 10s", ttl, ok)
+	}
+	if ttl, ok := s.TTL("c", "name"); !ok || ttl != NoExpiry {
+		t.Fatalf("TTL of a plain key = %v, %v; want NoExpiry", ttl, ok)
+	}
+
+	// One tick before expiry the value is still there
+	clock.Advance(10*time.Second - time.Nanosecond)
+	if value, ok := s.Get("c", "presence"); !ok || value != "online" {
+		t.Fatalf("Get just before expiry = %q, %v", value, ok)
+	}
+	if ttl, _ := s.TTL("c", "presence"); ttl != time.Nanosecond {
+		t.Fatalf("TTL just before expiry = %v, want 1ns", ttl)
+	}
+
+	// At the expiry instant it is gone, before any sweep
+	clock.Advance(time.Nanosecond)
+	if _, ok := s.Get("c", "presence"); ok {
+		t.Fatal("Get returned a value at its expiry instant")
+	}
+	if all, _ := s.GetAll("c"); len(all) != 1 || all["name"] != "alice" {
+		t.Fatalf("GetAll = %v, want only the plain key", all)
+	}
+	if _, ok := s.TTL("c", "presence"); ok {
+		t.Fatal("TTL reported for an expired key")
+	}
+
+	if removed := s.Sweep(); removed != 1 {
+		t.Fatalf("Sweep removed %d keys, want 1", removed)
+	}
+}
+
+func TestSweepDropsClientWithNoKeysLeft(t *testing.T) {
+	clock := newFakeClock()
+	s := NewContextStore(WithClock(clock.Now))
+
+	s.SetWithTTL("gone", "a", "1", time.Second, false)
+	s.SetWithTTL("gone", "b", "2", 2*time.Second, false)
+	s.Set("stays", "a", "1")
+
+	clock.Advance(time.Second)
+	s.Sweep()
+	if clients := s.ListClients(); len(clients) != 2 {
+		t.Fatalf("clients after the first expiry = %v", clients)
+	}
+
+	clock.Advance(time.Second)
+	s.Sweep()
+	if clients := s.ListClients(); len(clients) != 1 || clients[0] != "stays" {
+		t.Fatalf("clients after the last key expired = %v, want [stays]", clients)
+	}
+}
+
+func TestExpiryReportedOnlyWhenAsked(t *testing.T) {
+	clock := newFakeClock()
+	s := NewContextStore(WithClock(clock.Now))
+
+	var expiries []Expiry
+	s.OnExpiry(func(exp Expiry) { expiries = append(expiries, exp) })
+
+	s.SetWithTTL("c", "quiet", "q", time.Second, false)
+	s.SetWithTTL("c", "later", "l", 2*time.Second, true)
+	s.SetWithTTL("c", "first", "f", time.Second, true)
+	s.SetWithTTL("c", "replaced", "r", time.Second, true)
+	s.Set("c", "replaced", "kept")
+
+	clock.Advance(5 * time.Second)
+	s.Sweep()
+
+	if len(expiries) != 2 || expiries[0].Key != "first" || expiries[1].Key != "later" {
+		t.Fatalf("expiries = %+v, want first then later", expiries)
+	}
+	if exp := expiries[1]; exp.Value != "l" || exp.ExpiredAt.Sub(exp.SetAt) != 2*time.Second {
+		t.Fatalf("expiry = %+v", exp)
+	}
+}
+
+func TestStopSweeperIsIdempotent(t *testing.T) {
+	s := NewContextStore()
+	s.StartSweeper(time.Millisecond)
+	s.StopSweeper()
+	s.StopSweeper()
+}
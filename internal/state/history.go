@@ -0,0 +1,87 @@
+package state
+
+import "time"
+
+// HistoryEntry is a value a context key held and when it was set
+type HistoryEntry struct {
+	Value string
+	Time  time.Time
+}
+
+// historyRing holds the most recent values of a key, overwriting the oldest
+// once full. Values are held in their encoded form, like stored entries.
+type historyRing struct {
+	entries []HistoryEntry
+	start   int // index of the oldest entry once the ring is full
+}
+
+// add records an entry, dropping the oldest if the ring is at depth
+func (r *historyRing) add(e HistoryEntry, depth int) {
+	if len(r.entries) < depth {
+		r.entries = append(r.entries, e)
+		return
+	}
+	r.entries[r.start] = e
+	r.start = (r.start + 1) % len(r.entries)
+}
+
+// ordered returns the entries oldest first
+func (r *historyRing) ordered() []HistoryEntry {
+	result := make([]HistoryEntry, 0, len(r.entries))
+	result = append(result, r.entries[r.start:]...)
+	return append(result, r.entries[:r.start]...)
+}
+
+// WithHistory records the last depth values set for every key, retrievable
+// with History. History is kept in memory only and is not part of
+// snapshots. It is off by default.
+func WithHistory(depth int) Option {
+	return func(s *ContextStore) {
+		s.historyDepth = depth
+	}
+}
+
+// History returns the values most recently set for a client's key, oldest
+// first, including values since overwritten, removed or expired. It returns
+// nil if history is disabled or the key was never set.
+func (s *ContextStore) History(clientID, key string) []HistoryEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ring, exists := s.history[clientID][key]
+	if !exists {
+		return nil
+	}
+
+	entries := ring.ordered()
+	result := entries[:0]
+	for _, e := range entries {
+		value, err := s.codec.Decode(e.Value)
+		if err != nil {
+			continue
+		}
+		result = append(result, HistoryEntry{Value: value, Time: e.Time})
+	}
+	return result
+}
+
+// recordHistory appends a value to a key's history if history is enabled.
+// Callers must hold s.mu.
+func (s *ContextStore) recordHistory(clientID, key, value string) {
+	if s.historyDepth <= 0 {
+		return
+	}
+
+	keys, exists := s.history[clientID]
+	if !exists {
+		keys = make(map[string]*historyRing)
+		s.history[clientID] = keys
+	}
+	ring, exists := keys[key]
+	if !exists {
+		ring = &historyRing{}
+		keys[key] = ring
+	}
+
+	ring.add(HistoryEntry{Value: s.codec.Encode(value), Time: s.now()}, s.historyDepth)
+}
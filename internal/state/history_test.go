@@ -0,0 +1,83 @@
+package state
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// historyValues returns the values in a key's history, oldest first
+func historyValues(s *ContextStore, clientID, key string) []string {
+	var values []string
+	for _, e := range s.History(clientID, key) {
+		values = append(values, e.Value)
+	}
+	return values
+}
+
+func TestHistoryDropsOldestPastDepth(t *testing.T) {
+	clock := newFakeClock()
+	s := NewContextStore(WithHistory(3), WithClock(clock.Now))
+	start := clock.Now()
+
+	for _, v := range []string{"v1", "v2", "v3", "v4", "v5"} {
+		s.Set("a", "k", v)
+		clock.Advance(time.Second)
+	}
+
+	history := s.History("a", "k")
+	want := []HistoryEntry{
+		{Value: "v3", Time: start.Add(2 * time.Second)},
+		{Value: "v4", Time: start.Add(3 * time.Second)},
+		{Value: "v5", Time: start.Add(4 * time.Second)},
+	}
+	if !reflect.DeepEqual(history, want) {
+		t.Fatalf("History = %v, want %v", history, want)
+	}
+
+	// The ring keeps turning over
+	s.Set("a", "k", "v6")
+	if got := historyValues(s, "a", "k"); !reflect.DeepEqual(got, []string{"v4", "v5", "v6"}) {
+		t.Fatalf("History = %q after another set", got)
+	}
+}
+
+func TestHistoryPerClientAndKey(t *testing.T) {
+	s := NewContextStore(WithHistory(2))
+	s.Set("a", "k", "a1")
+	s.Set("b", "k", "b1")
+	s.Set("a", "j", "j1")
+	s.Set("a", "k", "a2")
+
+	if got := historyValues(s, "a", "k"); !reflect.DeepEqual(got, []string{"a1", "a2"}) {
+		t.Errorf("a/k = %q", got)
+	}
+	if got := historyValues(s, "b", "k"); !reflect.DeepEqual(got, []string{"b1"}) {
+		t.Errorf("b/k = %q", got)
+	}
+	if got := s.History("b", "j"); got != nil {
+		t.Errorf("b/j = %v, want nil for a key never set", got)
+	}
+}
+
+func TestHistoryOutlivesRemoveButNotClear(t *testing.T) {
+	s := NewContextStore(WithHistory(4), WithCodec(base64Codec{}))
+	s.Set("a", "k", "old")
+	s.Remove("a", "k")
+
+	if got := historyValues(s, "a", "k"); !reflect.DeepEqual(got, []string{"old"}) {
+		t.Fatalf("History after Remove = %q, want the removed value decoded", got)
+	}
+	s.Clear("a")
+	if got := s.History("a", "k"); got != nil {
+		t.Fatalf("History after Clear = %v", got)
+	}
+}
+
+func TestHistoryOffByDefault(t *testing.T) {
+	s := NewContextStore()
+	s.Set("a", "k", "v")
+	if got := s.History("a", "k"); got != nil {
+		t.Fatalf("History = %v with history disabled", got)
+	}
+}
@@ -0,0 +1,88 @@
+package state
+
+// DefaultJournalSize is the number of recent changes kept for resuming
+// subscriptions unless changed with WithJournal
+const DefaultJournalSize = 1024
+
+// WithJournal keeps the last size changes delivered to subscribers, so
+// ResumeSubscriptions can replay those a subscriber missed. Zero disables
+// the journal.
+func WithJournal(size int) Option {
+	return func(s *ContextStore) {
+		s.journal.size = size
+	}
+}
+
+// journal holds the most recent context events in version order, in a ring
+// of at most size events
+type journal struct {
+	events  []ContextEvent
+	start   int // index of the oldest event once the ring is full
+	size    int
+	evicted uint64 // version of the newest event dropped to stay within size
+}
+
+// add appends an event, dropping the oldest beyond the journal size
+func (j *journal) add(event ContextEvent) {
+	if j.size <= 0 {
+		j.evicted = event.Version
+		return
+	}
+
+	if len(j.events) < j.size {
+		j.events = append(j.events, event)
+		return
+	}
+	j.evicted = j.events[j.start].Version
+	j.events[j.start] = event
+	j.start = (j.start + 1) % len(j.events)
+}
+
+// each calls fn for every event held, oldest first
+func (j *journal) each(fn func(ContextEvent)) {
+	for i := range j.events {
+		fn(j.events[(j.start+i)%len(j.events)])
+	}
+}
+
+// ResumeSubscriptions registers subs for a subscriber as Subscribe does and
+// queues on ch, ahead of any later change, the journaled changes they select
+// made after revision since, marked Replayed. Registration and replay happen
+// under one lock, so nothing is delivered out of order or twice. gap reports
+// that changes after since may be missing, either because the journal no
+// longer holds them or because ch filled up during the replay.
+func (s *ContextStore) ResumeSubscriptions(subscriberID string, subs []Subscription, ch chan<- ContextEvent, since uint64) (replayed int, gap bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sub := range subs {
+		s.subscribe(subscriberID, sub, ch)
+	}
+
+	gap = since < s.journal.evicted
+	s.journal.each(func(event ContextEvent) {
+		if event.Version <= since || !matchesAny(subs, event) {
+			return
+		}
+
+		event.Replayed = true
+		select {
+		case ch <- event:
+			replayed++
+		default:
+			gap = true
+		}
+	})
+
+	return replayed, gap
+}
+
+// matchesAny reports whether any of subs selects event
+func matchesAny(subs []Subscription, event ContextEvent) bool {
+	for _, sub := range subs {
+		if sub.matches(event) {
+			return true
+		}
+	}
+	return false
+}
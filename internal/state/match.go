@@ -0,0 +1,43 @@
+package state
+
+import (
+	"path"
+	"sort"
+
+	"github.com/Artimus100/mcp-server-go/internal/errs"
+)
+
+// MatchKeys returns a client's keys matching a glob pattern, sorted. Patterns
+// use path.Match syntax: * matches any run of characters other than /, ?
+// any single such character, and [...] a character class, so model.*
+// matches model.name and *.id matches user.id. An empty pattern matches
+// every key. A malformed pattern is reported as errs.ErrInvalidParams.
+func (s *ContextStore) MatchKeys(clientID, pattern string) ([]string, error) {
+	// Match checks the whole pattern, so a malformed one is caught here
+	// rather than part way through the keys
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, errs.New(errs.ErrInvalidParams, "invalid key pattern %q", pattern)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := []string{}
+	client, exists := s.contexts[clientID]
+	if !exists {
+		return keys, nil
+	}
+
+	now := s.now()
+	for key, e := range client.entries {
+		if e.expired(now) {
+			continue
+		}
+		if matched, _ := path.Match(pattern, key); matched || pattern == "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
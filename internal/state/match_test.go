@@ -0,0 +1,64 @@
+package state
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/errs"
+)
+
+func TestMatchKeys(t *testing.T) {
+	clock := newFakeClock()
+	s := NewContextStore(WithClock(clock.Now))
+	s.SetMultiple("c", map[string]string{
+		"model.name": "x",
+		"model.size": "7b",
+		"user.id":    "1",
+		"session.id": "2",
+		"a/b.id":     "3",
+		"region":     "eu",
+	})
+	s.SetWithTTL("c", "model.old", "gone", time.Second, false)
+	clock.Advance(2 * time.Second)
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"model.*", []string{"model.name", "model.size"}},
+		{"*.id", []string{"session.id", "user.id"}},
+		{"*/*.id", []string{"a/b.id"}},
+		{"model.?ize", []string{"model.size"}},
+		{"[ru]*", []string{"region", "user.id"}},
+		{"region", []string{"region"}},
+		{"nothing.*", []string{}},
+		{"", []string{"a/b.id", "model.name", "model.size", "region", "session.id", "user.id"}},
+	}
+	for _, tt := range tests {
+		got, err := s.MatchKeys("c", tt.pattern)
+		if err != nil {
+			t.Errorf("MatchKeys(%q): %v", tt.pattern, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("MatchKeys(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+
+	if got, err := s.MatchKeys("nobody", "*"); err != nil || len(got) != 0 {
+		t.Errorf("MatchKeys for an unknown client = %q, %v", got, err)
+	}
+}
+
+func TestMatchKeysRejectsInvalidPattern(t *testing.T) {
+	s := NewContextStore()
+	s.Set("c", "k", "v")
+
+	for _, pattern := range []string{"[", "model.[a-", `k\`} {
+		if _, err := s.MatchKeys("c", pattern); !errors.Is(err, errs.ErrInvalidParams) {
+			t.Errorf("MatchKeys(%q) error = %v, want ErrInvalidParams", pattern, err)
+		}
+	}
+}
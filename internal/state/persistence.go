@@ -0,0 +1,200 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// snapshotVersion is the version of the snapshot file format
+const snapshotVersion = 1
+
+// snapshot is the on-disk form of a ContextStore
+type snapshot struct {
+	Version      int                                 `json:"version"`
+	Clients      map[string]map[string]snapshotEntry `json:"clients"`
+	Schedules    []Schedule                          `json:"schedules,omitempty"`
+	NextSchedule uint64                              `json:"next_schedule,omitempty"`
+	Revision     uint64                              `json:"revision,omitempty"`
+}
+
+// snapshotEntry is the on-disk form of a stored value. Compressed values are
+// persisted in their compressed form so saving and loading skip the codec.
+type snapshotEntry struct {
+	Value      string     `json:"value,omitempty"`
+	Compressed []byte     `json:"compressed,omitempty"`
+	RawSize    int        `json:"raw_size,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	Revision   uint64     `json:"revision,omitempty"`
+
+	// NotifyExpiry and SetAt carry an expiry notification request
+	NotifyExpiry bool       `json:"notify_expiry,omitempty"`
+	SetAt        *time.Time `json:"set_at,omitempty"`
+}
+
+// Save writes a JSON snapshot of the store to path. The store is copied
+// under the read lock and written without it, so writers are not blocked by
+// disk I/O. The file is written to a temporary file in the same directory
+// and renamed into place, so a failed save never leaves a partial snapshot.
+func (s *ContextStore) Save(path string) error {
+	snap := s.snapshot()
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %v", err)
+	}
+
+	return writeFileAtomic(path, data)
+}
+
+// Load replaces the contents of the store with the snapshot at path.
+// Entries that expired while the snapshot was on disk are skipped, and
+// schedules that fell due are run immediately with Overdue set on their
+// events.
+func (s *ContextStore) Load(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("failed to decode snapshot %s: %v", path, err)
+	}
+	if snap.Version != snapshotVersion {
+		return fmt.Errorf("unsupported snapshot version %d in %s", snap.Version, path)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.contexts = make(map[string]*ClientContext)
+	s.schedules = make(map[string]Schedule, len(snap.Schedules))
+	s.scheduleSeq = snap.NextSchedule
+	s.rawBytes = 0
+	s.storedBytes = 0
+	// Keep revisions increasing across restarts so versions held by
+	// subscribers stay comparable
+	if snap.Revision > s.revision {
+		s.revision = snap.Revision
+	}
+
+	now := s.now()
+	for clientID, entries := range snap.Clients {
+		client := newClientContext()
+		for key, se := range entries {
+			e := &entry{value: se.Value, rawSize: len(se.Value)}
+			if se.Compressed != nil {
+				e.value = string(se.Compressed)
+				e.compressed = true
+				e.rawSize = se.RawSize
+			}
+			if se.ExpiresAt != nil {
+				e.expiresAt = *se.ExpiresAt
+			}
+			if se.NotifyExpiry && se.SetAt != nil {
+				e.notifyExpiry = true
+				e.setAt = *se.SetAt
+			}
+
+			if !e.expired(now) {
+				s.putEntry(client, key, e)
+				if se.Revision != 0 {
+					e.revision = se.Revision
+				}
+			}
+		}
+
+		if len(client.entries) > 0 {
+			s.contexts[clientID] = client
+		}
+	}
+
+	for _, sched := range snap.Schedules {
+		s.schedules[sched.ID] = sched
+	}
+	s.runSchedules(now, true)
+
+	return nil
+}
+
+// snapshot copies the store contents into their on-disk form
+func (s *ContextStore) snapshot() snapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	snap := snapshot{
+		Version:      snapshotVersion,
+		Clients:      make(map[string]map[string]snapshotEntry, len(s.contexts)),
+		NextSchedule: s.scheduleSeq,
+		Revision:     s.revision,
+	}
+
+	for _, sched := range s.schedules {
+		snap.Schedules = append(snap.Schedules, sched)
+	}
+	sortSchedules(snap.Schedules)
+
+	for clientID, client := range s.contexts {
+		// Server statistics are republished after a restart
+		if clientID == ServerClientID {
+			continue
+		}
+		entries := make(map[string]snapshotEntry, len(client.entries))
+		for key, e := range client.entries {
+			se := snapshotEntry{Revision: e.revision}
+			if e.compressed {
+				se.Compressed = []byte(e.value)
+				se.RawSize = e.rawSize
+			} else {
+				se.Value = e.value
+			}
+			if !e.expiresAt.IsZero() {
+				expiresAt := e.expiresAt
+				se.ExpiresAt = &expiresAt
+			}
+			if e.notifyExpiry {
+				setAt := e.setAt
+				se.NotifyExpiry = true
+				se.SetAt = &setAt
+			}
+			entries[key] = se
+		}
+		snap.Clients[clientID] = entries
+	}
+
+	return snap
+}
+
+// writeFileAtomic writes data to a temporary file next to path, syncs it and
+// renames it over path. On any failure the temporary file is removed.
+func writeFileAtomic(path string, data []byte) (err error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary snapshot: %v", err)
+	}
+
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	if err = tmp.Sync(); err != nil {
+		return fmt.Errorf("failed to sync snapshot: %v", err)
+	}
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close snapshot: %v", err)
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace snapshot: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSaveLoadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	clients := map[string]map[string]string{
+		"alice": {"name": "Ålice", "greeting": "こんにちは", "emoji": "🚀✨"},
+		"bob":   {"region": "eu-west", "note": "line one\nline two;with=separators"},
+		"zoë 🙂": {"empty": "", "rtl": "مرحبا"},
+	}
+
+	s := NewContextStore()
+	for clientID, values := range clients {
+		s.SetMultiple(clientID, values)
+	}
+	s.SetWithTTL("bob", "lease", "held", time.Hour, false)
+	if err := s.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewContextStore()
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+
+	clients["bob"]["lease"] = "held"
+	for clientID, want := range clients {
+		got, _ := loaded.GetAll(clientID)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: loaded %q, want %q", clientID, got, want)
+		}
+	}
+	if ttl, ok := loaded.TTL("bob", "lease"); !ok || ttl <= 0 || ttl > time.Hour {
+		t.Errorf("TTL after load = %v, %v", ttl, ok)
+	}
+}
+
+func TestLoadSkipsValuesExpiredOnDisk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	clock := newFakeClock()
+
+	s := NewContextStore(WithClock(clock.Now))
+	s.SetWithTTL("c", "short", "s", time.Minute, false)
+	s.Set("c", "kept", "k")
+	if err := s.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(time.Hour)
+	loaded := NewContextStore(WithClock(clock.Now))
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if all, _ := loaded.GetAll("c"); len(all) != 1 || all["kept"] != "k" {
+		t.Fatalf("loaded %v, want only the unexpired key", all)
+	}
+}
+
+func TestFailedSaveLeavesNoPartialFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "store.json")
+
+	// A non-empty directory where the snapshot goes makes the rename fail
+	// after the temporary file has been written
+	if err := os.MkdirAll(filepath.Join(path, "blocker"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewContextStore()
+	s.Set("c", "k", "v")
+	if err := s.Save(path); err == nil {
+		t.Fatal("Save over a directory succeeded")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "store.json" {
+		t.Fatalf("directory holds %v, want no temporary files left", entries)
+	}
+}
+
+func TestLoadMissingOrCorruptSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	s := NewContextStore()
+	s.Set("c", "k", "v")
+
+	if err := s.Load(filepath.Join(dir, "missing.json")); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Load of a missing file = %v, want ErrNotExist", err)
+	}
+
+	corrupt := filepath.Join(dir, "corrupt.json")
+	if err := os.WriteFile(corrupt, []byte(`{"version":1,"clients":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Load(corrupt); err == nil {
+		t.Fatal("Load of a truncated snapshot succeeded")
+	}
+
+	future := filepath.Join(dir, "future.json")
+	if err := os.WriteFile(future, []byte(`{"version":99,"clients":{}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Load(future); err == nil {
+		t.Fatal("Load of an unknown snapshot version succeeded")
+	}
+
+	// A failed load leaves the store as it was
+	if value, ok := s.Get("c", "k"); !ok || value != "v" {
+		t.Fatalf("store changed by failed loads: %q, %v", value, ok)
+	}
+}
+
+func TestSaveWhileWriting(t *testing.T) {
+	dir := t.TempDir()
+	s := NewContextStore()
+	s.Set("c", "base", "0")
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				s.Set(fmt.Sprintf("writer%d", w), fmt.Sprintf("k%d", i%50), fmt.Sprint(i))
+			}
+		}(w)
+	}
+
+	for i := 0; i < 20; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("snap%d.json", i))
+		if err := s.Save(path); err != nil {
+			t.Fatal(err)
+		}
+		loaded := NewContextStore()
+		if err := loaded.Load(path); err != nil {
+			t.Fatalf("snapshot %d does not load: %v", i, err)
+		}
+		if value, _ := loaded.Get("c", "base"); value != "0" {
+			t.Fatalf("snapshot %d lost the untouched key", i)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	// Once writers stop, a snapshot matches the store exactly
+	path := filepath.Join(dir, "final.json")
+	if err := s.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded := NewContextStore()
+	if err := loaded.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, clientID := range s.ListClients() {
+		want, _ := s.GetAll(clientID)
+		got, _ := loaded.GetAll(clientID)
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: loaded %v, want %v", clientID, got, want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package state
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/errs"
+)
+
+// ScheduleOp is the operation a schedule performs when it fires
+type ScheduleOp string
+
+const (
+	// ScheduleOpSet sets the key to the scheduled value
+	ScheduleOpSet ScheduleOp = "set"
+	// ScheduleOpDelete removes the key
+	ScheduleOpDelete ScheduleOp = "delete"
+)
+
+// Schedule is a context operation deferred until FireAt
+type Schedule struct {
+	ID       string     `json:"id"`
+	ClientID string     `json:"client"`
+	Key      string     `json:"key"`
+	Op       ScheduleOp `json:"op"`
+	Value    string     `json:"value,omitempty"` // only used by ScheduleOpSet
+	FireAt   time.Time  `json:"fire_at"`
+}
+
+// ScheduleSet arranges for key to be set to value at fireAt and returns the
+// schedule ID. Schedules are executed by the sweeper, so they fire up to one
+// sweep interval late. A direct write or removal of the key before then
+// cancels the schedule.
+func (s *ContextStore) ScheduleSet(clientID, key, value string, fireAt time.Time) string {
+	return s.schedule(Schedule{ClientID: clientID, Key: key, Op: ScheduleOpSet, Value: value, FireAt: fireAt})
+}
+
+// ScheduleDelete arranges for key to be removed at fireAt and returns the
+// schedule ID, with the same semantics as ScheduleSet
+func (s *ContextStore) ScheduleDelete(clientID, key string, fireAt time.Time) string {
+	return s.schedule(Schedule{ClientID: clientID, Key: key, Op: ScheduleOpDelete, FireAt: fireAt})
+}
+
+// schedule assigns an ID to sched and records it
+func (s *ContextStore) schedule(sched Schedule) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scheduleSeq++
+	sched.ID = fmt.Sprintf("sched-%d", s.scheduleSeq)
+	s.schedules[sched.ID] = sched
+
+	return sched.ID
+}
+
+// CancelSchedule cancels a pending schedule owned by clientID, returning
+// errs.ErrNotFound if there is no such schedule
+func (s *ContextStore) CancelSchedule(clientID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sched, exists := s.schedules[id]
+	if !exists || sched.ClientID != clientID {
+		return errs.New(errs.ErrNotFound, "no pending schedule %s", id)
+	}
+
+	delete(s.schedules, id)
+	return nil
+}
+
+// Schedules returns the pending schedules owned by clientID, earliest first
+func (s *ContextStore) Schedules(clientID string) []Schedule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var scheds []Schedule
+	for _, sched := range s.schedules {
+		if sched.ClientID == clientID {
+			scheds = append(scheds, sched)
+		}
+	}
+
+	sortSchedules(scheds)
+	return scheds
+}
+
+// supersedeSchedules cancels the schedules pending for a key after a direct
+// write or removal. Callers must hold s.mu.
+func (s *ContextStore) supersedeSchedules(clientID, key string) {
+	for id, sched := range s.schedules {
+		if sched.ClientID == clientID && sched.Key == key {
+			delete(s.schedules, id)
+		}
+	}
+}
+
+// runSchedules executes every schedule due at now in firing order and
+// returns how many ran. Overdue marks schedules that should have fired while
+// the server was down. Callers must hold s.mu.
+func (s *ContextStore) runSchedules(now time.Time, overdue bool) int {
+	var due []Schedule
+	for _, sched := range s.schedules {
+		if !now.Before(sched.FireAt) {
+			due = append(due, sched)
+		}
+	}
+
+	sortSchedules(due)
+	for _, sched := range due {
+		delete(s.schedules, sched.ID)
+		s.fire(sched, overdue)
+	}
+
+	return len(due)
+}
+
+// fire applies a schedule's operation and notifies subscribers. Callers
+// must hold s.mu.
+func (s *ContextStore) fire(sched Schedule, overdue bool) {
+	event := ContextEvent{
+		ClientID:  sched.ClientID,
+		Key:       sched.Key,
+		Scheduled: true,
+		Overdue:   overdue,
+	}
+
+	switch sched.Op {
+	case ScheduleOpSet:
+		event.OldValue = s.setEntry(sched.ClientID, sched.Key, sched.Value, time.Time{})
+		event.Value = sched.Value
+
+	case ScheduleOpDelete:
+		client, exists := s.contexts[sched.ClientID]
+		if !exists {
+			return
+		}
+		e, exists := client.entries[sched.Key]
+		if !exists {
+			return
+		}
+		if !e.expired(s.now()) {
+			event.OldValue, _ = s.load(e)
+		}
+
+		s.deleteEntry(client, sched.Key)
+		if len(client.entries) == 0 {
+			delete(s.contexts, sched.ClientID)
+		}
+		event.Deleted = true
+	}
+
+	s.notify(event)
+}
+
+// sortSchedules orders schedules by firing time, then in the order they
+// were made
+func sortSchedules(scheds []Schedule) {
+	sort.Slice(scheds, func(i, j int) bool {
+		if !scheds[i].FireAt.Equal(scheds[j].FireAt) {
+			return scheds[i].FireAt.Before(scheds[j].FireAt)
+		}
+		return scheduleSeq(scheds[i].ID) < scheduleSeq(scheds[j].ID)
+	})
+}
+
+// scheduleSeq returns the sequence number in a schedule ID, so that
+// sched-9 orders before sched-10
+func scheduleSeq(id string) uint64 {
+	seq, _ := strconv.ParseUint(strings.TrimPrefix(id, "sched-"), 10, 64)
+	return seq
+}
@@ -0,0 +1,129 @@
+package state
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/errs"
+)
+
+func TestCancelSchedule(t *testing.T) {
+	clock := newFakeClock()
+	s := NewContextStore(WithClock(clock.Now))
+	id := s.ScheduleSet("c", "k", "later", clock.Now().Add(10*time.Second))
+
+	if err := s.CancelSchedule("other", id); !errors.Is(err, errs.ErrNotFound) {
+		t.Fatalf("another client cancelled the schedule: %v", err)
+	}
+	if err := s.CancelSchedule("c", id); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CancelSchedule("c", id); !errors.Is(err, errs.ErrNotFound) {
+		t.Fatalf("second cancel = %v, want not found", err)
+	}
+
+	clock.Advance(time.Minute)
+	s.Sweep()
+	if value, ok := s.Get("c", "k"); ok {
+		t.Fatalf("cancelled schedule set k to %q", value)
+	}
+}
+
+func TestOverdueSchedulesFireOnLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	clock := newFakeClock()
+	s := NewContextStore(WithClock(clock.Now))
+	s.Set("c", "old", "1")
+	s.ScheduleSet("c", "k", "v", clock.Now().Add(10*time.Second))
+	s.ScheduleDelete("c", "old", clock.Now().Add(20*time.Second))
+	pending := s.ScheduleSet("c", "future", "f", clock.Now().Add(time.Hour))
+	if err := s.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// The server was down while the first two fell due
+	clock.Advance(time.Minute)
+	restarted := NewContextStore(WithClock(clock.Now))
+	events := make(chan ContextEvent, 4)
+	restarted.Subscribe("watcher", Subscription{Prefix: true}, events)
+	if err := restarted.Load(path); err != nil {
+		t.Fatal(err)
+	}
+
+	all, _ := restarted.GetAll("c")
+	if len(all) != 1 || all["k"] != "v" {
+		t.Fatalf("context after load = %v, want only k=v", all)
+	}
+	// Load notifies as it runs them, in firing order
+	for _, key := range []string{"k", "old"} {
+		select {
+		case event := <-events:
+			if event.Key != key || !event.Scheduled || !event.Overdue {
+				t.Fatalf("event %+v, want an overdue scheduled change to %s", event, key)
+			}
+		default:
+			t.Fatalf("no event for %s by the time Load returned", key)
+		}
+	}
+	if scheds := restarted.Schedules("c"); len(scheds) != 1 || scheds[0].ID != pending {
+		t.Fatalf("pending schedules = %v, want only %s", scheds, pending)
+	}
+
+	// Schedules made after the restart do not reuse IDs
+	if id := restarted.ScheduleSet("c", "next", "n", clock.Now().Add(time.Hour)); id == pending {
+		t.Fatalf("new schedule reused ID %s", id)
+	}
+}
+
+func TestDirectWriteSupersedesSchedule(t *testing.T) {
+	clock := newFakeClock()
+	s := NewContextStore(WithClock(clock.Now))
+	fireAt := clock.Now().Add(10 * time.Second)
+	s.ScheduleSet("c", "k", "scheduled", fireAt)
+	s.ScheduleSet("c", "other", "scheduled", fireAt)
+	s.Set("gone", "k", "1")
+	s.ScheduleSet("gone", "k", "scheduled", fireAt)
+
+	s.Set("c", "k", "direct")
+	s.Remove("gone", "k")
+	if scheds := s.Schedules("c"); len(scheds) != 1 || scheds[0].Key != "other" {
+		t.Fatalf("schedules after the write = %v, want only other's", scheds)
+	}
+
+	clock.Advance(time.Minute)
+	s.Sweep()
+	if value, _ := s.Get("c", "k"); value != "direct" {
+		t.Fatalf("k = %q, want the direct write kept", value)
+	}
+	if _, ok := s.Get("gone", "k"); ok {
+		t.Fatal("superseded schedule restored a removed key")
+	}
+	if value, _ := s.Get("c", "other"); value != "scheduled" {
+		t.Fatalf("other = %q, want its schedule to have fired", value)
+	}
+}
+
+func TestSchedulesDueTogetherFireInOrder(t *testing.T) {
+	clock := newFakeClock()
+	s := NewContextStore(WithClock(clock.Now))
+	fireAt := clock.Now().Add(time.Second)
+	for i := 1; i <= 12; i++ {
+		s.ScheduleSet("c", "k", strconv.Itoa(i), fireAt)
+	}
+
+	scheds := s.Schedules("c")
+	for i, sched := range scheds {
+		if want := "sched-" + strconv.Itoa(i+1); sched.ID != want {
+			t.Fatalf("Schedules()[%d] = %s, want %s", i, sched.ID, want)
+		}
+	}
+
+	clock.Advance(time.Second)
+	s.Sweep()
+	if value, _ := s.Get("c", "k"); value != "12" {
+		t.Fatalf("k = %q, want the last schedule made to fire last", value)
+	}
+}
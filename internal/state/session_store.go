@@ -0,0 +1,131 @@
+package state
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// tokenBytes is the number of random bytes in a reconnect token
+const tokenBytes = 32
+
+// session maps a reconnect token to the client it resumes
+type session struct {
+	clientID  string
+	expiresAt time.Time
+}
+
+// SessionStore provides a thread-safe store of reconnect tokens, each mapping
+// to a client ID until it expires
+type SessionStore struct {
+	sessions  map[string]session
+	random    io.Reader // source of token bytes, crypto/rand's Reader
+	mu        sync.Mutex
+	stopChan  chan struct{}
+	stopOnce  sync.Once
+	startOnce sync.Once
+}
+
+// NewSessionStore creates a new empty session store
+func NewSessionStore() *SessionStore {
+	return &SessionStore{
+		sessions: make(map[string]session),
+		random:   rand.Reader,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Issue creates a cryptographically random token that resolves to clientID
+// until ttl elapses. It fails if the system's secure random source does,
+// as there is no safe fallback for generating tokens.
+func (s *SessionStore) Issue(clientID string, ttl time.Duration) (string, error) {
+	buf := make([]byte, tokenBytes)
+	if _, err := io.ReadFull(s.random, buf); err != nil {
+		return "", fmt.Errorf("failed to generate reconnect token: %v", err)
+	}
+	token := hex.EncodeToString(buf)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[token] = session{
+		clientID:  clientID,
+		expiresAt: time.Now().Add(ttl),
+	}
+
+	return token, nil
+}
+
+// Resolve returns the client ID a token maps to. Expired tokens do not
+// resolve, even if the sweeper has not removed them yet.
+func (s *SessionStore) Resolve(token string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, exists := s.sessions[token]
+	if !exists {
+		return "", false
+	}
+
+	if !time.Now().Before(sess.expiresAt) {
+		delete(s.sessions, token)
+		return "", false
+	}
+
+	return sess.clientID, true
+}
+
+// Revoke invalidates a token before its expiry
+func (s *SessionStore) Revoke(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, token)
+}
+
+// Sweep removes all expired tokens and returns how many were removed
+func (s *SessionStore) Sweep() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	removed := 0
+	for token, sess := range s.sessions {
+		if !now.Before(sess.expiresAt) {
+			delete(s.sessions, token)
+			removed++
+		}
+	}
+
+	return removed
+}
+
+// StartSweeper periodically removes expired tokens in the background until
+// StopSweeper is called
+func (s *SessionStore) StartSweeper(interval time.Duration) {
+	s.startOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-s.stopChan:
+					return
+				case <-ticker.C:
+					s.Sweep()
+				}
+			}
+		}()
+	})
+}
+
+// StopSweeper stops the background sweeper
+func (s *SessionStore) StopSweeper() {
+	s.stopOnce.Do(func() {
+		close(s.stopChan)
+	})
+}
@@ -0,0 +1,68 @@
+package state
+
+import (
+	"errors"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestSessionTokenResolvesToClient(t *testing.T) {
+	s := NewSessionStore()
+
+	token, err := s.Issue("agent-7", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(token) != 2*tokenBytes {
+		t.Fatalf("token %q is not %d hex digits", token, 2*tokenBytes)
+	}
+	if clientID, ok := s.Resolve(token); !ok || clientID != "agent-7" {
+		t.Fatalf("Resolve = %q, %v", clientID, ok)
+	}
+
+	other, _ := s.Issue("agent-7", time.Minute)
+	if other == token {
+		t.Fatal("two tokens for one client are equal")
+	}
+
+	s.Revoke(token)
+	if _, ok := s.Resolve(token); ok {
+		t.Fatal("revoked token still resolves")
+	}
+	if _, ok := s.Resolve("not-a-token"); ok {
+		t.Fatal("unknown token resolves")
+	}
+}
+
+func TestSessionTokenExpires(t *testing.T) {
+	s := NewSessionStore()
+
+	expiring, _ := s.Issue("a", 20*time.Millisecond)
+	lasting, _ := s.Issue("b", time.Minute)
+	time.Sleep(30 * time.Millisecond)
+
+	if _, ok := s.Resolve(expiring); ok {
+		t.Fatal("token resolves after its TTL")
+	}
+	if removed := s.Sweep(); removed != 0 {
+		t.Fatalf("Sweep removed %d tokens after Resolve dropped the expired one", removed)
+	}
+	if _, ok := s.Resolve(lasting); !ok {
+		t.Fatal("unexpired token no longer resolves")
+	}
+}
+
+func TestSessionTokenFailsWithoutRandomness(t *testing.T) {
+	s := NewSessionStore()
+	broken := errors.New("entropy pool unavailable")
+	s.random = iotest.ErrReader(broken)
+
+	token, err := s.Issue("a", time.Minute)
+	if err == nil || token != "" {
+		t.Fatalf("Issue = %q, %v; want an error", token, err)
+	}
+	if len(s.sessions) != 0 {
+		t.Fatal("a failed Issue stored a session")
+	}
+}
@@ -0,0 +1,257 @@
+package state
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Artimus100/mcp-server-go/internal/errs"
+)
+
+// ContextEvent describes a change to a single context key
+type ContextEvent struct {
+	ClientID string
+	Key      string
+	OldValue string // empty if the key was not previously set
+	Value    string
+	Deleted  bool // the key was removed rather than set
+
+	// Version is the store revision of the change; see SubscribeSince
+	Version uint64
+
+	// Scheduled marks changes made by a schedule firing; Overdue marks
+	// schedules that fell due while the server was down
+	Scheduled bool
+	Overdue   bool
+
+	// Replayed marks changes replayed from the journal by
+	// ResumeSubscriptions
+	Replayed bool
+
+	// Dropped is the number of events dropped before this one because the
+	// watcher receiving it was not keeping up; see Watch
+	Dropped uint64
+}
+
+// Subscription selects the context changes delivered to a subscriber
+type Subscription struct {
+	// Key is the exact key to watch, or a key prefix if Prefix is set
+	Key    string
+	Prefix bool
+	// ClientID restricts the subscription to changes made by one client;
+	// empty matches changes made by any client
+	ClientID string
+}
+
+// matches reports whether an event is selected by the subscription
+func (sub Subscription) matches(event ContextEvent) bool {
+	if sub.ClientID != "" && sub.ClientID != event.ClientID {
+		return false
+	}
+	if sub.Prefix {
+		return strings.HasPrefix(event.Key, sub.Key)
+	}
+	return sub.Key == event.Key
+}
+
+// subscription is a registered Subscription and the channel it feeds
+type subscription struct {
+	Subscription
+	ch chan<- ContextEvent
+}
+
+// Subscribe registers ch to receive an event for every change selected by
+// sub. Delivery is at-most-once with no replay: events are sent without
+// blocking, so if ch is full the event is dropped, and changes made before
+// the subscription was registered are never delivered. Registering the same
+// subscription again replaces its channel.
+func (s *ContextStore) Subscribe(subscriberID string, sub Subscription, ch chan<- ContextEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subscribe(subscriberID, sub, ch)
+}
+
+// SubscribeSince registers a subscription as Subscribe does and returns the
+// current values selected by sub that were set after revision since, then
+// the store's current revision. A subscriber that passes the Version of the
+// last event it saw thereby catches up on changes made while it was away
+// without being resent unchanged keys. Keys removed since then, and not
+// set again, are reported as Deleted events taken from the journal; gap
+// reports that the journal no longer reaches back to since, so removals
+// may be missing. Events are in revision order. Registration and the read
+// happen under one lock, so every later change is delivered on ch.
+func (s *ContextStore) SubscribeSince(subscriberID string, sub Subscription, ch chan<- ContextEvent, since uint64) (events []ContextEvent, version uint64, gap bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.subscribe(subscriberID, sub, ch)
+
+	now := s.now()
+	for clientID, client := range s.contexts {
+		for key, e := range client.entries {
+			if e.revision <= since || e.expired(now) {
+				continue
+			}
+			event := ContextEvent{ClientID: clientID, Key: key, Version: e.revision}
+			if !sub.matches(event) {
+				continue
+			}
+			value, err := s.load(e)
+			if err != nil {
+				continue
+			}
+			event.Value = value
+			events = append(events, event)
+		}
+	}
+
+	// The last removal of each key that is still gone
+	removed := make(map[[2]string]ContextEvent)
+	s.journal.each(func(event ContextEvent) {
+		if event.Version <= since || !sub.matches(event) {
+			return
+		}
+		id := [2]string{event.ClientID, event.Key}
+		if event.Deleted {
+			removed[id] = event
+		} else {
+			delete(removed, id)
+		}
+	})
+	for _, event := range removed {
+		events = append(events, event)
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Version < events[j].Version
+	})
+
+	return events, s.revision, since < s.journal.evicted
+}
+
+// subscribe registers a subscription. Callers must hold s.mu.
+func (s *ContextStore) subscribe(subscriberID string, sub Subscription, ch chan<- ContextEvent) {
+	subs := s.subs[subscriberID]
+	for i := range subs {
+		if subs[i].Subscription == sub {
+			subs[i].ch = ch
+			return
+		}
+	}
+
+	s.subs[subscriberID] = append(subs, subscription{Subscription: sub, ch: ch})
+}
+
+// OnEventDropped registers fn to be called with the subscriber ID whenever
+// an event is dropped because the subscriber's channel is full. It is
+// called with the store locked, so it must not use the store. Only the last
+// function registered is called.
+func (s *ContextStore) OnEventDropped(fn func(subscriberID string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onDropped = fn
+}
+
+// Unsubscribe removes a subscriber's registration for sub, returning
+// errs.ErrNotFound if it was not registered
+func (s *ContextStore) Unsubscribe(subscriberID string, sub Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subs := s.subs[subscriberID]
+	for i := range subs {
+		if subs[i].Subscription == sub {
+			subs = append(subs[:i], subs[i+1:]...)
+			if len(subs) == 0 {
+				delete(s.subs, subscriberID)
+			} else {
+				s.subs[subscriberID] = subs
+			}
+			return nil
+		}
+	}
+
+	return errs.New(errs.ErrNotFound, "no matching subscription")
+}
+
+// UnsubscribeAll removes every registration held by a subscriber, including
+// one made with Watch
+func (s *ContextStore) UnsubscribeAll(subscriberID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.subs, subscriberID)
+	delete(s.watchers, subscriberID)
+}
+
+// SubscriptionCount returns the number of registered subscriptions across
+// all subscribers. A server with no connections should report zero; anything
+// else is a subscription leaked by a connection that did not clean up.
+func (s *ContextStore) SubscriptionCount() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, subs := range s.subs {
+		count += len(subs)
+	}
+	return count
+}
+
+// notify fans an event out to matching subscribers without blocking. A
+// subscriber with several matching subscriptions receives the event once.
+// Callers must hold s.mu and have just made the change.
+func (s *ContextStore) notify(event ContextEvent) {
+	event.Version = s.revision
+	s.journal.add(event)
+	s.notifyWatchers(event)
+	for subscriberID, subs := range s.subs {
+		for _, sub := range subs {
+			if !sub.matches(event) {
+				continue
+			}
+
+			select {
+			case sub.ch <- event:
+			default:
+				// Subscriber is not keeping up; drop the event
+				if s.onDropped != nil {
+					s.onDropped(subscriberID)
+				}
+			}
+			break
+		}
+	}
+}
+
+// MoveSubscriptions transfers every subscription of subscriber from to
+// subscriber to, delivering to ch from now on, and returns how many were
+// moved. Events still waiting on queued, the channel from's subscriptions
+// fed, are moved onto ch ahead of later ones as far as ch has room; the
+// rest are dropped, as is any event a concurrent reader of queued takes.
+func (s *ContextStore) MoveSubscriptions(from, to string, queued <-chan ContextEvent, ch chan<- ContextEvent) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	moved := s.subs[from]
+	delete(s.subs, from)
+	for _, sub := range moved {
+		s.subscribe(to, sub.Subscription, ch)
+	}
+
+	// Nothing sends on queued once it is unregistered, so this drains it
+	for {
+		select {
+		case event := <-queued:
+			select {
+			case ch <- event:
+			default:
+				if s.onDropped != nil {
+					s.onDropped(to)
+				}
+			}
+		default:
+			return len(moved)
+		}
+	}
+}
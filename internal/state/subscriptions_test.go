@@ -0,0 +1,105 @@
+package state
+
+import (
+	"testing"
+)
+
+// eventKeys summarises events as key, or -key for a removal, in order
+func eventKeys(events []ContextEvent) []string {
+	keys := make([]string, len(events))
+	for i, event := range events {
+		keys[i] = event.Key
+		if event.Deleted {
+			keys[i] = "-" + event.Key
+		}
+	}
+	return keys
+}
+
+func equalKeys(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// subscribeSince subscribes s to prefix "k" since the given version
+func subscribeSince(s *ContextStore, since uint64) ([]ContextEvent, uint64, bool) {
+	return s.SubscribeSince("sub", Subscription{Key: "k", Prefix: true}, make(chan ContextEvent, 16), since)
+}
+
+func TestSubscribeSinceSendsOnlyChangedKeys(t *testing.T) {
+	s := NewContextStore()
+	s.Set("c", "k1", "a")
+	s.Set("c", "k2", "b")
+	_, seen, _ := subscribeSince(s, ^uint64(0))
+
+	s.Set("c", "k2", "b2")
+	s.Set("c", "k3", "c")
+	s.Set("c", "other", "x")
+
+	events, version, gap := subscribeSince(s, seen)
+	if got := eventKeys(events); !equalKeys(got, []string{"k2", "k3"}) {
+		t.Fatalf("backfill %v, want [k2 k3]", got)
+	}
+	if events[0].Value != "b2" {
+		t.Fatalf("k2 backfilled as %q", events[0].Value)
+	}
+	if gap {
+		t.Fatal("gap reported with a journal covering every change")
+	}
+	if version <= seen {
+		t.Fatalf("version %d not past %d", version, seen)
+	}
+
+	if events, _, _ := subscribeSince(s, 0); len(events) != 3 {
+		t.Fatalf("since 0 backfilled %v, want every selected key", eventKeys(events))
+	}
+}
+
+func TestSubscribeSinceReportsRemovals(t *testing.T) {
+	s := NewContextStore()
+	s.Set("c", "k1", "a")
+	s.Set("c", "k2", "b")
+	s.Set("c", "k3", "c")
+	_, seen, _ := subscribeSince(s, ^uint64(0))
+
+	s.Remove("c", "k1")
+	s.Remove("c", "k2")
+	s.Set("c", "k2", "back")
+
+	events, _, gap := subscribeSince(s, seen)
+	if got := eventKeys(events); !equalKeys(got, []string{"-k1", "k2"}) {
+		t.Fatalf("backfill %v, want [-k1 k2]", got)
+	}
+	if events[0].OldValue != "a" {
+		t.Fatalf("removal of k1 carries old value %q", events[0].OldValue)
+	}
+	if gap {
+		t.Fatal("gap reported with a journal covering every change")
+	}
+}
+
+func TestSubscribeSinceReportsGapBeyondJournal(t *testing.T) {
+	s := NewContextStore(WithJournal(2))
+	s.Set("c", "k1", "a")
+	_, seen, _ := subscribeSince(s, ^uint64(0))
+
+	s.Remove("c", "k1")
+	for _, value := range []string{"1", "2", "3"} {
+		s.Set("c", "k2", value)
+	}
+
+	events, _, gap := subscribeSince(s, seen)
+	if !gap {
+		t.Fatal("no gap reported once the journal lost the removal")
+	}
+	if got := eventKeys(events); !equalKeys(got, []string{"k2"}) {
+		t.Fatalf("backfill %v, want [k2]", got)
+	}
+}
@@ -0,0 +1,45 @@
+package state
+
+// watcher is a channel registered with Watch and the number of events
+// dropped since one was last delivered to it
+type watcher struct {
+	ch      chan<- ContextEvent
+	dropped uint64
+}
+
+// Watch registers ch to receive an event for every change to any client's
+// context, replacing a channel the watcher registered before. As with
+// Subscribe, events are sent without blocking and dropped if ch is full;
+// the next event delivered reports how many were dropped in Dropped.
+// UnsubscribeAll removes the watcher along with its subscriptions.
+func (s *ContextStore) Watch(watcherID string, ch chan<- ContextEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.watchers[watcherID] = &watcher{ch: ch}
+}
+
+// Unwatch removes a channel registered with Watch
+func (s *ContextStore) Unwatch(watcherID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.watchers, watcherID)
+}
+
+// notifyWatchers sends an event to every watcher without blocking. Callers
+// must hold s.mu.
+func (s *ContextStore) notifyWatchers(event ContextEvent) {
+	for watcherID, w := range s.watchers {
+		event.Dropped = w.dropped
+		select {
+		case w.ch <- event:
+			w.dropped = 0
+		default:
+			w.dropped++
+			if s.onDropped != nil {
+				s.onDropped(watcherID)
+			}
+		}
+	}
+}
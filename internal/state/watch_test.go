@@ -0,0 +1,36 @@
+package state
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestSlowWatcherDropsRatherThanBlocks(t *testing.T) {
+	s := NewContextStore()
+	var dropped int
+	s.OnEventDropped(func(string) { dropped++ })
+
+	ch := make(chan ContextEvent, 2)
+	s.Watch("w", ch)
+
+	// Nothing reads ch, so all but two are dropped; none of these block
+	for i := 0; i < 10; i++ {
+		s.Set("c", "k", strconv.Itoa(i))
+	}
+	if dropped != 8 {
+		t.Fatalf("dropped %d events, want 8", dropped)
+	}
+
+	<-ch
+	<-ch
+	s.Set("c", "k", "after")
+	if event := <-ch; event.Value != "after" || event.Dropped != 8 {
+		t.Fatalf("got %+v, want the next change reporting 8 dropped", event)
+	}
+
+	s.Unwatch("w")
+	s.Set("c", "k", "unwatched")
+	if len(ch) != 0 {
+		t.Fatal("event delivered after Unwatch")
+	}
+}
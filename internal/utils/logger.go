@@ -1,10 +1,12 @@
 package utils
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
+	"io"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -38,14 +40,119 @@ func (l LogLevel) String() string {
 	}
 }
 
+// ParseLevel returns the log level named by s, one of debug, info, warning
+// or error
+func ParseLevel(s string) (LogLevel, error) {
+	switch s {
+	case "debug":
+		return DEBUG, nil
+	case "info":
+		return INFO, nil
+	case "warning":
+		return WARNING, nil
+	case "error":
+		return ERROR, nil
+	default:
+		return INFO, fmt.Errorf("unknown log level %q", s)
+	}
+}
+
+// LogFormat selects how log entries are rendered
+type LogFormat int
+
+// Log formats
+const (
+	// LogFormatText renders "timestamp LEVEL [prefix] message" lines
+	LogFormatText LogFormat = iota
+	// LogFormatJSON renders one JSON object per line
+	LogFormatJSON
+)
+
+// ParseFormat returns the log format named by s, either text or json
+func ParseFormat(s string) (LogFormat, error) {
+	switch s {
+	case "text":
+		return LogFormatText, nil
+	case "json":
+		return LogFormatJSON, nil
+	default:
+		return LogFormatText, fmt.Errorf("unknown log format %q", s)
+	}
+}
+
+// jsonEntry is the shape of a log entry in LogFormatJSON
+type jsonEntry struct {
+	Timestamp string `json:"ts"`
+	Level     string `json:"level"`
+	Component string `json:"component,omitempty"`
+	Message   string `json:"msg"`
+}
+
+// sink is the set of destinations shared by a logger and every logger
+// derived from it. Each record is written whole to every destination under
+// the sink's mutex so records from different loggers never interleave.
+type sink struct {
+	writers []io.Writer
+	mu      sync.Mutex
+}
+
+// write sends a record to every destination. A failing destination does not
+// stop the record reaching the others.
+func (s *sink) write(record []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, w := range s.writers {
+		w.Write(record)
+	}
+}
+
+// add appends a destination
+func (s *sink) add(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writers = append(s.writers, w)
+}
+
+// sync flushes destinations that support it, such as files
+func (s *sink) sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var firstErr error
+	for _, w := range s.writers {
+		if syncer, ok := w.(interface{ Sync() error }); ok {
+			if err := syncer.Sync(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
 // Logger provides a simple logging interface
 type Logger struct {
 	prefix   string
-	minLevel LogLevel
-	logger   *log.Logger
+	minLevel *atomic.Int32 // shared with derived loggers; holds a LogLevel
+	format   *atomic.Int32 // shared with derived loggers; holds a LogFormat
+	out      *sink
 	mu       sync.Mutex
 }
 
+// newLevel returns a minimum level holding level
+func newLevel(level LogLevel) *atomic.Int32 {
+	v := new(atomic.Int32)
+	v.Store(int32(level))
+	return v
+}
+
+// newFormat returns an output format holding format
+func newFormat(format LogFormat) *atomic.Int32 {
+	v := new(atomic.Int32)
+	v.Store(int32(format))
+	return v
+}
+
 var (
 	// Default logger
 	defaultLogger *Logger
@@ -56,36 +163,82 @@ var (
 func initDefaultLogger() {
 	defaultLogger = &Logger{
 		prefix:   "",
-		minLevel: INFO,
-		logger:   log.New(os.Stdout, "", 0),
+		minLevel: newLevel(INFO),
+		format:   newFormat(LogFormatText),
+		out:      &sink{writers: []io.Writer{os.Stdout}},
 	}
 }
 
-// NewLogger creates a new logger with the given prefix
+// NewLogger creates a new logger with the given prefix writing to stdout
 func NewLogger(prefix string) *Logger {
+	return NewLoggerTo(os.Stdout, prefix)
+}
+
+// NewLoggerTo creates a new logger with the given prefix writing to w
+func NewLoggerTo(w io.Writer, prefix string) *Logger {
 	once.Do(initDefaultLogger)
 
 	return &Logger{
 		prefix:   prefix,
-		minLevel: defaultLogger.minLevel,
-		logger:   log.New(os.Stdout, "", 0),
+		minLevel: newLevel(LogLevel(defaultLogger.minLevel.Load())),
+		format:   newFormat(LogFormatText),
+		out:      &sink{writers: []io.Writer{w}},
 	}
 }
 
 // WithPrefix returns a new logger with an additional prefix
 func (l *Logger) WithPrefix(prefix string) *Logger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	return &Logger{
 		prefix:   fmt.Sprintf("%s.%s", l.prefix, prefix),
 		minLevel: l.minLevel,
-		logger:   l.logger,
+		format:   l.format,
+		out:      l.out,
 	}
 }
 
-// SetLevel sets the minimum log level
-func (l *Logger) SetLevel(level LogLevel) {
+// AddOutput tees every subsequent record to w as well. Destinations are
+// shared with the logger this one was derived from and every logger derived
+// from either, so the new output receives all of their records.
+func (l *Logger) AddOutput(w io.Writer) {
+	l.out.add(w)
+}
+
+// Sync flushes outputs that support it, such as files. Call it before
+// closing a file output.
+func (l *Logger) Sync() error {
+	return l.out.sync()
+}
+
+// WithFixedLevel returns a logger like l whose minimum level is level and
+// stays so: SetLevel on l or its relatives does not change it, nor does
+// SetLevel on it change theirs. Loggers derived from it share its level.
+func (l *Logger) WithFixedLevel(level LogLevel) *Logger {
 	l.mu.Lock()
 	defer l.mu.Unlock()
-	l.minLevel = level
+
+	return &Logger{
+		prefix:   l.prefix,
+		minLevel: newLevel(level),
+		format:   l.format,
+		out:      l.out,
+	}
+}
+
+// SetLevel sets the minimum log level. The level is shared like the
+// outputs: it applies to the logger this one was derived from and every
+// logger derived from either, including ones derived earlier.
+func (l *Logger) SetLevel(level LogLevel) {
+	l.minLevel.Store(int32(level))
+}
+
+// SetFormat sets the output format. Like the level, the format is shared
+// with the logger this one was derived from and every logger derived from
+// either, including ones derived earlier.
+func (l *Logger) SetFormat(format LogFormat) {
+	l.format.Store(int32(format))
 }
 
 // log logs a message with the given level and arguments
@@ -93,23 +246,36 @@ func (l *Logger) log(level LogLevel, format string, args ...interface{}) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	if level < l.minLevel {
+	if level < LogLevel(l.minLevel.Load()) {
 		return
 	}
 
-	timestamp := time.Now().Format("2006-01-02 15:04:05.000")
-	prefix := l.prefix
-	if prefix != "" {
-		prefix = "[" + prefix + "] "
-	}
-
+	now := time.Now()
 	levelStr := level.String()
 	message := fmt.Sprintf(format, args...)
 
-	l.logger.Printf("%s %s %s%s", timestamp, levelStr, prefix, message)
+	if LogFormat(l.format.Load()) == LogFormatJSON {
+		// Marshalling a struct of strings cannot fail
+		entry, _ := json.Marshal(jsonEntry{
+			Timestamp: now.Format(time.RFC3339Nano),
+			Level:     levelStr,
+			Component: l.prefix,
+			Message:   message,
+		})
+		l.out.write(append(entry, '\n'))
+	} else {
+		timestamp := now.Format("2006-01-02 15:04:05.000")
+		prefix := l.prefix
+		if prefix != "" {
+			prefix = "[" + prefix + "] "
+		}
+
+		l.out.write([]byte(fmt.Sprintf("%s %s %s%s\n", timestamp, levelStr, prefix, message)))
+	}
 
-	// If this is a fatal message, exit the program
+	// If this is a fatal message, exit the program once it is on disk
 	if level == FATAL {
+		l.out.sync()
 		os.Exit(1)
 	}
 }
@@ -140,7 +306,4 @@ func (l *Logger) Fatal(format string, args ...interface{}) {
 }
 
 // TODO: Consider adding additional features:
-// - Log rotation
-// - Output to multiple destinations (file, stdout, etc.)
-// - Structured logging (JSON)
 // - Log filtering by module/component
@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetLevelReachesDerivedLoggers(t *testing.T) {
+	var out bytes.Buffer
+	root := NewLoggerTo(&out, "server")
+	conn := root.WithPrefix("conn[1]")
+
+	root.SetLevel(ERROR)
+	conn.Info("hidden")
+	if out.Len() != 0 {
+		t.Fatalf("INFO logged at ERROR level: %q", out.String())
+	}
+
+	conn.SetLevel(DEBUG)
+	root.Debug("shown")
+	if !strings.Contains(out.String(), "shown") {
+		t.Fatalf("level set on a derived logger did not reach its parent: %q", out.String())
+	}
+}
+
+func TestWithFixedLevelIgnoresSetLevel(t *testing.T) {
+	var out bytes.Buffer
+	root := NewLoggerTo(&out, "server")
+	audit := root.WithPrefix("audit").WithFixedLevel(INFO)
+
+	root.SetLevel(ERROR)
+	audit.Info("kept")
+	if !strings.Contains(out.String(), "[server.audit] kept") {
+		t.Fatalf("fixed-level logger followed SetLevel: %q", out.String())
+	}
+
+	audit.SetLevel(ERROR)
+	root.SetLevel(DEBUG)
+	root.Debug("root")
+	if !strings.Contains(out.String(), "root") {
+		t.Fatal("SetLevel on the fixed-level logger changed its parent")
+	}
+}
+
+// decodeJSONLines unmarshals each line of out as a JSON log entry
+func decodeJSONLines(t *testing.T, out string) []map[string]string {
+	t.Helper()
+
+	var entries []map[string]string
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		var entry map[string]string
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("line %q is not a JSON object of strings: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestJSONFormat(t *testing.T) {
+	var out bytes.Buffer
+	root := NewLoggerTo(&out, "server")
+	root.SetFormat(LogFormatJSON)
+	conn := root.WithPrefix("conn[7]")
+
+	before := time.Now()
+	root.Info("listening on %s", ":8080")
+	conn.Warning("bad line %q\nnext", `a"b`)
+
+	entries := decodeJSONLines(t, out.String())
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2: %q", len(entries), out.String())
+	}
+	want := []map[string]string{
+		{"level": "INFO", "component": "server", "msg": "listening on :8080"},
+		{"level": "WARN", "component": "server.conn[7]", "msg": "bad line \"a\\\"b\"\nnext"},
+	}
+	for i, entry := range entries {
+		ts, err := time.Parse(time.RFC3339Nano, entry["ts"])
+		if err != nil {
+			t.Errorf("entry %d: ts %q is not RFC3339Nano: %v", i, entry["ts"], err)
+		} else if ts.Before(before.Truncate(time.Second)) {
+			t.Errorf("entry %d: ts %v is before the call", i, ts)
+		}
+		delete(entry, "ts")
+		if !reflect.DeepEqual(entry, want[i]) {
+			t.Errorf("entry %d = %v, want %v", i, entry, want[i])
+		}
+	}
+}
+
+func TestSetFormatReachesDerivedLoggers(t *testing.T) {
+	var out bytes.Buffer
+	root := NewLoggerTo(&out, "server")
+	conn := root.WithPrefix("conn[1]")
+	audit := root.WithPrefix("audit").WithFixedLevel(INFO)
+
+	// Derived before the switch, as connection loggers are
+	root.SetFormat(LogFormatJSON)
+	conn.Info("from conn")
+	audit.Info("from audit")
+	entries := decodeJSONLines(t, out.String())
+	if len(entries) != 2 || entries[0]["msg"] != "from conn" || entries[1]["msg"] != "from audit" {
+		t.Fatalf("derived loggers did not switch to JSON: %q", out.String())
+	}
+
+	out.Reset()
+	conn.SetFormat(LogFormatText)
+	root.Info("back to text")
+	if !strings.HasSuffix(out.String(), " INFO [server] back to text\n") {
+		t.Fatalf("format set on a derived logger did not reach its parent: %q", out.String())
+	}
+}
+
+func TestJSONFormatOmitsEmptyComponent(t *testing.T) {
+	var out bytes.Buffer
+	l := NewLoggerTo(&out, "")
+	l.SetFormat(LogFormatJSON)
+	l.Error("plain")
+
+	entry := decodeJSONLines(t, out.String())[0]
+	if _, ok := entry["component"]; ok {
+		t.Fatalf("entry %v has a component", entry)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for name, want := range map[string]LogFormat{"text": LogFormatText, "json": LogFormatJSON} {
+		if got, err := ParseFormat(name); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %v, %v", name, got, err)
+		}
+	}
+	if _, err := ParseFormat("JSON"); err == nil {
+		t.Error("ParseFormat accepted JSON")
+	}
+}
+
+// failingWriter rejects every write
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestAddOutputTeesToEveryDestination(t *testing.T) {
+	var stdout bytes.Buffer
+	path := filepath.Join(t.TempDir(), "server.log")
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	root := NewLoggerTo(&stdout, "server")
+	conn := root.WithPrefix("conn[1]")
+	// Added after conn was derived, and ahead of a broken destination
+	root.AddOutput(failingWriter{})
+	conn.AddOutput(file)
+
+	conn.Info("accepted")
+	if err := root.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	for name, got := range map[string]string{"stdout": stdout.String(), "file": readFile(t, path)} {
+		if strings.Count(got, "\n") != 1 || !strings.Contains(got, "INFO [server.conn[1]] accepted") {
+			t.Errorf("%s holds %q, want the one record", name, got)
+		}
+	}
+}
+
+func TestConcurrentRecordsDoNotInterleave(t *testing.T) {
+	var out bytes.Buffer
+	root := NewLoggerTo(&out, "server")
+	record := strings.Repeat("x", 1000)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(l *Logger) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				l.Info("%s", record)
+			}
+		}(root.WithPrefix(fmt.Sprintf("conn[%d]", g)))
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 400 {
+		t.Fatalf("got %d lines, want 400", len(lines))
+	}
+	for _, line := range lines {
+		if !strings.HasSuffix(line, "] "+record) {
+			t.Fatalf("torn record %.80q", line)
+		}
+	}
+}
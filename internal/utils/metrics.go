@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Metrics receives instrumentation. Label arguments alternate between label
+// names and values, as in Inc("requests_total", "type", "PING"). The server
+// only depends on this interface, so exporters can be swapped without
+// touching instrumented code.
+type Metrics interface {
+	// Inc adds one to a counter
+	Inc(name string, labels ...string)
+	// Observe records a value in a histogram
+	Observe(name string, value float64, labels ...string)
+}
+
+// nopMetrics discards all instrumentation
+type nopMetrics struct{}
+
+func (nopMetrics) Inc(name string, labels ...string)                    {}
+func (nopMetrics) Observe(name string, value float64, labels ...string) {}
+
+// NopMetrics is a Metrics that discards everything, used when metrics are
+// not enabled
+var NopMetrics Metrics = nopMetrics{}
+
+// DefaultBuckets are the histogram bucket upper bounds in seconds, suited
+// to request latencies
+var DefaultBuckets = []float64{0.0005, 0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+
+// histogram accumulates observations into cumulative buckets
+type histogram struct {
+	counts []uint64 // per bucket in DefaultBuckets, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// MetricsRegistry is a Metrics that keeps counters and histograms in memory
+// and serves them, along with gauges read at scrape time, in the Prometheus
+// text exposition format. Series are created on first use.
+type MetricsRegistry struct {
+	counters   map[string]map[string]float64    // name -> rendered labels -> value
+	histograms map[string]map[string]*histogram // name -> rendered labels -> data
+	gauges     map[string]func() float64
+	mu         sync.Mutex
+}
+
+// NewMetricsRegistry creates an empty registry
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{
+		counters:   make(map[string]map[string]float64),
+		histograms: make(map[string]map[string]*histogram),
+		gauges:     make(map[string]func() float64),
+	}
+}
+
+// Inc adds one to a counter
+func (r *MetricsRegistry) Inc(name string, labels ...string) {
+	key := renderLabels(labels)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	series, exists := r.counters[name]
+	if !exists {
+		series = make(map[string]float64)
+		r.counters[name] = series
+	}
+	series[key]++
+}
+
+// Observe records a value in a histogram with DefaultBuckets
+func (r *MetricsRegistry) Observe(name string, value float64, labels ...string) {
+	key := renderLabels(labels)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	series, exists := r.histograms[name]
+	if !exists {
+		series = make(map[string]*histogram)
+		r.histograms[name] = series
+	}
+	h, exists := series[key]
+	if !exists {
+		h = &histogram{counts: make([]uint64, len(DefaultBuckets))}
+		series[key] = h
+	}
+
+	for i, bound := range DefaultBuckets {
+		if value <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+// GaugeFunc registers a gauge whose value is read from fn at every scrape,
+// for values such as connection counts that are cheaper to look up than to
+// track
+func (r *MetricsRegistry) GaugeFunc(name string, fn func() float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gauges[name] = fn
+}
+
+// WritePrometheus writes every metric in the Prometheus text format, sorted
+// by name and labels
+func (r *MetricsRegistry) WritePrometheus(w io.Writer) error {
+	// Gauge functions may take locks of their own, so read them first
+	r.mu.Lock()
+	gauges := make(map[string]func() float64, len(r.gauges))
+	for name, fn := range r.gauges {
+		gauges[name] = fn
+	}
+	r.mu.Unlock()
+
+	var b strings.Builder
+
+	for _, name := range sortedKeys(gauges) {
+		fmt.Fprintf(&b, "# TYPE %s gauge\n%s %s\n", name, name, formatFloat(gauges[name]()))
+	}
+
+	r.mu.Lock()
+	for _, name := range sortedKeys(r.counters) {
+		series := r.counters[name]
+		fmt.Fprintf(&b, "# TYPE %s counter\n", name)
+		for _, labels := range sortedKeys(series) {
+			fmt.Fprintf(&b, "%s%s %s\n", name, labels, formatFloat(series[labels]))
+		}
+	}
+
+	for _, name := range sortedKeys(r.histograms) {
+		series := r.histograms[name]
+		fmt.Fprintf(&b, "# TYPE %s histogram\n", name)
+		for _, labels := range sortedKeys(series) {
+			h := series[labels]
+			var cumulative uint64
+			for i, bound := range DefaultBuckets {
+				cumulative += h.counts[i]
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", name, withLabel(labels, "le", formatFloat(bound)), cumulative)
+			}
+			fmt.Fprintf(&b, "%s_bucket%s %d\n", name, withLabel(labels, "le", "+Inf"), h.count)
+			fmt.Fprintf(&b, "%s_sum%s %s\n", name, labels, formatFloat(h.sum))
+			fmt.Fprintf(&b, "%s_count%s %d\n", name, labels, h.count)
+		}
+	}
+	r.mu.Unlock()
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ServeHTTP serves the metrics for scraping
+func (r *MetricsRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	r.WritePrometheus(w)
+}
+
+// renderLabels renders alternating label names and values as {a="1",b="2"},
+// or an empty string for no labels. A trailing name without a value is
+// ignored.
+func renderLabels(labels []string) string {
+	if len(labels) < 2 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, labels[i]+`="`+escapeLabelValue(labels[i+1])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// withLabel appends a label to already rendered labels
+func withLabel(rendered, name, value string) string {
+	pair := name + `="` + escapeLabelValue(value) + `"`
+	if rendered == "" {
+		return "{" + pair + "}"
+	}
+	return rendered[:len(rendered)-1] + "," + pair + "}"
+}
+
+// labelEscaper escapes label values as the text format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// formatFloat formats a sample value, spelling infinities as Prometheus does
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,163 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is an io.Writer appending to a file that is rotated once it
+// reaches a size limit: the file is renamed to path.1, older backups shift
+// up to path.2 and so on, the oldest beyond maxBackups is removed, and a new
+// file is started at path. A single write is never split across files, so a
+// log record always lands whole in one of them.
+type RotatingFile struct {
+	path       string
+	maxBytes   int64
+	maxBackups int
+
+	file *os.File
+	size int64
+	mu   sync.Mutex
+}
+
+// NewRotatingFile opens path for appending, rotating it whenever a write
+// would take it past maxBytes. A maxBytes of zero never rotates. maxBackups
+// is the number of rotated files kept; with zero the file is simply
+// truncated on rotation.
+func NewRotatingFile(path string, maxBytes int64, maxBackups int) (*RotatingFile, error) {
+	if maxBytes < 0 || maxBackups < 0 {
+		return nil, fmt.Errorf("invalid rotation limits: maxBytes %d, maxBackups %d", maxBytes, maxBackups)
+	}
+
+	r := &RotatingFile{
+		path:       path,
+		maxBytes:   maxBytes,
+		maxBackups: maxBackups,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// open opens the file at path for appending and records its current size
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", r.path, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat %s: %v", r.path, err)
+	}
+
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if p would take the file past the limit.
+// A write larger than the limit on its own goes to a fresh file. If
+// rotation fails, p is still appended to the file at path and the rotation
+// error is returned; the next write that needs to rotate tries again.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	var rotateErr error
+	if r.maxBytes > 0 && r.size > 0 && r.size+int64(len(p)) > r.maxBytes {
+		rotateErr = r.rotate()
+		if r.file == nil {
+			return 0, rotateErr
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+	return n, err
+}
+
+// rotate shifts the backups, moves the current file to path.1 and opens a
+// fresh file. If a step fails, whatever is at path is reopened for
+// appending so writes carry on, and the error is returned. r.file is nil
+// afterwards only if that reopen failed too. Callers must hold r.mu.
+func (r *RotatingFile) rotate() error {
+	err := r.file.Close()
+	r.file = nil
+	if err != nil {
+		err = fmt.Errorf("failed to close %s: %v", r.path, err)
+	} else {
+		err = r.shift()
+	}
+
+	if openErr := r.open(); openErr != nil {
+		if err != nil {
+			return fmt.Errorf("%v; %v", err, openErr)
+		}
+		return openErr
+	}
+	return err
+}
+
+// shift moves the closed file at path out of the way: to path.1, shifting
+// older backups up, or into oblivion if no backups are kept. Callers must
+// hold r.mu.
+func (r *RotatingFile) shift() error {
+	if r.maxBackups == 0 {
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %v", r.path, err)
+		}
+		return nil
+	}
+
+	// The oldest backup falls off the end; renaming over it replaces it
+	for i := r.maxBackups - 1; i >= 1; i-- {
+		err := os.Rename(r.backup(i), r.backup(i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate %s: %v", r.backup(i), err)
+		}
+	}
+	if err := os.Rename(r.path, r.backup(1)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate %s: %v", r.path, err)
+	}
+	return nil
+}
+
+// backup returns the path of the nth most recent backup
+func (r *RotatingFile) backup(n int) string {
+	return fmt.Sprintf("%s.%d", r.path, n)
+}
+
+// Sync flushes the current file to disk
+func (r *RotatingFile) Sync() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	return r.file.Sync()
+}
+
+// Close closes the current file. Further writes fail.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// readFile returns the contents of path, failing the test if it is missing
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestRotatingFileCreatesBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	r, err := NewRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	for _, record := range []string{"first\n", "second\n", "third\n"} {
+		if _, err := r.Write([]byte(record)); err != nil {
+			t.Fatalf("Write %q: %v", record, err)
+		}
+	}
+
+	if got := readFile(t, path); got != "third\n" {
+		t.Errorf("current file = %q", got)
+	}
+	if got := readFile(t, path+".1"); got != "second\n" {
+		t.Errorf("%s.1 = %q", path, got)
+	}
+	if got := readFile(t, path+".2"); got != "first\n" {
+		t.Errorf("%s.2 = %q", path, got)
+	}
+}
+
+func TestRotatingFileDropsOldestBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	r, err := NewRotatingFile(path, 4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	for _, record := range []string{"aaa\n", "bbb\n", "ccc\n"} {
+		r.Write([]byte(record))
+	}
+
+	if got := readFile(t, path+".1"); got != "bbb\n" {
+		t.Errorf("%s.1 = %q", path, got)
+	}
+	if _, err := os.Stat(path + ".2"); !os.IsNotExist(err) {
+		t.Errorf("backup beyond maxBackups exists: %v", err)
+	}
+}
+
+func TestRotatingFileKeepsWritingWhenRotationFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	r, err := NewRotatingFile(path, 4, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// A non-empty directory where the backup should go makes the rename fail
+	if err := os.MkdirAll(filepath.Join(path+".1", "blocker"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	r.Write([]byte("aaa\n"))
+	n, err := r.Write([]byte("bbb\n"))
+	if err == nil {
+		t.Fatal("failed rotation was not reported")
+	}
+	if n != 4 {
+		t.Fatalf("Write wrote %d bytes, want the record appended anyway", n)
+	}
+
+	// Once the obstacle is gone rotation resumes
+	if err := os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Write([]byte("ccc\n")); err != nil {
+		t.Fatalf("Write after recovery: %v", err)
+	}
+	if got := readFile(t, path+".1"); got != "aaa\nbbb\n" {
+		t.Errorf("%s.1 = %q", path, got)
+	}
+	if got := readFile(t, path); got != "ccc\n" {
+		t.Errorf("current file = %q", got)
+	}
+}
+
+func TestLoggerToRotatingFileFromManyGoroutines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	r, err := NewRotatingFile(path, 512, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var stdout bytes.Buffer
+	logger := NewLoggerTo(&stdout, "test")
+	logger.AddOutput(r)
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				logger.Info("record %d", i)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// With room for every backup, each record lands whole in exactly one file
+	files, err := filepath.Glob(path + "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) < 2 {
+		t.Fatalf("no rotation happened: %v", files)
+	}
+	records := 0
+	for _, file := range files {
+		for _, line := range strings.Split(strings.TrimSuffix(readFile(t, file), "\n"), "\n") {
+			if !strings.Contains(line, "record ") {
+				t.Fatalf("torn record %q in %s", line, file)
+			}
+			records++
+		}
+	}
+	if records != 200 {
+		t.Fatalf("%d records across files, want 200", records)
+	}
+	if got := strings.Count(stdout.String(), "\n"); got != 200 {
+		t.Fatalf("%d records on the primary output, want 200", got)
+	}
+}
@@ -0,0 +1,33 @@
+package client
+
+import (
+	"context"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// WithAuthToken makes Dial authenticate with token before the handshake,
+// as servers started with an auth token require
+func WithAuthToken(token string) Option {
+	return func(o *options) {
+		o.authToken = token
+	}
+}
+
+// Principal returns the client name the server authenticated this client
+// as, or "" if it did not authenticate
+func (c *Client) Principal() string {
+	return c.principal
+}
+
+// authenticate sends AUTH with token and records the principal it is
+// accepted as
+func (c *Client) authenticate(token string) error {
+	auth := protocol.NewMessage(protocol.TypeAuth, map[string]string{"token": token})
+	reply, err := c.request(context.Background(), auth, protocol.TypeAck)
+	if err != nil {
+		return err
+	}
+	c.principal = reply.Params["principal"]
+	return nil
+}
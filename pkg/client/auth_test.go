@@ -0,0 +1,24 @@
+package client
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithAuthToken(t *testing.T) {
+	srv, addr := startServer(t, nil)
+	srv.SetAuthTokens(map[string]string{"s3cret": "alice"})
+
+	c := dial(t, addr, WithAuthToken("s3cret"), WithClientID("a"))
+	if c.Principal() != "alice" {
+		t.Fatalf("Principal() = %q, want alice", c.Principal())
+	}
+	if err := c.SetContext(context.Background(), map[string]string{"k": "v"}); err != nil {
+		t.Fatalf("SetContext after AUTH: %v", err)
+	}
+
+	if c, err := Dial(addr, WithTimeout(testTimeout), WithAuthToken("guess")); err == nil {
+		c.Close()
+		t.Fatal("Dial succeeded with a wrong token")
+	}
+}
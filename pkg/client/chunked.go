@@ -0,0 +1,184 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// streams reassembles values the server streams ahead of a GET reply
+type streams struct {
+	current *transfer
+	values  map[string]string
+	err     error // first failure since the last reply
+}
+
+// transfer is a streamed value being received
+type transfer struct {
+	key    string
+	size   int
+	chunks int
+	next   int // index of the next expected chunk
+	data   []byte
+}
+
+func newStreams() *streams {
+	return &streams{values: make(map[string]string)}
+}
+
+// add handles a VALUE_BEGIN, VALUE_CHUNK or VALUE_END message. A failure is
+// remembered and reported with the reply the values were streamed for.
+func (s *streams) add(msg protocol.Message) {
+	if s.err != nil {
+		return
+	}
+	if err := s.addMessage(msg); err != nil {
+		s.err = err
+		s.current = nil
+	}
+}
+
+func (s *streams) addMessage(msg protocol.Message) error {
+	key := msg.Params["key"]
+
+	if msg.Type == protocol.TypeValueBegin {
+		size, err := strconv.Atoi(msg.Params["size"])
+		if err != nil {
+			return fmt.Errorf("malformed size for streamed value %s", key)
+		}
+		chunks, err := strconv.Atoi(msg.Params["chunks"])
+		if err != nil {
+			return fmt.Errorf("malformed chunk count for streamed value %s", key)
+		}
+		s.current = &transfer{key: key, size: size, chunks: chunks, data: make([]byte, 0, size)}
+		return nil
+	}
+
+	t := s.current
+	if t == nil || t.key != key {
+		return fmt.Errorf("unexpected %s for %s", msg.Type, key)
+	}
+
+	if msg.Type == protocol.TypeValueChunk {
+		if t.next >= t.chunks || msg.Params["index"] != strconv.Itoa(t.next) {
+			return fmt.Errorf("expected chunk %d of %s, got %q", t.next, key, msg.Params["index"])
+		}
+		chunk, err := base64.RawStdEncoding.DecodeString(msg.Params["data"])
+		if err != nil {
+			return fmt.Errorf("chunk %d of %s is not valid base64", t.next, key)
+		}
+		t.data = append(t.data, chunk...)
+		t.next++
+		return nil
+	}
+
+	s.current = nil
+	if t.next != t.chunks || len(t.data) != t.size || msg.Params["sha256"] != checksum(t.data) {
+		return fmt.Errorf("streamed value %s failed verification", key)
+	}
+	s.values[key] = string(t.data)
+	return nil
+}
+
+// attach fills the values listed in a reply's _streamed parameter in from
+// the transfers that preceded it, then resets for the next reply
+func (s *streams) attach(msg protocol.Message) (protocol.Message, error) {
+	values, err := s.values, s.err
+	s.values = make(map[string]string)
+	s.err = nil
+	s.current = nil
+	if err != nil {
+		return msg, err
+	}
+
+	list, exists := msg.Params["_streamed"]
+	if !exists {
+		return msg, nil
+	}
+	delete(msg.Params, "_streamed")
+
+	keys, err := protocol.SplitList(list)
+	if err != nil {
+		return msg, fmt.Errorf("malformed list of streamed values: %v", err)
+	}
+	for _, key := range keys {
+		value, exists := values[key]
+		if !exists {
+			return msg, fmt.Errorf("value of %s announced as streamed was not received", key)
+		}
+		msg.Params[key] = value
+	}
+	return msg, nil
+}
+
+// setChunked sends a value as a CONTEXT_BEGIN/CONTEXT_CHUNK/CONTEXT_END
+// transfer sized to the negotiated message limit
+func (c *Client) setChunked(ctx context.Context, key, value string) error {
+	// Leave room for the message type, chunk index and separators;
+	// four base64 characters carry three bytes
+	size := (c.maxMessage - 64 - len(protocol.EscapeValue(key))) / 4 * 3
+	if size <= 0 {
+		return fmt.Errorf("key %s is too long to send within %d bytes", key, c.maxMessage)
+	}
+	chunks := (len(value) + size - 1) / size
+
+	_, err := c.request(ctx, protocol.NewMessage(protocol.TypeContextBegin, map[string]string{
+		"key":    key,
+		"size":   strconv.Itoa(len(value)),
+		"chunks": strconv.Itoa(chunks),
+	}), protocol.TypeAck)
+	if err != nil {
+		return err
+	}
+
+	// Accepted chunks get no reply, but a rejected one draws an ERROR that
+	// the END call receives in place of its own reply. A PING queued behind
+	// END skips whatever is left over, and sending everything under one
+	// hold of writeMu keeps other calls from being queued in between.
+	c.writeMu.Lock()
+	for i := 0; i < chunks; i++ {
+		end := (i + 1) * size
+		if end > len(value) {
+			end = len(value)
+		}
+		err := c.writeLocked(protocol.NewMessage(protocol.TypeContextChunk, map[string]string{
+			"key":   key,
+			"index": strconv.Itoa(i),
+			"data":  base64.RawStdEncoding.EncodeToString([]byte(value[i*size : end])),
+		}))
+		if err != nil {
+			c.writeMu.Unlock()
+			return err
+		}
+	}
+	endCall, err := c.startLocked(protocol.NewMessage(protocol.TypeContextEnd, map[string]string{
+		"key":    key,
+		"sha256": checksum([]byte(value)),
+	}), protocol.TypeAck, false)
+	if err != nil {
+		c.writeMu.Unlock()
+		return err
+	}
+	syncCall, err := c.startLocked(protocol.NewMessage(protocol.TypePing, nil), protocol.TypePong, true)
+	c.writeMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.wait(ctx, endCall)
+	if _, syncErr := c.wait(ctx, syncCall); err == nil {
+		err = syncErr
+	}
+	return err
+}
+
+// checksum returns the hex SHA-256 of data, as carried by END messages
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+func TestLargeValuesRoundTripInChunks(t *testing.T) {
+	_, addr := startServer(t, nil)
+	// A smaller limit than the server's own, so both directions chunk
+	c := dial(t, addr, WithClientID("big"), WithHelloParams(map[string]string{"max_message_size": "1024"}))
+	ctx := context.Background()
+
+	large := strings.Repeat("a;b=c\\", 5000)
+	values := map[string]string{"large": large, "small": "x"}
+	if err := c.SetContext(ctx, values); err != nil {
+		t.Fatalf("SetContext: %v", err)
+	}
+
+	got, err := c.GetContext(ctx)
+	if err != nil {
+		t.Fatalf("GetContext: %v", err)
+	}
+	if got["large"] != large || got["small"] != "x" || len(got) != 2 {
+		t.Fatalf("GetContext returned %d keys, large intact = %v", len(got), got["large"] == large)
+	}
+}
+
+func TestStreamedValueFailingVerification(t *testing.T) {
+	s := newStreams()
+	for _, msg := range []protocol.Message{
+		protocol.NewMessage(protocol.TypeValueBegin, map[string]string{"key": "k", "size": "3", "chunks": "1"}),
+		protocol.NewMessage(protocol.TypeValueChunk, map[string]string{"key": "k", "index": "0", "data": "YWJj"}),
+		protocol.NewMessage(protocol.TypeValueEnd, map[string]string{"key": "k", "sha256": checksum([]byte("abd"))}),
+	} {
+		s.add(msg)
+	}
+
+	reply := protocol.NewMessage(protocol.TypeResult, map[string]string{"_streamed": "k"})
+	if _, err := s.attach(reply); err == nil {
+		t.Fatal("a value failing its checksum was attached")
+	}
+
+	// The failure is not carried over to the next reply
+	if _, err := s.attach(protocol.NewMessage(protocol.TypeResult, map[string]string{"a": "1"})); err != nil {
+		t.Fatalf("next reply: %v", err)
+	}
+}
@@ -0,0 +1,552 @@
+// Package client is a Go client for the MCP server's line protocol. It
+// handles the HELLO handshake, answers server heartbeats, matches replies to
+// requests and turns ERROR replies into Go errors, so tools and integration
+// tests need not speak the protocol by hand.
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// DefaultTimeout is how long Dial and each request wait for the server
+// unless changed with WithTimeout
+const DefaultTimeout = 10 * time.Second
+
+// ErrClosed is returned by requests made on, or interrupted by, Close
+var ErrClosed = errors.New("client closed")
+
+// Error is an ERROR message received from the server
+type Error struct {
+	// Code is the numeric code, following HTTP status semantics
+	Code int
+	// Reason is the machine-readable reason, one of the protocol.Reason
+	// constants such as protocol.ReasonInvalidParams
+	Reason string
+	// Detail is the human-readable description
+	Detail string
+	// RetryAfter is how long the server asks the client to wait before
+	// retrying, or zero if it gave no hint
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("server error %d %s: %s", e.Code, e.Reason, e.Detail)
+}
+
+// errorFrom converts an ERROR message to an *Error
+func errorFrom(msg protocol.Message) *Error {
+	code, _ := strconv.Atoi(msg.Params["code"])
+	retryMs, _ := strconv.ParseInt(msg.Params["retry_after_ms"], 10, 64)
+	return &Error{
+		Code:       code,
+		Reason:     msg.Params["reason"],
+		Detail:     msg.Params["detail"],
+		RetryAfter: time.Duration(retryMs) * time.Millisecond,
+	}
+}
+
+// Option configures a Client
+type Option func(*options)
+
+type options struct {
+	timeout      time.Duration
+	tlsConfig    *tls.Config
+	updateBuffer int
+	clientID     string
+	resume       bool
+	onExpiry     func(Expiry)
+	contextFile  string
+	authToken    string
+	helloParams  map[string]string
+	lowLatency   *bool
+	onWarning    func(tag, detail string)
+}
+
+// WithTimeout sets how long Dial and each request wait for the server when
+// the request's context has no earlier deadline
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.timeout = d
+	}
+}
+
+// WithTLS connects over TLS configured by cfg
+func WithTLS(cfg *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = cfg
+	}
+}
+
+// WithClientID claims a stable client ID in the handshake, so the context
+// stored by this client is found again when it reconnects with the same
+// ID. The handshake fails if another connection holds the ID.
+func WithClientID(id string) Option {
+	return func(o *options) {
+		o.clientID = id
+	}
+}
+
+// WithResume makes the handshake take the client ID claimed with
+// WithClientID over from a connection still holding it, such as one that
+// dropped without the server noticing yet. The server moves that
+// connection's subscriptions to this one and closes it. Subscribe again to
+// receive their updates; the server keeps the moved registrations.
+func WithResume() Option {
+	return func(o *options) {
+		o.resume = true
+	}
+}
+
+// WithHelloParams adds params to the handshake, such as a token for a
+// server that authenticates clients in HELLO. Parameters set by other
+// options take precedence.
+func WithHelloParams(params map[string]string) Option {
+	return func(o *options) {
+		o.helloParams = params
+	}
+}
+
+// WithLowLatency asks the server to turn Nagle's algorithm off (true) or on
+// (false) for its side of the connection, in place of its default
+func WithLowLatency(on bool) Option {
+	return func(o *options) {
+		o.lowLatency = &on
+	}
+}
+
+// WithWarningHandler calls fn for each WARNING the server sends about a
+// deprecated construct this client used, with the deprecation's tag and a
+// description. fn runs on the connection's read loop, so it must not block
+// or make requests.
+func WithWarningHandler(fn func(tag, detail string)) Option {
+	return func(o *options) {
+		o.onWarning = fn
+	}
+}
+
+// WithUpdateBuffer sets how many updates each subscription channel holds
+// before further updates are dropped
+func WithUpdateBuffer(n int) Option {
+	return func(o *options) {
+		o.updateBuffer = n
+	}
+}
+
+// Client is a connection to an MCP server. Its methods are safe for
+// concurrent use. The server answers requests in the order it receives
+// them, so replies are matched to requests by order; pushes such as
+// UPDATE, EXPIRED, WARNING, watched CONTEXT changes and heartbeat PINGs
+// are handled as they arrive.
+type Client struct {
+	conn       net.Conn
+	opts       options
+	session    string
+	clientID   string
+	principal  string // client name the server authenticated, if it requires AUTH
+	maxMessage int    // message size limit negotiated in HELLO
+
+	// writeMu serializes writes, and with them the order in which calls
+	// join pending
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending []*call // requests awaiting a reply, in the order sent
+	subs    []*subscription
+	watch   chan Update   // changes pushed since WatchAll, nil before it
+	err     error         // why the connection ended, once it has
+	done    chan struct{} // closed when the read loop exits
+}
+
+// call is a request awaiting its reply
+type call struct {
+	expect string
+	// resync makes the call skip replies of other types, to realign after
+	// a request that may have drawn more than one reply
+	resync bool
+	// reply is buffered so the read loop never blocks on a caller that
+	// has given up waiting
+	reply chan result
+}
+
+type result struct {
+	msg protocol.Message
+	err error
+}
+
+// Dial connects to the server at addr and negotiates the current protocol
+// version
+func Dial(addr string, opts ...Option) (*Client, error) {
+	o := options{
+		timeout:      DefaultTimeout,
+		updateBuffer: config.SubscriptionBufferSize,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	dialer := &net.Dialer{Timeout: o.timeout}
+	var conn net.Conn
+	var err error
+	if o.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, o.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %v", addr, err)
+	}
+
+	c := &Client{
+		conn:       conn,
+		opts:       o,
+		maxMessage: config.MaxMessageSize,
+		done:       make(chan struct{}),
+	}
+	go c.readLoop()
+
+	if o.authToken != "" {
+		if err := c.authenticate(o.authToken); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+
+	hello := protocol.NewMessage(protocol.TypeHello, nil)
+	for key, value := range o.helloParams {
+		hello.Params[key] = value
+	}
+	hello.Params["version"] = config.ProtocolVersion
+	if o.clientID != "" {
+		hello.Params["client_id"] = o.clientID
+		if o.resume {
+			hello.Params["resume"] = "true"
+		}
+	}
+	if o.lowLatency != nil {
+		hello.Params["low_latency"] = strconv.FormatBool(*o.lowLatency)
+	}
+	reply, err := c.request(context.Background(), hello, protocol.TypeHello)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("handshake failed: %w", err)
+	}
+	c.session = reply.Params["session"]
+	c.clientID = reply.Params["client_id"]
+	if size, err := strconv.Atoi(reply.Params["max_message_size"]); err == nil {
+		c.maxMessage = size
+	}
+
+	if o.contextFile != "" {
+		if err := c.ImportContext(context.Background(), o.contextFile); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("failed to import initial context: %w", err)
+		}
+	}
+
+	return c, nil
+}
+
+// Session returns the session ID assigned by the server
+func (c *Client) Session() string {
+	return c.session
+}
+
+// ClientID returns the ID the server stores this client's context under:
+// the ID claimed with WithClientID, or otherwise the session ID
+func (c *Client) ClientID() string {
+	return c.clientID
+}
+
+// Ping checks that the server is responsive
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.request(ctx, protocol.NewMessage(protocol.TypePing, nil), protocol.TypePong)
+	return err
+}
+
+// SetContext stores context values for this client. Values that fit in one
+// message together are written atomically. Otherwise each is written on
+// its own, streaming those too large for a single message in chunks, and a
+// failure part way leaves the earlier values written.
+func (c *Client) SetContext(ctx context.Context, values map[string]string) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	msg := protocol.NewMessage(protocol.TypeContext, values)
+	if len(msg.Format()) <= c.maxMessage {
+		_, err := c.request(ctx, msg, protocol.TypeAck)
+		return err
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		single := protocol.NewMessage(protocol.TypeContext, map[string]string{key: values[key]})
+		var err error
+		if len(single.Format()) <= c.maxMessage {
+			_, err = c.request(ctx, single, protocol.TypeAck)
+		} else {
+			err = c.setChunked(ctx, key, values[key])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetContext fetches this client's values for keys, or every value if no
+// keys are given. Keys that are not set are left out of the result. Values
+// the server streams in chunks are reassembled and verified.
+func (c *Client) GetContext(ctx context.Context, keys ...string) (map[string]string, error) {
+	params := make(map[string]string, len(keys))
+	for i, key := range keys {
+		params["key"+strconv.Itoa(i)] = key
+	}
+
+	reply, err := c.request(ctx, protocol.NewMessage(protocol.TypeGet, params), protocol.TypeResult)
+	if err != nil {
+		return nil, err
+	}
+	return reply.Params, nil
+}
+
+// RemoveContext deletes this client's values for keys in one step and
+// returns how many of them were set
+func (c *Client) RemoveContext(ctx context.Context, keys ...string) (int, error) {
+	params := make(map[string]string, len(keys))
+	for i, key := range keys {
+		params["key"+strconv.Itoa(i)] = key
+	}
+
+	reply, err := c.request(ctx, protocol.NewMessage(protocol.TypeRemove, params), protocol.TypeAck)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(reply.Params["removed"])
+}
+
+// ListKeys returns this client's keys matching a glob pattern such as
+// model.*, sorted, or all its keys if pattern is empty. The server leaves
+// keys out if the list would not fit in one message; truncated reports it.
+func (c *Client) ListKeys(ctx context.Context, pattern string) (keys []string, truncated bool, err error) {
+	params := map[string]string{}
+	if pattern != "" {
+		params["pattern"] = pattern
+	}
+
+	reply, err := c.request(ctx, protocol.NewMessage(protocol.TypeList, params), protocol.TypeResult)
+	if err != nil {
+		return nil, false, err
+	}
+	keys, err = protocol.SplitList(reply.Params["keys"])
+	if err != nil {
+		return nil, false, fmt.Errorf("malformed key list: %v", err)
+	}
+	return keys, reply.Params["truncated"] == "true", nil
+}
+
+// request sends msg and waits for its reply, which must be of type expect.
+// ERROR replies are returned as *Error.
+func (c *Client) request(ctx context.Context, msg protocol.Message, expect string) (protocol.Message, error) {
+	c.writeMu.Lock()
+	call, err := c.startLocked(msg, expect, false)
+	c.writeMu.Unlock()
+	if err != nil {
+		return protocol.Message{}, err
+	}
+
+	return c.wait(ctx, call)
+}
+
+// startLocked queues a call and sends its message. Callers must hold
+// c.writeMu, so calls are queued in the order their messages are sent.
+func (c *Client) startLocked(msg protocol.Message, expect string, resync bool) (*call, error) {
+	call := &call{
+		expect: expect,
+		resync: resync,
+		reply:  make(chan result, 1),
+	}
+
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		return nil, err
+	}
+	c.pending = append(c.pending, call)
+	c.mu.Unlock()
+
+	if err := c.writeLocked(msg); err != nil {
+		return nil, err
+	}
+	return call, nil
+}
+
+// wait returns the reply to call, giving up when ctx is done or the
+// timeout passes. A reply arriving after that is discarded.
+func (c *Client) wait(ctx context.Context, call *call) (protocol.Message, error) {
+	timer := time.NewTimer(c.opts.timeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-call.reply:
+		return r.msg, r.err
+	case <-ctx.Done():
+		return protocol.Message{}, ctx.Err()
+	case <-timer.C:
+		return protocol.Message{}, fmt.Errorf("no %s reply within %v", call.expect, c.opts.timeout)
+	}
+}
+
+// send writes a message that expects no reply
+func (c *Client) send(msg protocol.Message) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.writeLocked(msg)
+}
+
+// writeLocked writes a message. A failed write may have left part of the
+// message on the wire, so it closes the connection. Callers must hold
+// c.writeMu.
+func (c *Client) writeLocked(msg protocol.Message) error {
+	c.conn.SetWriteDeadline(time.Now().Add(c.opts.timeout))
+	if _, err := c.conn.Write([]byte(msg.Format() + "\n")); err != nil {
+		c.conn.Close()
+		return fmt.Errorf("failed to send %s: %v", msg.Type, err)
+	}
+	return nil
+}
+
+// readLoop reads messages until the connection ends, handling pushes and
+// passing replies to the calls awaiting them
+func (c *Client) readLoop() {
+	defer close(c.done)
+
+	reader := bufio.NewReader(c.conn)
+	streamed := newStreams()
+	for {
+		line, err := reader.ReadString(config.MessageDelimiter)
+		if err != nil {
+			c.fail(fmt.Errorf("connection lost: %v", err))
+			return
+		}
+
+		msg, err := protocol.Parse(line)
+		if err != nil {
+			c.fail(fmt.Errorf("malformed message from server: %v", err))
+			c.conn.Close()
+			return
+		}
+
+		switch msg.Type {
+		case protocol.TypePing:
+			// A failed PONG closes the connection, ending the loop
+			c.send(protocol.NewMessage(protocol.TypePong, nil))
+		case protocol.TypeUpdate:
+			c.deliver(updateFrom(msg))
+		case protocol.TypeContext:
+			c.deliverWatched(msg)
+		case protocol.TypeExpired:
+			if c.opts.onExpiry != nil {
+				c.opts.onExpiry(expiryFrom(msg))
+			}
+		case protocol.TypeWarning:
+			if c.opts.onWarning != nil {
+				c.opts.onWarning(msg.Params["tag"], msg.Params["detail"])
+			}
+		case protocol.TypeGoodbye, protocol.TypeGoAway:
+			// The server stops serving this connection; pending calls
+			// fail once it closes
+		case protocol.TypeValueBegin, protocol.TypeValueChunk, protocol.TypeValueEnd:
+			streamed.add(msg)
+		default:
+			msg, err := streamed.attach(msg)
+			c.complete(msg, err)
+		}
+	}
+}
+
+// complete passes a reply to the oldest pending call. Replies with no call
+// awaiting them, such as an ERROR sent before the server closes the
+// connection, are dropped.
+func (c *Client) complete(msg protocol.Message, err error) {
+	c.mu.Lock()
+	if len(c.pending) == 0 {
+		c.mu.Unlock()
+		return
+	}
+	call := c.pending[0]
+	if call.resync && msg.Type != call.expect {
+		c.mu.Unlock()
+		return
+	}
+	c.pending = c.pending[1:]
+	c.mu.Unlock()
+
+	switch {
+	case err != nil:
+	case msg.Type == protocol.TypeError:
+		err = errorFrom(msg)
+	case msg.Type != call.expect:
+		err = fmt.Errorf("expected %s reply, got %s", call.expect, msg.Type)
+	}
+	call.reply <- result{msg: msg, err: err}
+}
+
+// fail ends the connection, failing pending calls with err, or ErrClosed
+// if Close was called, and closing subscription channels
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = err
+	}
+	pending := c.pending
+	subs := c.subs
+	watch := c.watch
+	c.pending = nil
+	c.subs = nil
+	c.watch = nil
+	err = c.err
+	c.mu.Unlock()
+
+	for _, call := range pending {
+		call.reply <- result{err: err}
+	}
+	for _, sub := range subs {
+		close(sub.ch)
+	}
+	if watch != nil {
+		close(watch)
+	}
+}
+
+// Close closes the connection. Pending requests fail with ErrClosed and
+// subscription channels are closed.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	if c.err == nil {
+		c.err = ErrClosed
+	}
+	c.mu.Unlock()
+
+	err := c.conn.Close()
+	<-c.done
+	return err
+}
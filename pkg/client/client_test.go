@@ -0,0 +1,224 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+	"github.com/Artimus100/mcp-server-go/internal/handler"
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+	"github.com/Artimus100/mcp-server-go/internal/state"
+	"github.com/Artimus100/mcp-server-go/internal/utils"
+)
+
+// testTimeout bounds every request in the tests
+const testTimeout = 2 * time.Second
+
+// startServer starts an in-process server on an ephemeral port with the
+// default configuration as changed by configure, if given, and returns the
+// address to dial. The server shuts down when the test ends.
+func startServer(t *testing.T, configure func(*config.Config)) (*handler.Server, string) {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.Port = 0
+	if configure != nil {
+		configure(&cfg)
+	}
+	srv := handler.NewServer(cfg, state.NewContextStore(), utils.NewLoggerTo(io.Discard, "test"))
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+		defer cancel()
+		srv.Shutdown(ctx)
+	})
+	return srv, net.JoinHostPort("127.0.0.1", strconv.Itoa(srv.Addr().(*net.TCPAddr).Port))
+}
+
+// dial connects a client to addr, closing it when the test ends
+func dial(t *testing.T, addr string, opts ...Option) *Client {
+	t.Helper()
+
+	c, err := Dial(addr, append([]Option{WithTimeout(testTimeout)}, opts...)...)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestPingLiveServer(t *testing.T) {
+	_, addr := startServer(t, nil)
+	c := dial(t, addr)
+
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if c.Session() == "" || c.ClientID() != c.Session() {
+		t.Fatalf("Session() = %q, ClientID() = %q; want the session to name the client", c.Session(), c.ClientID())
+	}
+}
+
+func TestContextRoundTrip(t *testing.T) {
+	_, addr := startServer(t, nil)
+	c := dial(t, addr, WithClientID("tool"))
+	ctx := context.Background()
+
+	values := map[string]string{"model.name": "x;y=z", "model.size": "7b", "region": "eu"}
+	if err := c.SetContext(ctx, values); err != nil {
+		t.Fatalf("SetContext: %v", err)
+	}
+	got, err := c.GetContext(ctx, "model.name", "region", "missing")
+	if err != nil {
+		t.Fatalf("GetContext: %v", err)
+	}
+	if want := map[string]string{"model.name": "x;y=z", "region": "eu"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetContext = %v, want %v", got, want)
+	}
+
+	keys, truncated, err := c.ListKeys(ctx, "model.*")
+	if err != nil || truncated || !reflect.DeepEqual(keys, []string{"model.name", "model.size"}) {
+		t.Fatalf("ListKeys = %q, %v, %v", keys, truncated, err)
+	}
+
+	removed, err := c.RemoveContext(ctx, "region", "missing")
+	if err != nil || removed != 1 {
+		t.Fatalf("RemoveContext = %d, %v; want 1 removed", removed, err)
+	}
+	if got, _ := c.GetContext(ctx); len(got) != 2 {
+		t.Fatalf("GetContext after remove = %v", got)
+	}
+}
+
+func TestServerErrorsAreTyped(t *testing.T) {
+	_, addr := startServer(t, nil)
+	c := dial(t, addr)
+
+	err := c.SetContext(context.Background(), map[string]string{"_reserved": "x"})
+	var serverErr *Error
+	if !errors.As(err, &serverErr) {
+		t.Fatalf("SetContext error %v is not an *Error", err)
+	}
+	if serverErr.Code != http.StatusBadRequest || serverErr.Reason != protocol.ReasonInvalidParams || serverErr.Detail == "" {
+		t.Fatalf("got %+v, want a 400 invalid_params with a detail", serverErr)
+	}
+
+	// The connection is still usable after an error
+	if err := c.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping after an error: %v", err)
+	}
+}
+
+func TestCloseFailsRequests(t *testing.T) {
+	_, addr := startServer(t, nil)
+	c := dial(t, addr)
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := c.Ping(context.Background()); !errors.Is(err, ErrClosed) {
+		t.Fatalf("Ping after Close: %v, want ErrClosed", err)
+	}
+}
+
+func TestDialFailsWithoutServer(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	if c, err := Dial(addr, WithTimeout(testTimeout)); err == nil {
+		c.Close()
+		t.Fatal("Dial succeeded with nothing listening")
+	}
+}
+
+func TestSubscribeReceivesUpdates(t *testing.T) {
+	_, addr := startServer(t, nil)
+	watcher := dial(t, addr)
+	writer := dial(t, addr, WithClientID("writer"))
+	ctx := context.Background()
+
+	sub := Subscription{Key: "status", ClientID: "writer"}
+	updates, err := watcher.Subscribe(ctx, sub)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	if err := writer.SetContext(ctx, map[string]string{"status": "up", "other": "x"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case u := <-updates:
+		if u.ClientID != "writer" || u.Key != "status" || u.Value != "up" || u.Deleted {
+			t.Fatalf("got %+v, want status set to up by writer", u)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("no update for the subscribed key")
+	}
+
+	if err := watcher.Unsubscribe(ctx, sub); err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+	select {
+	case u, open := <-updates:
+		if open {
+			t.Fatalf("got %+v after unsubscribing, want the channel closed", u)
+		}
+	case <-time.After(testTimeout):
+		t.Fatal("channel not closed by Unsubscribe")
+	}
+}
+
+func TestRequestsTimeOut(t *testing.T) {
+	// A server that completes the handshake and then never replies
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		reader.ReadString('\n')
+		io.WriteString(conn, "HELLO:session=silent\n")
+		io.Copy(io.Discard, reader)
+	}()
+
+	c, err := Dial(listener.Addr().String(), WithTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer c.Close()
+
+	start := time.Now()
+	if err := c.Ping(context.Background()); err == nil {
+		t.Fatal("Ping succeeded without a reply")
+	}
+	if elapsed := time.Since(start); elapsed > testTimeout {
+		t.Fatalf("Ping gave up after %v, want about 50ms", elapsed)
+	}
+
+	// An earlier context deadline wins over the client timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := c.Ping(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Ping with an expired context: %v, want DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// SubscribeDurable subscribes as Subscribe does, but the server keeps the
+// subscription for the client ID claimed with WithClientID after the
+// connection closes. Subscribing durably again after reconnecting with the
+// same client ID resumes it: the changes missed in between arrive first,
+// marked Replayed. gap reports that the server no longer held all of them.
+// Durable subscriptions are ended with Unsubscribe.
+func (c *Client) SubscribeDurable(ctx context.Context, sub Subscription) (updates <-chan Update, gap bool, err error) {
+	params := sub.params()
+	params["durable"] = "true"
+
+	ch, ack, err := c.subscribe(ctx, sub, params)
+	if err != nil {
+		return nil, false, err
+	}
+	return ch, ack.Params["gap"] == "true", nil
+}
+
+// DurableSubscriptions returns the durable subscriptions the server holds
+// for this client's ID, including those not yet resumed on this connection
+func (c *Client) DurableSubscriptions(ctx context.Context) ([]Subscription, error) {
+	reply, err := c.request(ctx, protocol.NewMessage(protocol.TypeSubscriptions, nil), protocol.TypeResult)
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := strconv.Atoi(reply.Params["count"])
+	if err != nil {
+		return nil, fmt.Errorf("malformed subscription count %q", reply.Params["count"])
+	}
+	subs := make([]Subscription, 0, count)
+	for i := 0; i < count; i++ {
+		n := strconv.Itoa(i)
+		subs = append(subs, Subscription{
+			Key:      reply.Params[n+".key"],
+			Prefix:   reply.Params[n+".prefix"] == "true",
+			ClientID: reply.Params[n+".client"],
+		})
+	}
+	return subs, nil
+}
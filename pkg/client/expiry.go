@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// Expiry is an EXPIRED notice for a value set with notifyExpiry
+type Expiry struct {
+	Key       string
+	Value     string // the value that expired
+	SetAt     time.Time
+	ExpiredAt time.Time
+}
+
+// expiryFrom converts an EXPIRED message to an Expiry
+func expiryFrom(msg protocol.Message) Expiry {
+	setAt, _ := time.Parse(time.RFC3339Nano, msg.Params["set_at"])
+	expiredAt, _ := time.Parse(time.RFC3339Nano, msg.Params["expired_at"])
+	return Expiry{
+		Key:       msg.Params["key"],
+		Value:     msg.Params["value"],
+		SetAt:     setAt,
+		ExpiredAt: expiredAt,
+	}
+}
+
+// WithExpiryHandler calls fn for each EXPIRED notice, including those the
+// server kept while this client ID had no connection. fn runs on the
+// connection's read loop, so it must not block or make requests.
+func WithExpiryHandler(fn func(Expiry)) Option {
+	return func(o *options) {
+		o.onExpiry = fn
+	}
+}
+
+// SetWithTTL stores a value that the server removes once ttl has passed.
+// With notifyExpiry set, the server sends an EXPIRED notice to the handler
+// given with WithExpiryHandler when it does.
+func (c *Client) SetWithTTL(ctx context.Context, key, value string, ttl time.Duration, notifyExpiry bool) error {
+	msg := protocol.NewMessage(protocol.TypeContext, map[string]string{
+		key:    value,
+		"_ttl": ttl.String(),
+	})
+	if notifyExpiry {
+		msg.Params["_notify_expiry"] = strconv.FormatBool(true)
+	}
+	_, err := c.request(ctx, msg, protocol.TypeAck)
+	return err
+}
@@ -0,0 +1,27 @@
+package client
+
+import (
+	"context"
+
+	"github.com/Artimus100/mcp-server-go/internal/config"
+)
+
+// WithContextFile makes Dial store the values in a JSON or .env-style file
+// as this client's context once connected, as ImportContext does. Dial
+// fails if the file cannot be read or the values are rejected.
+func WithContextFile(path string) Option {
+	return func(o *options) {
+		o.contextFile = path
+	}
+}
+
+// ImportContext stores the values in a JSON or .env-style file as this
+// client's context, in one batch where they fit in a message. See
+// SetContext for how larger sets of values are written.
+func (c *Client) ImportContext(ctx context.Context, path string) error {
+	values, err := config.LoadValues(path)
+	if err != nil {
+		return err
+	}
+	return c.SetContext(ctx, values)
+}
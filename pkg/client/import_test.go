@@ -0,0 +1,50 @@
+package client
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFile writes contents to a file named name in a temporary directory
+// and returns its path
+func writeFile(t *testing.T, name, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestWithContextFileSetsInitialContext(t *testing.T) {
+	_, addr := startServer(t, nil)
+	path := writeFile(t, "agent.env", "# bootstrap\nREGION=eu\nexport NOTE=\"a;b=c\"\n")
+
+	c := dial(t, addr, WithContextFile(path))
+	got, err := c.GetContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"REGION": "eu", "NOTE": "a;b=c"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetContext = %v, want %v", got, want)
+	}
+}
+
+func TestWithContextFileFailsDial(t *testing.T) {
+	_, addr := startServer(t, nil)
+	path := writeFile(t, "agent.env", "REGION=eu\nbroken line\n")
+
+	c, err := Dial(addr, WithTimeout(testTimeout), WithContextFile(path))
+	if err == nil {
+		c.Close()
+		t.Fatal("Dial succeeded with a malformed context file")
+	}
+	if !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("error %q does not point at the bad line", err)
+	}
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// Subscription selects the context changes delivered by Subscribe
+type Subscription struct {
+	// Key is the exact key to watch, or a key prefix if Prefix is set
+	Key    string
+	Prefix bool
+	// ClientID restricts the subscription to changes made by one client;
+	// empty matches changes made by any client
+	ClientID string
+}
+
+// params returns the SUBSCRIBE and UNSUBSCRIBE parameters for sub
+func (sub Subscription) params() map[string]string {
+	params := make(map[string]string)
+	if sub.Prefix {
+		params["prefix"] = sub.Key
+	} else {
+		params["key"] = sub.Key
+	}
+	if sub.ClientID != "" {
+		params["client"] = sub.ClientID
+	}
+	return params
+}
+
+// matches reports whether an update is selected by the subscription
+func (sub Subscription) matches(u Update) bool {
+	if sub.ClientID != "" && sub.ClientID != u.ClientID {
+		return false
+	}
+	if sub.Prefix {
+		return strings.HasPrefix(u.Key, sub.Key)
+	}
+	return sub.Key == u.Key
+}
+
+// Update is a context change pushed by the server
+type Update struct {
+	ClientID string
+	Key      string
+	OldValue string // empty if the key was not previously set
+	Value    string
+	Deleted  bool // the key was removed rather than set
+
+	// Scheduled marks changes made by a schedule firing; Overdue marks
+	// schedules that fell due while the server was down
+	Scheduled bool
+	Overdue   bool
+
+	// Version is the server's version of the change; Backfill marks
+	// current values and removals sent when subscribing with
+	// since_version, and Replayed changes missed by a durable
+	// subscription while away
+	Version  uint64
+	Backfill bool
+	Replayed bool
+
+	// Dropped is the number of changes dropped before this one because
+	// the watcher was not keeping up; see WatchAll
+	Dropped uint64
+}
+
+// updateFrom converts an UPDATE message to an Update
+func updateFrom(msg protocol.Message) Update {
+	version, _ := strconv.ParseUint(msg.Params["version"], 10, 64)
+	return Update{
+		ClientID:  msg.Params["client"],
+		Key:       msg.Params["key"],
+		OldValue:  msg.Params["old"],
+		Value:     msg.Params["new"],
+		Deleted:   msg.Params["deleted"] == "true",
+		Scheduled: msg.Params["scheduled"] == "true",
+		Overdue:   msg.Params["overdue"] == "true",
+		Version:   version,
+		Backfill:  msg.Params["backfill"] == "true",
+		Replayed:  msg.Params["replayed"] == "true",
+	}
+}
+
+// subscription is a registered Subscription and the channel it feeds
+type subscription struct {
+	Subscription
+	ch chan Update
+}
+
+// Subscribe asks the server for changes selected by sub and returns a
+// channel receiving them. As on the server, delivery is at-most-once:
+// updates arriving while the channel is full are dropped. The channel is
+// closed by Unsubscribe or when the connection ends.
+func (c *Client) Subscribe(ctx context.Context, sub Subscription) (<-chan Update, error) {
+	ch, _, err := c.subscribe(ctx, sub, sub.params())
+	return ch, err
+}
+
+// subscribe sends SUBSCRIBE with params for sub and returns the channel
+// receiving its updates along with the ACK
+func (c *Client) subscribe(ctx context.Context, sub Subscription, params map[string]string) (<-chan Update, protocol.Message, error) {
+	// Register first, as the server may push an update before its ACK
+	s := &subscription{Subscription: sub, ch: make(chan Update, c.opts.updateBuffer)}
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		return nil, protocol.Message{}, err
+	}
+	c.subs = append(c.subs, s)
+	c.mu.Unlock()
+
+	ack, err := c.request(ctx, protocol.NewMessage(protocol.TypeSubscribe, params), protocol.TypeAck)
+	if err != nil {
+		c.removeSubs(func(other *subscription) bool { return other == s })
+		return nil, protocol.Message{}, err
+	}
+	return s.ch, ack, nil
+}
+
+// Unsubscribe cancels sub on the server and closes every channel Subscribe
+// returned for it
+func (c *Client) Unsubscribe(ctx context.Context, sub Subscription) error {
+	if _, err := c.request(ctx, protocol.NewMessage(protocol.TypeUnsubscribe, sub.params()), protocol.TypeAck); err != nil {
+		return err
+	}
+
+	c.removeSubs(func(other *subscription) bool { return other.Subscription == sub })
+	return nil
+}
+
+// removeSubs unregisters and closes the subscriptions selected by remove
+func (c *Client) removeSubs(remove func(*subscription) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	kept := c.subs[:0]
+	for _, s := range c.subs {
+		if remove(s) {
+			close(s.ch)
+		} else {
+			kept = append(kept, s)
+		}
+	}
+	c.subs = kept
+}
+
+// deliver hands an update to every matching subscription without blocking
+func (c *Client) deliver(u Update) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, s := range c.subs {
+		if !s.matches(u) {
+			continue
+		}
+		select {
+		case s.ch <- u:
+		default:
+			// Subscriber is not keeping up; drop the update
+		}
+	}
+}
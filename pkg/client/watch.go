@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/Artimus100/mcp-server-go/internal/protocol"
+)
+
+// WatchAll asks the server for every change to any client's context and
+// returns a channel receiving them, for auditing. Updates carry no OldValue.
+// Delivery is at-most-once as with Subscribe; Dropped on an update reports
+// how many changes the server dropped before it. Calling WatchAll again
+// returns the same channel, which is closed when the connection ends.
+func (c *Client) WatchAll(ctx context.Context) (<-chan Update, error) {
+	// Register first, as the server may push a change before its ACK
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		return nil, err
+	}
+	if c.watch == nil {
+		c.watch = make(chan Update, c.opts.updateBuffer)
+	}
+	ch := c.watch
+	c.mu.Unlock()
+
+	if _, err := c.request(ctx, protocol.NewMessage(protocol.TypeWatchAll, nil), protocol.TypeAck); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// deliverWatched passes a change pushed to a watcher to the WatchAll channel
+func (c *Client) deliverWatched(msg protocol.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.watch == nil {
+		return
+	}
+	select {
+	case c.watch <- watchedFrom(msg):
+	default:
+		// Watcher is not keeping up; drop the update
+	}
+}
+
+// watchedFrom converts a CONTEXT push from WATCH_ALL to an Update
+func watchedFrom(msg protocol.Message) Update {
+	version, _ := strconv.ParseUint(msg.Params["_version"], 10, 64)
+	dropped, _ := strconv.ParseUint(msg.Params["_dropped"], 10, 64)
+	u := Update{
+		ClientID: msg.Params["_client"],
+		Deleted:  msg.Params["_deleted"] == "true",
+		Version:  version,
+		Dropped:  dropped,
+	}
+	for key, value := range msg.Params {
+		if !strings.HasPrefix(key, "_") {
+			u.Key, u.Value = key, value
+		}
+	}
+	return u
+}